@@ -6,5 +6,137 @@ CREATE TABLE IF NOT EXISTS requests (
     path TEXT NOT NULL,
     headers TEXT NOT NULL,
     body TEXT NOT NULL,
-    params TEXT NOT NULL
+    params TEXT NOT NULL,
+    proto TEXT NOT NULL,
+    username TEXT NOT NULL,
+    parent_id INT REFERENCES requests (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS requests_host_idx ON requests (LOWER(host));
+CREATE INDEX IF NOT EXISTS requests_method_idx ON requests (method);
+CREATE INDEX IF NOT EXISTS requests_created_at_idx ON requests (created_at);
+CREATE INDEX IF NOT EXISTS requests_path_trgm_idx ON requests USING GIN (LOWER(path) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS responses (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status_code INT NOT NULL,
+    headers TEXT NOT NULL,
+    body BYTEA NOT NULL,
+    content_length BIGINT NOT NULL,
+    duration BIGINT NOT NULL,
+    truncated BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS responses_request_id_idx ON responses (request_id);
+
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id SERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    target TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    replacement TEXT NOT NULL,
+    is_regex BOOLEAN NOT NULL,
+    host TEXT NOT NULL,
+    path TEXT NOT NULL,
+    method TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS applied_rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    rule_id INT NOT NULL REFERENCES rules (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS applied_rules_request_id_idx ON applied_rules (request_id);
+
+CREATE TABLE IF NOT EXISTS scan_jobs (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    progress INT NOT NULL,
+    total INT NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scan_jobs_status_idx ON scan_jobs (status);
+
+CREATE TABLE IF NOT EXISTS findings (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    check_name TEXT NOT NULL,
+    parameter TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    evidence TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    confidence TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (endpoint, check_name, parameter)
+);
+
+CREATE TABLE IF NOT EXISTS job_findings (
+    job_id INT NOT NULL REFERENCES scan_jobs (id) ON DELETE CASCADE,
+    finding_id INT NOT NULL REFERENCES findings (id) ON DELETE CASCADE,
+    PRIMARY KEY (job_id, finding_id)
+);
+
+CREATE TABLE IF NOT EXISTS websocket_messages (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    parent_id INT REFERENCES websocket_messages (id) ON DELETE SET NULL,
+    direction TEXT NOT NULL,
+    opcode INT NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS websocket_messages_request_id_idx ON websocket_messages (request_id);
+
+CREATE TABLE IF NOT EXISTS proxy_users (
+    id SERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS proxy_users_token_hash_idx ON proxy_users (token_hash) WHERE token_hash <> '';
+
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    action TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port INT NOT NULL,
+    scheme TEXT NOT NULL,
+    path TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS tunnels (
+    id SERIAL NOT NULL PRIMARY KEY,
+    host TEXT NOT NULL,
+    port INT NOT NULL,
+    sni TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    username TEXT NOT NULL,
+    bytes_sent BIGINT NOT NULL,
+    bytes_received BIGINT NOT NULL,
+    duration BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
 );
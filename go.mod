@@ -5,4 +5,6 @@ go 1.15
 require (
 	github.com/gorilla/mux v1.8.0
 	github.com/lib/pq v1.10.0
+	github.com/mattn/go-sqlite3 v1.14.16
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	harInterfaces "github.com/aanufriev/httpproxy/internal/pkg/har/interfaces"
+	HarUsecase "github.com/aanufriev/httpproxy/internal/pkg/har/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	ProxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
+	"github.com/aanufriev/httpproxy/pkg/cert"
+)
+
+const commandUsage = `commands:
+  har export [-ids 1,2,3] [-q query] [-saved name] [-o file]
+                                      write captured requests as HAR 1.2
+  har import file...                  load HAR files into storage
+  ca generate                         create the CA at cert.ca_cert and cert.ca_key
+  ca rotate                           replace the CA, previous files are kept as .old
+  ca export [-format pem|der|p12] [-password secret] [-o file]
+                                      write the CA certificate, p12 also holds the key`
+
+func RunCommand(cfg config.Config) error {
+	switch cfg.Args[0] {
+	case "har":
+		return runHAR(cfg)
+	case "ca":
+		return runCA(cfg)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cfg.Args[0], commandUsage)
+	}
+}
+
+func runHAR(cfg config.Config) error {
+	args := cfg.Args
+	if len(args) < 2 || (args[1] != "export" && args[1] != "import") {
+		return fmt.Errorf("har needs export or import\n%s", commandUsage)
+	}
+
+	repos, err := newRepositories(cfg.Storage)
+	if err != nil {
+		return err
+	}
+	harUsecase := HarUsecase.NewHarUsecase(ProxyUsecase.NewProxyUsecase(repos.proxy))
+
+	if args[1] == "export" {
+		return exportHAR(harUsecase, args[2:])
+	}
+
+	return importHAR(harUsecase, args[2:])
+}
+
+func exportHAR(harUsecase harInterfaces.Usecase, args []string) error {
+	fs := flag.NewFlagSet("har export", flag.ContinueOnError)
+	idsFlag := fs.String("ids", "", "comma separated request ids, all requests if empty")
+	queryFlag := fs.String("q", "", "export requests matching the query, ignored with -ids")
+	saved := fs.String("saved", "", "export requests matching the saved query, ignored with -ids")
+	output := fs.String("o", "", "output file, stdout if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ids, err := HarUsecase.ParseIDs(*idsFlag)
+	if err != nil {
+		return err
+	}
+
+	filter := models.RequestFilter{
+		Query: *queryFlag,
+		Saved: *saved,
+	}
+	if filter.Query != "" {
+		filter.Expr, err = query.Parse(filter.Query)
+		if err != nil {
+			return err
+		}
+	}
+
+	har, err := harUsecase.Export(ids, filter)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(har)
+}
+
+func importHAR(harUsecase harInterfaces.Usecase, paths []string) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("har import needs at least one file\n%s", commandUsage)
+	}
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+
+		var har models.HAR
+		err = json.NewDecoder(file).Decode(&har)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("couldn't parse %s: %w", path, err)
+		}
+
+		ids, err := harUsecase.Import(har)
+		fmt.Printf("%s: imported %d requests %v\n", path, len(ids), ids)
+		if err != nil {
+			return fmt.Errorf("couldn't import %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+func runCA(cfg config.Config) error {
+	args := cfg.Args
+	if len(args) < 2 {
+		return fmt.Errorf("ca needs generate, rotate or export\n%s", commandUsage)
+	}
+
+	switch args[1] {
+	case "generate":
+		generated, err := cert.EnsureCA(cfg.Cert.CACert, cfg.Cert.CAKey, newCAOptions(cfg.Cert))
+		if err != nil {
+			return err
+		}
+		if !generated {
+			return fmt.Errorf("%s or %s already exists, use ca rotate to replace the CA", cfg.Cert.CACert, cfg.Cert.CAKey)
+		}
+
+		return printCA(cfg.Cert.CACert, cfg.Cert.CAKey)
+	case "rotate":
+		if _, err := cert.RotateCA(cfg.Cert.CACert, cfg.Cert.CAKey, newCAOptions(cfg.Cert)); err != nil {
+			return err
+		}
+
+		fmt.Println("restart the proxy and install the new CA on clients")
+		return printCA(cfg.Cert.CACert, cfg.Cert.CAKey)
+	case "export":
+		return exportCA(cfg.Cert, args[2:])
+	default:
+		return fmt.Errorf("unknown ca command %q\n%s", args[1], commandUsage)
+	}
+}
+
+func exportCA(cfg config.CertConfig, args []string) error {
+	fs := flag.NewFlagSet("ca export", flag.ContinueOnError)
+	format := fs.String("format", "pem", "pem, der or p12")
+	password := fs.String("password", "", "password protecting the p12 file")
+	output := fs.String("o", "", "output file, stdout if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ca, err := cert.LoadCA(cfg.CACert, cfg.CAKey)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch *format {
+	case "pem":
+		data = cert.EncodePEM(ca.Leaf)
+	case "der":
+		data = ca.Leaf.Raw
+	case "p12":
+		data, err = cert.EncodePKCS12(ca, ca.Leaf.Subject.CommonName, *password)
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q\n%s", *format, commandUsage)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+
+	perm := os.FileMode(0644)
+	if *format == "p12" {
+		perm = 0600
+	}
+
+	return ioutil.WriteFile(*output, data, perm)
+}
+
+func printCA(certFile, keyFile string) error {
+	ca, err := cert.LoadCA(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"%s: %s, valid until %s, SHA-256 %X\n",
+		certFile, ca.Leaf.Subject, ca.Leaf.NotAfter.Format("2006-01-02"), sha256.Sum256(ca.Leaf.Raw),
+	)
+
+	return nil
+}
+
+func newCAOptions(cfg config.CertConfig) cert.CAOptions {
+	return cert.CAOptions{
+		CommonName: cfg.CAName,
+		KeyType:    cfg.KeyType,
+		Validity:   cfg.CAValidity,
+	}
+}
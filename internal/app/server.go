@@ -4,42 +4,246 @@ import (
 	"bufio"
 	"crypto/tls"
 	"database/sql"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"time"
 
+	apiDelivery "github.com/aanufriev/httpproxy/internal/pkg/api/delivery"
+	authDelivery "github.com/aanufriev/httpproxy/internal/pkg/auth/delivery"
+	authInterfaces "github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	authRepository "github.com/aanufriev/httpproxy/internal/pkg/auth/repository"
+	AuthUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	HarUsecase "github.com/aanufriev/httpproxy/internal/pkg/har/usecase"
+	InterceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
 	proxyDelivery "github.com/aanufriev/httpproxy/internal/pkg/proxy/delivery"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
 	proxyRepository "github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
 	ProxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
 	repeaterDelivery "github.com/aanufriev/httpproxy/internal/pkg/repeater/delivery"
+	RepeaterUsecase "github.com/aanufriev/httpproxy/internal/pkg/repeater/usecase"
+	rulesInterfaces "github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+	rulesRepository "github.com/aanufriev/httpproxy/internal/pkg/rules/repository"
+	RulesUsecase "github.com/aanufriev/httpproxy/internal/pkg/rules/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/checks"
+	scannerInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	scannerRepository "github.com/aanufriev/httpproxy/internal/pkg/scanner/repository"
+	ScannerUsecase "github.com/aanufriev/httpproxy/internal/pkg/scanner/usecase"
+	scopeInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+	scopeRepository "github.com/aanufriev/httpproxy/internal/pkg/scope/repository"
+	ScopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+	tunnelInterfaces "github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+	tunnelRepository "github.com/aanufriev/httpproxy/internal/pkg/tunnel/repository"
+	TunnelUsecase "github.com/aanufriev/httpproxy/internal/pkg/tunnel/usecase"
+	websocketInterfaces "github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+	websocketRepository "github.com/aanufriev/httpproxy/internal/pkg/websocket/repository"
+	WebSocketUsecase "github.com/aanufriev/httpproxy/internal/pkg/websocket/usecase"
+	"github.com/aanufriev/httpproxy/pkg/cert"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
 	"github.com/gorilla/mux"
 
 	_ "github.com/lib/pq"
 )
 
-func RunProxyServer() {
-	port := ":8080"
+type repositories struct {
+	proxy     proxyInterfaces.Repository
+	rules     rulesInterfaces.Repository
+	scanner   scannerInterfaces.Repository
+	websocket websocketInterfaces.Repository
+	auth      authInterfaces.Repository
+	scope     scopeInterfaces.Repository
+	tunnel    tunnelInterfaces.Repository
+}
+
+func newRepositories(cfg config.StorageConfig) (repositories, error) {
+	switch cfg.Driver {
+	case config.StoragePostgres:
+		db, err := sql.Open("postgres", cfg.PostgresDSN)
+		if err != nil {
+			return repositories{}, fmt.Errorf("postgres not available: %w", err)
+		}
+
+		err = db.Ping()
+		if err != nil {
+			return repositories{}, fmt.Errorf("no connection with db: %w", err)
+		}
+
+		migrations := []func(*sql.DB) error{
+			proxyRepository.MigratePostgres,
+			rulesRepository.MigratePostgres,
+			scannerRepository.MigratePostgres,
+			websocketRepository.MigratePostgres,
+			authRepository.MigratePostgres,
+			scopeRepository.MigratePostgres,
+			tunnelRepository.MigratePostgres,
+		}
+		for _, migrate := range migrations {
+			err = migrate(db)
+			if err != nil {
+				return repositories{}, fmt.Errorf("couldn't migrate db: %w", err)
+			}
+		}
+
+		return repositories{
+			proxy:     proxyRepository.NewProxyRepository(db),
+			rules:     rulesRepository.NewRulesRepository(db),
+			scanner:   scannerRepository.NewScannerRepository(db),
+			websocket: websocketRepository.NewWebSocketRepository(db),
+			auth:      authRepository.NewAuthRepository(db),
+			scope:     scopeRepository.NewScopeRepository(db),
+			tunnel:    tunnelRepository.NewTunnelRepository(db),
+		}, nil
+	case config.StorageSqlite:
+		db, err := sql.Open(proxyRepository.SqliteDriver, cfg.SqlitePath+"?_foreign_keys=on")
+		if err != nil {
+			return repositories{}, fmt.Errorf("sqlite not available: %w", err)
+		}
+		db.SetMaxOpenConns(1)
+
+		var repos repositories
+		if repos.proxy, err = proxyRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.rules, err = rulesRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.scanner, err = scannerRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.websocket, err = websocketRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.auth, err = authRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.scope, err = scopeRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+		if repos.tunnel, err = tunnelRepository.NewSqliteRepository(db); err != nil {
+			return repositories{}, err
+		}
+
+		return repos, nil
+	case config.StorageMemory:
+		return repositories{
+			proxy:     proxyRepository.NewMemoryRepository(cfg.MemoryCapacity),
+			rules:     rulesRepository.NewMemoryRepository(cfg.MemoryCapacity),
+			scanner:   scannerRepository.NewMemoryRepository(),
+			websocket: websocketRepository.NewMemoryRepository(cfg.MemoryCapacity),
+			auth:      authRepository.NewMemoryRepository(),
+			scope:     scopeRepository.NewMemoryRepository(),
+			tunnel:    tunnelRepository.NewMemoryRepository(cfg.MemoryCapacity),
+		}, nil
+	default:
+		return repositories{}, fmt.Errorf("unknown storage: %s", cfg.Driver)
+	}
+}
+
+func loadPayloads(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	payloads := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		payloads = append(payloads, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return payloads, nil
+}
+
+func RunProxyServer(cfg config.Config) {
+	repos, err := newRepositories(cfg.Storage)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+
+	dialer, err := upstream.New(cfg.Upstream.URL, cfg.Upstream.Bypass)
+	if err != nil {
+		log.Print(err)
+		return
+	}
 
-	db, err := sql.Open("postgres", "host=localhost user=test_user password=test_password dbname=requests sslmode=disable")
+	generated, err := cert.EnsureCA(cfg.Cert.CACert, cfg.Cert.CAKey, newCAOptions(cfg.Cert))
 	if err != nil {
-		log.Printf("postgres not available: %v", err)
+		log.Print(err)
 		return
 	}
+	if generated {
+		log.Printf("generated CA %s, install it on clients, e.g. from http://%s/cert through the proxy", cfg.Cert.CACert, cfg.Proxy.CertHost)
+	}
 
-	err = db.Ping()
+	certManager, err := cert.NewCertManager(cert.Options{
+		CACertFile: cfg.Cert.CACert,
+		CAKeyFile:  cfg.Cert.CAKey,
+		Dir:        cfg.Cert.Dir,
+		KeyType:    cfg.Cert.KeyType,
+		CacheSize:  cfg.Cert.CacheSize,
+		Validity:   cfg.Cert.Validity,
+		Wildcard:   cfg.Cert.Wildcard,
+		Mirror:     cfg.Cert.Mirror,
+	})
 	if err != nil {
-		log.Printf("no connection with db: %v", err)
+		log.Print(err)
 		return
 	}
 
-	proxyRepository := proxyRepository.NewProxyRepository(db)
-	proxyUsecase := ProxyUsecase.NewProxyUsecase(proxyRepository)
-	proxyHandler := proxyDelivery.NewProxyHandler(proxyUsecase)
+	interceptUsecase := InterceptUsecase.NewInterceptUsecase(models.InterceptSettings{
+		Enabled:   cfg.Intercept.Enabled,
+		Responses: cfg.Intercept.Responses,
+		Host:      cfg.Intercept.Host,
+		Methods:   cfg.Intercept.Methods,
+	}, cfg.Intercept.Timeout)
+
+	authUsecase := AuthUsecase.NewAuthUsecase(repos.auth, cfg.Auth.Enabled)
+	if cfg.Auth.UsersFile != "" {
+		if err := authUsecase.LoadUsers(cfg.Auth.UsersFile); err != nil {
+			log.Print(err)
+			return
+		}
+	}
+	if users, err := authUsecase.GetUsers(); cfg.Auth.Enabled && err == nil && len(users) == 0 {
+		log.Print("proxy authentication is enabled but there are no users, add them with -proxy-users or /api/v1/users")
+	}
+
+	scopeUsecase := ScopeUsecase.NewScopeUsecase(repos.scope)
+	tunnelUsecase := TunnelUsecase.NewTunnelUsecase(
+		repos.tunnel, cfg.Passthrough.Hosts, cfg.Passthrough.Auto, cfg.Passthrough.AutoTTL,
+	)
+	rulesUsecase := RulesUsecase.NewRulesUsecase(repos.rules)
+	proxyUsecase := ProxyUsecase.NewProxyUsecase(repos.proxy)
+	websocketUsecase := WebSocketUsecase.NewWebSocketUsecase(
+		repos.websocket, proxyUsecase, dialer, cfg.Repeater.ClientTimeout,
+	)
+	proxyHandler := proxyDelivery.NewProxyHandler(
+		proxyUsecase, interceptUsecase, rulesUsecase, websocketUsecase, authUsecase, scopeUsecase,
+		tunnelUsecase, cfg.Proxy, certManager, dialer,
+	)
+	proxyAuthHandler := authDelivery.NewProxyAuthHandler(authUsecase, cfg.Auth.Realm)
 
 	proxyServer := http.Server{
-		Addr: port,
+		Addr: cfg.Proxy.Addr,
 		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if proxyHandler.IsCertRequest(r) {
+				proxyHandler.ServeCert(w, r)
+				return
+			}
+
+			r, ok := proxyAuthHandler.Authenticate(w, r)
+			if !ok {
+				return
+			}
+
 			delete(r.Header, "Proxy-Connection")
 			r.RequestURI = ""
 
@@ -50,43 +254,124 @@ func RunProxyServer() {
 
 			proxyHandler.HandleHTTP(w, r)
 		}),
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  cfg.Proxy.ReadTimeout,
+		WriteTimeout: cfg.Proxy.WriteTimeout,
+		ConnContext:  proxyDelivery.WithConn,
 
+		// CONNECT needs a hijackable HTTP/1.1 connection, h2 is negotiated inside the tunnel
 		TLSNextProto: make(map[string]func(*http.Server, *tls.Conn, http.Handler)),
 	}
 
 	go func() {
-		log.Printf("starting proxy server at %s", port)
+		log.Printf("starting proxy server at %s", cfg.Proxy.Addr)
 		log.Fatal(proxyServer.ListenAndServe())
 	}()
 
-	file, err := os.Open("configs/payloads")
+	if cfg.Proxy.SocksAddr != "" {
+		go func() {
+			log.Printf("starting socks5 proxy at %s", cfg.Proxy.SocksAddr)
+			log.Fatal(proxyHandler.ListenAndServeSOCKS(cfg.Proxy.SocksAddr))
+		}()
+	}
+
+	if cfg.Proxy.TransparentAddr != "" {
+		go func() {
+			log.Printf("starting transparent proxy at %s", cfg.Proxy.TransparentAddr)
+			log.Fatal(proxyHandler.ListenAndServeTransparent(cfg.Proxy.TransparentAddr))
+		}()
+	}
+
+	payloads, err := loadPayloads(cfg.Repeater.Payloads)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer file.Close()
 
-	payloads := make([]string, 0)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		payloads = append(payloads, scanner.Text())
-	}
+	scannerUsecase := ScannerUsecase.NewScannerUsecase(
+		checks.Default(payloads),
+		ScannerUsecase.NewHTTPSender(dialer, cfg.Repeater.ClientTimeout),
+		repos.scanner,
+		proxyUsecase,
+		scopeUsecase,
+		cfg.Scanner,
+	)
 
-	if err := scanner.Err(); err != nil {
+	err = scannerUsecase.Start()
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	repeatHandler := repeaterDelivery.NewRepeaterHandler(proxyUsecase, payloads)
+	harUsecase := HarUsecase.NewHarUsecase(proxyUsecase)
+	repeaterUsecase := RepeaterUsecase.NewRepeaterUsecase(proxyUsecase, dialer, cfg.Repeater.ClientTimeout)
+
+	repeatHandler := repeaterDelivery.NewRepeaterHandler(proxyUsecase, rulesUsecase, repeaterUsecase)
+	scanHandler := repeaterDelivery.NewScanHandler(scannerUsecase)
+	interceptHandler := repeaterDelivery.NewInterceptHandler(interceptUsecase)
+	rulesHandler := repeaterDelivery.NewRulesHandler(rulesUsecase)
+	queriesHandler := repeaterDelivery.NewQueriesHandler(proxyUsecase)
+	harHandler := repeaterDelivery.NewHarHandler(harUsecase)
+	websocketHandler := repeaterDelivery.NewWebSocketHandler(websocketUsecase)
+	usersHandler := repeaterDelivery.NewUsersHandler(authUsecase)
+	scopeHandler := repeaterDelivery.NewScopeHandler(scopeUsecase)
+	tunnelsHandler := repeaterDelivery.NewTunnelsHandler(tunnelUsecase)
+
+	apiHandler := apiDelivery.NewAPIHandler(
+		proxyUsecase, repeaterUsecase, rulesUsecase, scannerUsecase, interceptUsecase, harUsecase,
+		websocketUsecase, authUsecase, scopeUsecase, tunnelUsecase,
+	)
 
 	mux := mux.NewRouter()
 
+	apiHandler.Register(mux)
+	apiHandler.RegisterNegotiated(mux)
+
 	mux.HandleFunc("/requests", repeatHandler.ShowAllRequests)
 	mux.HandleFunc("/request/{id}", repeatHandler.ShowRequest)
 	mux.HandleFunc("/repeat/{id}", repeatHandler.RepeatRequest)
-	mux.HandleFunc("/scan/{id}", repeatHandler.ScanRequest)
+	mux.HandleFunc("/repeat/{id}/edit", repeatHandler.ResendRequest).Methods(http.MethodPost)
+	mux.HandleFunc("/har", harHandler.ExportHAR).Methods(http.MethodGet)
+	mux.HandleFunc("/har", harHandler.ImportHAR).Methods(http.MethodPost)
+	mux.HandleFunc("/scan/{id}", scanHandler.ScanRequest)
+	mux.HandleFunc("/scans", scanHandler.ShowAllJobs).Methods(http.MethodGet)
+	mux.HandleFunc("/scans/{id}", scanHandler.ShowJob).Methods(http.MethodGet)
+	mux.HandleFunc("/scans/{id}/cancel", scanHandler.CancelJob).Methods(http.MethodPost)
+	mux.HandleFunc("/findings", scanHandler.ShowAllFindings).Methods(http.MethodGet)
+	mux.HandleFunc("/findings/{id}", scanHandler.ShowFinding).Methods(http.MethodGet)
+
+	mux.HandleFunc("/intercept", interceptHandler.ShowQueue).Methods(http.MethodGet)
+	mux.HandleFunc("/intercept/settings", interceptHandler.UpdateSettings).Methods(http.MethodPost)
+	mux.HandleFunc("/intercept/{id}", interceptHandler.ShowItem).Methods(http.MethodGet)
+	mux.HandleFunc("/intercept/{id}/forward", interceptHandler.ForwardItem).Methods(http.MethodPost)
+	mux.HandleFunc("/intercept/{id}/drop", interceptHandler.DropItem).Methods(http.MethodPost)
+
+	mux.HandleFunc("/queries", queriesHandler.ShowAllQueries).Methods(http.MethodGet)
+	mux.HandleFunc("/queries", queriesHandler.SaveQuery).Methods(http.MethodPost)
+	mux.HandleFunc("/queries/{name}", queriesHandler.ShowQuery).Methods(http.MethodGet)
+	mux.HandleFunc("/queries/{name}", queriesHandler.DeleteQuery).Methods(http.MethodDelete)
+
+	mux.HandleFunc("/websockets", websocketHandler.ShowAllConnections).Methods(http.MethodGet)
+	mux.HandleFunc("/websockets/{id}", websocketHandler.ShowConnection).Methods(http.MethodGet)
+	mux.HandleFunc("/websockets/messages/{id}/resend", websocketHandler.ResendMessage).Methods(http.MethodPost)
+
+	mux.HandleFunc("/tunnels", tunnelsHandler.ShowAllTunnels).Methods(http.MethodGet)
+	mux.HandleFunc("/tunnels/{id}", tunnelsHandler.ShowTunnel).Methods(http.MethodGet)
+
+	mux.HandleFunc("/scope", scopeHandler.ShowScope).Methods(http.MethodGet)
+	mux.HandleFunc("/scope", scopeHandler.CreateRule).Methods(http.MethodPost)
+	mux.HandleFunc("/scope/{id}", scopeHandler.ShowRule).Methods(http.MethodGet)
+	mux.HandleFunc("/scope/{id}", scopeHandler.UpdateRule).Methods(http.MethodPost)
+	mux.HandleFunc("/scope/{id}", scopeHandler.DeleteRule).Methods(http.MethodDelete)
+
+	mux.HandleFunc("/users", usersHandler.ShowAllUsers).Methods(http.MethodGet)
+	mux.HandleFunc("/users", usersHandler.SaveUser).Methods(http.MethodPost)
+	mux.HandleFunc("/users/{name}", usersHandler.ShowUser).Methods(http.MethodGet)
+	mux.HandleFunc("/users/{name}", usersHandler.DeleteUser).Methods(http.MethodDelete)
+
+	mux.HandleFunc("/rules", rulesHandler.ShowAllRules).Methods(http.MethodGet)
+	mux.HandleFunc("/rules", rulesHandler.CreateRule).Methods(http.MethodPost)
+	mux.HandleFunc("/rules/{id}", rulesHandler.ShowRule).Methods(http.MethodGet)
+	mux.HandleFunc("/rules/{id}", rulesHandler.UpdateRule).Methods(http.MethodPost)
+	mux.HandleFunc("/rules/{id}", rulesHandler.DeleteRule).Methods(http.MethodDelete)
 
-	repeaterPort := ":8000"
-	log.Printf("starting repeater at %s", repeaterPort)
-	log.Fatal(http.ListenAndServe(repeaterPort, mux))
+	log.Printf("starting repeater at %s", cfg.Repeater.Addr)
+	log.Fatal(http.ListenAndServe(cfg.Repeater.Addr, mux))
 }
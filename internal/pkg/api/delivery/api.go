@@ -0,0 +1,285 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	authInterfaces "github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	harInterfaces "github.com/aanufriev/httpproxy/internal/pkg/har/interfaces"
+	interceptInterfaces "github.com/aanufriev/httpproxy/internal/pkg/intercept/interfaces"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	repeaterInterfaces "github.com/aanufriev/httpproxy/internal/pkg/repeater/interfaces"
+	rulesInterfaces "github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+	scannerInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	scopeInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+	tunnelInterfaces "github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+	websocketInterfaces "github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+	"github.com/gorilla/mux"
+)
+
+const (
+	Prefix = "/api/v1"
+
+	contentTypeJSON = "application/json"
+	maxBodySize     = 32 << 20
+)
+
+type APIHandler struct {
+	proxyUsecase     proxyInterfaces.Usecase
+	repeaterUsecase  repeaterInterfaces.Usecase
+	rulesUsecase     rulesInterfaces.Usecase
+	scannerUsecase   scannerInterfaces.Usecase
+	interceptUsecase interceptInterfaces.Usecase
+	harUsecase       harInterfaces.Usecase
+	websocketUsecase websocketInterfaces.Usecase
+	authUsecase      authInterfaces.Usecase
+	scopeUsecase     scopeInterfaces.Usecase
+	tunnelUsecase    tunnelInterfaces.Usecase
+}
+
+func NewAPIHandler(
+	proxyUsecase proxyInterfaces.Usecase, repeaterUsecase repeaterInterfaces.Usecase,
+	rulesUsecase rulesInterfaces.Usecase, scannerUsecase scannerInterfaces.Usecase,
+	interceptUsecase interceptInterfaces.Usecase, harUsecase harInterfaces.Usecase,
+	websocketUsecase websocketInterfaces.Usecase, authUsecase authInterfaces.Usecase,
+	scopeUsecase scopeInterfaces.Usecase, tunnelUsecase tunnelInterfaces.Usecase,
+) APIHandler {
+	return APIHandler{
+		proxyUsecase:     proxyUsecase,
+		repeaterUsecase:  repeaterUsecase,
+		rulesUsecase:     rulesUsecase,
+		scannerUsecase:   scannerUsecase,
+		interceptUsecase: interceptUsecase,
+		harUsecase:       harUsecase,
+		websocketUsecase: websocketUsecase,
+		authUsecase:      authUsecase,
+		scopeUsecase:     scopeUsecase,
+		tunnelUsecase:    tunnelUsecase,
+	}
+}
+
+func (h APIHandler) Register(router *mux.Router) {
+	api := router.PathPrefix(Prefix).Subrouter()
+
+	api.HandleFunc("/openapi.yaml", h.ShowOpenAPI).Methods(http.MethodGet)
+
+	handle := func(path string, handler http.HandlerFunc, method string) {
+		api.Handle(path, negotiate(handler)).Methods(method)
+	}
+
+	handle("/requests", h.ListRequests, http.MethodGet)
+	handle("/requests/repeat", h.RepeatRequests, http.MethodPost)
+	handle("/requests/{id}", h.GetRequest, http.MethodGet)
+	handle("/requests/{id}/repeat", h.RepeatRequest, http.MethodPost)
+	handle("/requests/{id}/resend", h.ResendRequest, http.MethodPost)
+	handle("/requests/{id}/scan", h.ScanRequest, http.MethodPost)
+
+	handle("/scans", h.ListJobs, http.MethodGet)
+	handle("/scans", h.ScanRequests, http.MethodPost)
+	handle("/scans/{id}", h.GetJob, http.MethodGet)
+	handle("/scans/{id}/cancel", h.CancelJob, http.MethodPost)
+	handle("/findings", h.ListFindings, http.MethodGet)
+	handle("/findings/{id}", h.GetFinding, http.MethodGet)
+
+	handle("/queries", h.ListQueries, http.MethodGet)
+	handle("/queries/{name}", h.GetQuery, http.MethodGet)
+	handle("/queries/{name}", h.SaveQuery, http.MethodPut)
+	handle("/queries/{name}", h.DeleteQuery, http.MethodDelete)
+
+	handle("/rules", h.ListRules, http.MethodGet)
+	handle("/rules", h.CreateRule, http.MethodPost)
+	handle("/rules/{id}", h.GetRule, http.MethodGet)
+	handle("/rules/{id}", h.UpdateRule, http.MethodPut)
+	handle("/rules/{id}", h.DeleteRule, http.MethodDelete)
+
+	handle("/scope", h.ListScope, http.MethodGet)
+	handle("/scope", h.CreateScopeRule, http.MethodPost)
+	handle("/scope/{id}", h.GetScopeRule, http.MethodGet)
+	handle("/scope/{id}", h.UpdateScopeRule, http.MethodPut)
+	handle("/scope/{id}", h.DeleteScopeRule, http.MethodDelete)
+
+	handle("/intercept", h.ShowQueue, http.MethodGet)
+	handle("/intercept/settings", h.GetSettings, http.MethodGet)
+	handle("/intercept/settings", h.UpdateSettings, http.MethodPut)
+	handle("/intercept/{id}", h.GetItem, http.MethodGet)
+	handle("/intercept/{id}/forward", h.ForwardItem, http.MethodPost)
+	handle("/intercept/{id}/drop", h.DropItem, http.MethodPost)
+
+	handle("/har", h.ExportHAR, http.MethodGet)
+	handle("/har", h.ImportHAR, http.MethodPost)
+
+	handle("/websockets", h.ListConnections, http.MethodGet)
+	handle("/websockets/{id}", h.GetConnection, http.MethodGet)
+	handle("/websockets/messages/{id}", h.GetMessage, http.MethodGet)
+	handle("/websockets/messages/{id}/resend", h.ResendMessage, http.MethodPost)
+
+	handle("/tunnels", h.ListTunnels, http.MethodGet)
+	handle("/tunnels/{id}", h.GetTunnel, http.MethodGet)
+
+	handle("/users", h.ListUsers, http.MethodGet)
+	handle("/users/{name}", h.GetUser, http.MethodGet)
+	handle("/users/{name}", h.SaveUser, http.MethodPut)
+	handle("/users/{name}", h.DeleteUser, http.MethodDelete)
+
+	api.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
+	api.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	})
+}
+
+func (h APIHandler) RegisterNegotiated(router *mux.Router) {
+	handle := func(path string, handler http.HandlerFunc) {
+		router.HandleFunc(path, handler).Methods(http.MethodGet).MatcherFunc(wantsJSON)
+	}
+
+	handle("/requests", h.ListRequests)
+	handle("/request/{id}", h.GetRequest)
+	handle("/scans", h.ListJobs)
+	handle("/scans/{id}", h.GetJob)
+	handle("/findings", h.ListFindings)
+	handle("/findings/{id}", h.GetFinding)
+	handle("/queries", h.ListQueries)
+	handle("/queries/{name}", h.GetQuery)
+	handle("/rules", h.ListRules)
+	handle("/rules/{id}", h.GetRule)
+	handle("/scope", h.ListScope)
+	handle("/scope/{id}", h.GetScopeRule)
+	handle("/intercept", h.ShowQueue)
+	handle("/intercept/{id}", h.GetItem)
+	handle("/websockets", h.ListConnections)
+	handle("/websockets/{id}", h.GetConnection)
+	handle("/tunnels", h.ListTunnels)
+	handle("/tunnels/{id}", h.GetTunnel)
+	handle("/users", h.ListUsers)
+	handle("/users/{name}", h.GetUser)
+}
+
+type errorJSON struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorJSON{Error: message})
+}
+
+func writeStorageError(w http.ResponseWriter, err error, entity string) {
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, entity+" not found")
+		return
+	}
+
+	log.Printf("couldn't get %s: %v", entity, err)
+	writeError(w, http.StatusServiceUnavailable, err.Error())
+}
+
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "id must be a number")
+		return 0, false
+	}
+
+	return id, true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	return decodeJSON(w, r, v, true)
+}
+
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, strict bool) bool {
+	contentType := r.Header.Get("Content-Type")
+	if contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || mediaType != contentTypeJSON {
+			writeError(w, http.StatusUnsupportedMediaType, "request body must be "+contentTypeJSON)
+			return false
+		}
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid json: "+err.Error())
+		return false
+	}
+
+	return true
+}
+
+func negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptsJSON(r.Header.Get("Accept")) {
+			writeError(w, http.StatusNotAcceptable, "only "+contentTypeJSON+" is available")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func wantsJSON(r *http.Request, rm *mux.RouteMatch) bool {
+	for _, mediaType := range acceptedTypes(r.Header.Get("Accept")) {
+		if mediaType == contentTypeJSON {
+			return true
+		}
+	}
+
+	return false
+}
+
+func acceptsJSON(accept string) bool {
+	types := acceptedTypes(accept)
+	if len(types) == 0 {
+		return true
+	}
+
+	for _, mediaType := range types {
+		switch mediaType {
+		case contentTypeJSON, "application/*", "*/*":
+			return true
+		}
+	}
+
+	return false
+}
+
+func acceptedTypes(accept string) []string {
+	types := make([]string, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		if q, ok := params["q"]; ok {
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+		}
+
+		types = append(types, mediaType)
+	}
+
+	return types
+}
@@ -0,0 +1,63 @@
+package delivery
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	harUsecase "github.com/aanufriev/httpproxy/internal/pkg/har/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+)
+
+func (h APIHandler) ExportHAR(w http.ResponseWriter, r *http.Request) {
+	ids, err := harUsecase.ParseIDs(r.URL.Query().Get("ids"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	filter, err := models.RequestFilterFromQuery(r.URL.Query(), 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	har, err := h.harUsecase.Export(ids, filter)
+	if errors.Is(err, proxyUsecase.ErrQueryNotFound) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err == sql.ErrNoRows {
+		writeError(w, http.StatusNotFound, "request not found")
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't export har: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, har)
+}
+
+func (h APIHandler) ImportHAR(w http.ResponseWriter, r *http.Request) {
+	var har models.HAR
+	if !decodeJSON(w, r, &har, false) {
+		return
+	}
+
+	ids, err := h.harUsecase.Import(har)
+	if err == harUsecase.ErrNotHAR || errors.Is(err, harUsecase.ErrInvalidEntry) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't import har: %v", err)
+		writeJSON(w, http.StatusServiceUnavailable, importJSON{IDs: ids, Error: err.Error()})
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, importJSON{IDs: ids})
+}
@@ -0,0 +1,133 @@
+package delivery
+
+import (
+	"net/http"
+	"strings"
+
+	interceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+func (h APIHandler) ShowQueue(w http.ResponseWriter, r *http.Request) {
+	result := queueJSON{
+		Settings: newSettingsJSON(h.interceptUsecase.GetSettings()),
+		Items:    make([]itemJSON, 0),
+	}
+
+	for _, item := range h.interceptUsecase.GetItems() {
+		result.Items = append(result.Items, newItemJSON(item))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, newSettingsJSON(h.interceptUsecase.GetSettings()))
+}
+
+func (h APIHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	input := newSettingsJSON(h.interceptUsecase.GetSettings())
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	for i, method := range input.Methods {
+		input.Methods[i] = strings.ToUpper(strings.TrimSpace(method))
+	}
+
+	err := h.interceptUsecase.SetSettings(input.toModel())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSettingsJSON(h.interceptUsecase.GetSettings()))
+}
+
+func (h APIHandler) GetItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newItemJSON(item))
+}
+
+func (h APIHandler) ForwardItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	var input forwardJSON
+	if r.ContentLength != 0 && !readJSON(w, r, &input) {
+		return
+	}
+
+	decision := models.InterceptDecision{
+		Action:   models.ActionForward,
+		Request:  item.Request,
+		Response: item.Response,
+	}
+
+	var err error
+	switch {
+	case item.Type == models.InterceptRequest && input.Request != nil:
+		decision.Request, err = input.Request.Apply(item.Request)
+		decision.Request.ID = item.Request.ID
+		decision.Request.ParentID = item.Request.ParentID
+		decision.Request.CreatedAt = item.Request.CreatedAt
+	case item.Type == models.InterceptResponse && input.Response != nil:
+		decision.Response, err = input.Response.apply(item.Response)
+	case input.Request != nil || input.Response != nil:
+		writeError(w, http.StatusBadRequest, "item is a "+item.Type+", only "+item.Type+" can be edited")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.resolve(w, item, decision)
+}
+
+func (h APIHandler) DropItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	h.resolve(w, item, models.InterceptDecision{Action: models.ActionDrop})
+}
+
+func (h APIHandler) resolve(w http.ResponseWriter, item models.InterceptedItem, decision models.InterceptDecision) {
+	err := h.interceptUsecase.Resolve(item.ID, decision)
+	if err == interceptUsecase.ErrNotFound {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"id":     item.ID,
+		"action": decision.Action,
+	})
+}
+
+func (h APIHandler) getItem(w http.ResponseWriter, r *http.Request) (models.InterceptedItem, bool) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return models.InterceptedItem{}, false
+	}
+
+	item, err := h.interceptUsecase.GetItem(id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return models.InterceptedItem{}, false
+	}
+
+	return item, true
+}
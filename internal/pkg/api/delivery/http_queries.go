@@ -0,0 +1,80 @@
+package delivery
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+type queryInputJSON struct {
+	Query string `json:"query"`
+}
+
+func (h APIHandler) ListQueries(w http.ResponseWriter, r *http.Request) {
+	queries, err := h.proxyUsecase.GetQueries()
+	if err != nil {
+		log.Printf("couldn't get saved queries: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]savedQueryJSON, 0, len(queries))
+	for _, q := range queries {
+		result = append(result, newSavedQueryJSON(q))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetQuery(w http.ResponseWriter, r *http.Request) {
+	q, err := h.proxyUsecase.GetQuery(mux.Vars(r)["name"])
+	if err != nil {
+		writeStorageError(w, err, "saved query")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newSavedQueryJSON(q))
+}
+
+func (h APIHandler) SaveQuery(w http.ResponseWriter, r *http.Request) {
+	var input queryInputJSON
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	q := models.SavedQuery{
+		Name:  mux.Vars(r)["name"],
+		Query: input.Query,
+	}
+	if err := q.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if _, err := h.proxyUsecase.GetQuery(q.Name); err == sql.ErrNoRows {
+		status = http.StatusCreated
+	}
+
+	q, err := h.proxyUsecase.SaveQuery(q)
+	if err != nil {
+		log.Printf("couldn't save query: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, status, newSavedQueryJSON(q))
+}
+
+func (h APIHandler) DeleteQuery(w http.ResponseWriter, r *http.Request) {
+	err := h.proxyUsecase.DeleteQuery(mux.Vars(r)["name"])
+	if err != nil {
+		writeStorageError(w, err, "saved query")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
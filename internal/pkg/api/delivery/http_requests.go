@@ -0,0 +1,231 @@
+package delivery
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+	scannerUsecase "github.com/aanufriev/httpproxy/internal/pkg/scanner/usecase"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+)
+
+func (h APIHandler) ListRequests(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.getRequests(w, r)
+	if !ok {
+		return
+	}
+
+	result := make([]requestJSON, 0, len(page.Requests))
+	for _, request := range page.Requests {
+		result = append(result, newRequestJSON(request))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) RepeatRequests(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.getRequests(w, r)
+	if !ok {
+		return
+	}
+
+	result := make([]repeatResultJSON, 0, len(page.Requests))
+	for _, request := range page.Requests {
+		item := repeatResultJSON{RequestID: request.ID}
+
+		resp, err := h.repeaterUsecase.Repeat(request.ID)
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			response := newResponseJSON(resp)
+			item.Response = &response
+		}
+
+		result = append(result, item)
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) getRequests(w http.ResponseWriter, r *http.Request) (models.RequestPage, bool) {
+	filter, err := models.RequestFilterFromQuery(r.URL.Query(), models.DefaultRequestLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return models.RequestPage{}, false
+	}
+
+	page, err := h.proxyUsecase.GetRequests(filter)
+	if errors.Is(err, proxyUsecase.ErrQueryNotFound) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return models.RequestPage{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get requests: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return models.RequestPage{}, false
+	}
+
+	if page.NextCursor != 0 {
+		filter.Cursor = page.NextCursor
+		w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, r.URL.Path, filter.Values().Encode()))
+	}
+
+	return page, true
+}
+
+func (h APIHandler) GetRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	request, err := h.proxyUsecase.GetRequest(id)
+	if err != nil {
+		writeStorageError(w, err, "request")
+		return
+	}
+
+	result := requestDetailJSON{
+		requestJSON:  newRequestJSON(request),
+		AppliedRules: make([]ruleJSON, 0),
+	}
+
+	resp, err := h.proxyUsecase.GetResponse(id)
+	switch {
+	case err == nil:
+		response := newResponseJSON(resp)
+		result.Response = &response
+	case err != sql.ErrNoRows:
+		log.Printf("couldn't get response: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	applied, err := h.rulesUsecase.GetApplied(id)
+	if err != nil {
+		log.Printf("couldn't get applied rules: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	for _, rule := range applied {
+		result.AppliedRules = append(result.AppliedRules, newRuleJSON(rule))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) RepeatRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := h.proxyUsecase.GetRequest(id); err != nil {
+		writeStorageError(w, err, "request")
+		return
+	}
+
+	resp, err := h.repeaterUsecase.Repeat(id)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newResponseJSON(resp))
+}
+
+func (h APIHandler) ResendRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	original, err := h.proxyUsecase.GetRequest(id)
+	if err != nil {
+		writeStorageError(w, err, "request")
+		return
+	}
+
+	raw, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	request, err := models.ParseEditedRequest(r.Header.Get("Content-Type"), raw, original)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "couldn't parse edited request: "+err.Error())
+		return
+	}
+
+	request, resp, err := h.repeaterUsecase.Resend(request)
+	if err != nil && request.ID == 0 {
+		log.Printf("couldn't save request: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		w.Header().Set("Location", fmt.Sprintf("%s/requests/%d", Prefix, request.ID))
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/requests/%d", Prefix, request.ID))
+	writeJSON(w, http.StatusCreated, exchangeJSON{
+		Request:  newRequestJSON(request),
+		Response: newResponseJSON(resp),
+	})
+}
+
+func (h APIHandler) ScanRequests(w http.ResponseWriter, r *http.Request) {
+	page, ok := h.getRequests(w, r)
+	if !ok {
+		return
+	}
+
+	result := make([]scanResultJSON, 0, len(page.Requests))
+	for _, request := range page.Requests {
+		item := scanResultJSON{RequestID: request.ID}
+
+		job, err := h.scannerUsecase.SubmitJob(request.ID)
+		if job.ID != 0 {
+			submitted := newJobJSON(job)
+			item.Job = &submitted
+		}
+		if err != nil {
+			item.Error = err.Error()
+		}
+
+		result = append(result, item)
+	}
+
+	writeJSON(w, http.StatusAccepted, result)
+}
+
+func (h APIHandler) ScanRequest(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.scannerUsecase.SubmitJob(id)
+	switch {
+	case err == sql.ErrNoRows:
+		writeError(w, http.StatusNotFound, "request not found")
+	case err == scopeUsecase.ErrOutOfScope:
+		writeError(w, http.StatusBadRequest, err.Error())
+	case err == scannerUsecase.ErrQueueFull:
+		writeJSON(w, http.StatusServiceUnavailable, newJobJSON(job))
+	case err != nil:
+		log.Printf("couldn't submit scan: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+	default:
+		w.Header().Set("Location", fmt.Sprintf("%s/scans/%d", Prefix, job.ID))
+		writeJSON(w, http.StatusAccepted, newJobJSON(job))
+	}
+}
@@ -0,0 +1,110 @@
+package delivery
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func (h APIHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.rulesUsecase.GetRules()
+	if err != nil {
+		log.Printf("couldn't get rules: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]ruleJSON, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, newRuleJSON(rule))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.rulesUsecase.GetRule(id)
+	if err != nil {
+		writeStorageError(w, err, "rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRuleJSON(rule))
+}
+
+func (h APIHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	input := ruleJSON{Enabled: true}
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	rule := input.toModel()
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var err error
+	rule.ID, err = h.rulesUsecase.CreateRule(rule)
+	if err != nil {
+		log.Printf("couldn't create rule: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/rules/%d", Prefix, rule.ID))
+	writeJSON(w, http.StatusCreated, newRuleJSON(rule))
+}
+
+func (h APIHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.rulesUsecase.GetRule(id)
+	if err != nil {
+		writeStorageError(w, err, "rule")
+		return
+	}
+
+	input := newRuleJSON(rule)
+	if !readJSON(w, r, &input) {
+		return
+	}
+	input.ID = id
+
+	rule = input.toModel()
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.rulesUsecase.UpdateRule(rule)
+	if err != nil {
+		writeStorageError(w, err, "rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newRuleJSON(rule))
+}
+
+func (h APIHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.rulesUsecase.DeleteRule(id)
+	if err != nil {
+		writeStorageError(w, err, "rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	scannerUsecase "github.com/aanufriev/httpproxy/internal/pkg/scanner/usecase"
+)
+
+func (h APIHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.scannerUsecase.GetJobs()
+	if err != nil {
+		log.Printf("couldn't get scan jobs: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]jobJSON, 0, len(jobs))
+	for _, job := range jobs {
+		result = append(result, newJobJSON(job))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	job, err := h.scannerUsecase.GetJob(id)
+	if err != nil {
+		writeStorageError(w, err, "scan job")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newJobJSON(job))
+}
+
+func (h APIHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.scannerUsecase.CancelJob(id)
+	if err == scannerUsecase.ErrJobFinished {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeStorageError(w, err, "scan job")
+		return
+	}
+
+	job, err := h.scannerUsecase.GetJob(id)
+	if err != nil {
+		writeStorageError(w, err, "scan job")
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, newJobJSON(job))
+}
+
+func (h APIHandler) ListFindings(w http.ResponseWriter, r *http.Request) {
+	filter, err := models.FindingFilterFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	findings, err := h.scannerUsecase.GetFindings(filter)
+	if err != nil {
+		log.Printf("couldn't get findings: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]findingJSON, 0, len(findings))
+	for _, finding := range findings {
+		result = append(result, newFindingJSON(finding))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetFinding(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	finding, err := h.scannerUsecase.GetFinding(id)
+	if err != nil {
+		writeStorageError(w, err, "finding")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newFindingJSON(finding))
+}
@@ -0,0 +1,110 @@
+package delivery
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+func (h APIHandler) ListScope(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.scopeUsecase.GetRules()
+	if err != nil {
+		log.Printf("couldn't get scope rules: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]scopeRuleJSON, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, newScopeRuleJSON(rule))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetScopeRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.scopeUsecase.GetRule(id)
+	if err != nil {
+		writeStorageError(w, err, "scope rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScopeRuleJSON(rule))
+}
+
+func (h APIHandler) CreateScopeRule(w http.ResponseWriter, r *http.Request) {
+	input := scopeRuleJSON{Enabled: true}
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	rule := input.toModel()
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var err error
+	rule.ID, err = h.scopeUsecase.CreateRule(rule)
+	if err != nil {
+		log.Printf("couldn't create scope rule: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/scope/%d", Prefix, rule.ID))
+	writeJSON(w, http.StatusCreated, newScopeRuleJSON(rule))
+}
+
+func (h APIHandler) UpdateScopeRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	rule, err := h.scopeUsecase.GetRule(id)
+	if err != nil {
+		writeStorageError(w, err, "scope rule")
+		return
+	}
+
+	input := newScopeRuleJSON(rule)
+	if !readJSON(w, r, &input) {
+		return
+	}
+	input.ID = id
+
+	rule = input.toModel()
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = h.scopeUsecase.UpdateRule(rule)
+	if err != nil {
+		writeStorageError(w, err, "scope rule")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScopeRuleJSON(rule))
+}
+
+func (h APIHandler) DeleteScopeRule(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.scopeUsecase.DeleteRule(id)
+	if err != nil {
+		writeStorageError(w, err, "scope rule")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,37 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+)
+
+func (h APIHandler) ListTunnels(w http.ResponseWriter, r *http.Request) {
+	tunnels, err := h.tunnelUsecase.GetTunnels()
+	if err != nil {
+		log.Printf("couldn't get tunnels: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]tunnelJSON, 0, len(tunnels))
+	for _, tunnel := range tunnels {
+		result = append(result, newTunnelJSON(tunnel))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetTunnel(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	tunnel, err := h.tunnelUsecase.GetTunnel(id)
+	if err != nil {
+		writeStorageError(w, err, "tunnel")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTunnelJSON(tunnel))
+}
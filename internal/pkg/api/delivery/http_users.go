@@ -0,0 +1,87 @@
+package delivery
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+
+	AuthUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+type userInputJSON struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+func (h APIHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.authUsecase.GetUsers()
+	if err != nil {
+		log.Printf("couldn't get proxy users: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]userJSON, 0, len(users))
+	for _, user := range users {
+		result = append(result, newUserJSON(user))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.authUsecase.GetUser(mux.Vars(r)["name"])
+	if err != nil {
+		writeStorageError(w, err, "user")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newUserJSON(user))
+}
+
+func (h APIHandler) SaveUser(w http.ResponseWriter, r *http.Request) {
+	var input userInputJSON
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	edit := models.ProxyUserEdit{
+		Name:     mux.Vars(r)["name"],
+		Password: input.Password,
+		Token:    input.Token,
+	}
+	if err := edit.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if _, err := h.authUsecase.GetUser(edit.Name); err == sql.ErrNoRows {
+		status = http.StatusCreated
+	}
+
+	user, err := h.authUsecase.SaveUser(edit)
+	if err == AuthUsecase.ErrTokenTaken {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't save proxy user: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, status, newUserJSON(user))
+}
+
+func (h APIHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	err := h.authUsecase.DeleteUser(mux.Vars(r)["name"])
+	if err != nil {
+		writeStorageError(w, err, "user")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
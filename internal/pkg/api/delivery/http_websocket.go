@@ -0,0 +1,101 @@
+package delivery
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	websocketUsecase "github.com/aanufriev/httpproxy/internal/pkg/websocket/usecase"
+)
+
+func (h APIHandler) ListConnections(w http.ResponseWriter, r *http.Request) {
+	connections, err := h.websocketUsecase.GetConnections()
+	if err != nil {
+		log.Printf("couldn't get websocket connections: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	result := make([]connectionJSON, 0, len(connections))
+	for _, conn := range connections {
+		result = append(result, newConnectionJSON(conn))
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (h APIHandler) GetConnection(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := h.websocketUsecase.GetConnection(id)
+	if err != nil {
+		writeStorageError(w, err, "connection")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newConnectionJSON(conn))
+}
+
+func (h APIHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	msg, err := h.websocketUsecase.GetMessage(id)
+	if err != nil {
+		writeStorageError(w, err, "message")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newMessageJSON(msg))
+}
+
+func (h APIHandler) ResendMessage(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+
+	var input messageEditJSON
+	if !readJSON(w, r, &input) {
+		return
+	}
+
+	edit, err := input.toModel()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg, err := h.websocketUsecase.GetMessage(id)
+	if err != nil {
+		writeStorageError(w, err, "message")
+		return
+	}
+
+	msg, err = edit.Apply(msg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conn, err := h.websocketUsecase.Resend(msg)
+	switch {
+	case errors.Is(err, websocketUsecase.ErrNotResendable):
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, websocketUsecase.ErrHandshakeFailed):
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	case err != nil:
+		log.Printf("couldn't resend websocket message: %v", err)
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newConnectionJSON(conn))
+}
@@ -0,0 +1,1075 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+)
+
+func (h APIHandler) ShowOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+
+	_, err := w.Write([]byte(openAPISpec))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+const openAPISpec = `openapi: 3.0.3
+info:
+  title: httpproxy API
+  version: "1"
+  description: >
+    JSON API of the repeater server. Every endpoint answers with
+    application/json; clients that don't accept it get 406. Errors are
+    returned as {"error": "..."}. Non-numeric ids give 400, unknown ids 404.
+servers:
+  - url: /api/v1
+paths:
+  /requests:
+    get:
+      summary: List captured requests
+      parameters:
+        - $ref: "#/components/parameters/Host"
+        - $ref: "#/components/parameters/Method"
+        - $ref: "#/components/parameters/Scheme"
+        - $ref: "#/components/parameters/Path"
+        - $ref: "#/components/parameters/Header"
+        - $ref: "#/components/parameters/Body"
+        - $ref: "#/components/parameters/From"
+        - $ref: "#/components/parameters/To"
+        - $ref: "#/components/parameters/Query"
+        - $ref: "#/components/parameters/Saved"
+        - $ref: "#/components/parameters/Cursor"
+        - $ref: "#/components/parameters/Limit"
+        - $ref: "#/components/parameters/Order"
+      responses:
+        "200":
+          description: Captured requests
+          headers:
+            Link:
+              description: URL of the next page with rel="next", absent on the last page
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Request"
+        "400":
+          $ref: "#/components/responses/Error"
+  /requests/repeat:
+    post:
+      summary: Repeat every request matching the filters
+      parameters:
+        - $ref: "#/components/parameters/Host"
+        - $ref: "#/components/parameters/Method"
+        - $ref: "#/components/parameters/Scheme"
+        - $ref: "#/components/parameters/Path"
+        - $ref: "#/components/parameters/Header"
+        - $ref: "#/components/parameters/Body"
+        - $ref: "#/components/parameters/From"
+        - $ref: "#/components/parameters/To"
+        - $ref: "#/components/parameters/Query"
+        - $ref: "#/components/parameters/Saved"
+        - $ref: "#/components/parameters/Cursor"
+        - $ref: "#/components/parameters/Limit"
+        - $ref: "#/components/parameters/Order"
+      responses:
+        "200":
+          description: Result of each repeat
+          headers:
+            Link:
+              description: URL of the next page with rel="next", absent on the last page
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    request_id: {type: integer}
+                    response: {$ref: "#/components/schemas/Response"}
+                    error: {type: string}
+        "400":
+          $ref: "#/components/responses/Error"
+  /requests/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a request with its response and applied rules
+      responses:
+        "200":
+          description: Request
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/RequestDetail"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+  /requests/{id}/repeat:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Send the stored request again
+      responses:
+        "200":
+          description: Upstream response
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Response"
+        "404":
+          $ref: "#/components/responses/Error"
+        "502":
+          $ref: "#/components/responses/Error"
+  /requests/{id}/resend:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Send a modified copy of the request and store it as a new request
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/RequestEdit"
+          text/plain:
+            schema:
+              type: string
+              description: Raw HTTP request
+      responses:
+        "201":
+          description: Stored request and upstream response
+          headers:
+            Location:
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Exchange"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+        "502":
+          $ref: "#/components/responses/Error"
+  /requests/{id}/scan:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Queue a scan job for the request
+      responses:
+        "202":
+          description: Queued job
+          headers:
+            Location:
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScanJob"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+        "503":
+          description: Scan queue is full, the job is marked failed
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScanJob"
+  /scans:
+    get:
+      summary: List scan jobs
+      responses:
+        "200":
+          description: Scan jobs
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/ScanJob"
+    post:
+      summary: Queue scans of every request matching the filters
+      parameters:
+        - $ref: "#/components/parameters/Host"
+        - $ref: "#/components/parameters/Method"
+        - $ref: "#/components/parameters/Scheme"
+        - $ref: "#/components/parameters/Path"
+        - $ref: "#/components/parameters/Header"
+        - $ref: "#/components/parameters/Body"
+        - $ref: "#/components/parameters/From"
+        - $ref: "#/components/parameters/To"
+        - $ref: "#/components/parameters/Query"
+        - $ref: "#/components/parameters/Saved"
+        - $ref: "#/components/parameters/Cursor"
+        - $ref: "#/components/parameters/Limit"
+        - $ref: "#/components/parameters/Order"
+      responses:
+        "202":
+          description: Result of each submission
+          headers:
+            Link:
+              description: URL of the next page with rel="next", absent on the last page
+              schema:
+                type: string
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  type: object
+                  properties:
+                    request_id: {type: integer}
+                    job: {$ref: "#/components/schemas/ScanJob"}
+                    error: {type: string}
+        "400":
+          $ref: "#/components/responses/Error"
+  /scans/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a scan job with its findings
+      responses:
+        "200":
+          description: Scan job
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScanJob"
+        "404":
+          $ref: "#/components/responses/Error"
+  /scans/{id}/cancel:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Cancel a queued or running scan job
+      responses:
+        "202":
+          description: Cancellation requested
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScanJob"
+        "404":
+          $ref: "#/components/responses/Error"
+        "409":
+          $ref: "#/components/responses/Error"
+  /findings:
+    get:
+      summary: List deduplicated findings
+      parameters:
+        - {name: request_id, in: query, schema: {type: integer}}
+        - {name: endpoint, in: query, schema: {type: string}, description: Substring of the endpoint}
+        - {name: check, in: query, schema: {type: string}}
+        - {name: severity, in: query, schema: {type: string, enum: [high, medium, low, info]}}
+        - {name: confidence, in: query, schema: {type: string, enum: [certain, firm, tentative]}}
+      responses:
+        "200":
+          description: Findings
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Finding"
+        "400":
+          $ref: "#/components/responses/Error"
+  /findings/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a finding
+      responses:
+        "200":
+          description: Finding
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Finding"
+        "404":
+          $ref: "#/components/responses/Error"
+  /queries:
+    get:
+      summary: List saved queries
+      responses:
+        "200":
+          description: Saved queries
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/SavedQuery"
+  /queries/{name}:
+    parameters:
+      - name: name
+        in: path
+        required: true
+        schema:
+          type: string
+          pattern: "^[A-Za-z0-9_.-]{1,64}$"
+    get:
+      summary: Show a saved query
+      responses:
+        "200":
+          description: Saved query
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/SavedQuery"
+        "404":
+          $ref: "#/components/responses/Error"
+    put:
+      summary: Create or replace a saved query
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [query]
+              properties:
+                query: {type: string}
+      responses:
+        "200":
+          description: Replaced
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/SavedQuery"
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/SavedQuery"
+        "400":
+          $ref: "#/components/responses/Error"
+    delete:
+      summary: Delete a saved query
+      responses:
+        "204":
+          description: Deleted
+        "404":
+          $ref: "#/components/responses/Error"
+  /rules:
+    get:
+      summary: List match-and-replace rules
+      responses:
+        "200":
+          description: Rules
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Rule"
+    post:
+      summary: Create a rule
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Rule"
+      responses:
+        "201":
+          description: Created rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Rule"
+        "400":
+          $ref: "#/components/responses/Error"
+  /rules/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a rule
+      responses:
+        "200":
+          description: Rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Rule"
+        "404":
+          $ref: "#/components/responses/Error"
+    put:
+      summary: Update a rule, omitted fields keep their values
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/Rule"
+      responses:
+        "200":
+          description: Updated rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Rule"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+    delete:
+      summary: Delete a rule
+      responses:
+        "204":
+          description: Deleted
+        "404":
+          $ref: "#/components/responses/Error"
+  /scope:
+    get:
+      summary: List scope rules
+      description: >-
+        Without rules everything is in scope. Requests matching an exclude rule
+        are out of scope, and once there is an include rule so is everything
+        matching none of them. Out of scope requests are forwarded but not
+        saved, intercepted or scanned, out of scope hosts are tunneled without
+        decryption.
+      responses:
+        "200":
+          description: Scope rules
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/ScopeRule"
+    post:
+      summary: Create a scope rule
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/ScopeRule"
+      responses:
+        "201":
+          description: Created scope rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScopeRule"
+        "400":
+          $ref: "#/components/responses/Error"
+  /scope/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a scope rule
+      responses:
+        "200":
+          description: Scope rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScopeRule"
+        "404":
+          $ref: "#/components/responses/Error"
+    put:
+      summary: Update a scope rule, omitted fields keep their values
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/ScopeRule"
+      responses:
+        "200":
+          description: Updated scope rule
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ScopeRule"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+    delete:
+      summary: Delete a scope rule
+      responses:
+        "204":
+          description: Deleted
+        "404":
+          $ref: "#/components/responses/Error"
+  /intercept:
+    get:
+      summary: Show intercept settings and held items
+      responses:
+        "200":
+          description: Intercept queue
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  settings:
+                    $ref: "#/components/schemas/InterceptSettings"
+                  items:
+                    type: array
+                    items:
+                      $ref: "#/components/schemas/InterceptedItem"
+  /intercept/settings:
+    get:
+      summary: Show intercept settings
+      responses:
+        "200":
+          description: Settings
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/InterceptSettings"
+    put:
+      summary: Update intercept settings, omitted fields keep their values
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/InterceptSettings"
+      responses:
+        "200":
+          description: Settings
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/InterceptSettings"
+        "400":
+          $ref: "#/components/responses/Error"
+  /intercept/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a held request or response
+      responses:
+        "200":
+          description: Item
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/InterceptedItem"
+        "404":
+          $ref: "#/components/responses/Error"
+  /intercept/{id}/forward:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Forward a held item, optionally edited
+      requestBody:
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                request:
+                  $ref: "#/components/schemas/RequestEdit"
+                response:
+                  $ref: "#/components/schemas/ResponseEdit"
+      responses:
+        "200":
+          $ref: "#/components/responses/Resolved"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+  /intercept/{id}/drop:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Drop a held item
+      responses:
+        "200":
+          $ref: "#/components/responses/Resolved"
+        "404":
+          $ref: "#/components/responses/Error"
+  /har:
+    get:
+      summary: Export requests as HAR 1.2
+      parameters:
+        - name: ids
+          in: query
+          description: Comma separated request ids, all requests matching the filters if omitted
+          schema:
+            type: string
+        - $ref: "#/components/parameters/Host"
+        - $ref: "#/components/parameters/Method"
+        - $ref: "#/components/parameters/Scheme"
+        - $ref: "#/components/parameters/Path"
+        - $ref: "#/components/parameters/Header"
+        - $ref: "#/components/parameters/Body"
+        - $ref: "#/components/parameters/From"
+        - $ref: "#/components/parameters/To"
+        - $ref: "#/components/parameters/Query"
+        - $ref: "#/components/parameters/Saved"
+        - {name: limit, in: query, schema: {type: integer, minimum: 1, maximum: 1000}, description: No limit if omitted}
+      responses:
+        "200":
+          description: HAR document
+          content:
+            application/json:
+              schema:
+                type: object
+        "404":
+          $ref: "#/components/responses/Error"
+    post:
+      summary: Import a HAR file
+      description: All entries are validated before any is stored.
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+      responses:
+        "201":
+          description: Ids of the imported requests
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  ids:
+                    type: array
+                    items:
+                      type: integer
+        "400":
+          $ref: "#/components/responses/Error"
+        "503":
+          description: Storage failed, ids lists the entries imported before the error
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  ids:
+                    type: array
+                    items:
+                      type: integer
+                  error:
+                    type: string
+  /websockets:
+    get:
+      summary: List recorded WebSocket connections
+      responses:
+        "200":
+          description: Connections without their messages
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/WebSocketConnection"
+  /websockets/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a connection with all its messages, id is the handshake request id
+      responses:
+        "200":
+          description: Connection
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/WebSocketConnection"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+  /websockets/messages/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a WebSocket message
+      responses:
+        "200":
+          description: Message
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/WebSocketMessage"
+        "404":
+          $ref: "#/components/responses/Error"
+  /websockets/messages/{id}/resend:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    post:
+      summary: Open a new connection with the stored handshake and send an edited copy of a client message
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/WebSocketEdit"
+      responses:
+        "201":
+          description: New connection with the sent message and replies received within two seconds
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/WebSocketConnection"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+        "502":
+          $ref: "#/components/responses/Error"
+  /tunnels:
+    get:
+      summary: List tunnels relayed without decryption
+      responses:
+        "200":
+          description: Tunnel metadata, recorded when a tunnel is closed
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/Tunnel"
+  /tunnels/{id}:
+    parameters:
+      - $ref: "#/components/parameters/ID"
+    get:
+      summary: Show a tunnel
+      responses:
+        "200":
+          description: Tunnel
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/Tunnel"
+        "400":
+          $ref: "#/components/responses/Error"
+        "404":
+          $ref: "#/components/responses/Error"
+  /users:
+    get:
+      summary: List proxy users
+      responses:
+        "200":
+          description: Users allowed to use the proxy when proxy authentication is enabled
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: "#/components/schemas/ProxyUser"
+  /users/{name}:
+    parameters:
+      - name: name
+        in: path
+        required: true
+        schema:
+          type: string
+          pattern: "^[A-Za-z0-9_.@-]{1,64}$"
+    get:
+      summary: Show a proxy user
+      responses:
+        "200":
+          description: Proxy user
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ProxyUser"
+        "404":
+          $ref: "#/components/responses/Error"
+    put:
+      summary: Create or replace a proxy user
+      description: >-
+        The password is used with Basic Proxy-Authorization and SOCKS5
+        username/password authentication, the token with Bearer
+        Proxy-Authorization. Both are stored hashed.
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              properties:
+                password: {type: string}
+                token: {type: string, minLength: 16}
+      responses:
+        "200":
+          description: Replaced
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ProxyUser"
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/ProxyUser"
+        "400":
+          $ref: "#/components/responses/Error"
+        "409":
+          $ref: "#/components/responses/Error"
+    delete:
+      summary: Delete a proxy user
+      responses:
+        "204":
+          description: Deleted
+        "404":
+          $ref: "#/components/responses/Error"
+components:
+  parameters:
+    ID:
+      name: id
+      in: path
+      required: true
+      schema:
+        type: integer
+    Host: {name: host, in: query, schema: {type: string}, description: Exact host, case-insensitive}
+    Method: {name: method, in: query, schema: {type: string}}
+    Scheme: {name: scheme, in: query, schema: {type: string, enum: [http, https]}}
+    Path: {name: path, in: query, schema: {type: string}, description: Substring of the path}
+    Header: {name: header, in: query, schema: {type: string}, description: Substring of the encoded headers}
+    Body: {name: body, in: query, schema: {type: string}, description: Substring of the body}
+    From: {name: from, in: query, schema: {type: string, format: date-time}, description: Captured at or after}
+    To: {name: to, in: query, schema: {type: string, format: date-time}, description: Captured before}
+    Query:
+      name: q
+      in: query
+      description: >-
+        Query expression, e.g. req.host ~ "api\." and resp.status >= 500.
+        Fields req.id, req.parent_id, req.method, req.scheme, req.proto, req.user, req.host,
+        req.path, req.query, req.headers, req.body, req.time, resp.status, resp.headers,
+        resp.body, resp.length, resp.duration; operators = != ~ !~ contains for
+        strings, = != < <= > >= for numbers and times; and, or, not, parentheses.
+      schema:
+        type: string
+    Saved: {name: saved, in: query, schema: {type: string}, description: Name of a saved query, combined with q using and}
+    Cursor: {name: cursor, in: query, schema: {type: integer}, description: Id of the last request of the previous page}
+    Limit: {name: limit, in: query, schema: {type: integer, minimum: 1, maximum: 1000, default: 100}}
+    Order: {name: order, in: query, schema: {type: string, enum: [asc, desc], default: asc}}
+  responses:
+    Error:
+      description: Error
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              error:
+                type: string
+    Resolved:
+      description: Decision applied
+      content:
+        application/json:
+          schema:
+            type: object
+            properties:
+              id:
+                type: integer
+              action:
+                type: string
+                enum: [forward, drop]
+  schemas:
+    Headers:
+      type: object
+      additionalProperties:
+        type: array
+        items:
+          type: string
+    Request:
+      type: object
+      properties:
+        id: {type: integer}
+        parent_id: {type: integer, description: Request this one was edited from}
+        method: {type: string}
+        url: {type: string}
+        scheme: {type: string}
+        host: {type: string}
+        path: {type: string}
+        proto: {type: string, description: Protocol version the request was captured with, e.g. HTTP/2.0}
+        user: {type: string, description: Proxy user the request was captured for, omitted without proxy authentication}
+        query: {$ref: "#/components/schemas/Headers"}
+        headers: {$ref: "#/components/schemas/Headers"}
+        body: {type: string}
+        body_encoding: {type: string, enum: [base64], description: Set when body is not valid UTF-8}
+        created_at: {type: string, format: date-time}
+    Response:
+      type: object
+      properties:
+        id: {type: integer}
+        request_id: {type: integer}
+        status_code: {type: integer}
+        headers: {$ref: "#/components/schemas/Headers"}
+        body: {type: string}
+        body_encoding: {type: string, enum: [base64]}
+        content_length: {type: integer}
+        truncated: {type: boolean, description: Set when body holds only the first proxy.max_body_size bytes}
+        duration_ms: {type: integer}
+    RequestDetail:
+      allOf:
+        - $ref: "#/components/schemas/Request"
+        - type: object
+          properties:
+            response:
+              nullable: true
+              allOf:
+                - $ref: "#/components/schemas/Response"
+            applied_rules:
+              type: array
+              items:
+                $ref: "#/components/schemas/Rule"
+    Exchange:
+      type: object
+      properties:
+        request: {$ref: "#/components/schemas/Request"}
+        response: {$ref: "#/components/schemas/Response"}
+    RequestEdit:
+      type: object
+      description: Omitted fields are taken from the original request
+      properties:
+        method: {type: string}
+        url: {type: string, description: Absolute URL or path with query}
+        headers: {$ref: "#/components/schemas/Headers"}
+        body: {type: string}
+    ResponseEdit:
+      type: object
+      properties:
+        status_code: {type: integer, minimum: 100, maximum: 999}
+        headers: {$ref: "#/components/schemas/Headers"}
+        body: {type: string}
+    SavedQuery:
+      type: object
+      properties:
+        name: {type: string}
+        query: {type: string}
+        created_at: {type: string, format: date-time}
+        updated_at: {type: string, format: date-time}
+    Rule:
+      type: object
+      properties:
+        id: {type: integer, readOnly: true}
+        enabled: {type: boolean, default: true}
+        target: {type: string, enum: [request_line, request_header, request_body, response_header, response_body]}
+        match: {type: string}
+        replace: {type: string}
+        regex: {type: boolean}
+        host: {type: string, description: Host regexp}
+        path: {type: string, description: Path regexp}
+        method: {type: string}
+    ScopeRule:
+      type: object
+      required: [action, host]
+      properties:
+        id: {type: integer, readOnly: true}
+        enabled: {type: boolean, default: true}
+        action: {type: string, enum: [include, exclude]}
+        host: {type: string, description: "Host glob, e.g. *.example.com, * for any host"}
+        port: {type: integer, description: 0 matches any port}
+        scheme: {type: string, enum: ["", http, https], description: Empty matches any scheme}
+        path: {type: string, description: Path regexp, empty matches any path}
+    ScanJob:
+      type: object
+      properties:
+        id: {type: integer}
+        request_id: {type: integer}
+        status: {type: string, enum: [queued, running, done, failed, canceled]}
+        progress: {type: integer}
+        total: {type: integer}
+        error: {type: string}
+        found: {type: integer}
+        findings:
+          type: array
+          items:
+            $ref: "#/components/schemas/Finding"
+        created_at: {type: string, format: date-time}
+        updated_at: {type: string, format: date-time}
+    Finding:
+      type: object
+      properties:
+        id: {type: integer}
+        request_id: {type: integer}
+        endpoint: {type: string}
+        check: {type: string}
+        parameter: {type: string}
+        payload: {type: string}
+        evidence: {type: string}
+        severity: {type: string, enum: [high, medium, low, info]}
+        confidence: {type: string, enum: [certain, firm, tentative]}
+        created_at: {type: string, format: date-time}
+        last_seen_at: {type: string, format: date-time}
+    InterceptSettings:
+      type: object
+      properties:
+        enabled: {type: boolean}
+        responses: {type: boolean}
+        host: {type: string, description: Host regexp, empty matches all}
+        methods:
+          type: array
+          items:
+            type: string
+    InterceptedItem:
+      type: object
+      properties:
+        id: {type: integer}
+        type: {type: string, enum: [request, response]}
+        request: {$ref: "#/components/schemas/Request"}
+        response: {$ref: "#/components/schemas/Response"}
+        created_at: {type: string, format: date-time}
+    WebSocketMessage:
+      type: object
+      properties:
+        id: {type: integer}
+        request_id: {type: integer, description: Handshake request id}
+        parent_id: {type: integer, description: Message this one was edited from}
+        direction: {type: string, enum: [to_server, to_client]}
+        opcode: {type: string, enum: [continuation, text, binary, close, ping, pong]}
+        payload: {type: string}
+        payload_encoding: {type: string, enum: [base64], description: Set when payload is not valid UTF-8}
+        created_at: {type: string, format: date-time}
+    WebSocketConnection:
+      type: object
+      properties:
+        request: {$ref: "#/components/schemas/Request"}
+        message_count: {type: integer}
+        last_message_at: {type: string, format: date-time}
+        messages:
+          type: array
+          items:
+            $ref: "#/components/schemas/WebSocketMessage"
+    WebSocketEdit:
+      type: object
+      description: Omitted fields are taken from the original message
+      properties:
+        opcode: {type: string, enum: [text, binary]}
+        payload: {type: string}
+        payload_encoding: {type: string, enum: [base64]}
+    Tunnel:
+      type: object
+      properties:
+        id: {type: integer}
+        host: {type: string}
+        port: {type: integer}
+        sni: {type: string}
+        reason: {type: string, enum: [host, handshake], description: "host - matched passthrough.hosts, handshake - the client rejected the generated certificate earlier"}
+        user: {type: string}
+        bytes_sent: {type: integer, description: Bytes sent by the client}
+        bytes_received: {type: integer, description: Bytes sent by the server}
+        duration: {type: integer, description: Milliseconds}
+        created_at: {type: string, format: date-time}
+    ProxyUser:
+      type: object
+      properties:
+        name: {type: string}
+        has_password: {type: boolean}
+        has_token: {type: boolean}
+        created_at: {type: string, format: date-time}
+        updated_at: {type: string, format: date-time}
+`
@@ -0,0 +1,496 @@
+package delivery
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+const encodingBase64 = "base64"
+
+type requestJSON struct {
+	ID           int         `json:"id"`
+	ParentID     int         `json:"parent_id,omitempty"`
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	Scheme       string      `json:"scheme"`
+	Host         string      `json:"host"`
+	Path         string      `json:"path"`
+	Proto        string      `json:"proto"`
+	User         string      `json:"user,omitempty"`
+	Query        url.Values  `json:"query"`
+	Headers      http.Header `json:"headers"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+type responseJSON struct {
+	ID            int         `json:"id,omitempty"`
+	RequestID     int         `json:"request_id,omitempty"`
+	StatusCode    int         `json:"status_code"`
+	Headers       http.Header `json:"headers"`
+	Body          string      `json:"body"`
+	BodyEncoding  string      `json:"body_encoding,omitempty"`
+	ContentLength int64       `json:"content_length"`
+	Truncated     bool        `json:"truncated"`
+	DurationMs    int64       `json:"duration_ms"`
+}
+
+type requestDetailJSON struct {
+	requestJSON
+	Response     *responseJSON `json:"response"`
+	AppliedRules []ruleJSON    `json:"applied_rules"`
+}
+
+type exchangeJSON struct {
+	Request  requestJSON  `json:"request"`
+	Response responseJSON `json:"response"`
+}
+
+type repeatResultJSON struct {
+	RequestID int           `json:"request_id"`
+	Response  *responseJSON `json:"response,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+type scanResultJSON struct {
+	RequestID int      `json:"request_id"`
+	Job       *jobJSON `json:"job,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type savedQueryJSON struct {
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type userJSON struct {
+	Name        string    `json:"name"`
+	HasPassword bool      `json:"has_password"`
+	HasToken    bool      `json:"has_token"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ruleJSON struct {
+	ID      int    `json:"id"`
+	Enabled bool   `json:"enabled"`
+	Target  string `json:"target"`
+	Match   string `json:"match"`
+	Replace string `json:"replace"`
+	Regex   bool   `json:"regex"`
+	Host    string `json:"host"`
+	Path    string `json:"path"`
+	Method  string `json:"method"`
+}
+
+type scopeRuleJSON struct {
+	ID      int    `json:"id"`
+	Enabled bool   `json:"enabled"`
+	Action  string `json:"action"`
+	Host    string `json:"host"`
+	Port    int    `json:"port"`
+	Scheme  string `json:"scheme"`
+	Path    string `json:"path"`
+}
+
+type tunnelJSON struct {
+	ID            int       `json:"id"`
+	Host          string    `json:"host"`
+	Port          int       `json:"port"`
+	SNI           string    `json:"sni,omitempty"`
+	Reason        string    `json:"reason"`
+	User          string    `json:"user,omitempty"`
+	BytesSent     int64     `json:"bytes_sent"`
+	BytesReceived int64     `json:"bytes_received"`
+	Duration      int64     `json:"duration"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type jobJSON struct {
+	ID        int           `json:"id"`
+	RequestID int           `json:"request_id"`
+	Status    string        `json:"status"`
+	Progress  int           `json:"progress"`
+	Total     int           `json:"total"`
+	Error     string        `json:"error,omitempty"`
+	Found     int           `json:"found"`
+	Findings  []findingJSON `json:"findings,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+type findingJSON struct {
+	ID         int       `json:"id"`
+	RequestID  int       `json:"request_id"`
+	Endpoint   string    `json:"endpoint"`
+	Check      string    `json:"check"`
+	Parameter  string    `json:"parameter"`
+	Payload    string    `json:"payload"`
+	Evidence   string    `json:"evidence"`
+	Severity   string    `json:"severity"`
+	Confidence string    `json:"confidence"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+}
+
+type settingsJSON struct {
+	Enabled   bool     `json:"enabled"`
+	Responses bool     `json:"responses"`
+	Host      string   `json:"host"`
+	Methods   []string `json:"methods"`
+}
+
+type queueJSON struct {
+	Settings settingsJSON `json:"settings"`
+	Items    []itemJSON   `json:"items"`
+}
+
+type itemJSON struct {
+	ID        int           `json:"id"`
+	Type      string        `json:"type"`
+	Request   requestJSON   `json:"request"`
+	Response  *responseJSON `json:"response,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type forwardJSON struct {
+	Request  *models.RequestEdit `json:"request"`
+	Response *responseEditJSON   `json:"response"`
+}
+
+type responseEditJSON struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Body       *string     `json:"body"`
+}
+
+type messageJSON struct {
+	ID              int       `json:"id"`
+	RequestID       int       `json:"request_id"`
+	ParentID        int       `json:"parent_id,omitempty"`
+	Direction       string    `json:"direction"`
+	Opcode          string    `json:"opcode"`
+	Payload         string    `json:"payload"`
+	PayloadEncoding string    `json:"payload_encoding,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type connectionJSON struct {
+	Request       requestJSON   `json:"request"`
+	MessageCount  int           `json:"message_count"`
+	LastMessageAt time.Time     `json:"last_message_at"`
+	Messages      []messageJSON `json:"messages,omitempty"`
+}
+
+type messageEditJSON struct {
+	Opcode          string  `json:"opcode"`
+	Payload         *string `json:"payload"`
+	PayloadEncoding string  `json:"payload_encoding"`
+}
+
+type importJSON struct {
+	IDs   []int  `json:"ids"`
+	Error string `json:"error,omitempty"`
+}
+
+func newRequestJSON(req models.Request) requestJSON {
+	result := requestJSON{
+		ID:        req.ID,
+		ParentID:  req.ParentID,
+		Method:    req.Method,
+		Scheme:    req.Scheme,
+		Host:      req.Host,
+		Path:      req.Path,
+		Proto:     req.Proto,
+		User:      req.User,
+		Query:     url.Values{},
+		Headers:   http.Header{},
+		CreatedAt: req.CreatedAt,
+	}
+	result.Body, result.BodyEncoding = encodeBody(req.Body)
+
+	_ = json.Unmarshal([]byte(req.Params), &result.Query)
+	_ = json.Unmarshal([]byte(req.Headers), &result.Headers)
+
+	u := url.URL{
+		Scheme:   req.Scheme,
+		Host:     req.Host,
+		Path:     req.Path,
+		RawQuery: result.Query.Encode(),
+	}
+	result.URL = u.String()
+
+	return result
+}
+
+func newResponseJSON(resp models.Response) responseJSON {
+	result := responseJSON{
+		ID:            resp.ID,
+		RequestID:     resp.RequestID,
+		StatusCode:    resp.StatusCode,
+		Headers:       http.Header{},
+		ContentLength: resp.ContentLength,
+		Truncated:     resp.Truncated,
+		DurationMs:    resp.Duration,
+	}
+	result.Body, result.BodyEncoding = encodeBody(resp.Body)
+
+	_ = json.Unmarshal([]byte(resp.Headers), &result.Headers)
+
+	return result
+}
+
+func newSavedQueryJSON(q models.SavedQuery) savedQueryJSON {
+	return savedQueryJSON{
+		Name:      q.Name,
+		Query:     q.Query,
+		CreatedAt: q.CreatedAt,
+		UpdatedAt: q.UpdatedAt,
+	}
+}
+
+func newUserJSON(user models.ProxyUser) userJSON {
+	return userJSON{
+		Name:        user.Name,
+		HasPassword: user.PasswordHash != "",
+		HasToken:    user.TokenHash != "",
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+func newRuleJSON(rule models.Rule) ruleJSON {
+	return ruleJSON{
+		ID:      rule.ID,
+		Enabled: rule.Enabled,
+		Target:  rule.Target,
+		Match:   rule.Match,
+		Replace: rule.Replace,
+		Regex:   rule.IsRegex,
+		Host:    rule.Host,
+		Path:    rule.Path,
+		Method:  rule.Method,
+	}
+}
+
+func (r ruleJSON) toModel() models.Rule {
+	return models.Rule{
+		ID:      r.ID,
+		Enabled: r.Enabled,
+		Target:  r.Target,
+		Match:   r.Match,
+		Replace: r.Replace,
+		IsRegex: r.Regex,
+		Host:    r.Host,
+		Path:    r.Path,
+		Method:  r.Method,
+	}
+}
+
+func newTunnelJSON(tunnel models.Tunnel) tunnelJSON {
+	return tunnelJSON{
+		ID:            tunnel.ID,
+		Host:          tunnel.Host,
+		Port:          tunnel.Port,
+		SNI:           tunnel.SNI,
+		Reason:        tunnel.Reason,
+		User:          tunnel.User,
+		BytesSent:     tunnel.BytesSent,
+		BytesReceived: tunnel.BytesReceived,
+		Duration:      tunnel.Duration,
+		CreatedAt:     tunnel.CreatedAt,
+	}
+}
+
+func newScopeRuleJSON(rule models.ScopeRule) scopeRuleJSON {
+	return scopeRuleJSON{
+		ID:      rule.ID,
+		Enabled: rule.Enabled,
+		Action:  rule.Action,
+		Host:    rule.Host,
+		Port:    rule.Port,
+		Scheme:  rule.Scheme,
+		Path:    rule.Path,
+	}
+}
+
+func (r scopeRuleJSON) toModel() models.ScopeRule {
+	return models.ScopeRule{
+		ID:      r.ID,
+		Enabled: r.Enabled,
+		Action:  r.Action,
+		Host:    r.Host,
+		Port:    r.Port,
+		Scheme:  r.Scheme,
+		Path:    r.Path,
+	}
+}
+
+func newJobJSON(job models.ScanJob) jobJSON {
+	result := jobJSON{
+		ID:        job.ID,
+		RequestID: job.RequestID,
+		Status:    job.Status,
+		Progress:  job.Progress,
+		Total:     job.Total,
+		Error:     job.Error,
+		Found:     job.Found,
+		CreatedAt: job.CreatedAt,
+		UpdatedAt: job.UpdatedAt,
+	}
+
+	for _, finding := range job.Findings {
+		result.Findings = append(result.Findings, newFindingJSON(finding))
+	}
+
+	return result
+}
+
+func newFindingJSON(finding models.Finding) findingJSON {
+	return findingJSON{
+		ID:         finding.ID,
+		RequestID:  finding.RequestID,
+		Endpoint:   finding.Endpoint,
+		Check:      finding.Check,
+		Parameter:  finding.Parameter,
+		Payload:    finding.Payload,
+		Evidence:   finding.Evidence,
+		Severity:   finding.Severity,
+		Confidence: finding.Confidence,
+		CreatedAt:  finding.CreatedAt,
+		LastSeenAt: finding.LastSeenAt,
+	}
+}
+
+func newSettingsJSON(settings models.InterceptSettings) settingsJSON {
+	result := settingsJSON{
+		Enabled:   settings.Enabled,
+		Responses: settings.Responses,
+		Host:      settings.Host,
+		Methods:   settings.Methods,
+	}
+	if result.Methods == nil {
+		result.Methods = make([]string, 0)
+	}
+
+	return result
+}
+
+func (s settingsJSON) toModel() models.InterceptSettings {
+	return models.InterceptSettings{
+		Enabled:   s.Enabled,
+		Responses: s.Responses,
+		Host:      s.Host,
+		Methods:   s.Methods,
+	}
+}
+
+func newItemJSON(item models.InterceptedItem) itemJSON {
+	result := itemJSON{
+		ID:        item.ID,
+		Type:      item.Type,
+		Request:   newRequestJSON(item.Request),
+		CreatedAt: item.CreatedAt,
+	}
+
+	if item.Type == models.InterceptResponse {
+		response := newResponseJSON(item.Response)
+		result.Response = &response
+	}
+
+	return result
+}
+
+func newMessageJSON(msg models.WebSocketMessage) messageJSON {
+	result := messageJSON{
+		ID:        msg.ID,
+		RequestID: msg.RequestID,
+		ParentID:  msg.ParentID,
+		Direction: msg.Direction,
+		Opcode:    models.OpcodeName(msg.Opcode),
+		CreatedAt: msg.CreatedAt,
+	}
+	result.Payload, result.PayloadEncoding = encodeBody(msg.Payload)
+
+	return result
+}
+
+func newConnectionJSON(conn models.WebSocketConnection) connectionJSON {
+	result := connectionJSON{
+		Request:       newRequestJSON(conn.Request),
+		MessageCount:  conn.MessageCount,
+		LastMessageAt: conn.LastMessageAt,
+	}
+
+	for _, msg := range conn.Messages {
+		result.Messages = append(result.Messages, newMessageJSON(msg))
+	}
+
+	return result
+}
+
+func (e messageEditJSON) toModel() (models.WebSocketEdit, error) {
+	edit := models.WebSocketEdit{Opcode: e.Opcode}
+	if e.Payload == nil {
+		return edit, nil
+	}
+
+	switch e.PayloadEncoding {
+	case "":
+		edit.Payload = e.Payload
+	case encodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(*e.Payload)
+		if err != nil {
+			return models.WebSocketEdit{}, err
+		}
+		payload := string(decoded)
+		edit.Payload = &payload
+	default:
+		return models.WebSocketEdit{}, fmt.Errorf("unknown payload encoding %q", e.PayloadEncoding)
+	}
+
+	return edit, nil
+}
+
+func (e responseEditJSON) apply(resp models.Response) (models.Response, error) {
+	if e.StatusCode != 0 {
+		resp.StatusCode = e.StatusCode
+	}
+
+	if e.Headers != nil {
+		encodedHeaders, err := json.Marshal(e.Headers)
+		if err != nil {
+			return models.Response{}, err
+		}
+		resp.Headers = string(encodedHeaders)
+	}
+
+	if e.Body != nil {
+		resp.Body = *e.Body
+		resp.ContentLength = int64(len(resp.Body))
+	}
+
+	if err := resp.Validate(); err != nil {
+		return models.Response{}, err
+	}
+
+	return resp, nil
+}
+
+func encodeBody(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte(body)), encodingBase64
+}
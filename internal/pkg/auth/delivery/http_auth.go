@@ -0,0 +1,52 @@
+package delivery
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	AuthUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+)
+
+type ProxyAuthHandler struct {
+	authUsecase interfaces.Usecase
+	realm       string
+}
+
+func NewProxyAuthHandler(authUsecase interfaces.Usecase, realm string) ProxyAuthHandler {
+	return ProxyAuthHandler{
+		authUsecase: authUsecase,
+		realm:       realm,
+	}
+}
+
+// Authenticate returns the request carrying the proxy user, or answers it
+// with 407 and returns false.
+func (h ProxyAuthHandler) Authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if !h.authUsecase.Enabled() {
+		return r, true
+	}
+
+	authorization := r.Header.Get("Proxy-Authorization")
+	r.Header.Del("Proxy-Authorization")
+
+	user, err := h.authUsecase.Authenticate(authorization)
+	if err == AuthUsecase.ErrUnauthorized {
+		if authorization != "" {
+			log.Printf("proxy authentication failed for %s", r.RemoteAddr)
+		}
+
+		w.Header().Set("Proxy-Authenticate", "Basic realm="+strconv.Quote(h.realm))
+		w.Header().Add("Proxy-Authenticate", "Bearer realm="+strconv.Quote(h.realm))
+		http.Error(w, err.Error(), http.StatusProxyAuthRequired)
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("couldn't authenticate proxy user: %v", err)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return nil, false
+	}
+
+	return r.WithContext(AuthUsecase.WithUser(r.Context(), user.Name)), true
+}
@@ -0,0 +1,68 @@
+package delivery
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/repository"
+	AuthUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+func TestProxyAuthenticate(t *testing.T) {
+	enabled := AuthUsecase.NewAuthUsecase(repository.NewMemoryRepository(), true)
+	if _, err := enabled.SaveUser(models.ProxyUserEdit{Name: "alice", Token: "0123456789abcdef"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		enabled       bool
+		authorization string
+		user          string
+		status        int
+	}{
+		{name: "disabled", authorization: "Bearer whatever", status: http.StatusOK},
+		{name: "missing", enabled: true, status: http.StatusProxyAuthRequired},
+		{name: "wrong", enabled: true, authorization: "Bearer 0000000000000000", status: http.StatusProxyAuthRequired},
+		{name: "valid", enabled: true, authorization: "Bearer 0123456789abcdef", user: "alice", status: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := AuthUsecase.NewAuthUsecase(repository.NewMemoryRepository(), false)
+			if tt.enabled {
+				usecase = enabled
+			}
+			h := NewProxyAuthHandler(usecase, "team proxy")
+
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Proxy-Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			authenticated, ok := h.Authenticate(w, r)
+			if ok != (tt.status == http.StatusOK) || w.Code != tt.status {
+				t.Fatalf("Authenticate() = %v with status %d, want %d", ok, w.Code, tt.status)
+			}
+
+			if !ok {
+				challenges := w.Header()["Proxy-Authenticate"]
+				if len(challenges) != 2 || challenges[0] != `Basic realm="team proxy"` || challenges[1] != `Bearer realm="team proxy"` {
+					t.Fatalf("Proxy-Authenticate = %q", challenges)
+				}
+				return
+			}
+
+			if user := AuthUsecase.UserFromContext(authenticated.Context()); user != tt.user {
+				t.Fatalf("user = %q, want %q", user, tt.user)
+			}
+			// credentials for the proxy mustn't reach the target
+			if tt.enabled && authenticated.Header.Get("Proxy-Authorization") != "" {
+				t.Fatal("Proxy-Authorization was forwarded")
+			}
+		})
+	}
+}
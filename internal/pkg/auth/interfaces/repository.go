@@ -0,0 +1,11 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	SaveUser(user models.ProxyUser) (int, error)
+	GetUsers() ([]models.ProxyUser, error)
+	GetUser(name string) (models.ProxyUser, error)
+	GetUserByToken(tokenHash string) (models.ProxyUser, error)
+	DeleteUser(name string) error
+}
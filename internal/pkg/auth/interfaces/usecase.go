@@ -0,0 +1,14 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	Enabled() bool
+	SaveUser(edit models.ProxyUserEdit) (models.ProxyUser, error)
+	GetUsers() ([]models.ProxyUser, error)
+	GetUser(name string) (models.ProxyUser, error)
+	DeleteUser(name string) error
+	LoadUsers(path string) error
+	Authenticate(authorization string) (models.ProxyUser, error)
+	AuthenticatePassword(name, password string) (models.ProxyUser, error)
+}
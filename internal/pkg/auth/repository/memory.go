@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	users  map[string]models.ProxyUser
+	nextID int
+}
+
+func NewMemoryRepository() interfaces.Repository {
+	return &MemoryRepository{
+		users:  make(map[string]models.ProxyUser),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryRepository) SaveUser(user models.ProxyUser) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.users[user.Name]; ok {
+		user.ID = existing.ID
+		user.CreatedAt = existing.CreatedAt
+	} else {
+		user.ID = r.nextID
+		r.nextID++
+	}
+	r.users[user.Name] = user
+
+	return user.ID, nil
+}
+
+func (r *MemoryRepository) GetUsers() ([]models.ProxyUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]models.ProxyUser, 0, len(r.users))
+	for _, user := range r.users {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Name < users[j].Name
+	})
+
+	return users, nil
+}
+
+func (r *MemoryRepository) GetUser(name string) (models.ProxyUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[name]
+	if !ok {
+		return models.ProxyUser{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+func (r *MemoryRepository) GetUserByToken(tokenHash string) (models.ProxyUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.TokenHash != "" && user.TokenHash == tokenHash {
+			return user, nil
+		}
+	}
+
+	return models.ProxyUser{}, sql.ErrNoRows
+}
+
+func (r *MemoryRepository) DeleteUser(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[name]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.users, name)
+
+	return nil
+}
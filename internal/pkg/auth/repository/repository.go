@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS proxy_users (
+    id SERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS proxy_users_token_hash_idx ON proxy_users (token_hash) WHERE token_hash <> '';
+`
+
+const userColumns = `id, name, password_hash, token_hash, created_at, updated_at`
+
+type AuthRepository struct {
+	db *sql.DB
+}
+
+func NewAuthRepository(db *sql.DB) interfaces.Repository {
+	return AuthRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r AuthRepository) SaveUser(user models.ProxyUser) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO proxy_users (name, password_hash, token_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET password_hash = excluded.password_hash,
+		token_hash = excluded.token_hash, updated_at = excluded.updated_at
+		RETURNING id`,
+		user.Name, user.PasswordHash, user.TokenHash, user.CreatedAt, user.UpdatedAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r AuthRepository) GetUsers() ([]models.ProxyUser, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + userColumns + ` FROM proxy_users
+		ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.ProxyUser, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r AuthRepository) GetUser(name string) (models.ProxyUser, error) {
+	row := r.db.QueryRow(
+		`SELECT `+userColumns+` FROM proxy_users
+		WHERE name = $1`,
+		name,
+	)
+
+	return scanUser(row)
+}
+
+func (r AuthRepository) GetUserByToken(tokenHash string) (models.ProxyUser, error) {
+	row := r.db.QueryRow(
+		`SELECT `+userColumns+` FROM proxy_users
+		WHERE token_hash = $1`,
+		tokenHash,
+	)
+
+	return scanUser(row)
+}
+
+func (r AuthRepository) DeleteUser(name string) error {
+	result, err := r.db.Exec(`DELETE FROM proxy_users WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row scanner) (models.ProxyUser, error) {
+	var user models.ProxyUser
+	err := row.Scan(&user.ID, &user.Name, &user.PasswordHash, &user.TokenHash, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		return models.ProxyUser{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS proxy_users (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    password_hash TEXT NOT NULL,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS proxy_users_token_hash_idx ON proxy_users (token_hash) WHERE token_hash <> '';
+`
+
+type SqliteRepository struct {
+	AuthRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		AuthRepository: AuthRepository{
+			db: db,
+		},
+	}, nil
+}
@@ -0,0 +1,298 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	passwordScheme = "sha256"
+	passwordRounds = 10000
+	saltSize       = 16
+
+	cacheTTL  = time.Minute
+	cacheSize = 1024
+)
+
+var (
+	ErrUnauthorized = errors.New("proxy authentication required")
+	ErrTokenTaken   = errors.New("token is already used by another user")
+)
+
+type userKey struct{}
+
+// WithUser marks everything captured under ctx as sent by the proxy user.
+func WithUser(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, userKey{}, name)
+}
+
+func UserFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(userKey{}).(string)
+	return name
+}
+
+type cachedUser struct {
+	user    models.ProxyUser
+	expires time.Time
+}
+
+type AuthUsecase struct {
+	authRepository interfaces.Repository
+	enabled        bool
+
+	mu    sync.Mutex
+	cache map[string]cachedUser
+}
+
+func NewAuthUsecase(authRepository interfaces.Repository, enabled bool) interfaces.Usecase {
+	return &AuthUsecase{
+		authRepository: authRepository,
+		enabled:        enabled,
+		cache:          make(map[string]cachedUser),
+	}
+}
+
+func (u *AuthUsecase) Enabled() bool {
+	return u.enabled
+}
+
+func (u *AuthUsecase) SaveUser(edit models.ProxyUserEdit) (models.ProxyUser, error) {
+	if err := edit.Validate(); err != nil {
+		return models.ProxyUser{}, err
+	}
+
+	user := models.ProxyUser{
+		Name: edit.Name,
+	}
+
+	if edit.Password != "" {
+		hash, err := hashPassword(edit.Password)
+		if err != nil {
+			return models.ProxyUser{}, err
+		}
+		user.PasswordHash = hash
+	}
+
+	if edit.Token != "" {
+		user.TokenHash = hashToken(edit.Token)
+
+		owner, err := u.authRepository.GetUserByToken(user.TokenHash)
+		switch {
+		case err == nil && owner.Name != user.Name:
+			return models.ProxyUser{}, ErrTokenTaken
+		case err != nil && err != sql.ErrNoRows:
+			return models.ProxyUser{}, err
+		}
+	}
+
+	now := time.Now().UTC()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	defer u.invalidate()
+
+	if _, err := u.authRepository.SaveUser(user); err != nil {
+		return models.ProxyUser{}, err
+	}
+
+	return u.authRepository.GetUser(user.Name)
+}
+
+func (u *AuthUsecase) GetUsers() ([]models.ProxyUser, error) {
+	return u.authRepository.GetUsers()
+}
+
+func (u *AuthUsecase) GetUser(name string) (models.ProxyUser, error) {
+	return u.authRepository.GetUser(name)
+}
+
+func (u *AuthUsecase) DeleteUser(name string) error {
+	defer u.invalidate()
+	return u.authRepository.DeleteUser(name)
+}
+
+// LoadUsers saves users listed in a YAML file, replacing stored users with
+// the same name.
+func (u *AuthUsecase) LoadUsers(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var edits []models.ProxyUserEdit
+	if err := yaml.UnmarshalStrict(data, &edits); err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+
+	for i, edit := range edits {
+		if _, err := u.SaveUser(edit); err != nil {
+			return fmt.Errorf("%s: user %d: %w", path, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (u *AuthUsecase) Authenticate(authorization string) (models.ProxyUser, error) {
+	key := hashToken(authorization)
+	if user, ok := u.cached(key); ok {
+		return user, nil
+	}
+
+	scheme, credentials := authorization, ""
+	if i := strings.IndexByte(authorization, ' '); i != -1 {
+		scheme, credentials = authorization[:i], strings.TrimSpace(authorization[i+1:])
+	}
+
+	var user models.ProxyUser
+	var err error
+	switch {
+	case strings.EqualFold(scheme, "Basic"):
+		decoded, decodeErr := base64.StdEncoding.DecodeString(credentials)
+		if decodeErr != nil {
+			return models.ProxyUser{}, ErrUnauthorized
+		}
+
+		name, password, ok := cut(string(decoded), ":")
+		if !ok {
+			return models.ProxyUser{}, ErrUnauthorized
+		}
+
+		user, err = u.AuthenticatePassword(name, password)
+	case strings.EqualFold(scheme, "Bearer") && credentials != "":
+		user, err = u.authRepository.GetUserByToken(hashToken(credentials))
+		if err == sql.ErrNoRows {
+			err = ErrUnauthorized
+		}
+	default:
+		err = ErrUnauthorized
+	}
+	if err != nil {
+		return models.ProxyUser{}, err
+	}
+
+	u.store(key, user)
+	return user, nil
+}
+
+func (u *AuthUsecase) AuthenticatePassword(name, password string) (models.ProxyUser, error) {
+	user, err := u.authRepository.GetUser(name)
+	if err == sql.ErrNoRows {
+		return models.ProxyUser{}, ErrUnauthorized
+	}
+	if err != nil {
+		return models.ProxyUser{}, err
+	}
+
+	if user.PasswordHash == "" || !checkPassword(user.PasswordHash, password) {
+		return models.ProxyUser{}, ErrUnauthorized
+	}
+
+	return user, nil
+}
+
+func (u *AuthUsecase) cached(key string) (models.ProxyUser, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	entry, ok := u.cache[key]
+	if !ok || time.Now().After(entry.expires) {
+		return models.ProxyUser{}, false
+	}
+
+	return entry.user, true
+}
+
+func (u *AuthUsecase) store(key string, user models.ProxyUser) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.cache) >= cacheSize {
+		u.cache = make(map[string]cachedUser)
+	}
+	u.cache[key] = cachedUser{user: user, expires: time.Now().Add(cacheTTL)}
+}
+
+func (u *AuthUsecase) invalidate() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.cache = make(map[string]cachedUser)
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordRounds),
+		hex.EncodeToString(salt),
+		hex.EncodeToString(stretch(salt, password, passwordRounds)),
+	}, "$"), nil
+}
+
+func checkPassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+
+	rounds, err := strconv.Atoi(parts[1])
+	if err != nil || rounds < 1 {
+		return false
+	}
+
+	salt, err := hex.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare(stretch(salt, password, rounds), expected) == 1
+}
+
+func stretch(salt []byte, password string, rounds int) []byte {
+	sum := sha256.Sum256(append(append([]byte{}, salt...), password...))
+	for i := 1; i < rounds; i++ {
+		sum = sha256.Sum256(sum[:])
+	}
+
+	return sum[:]
+}
+
+// hashToken is unsalted so that tokens can be looked up by their hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func cut(s, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i != -1 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
@@ -0,0 +1,169 @@
+package usecase
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/repository"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+func basic(name, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(name+":"+password))
+}
+
+func TestAuthenticate(t *testing.T) {
+	u := NewAuthUsecase(repository.NewMemoryRepository(), true)
+
+	users := []models.ProxyUserEdit{
+		{Name: "alice", Password: "secret"},
+		{Name: "bot", Token: "0123456789abcdef"},
+		{Name: "carol", Password: "pa:ss", Token: "fedcba9876543210"},
+	}
+	for _, edit := range users {
+		if _, err := u.SaveUser(edit); err != nil {
+			t.Fatalf("SaveUser(%s) error = %v", edit.Name, err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		user          string
+	}{
+		{name: "basic", authorization: basic("alice", "secret"), user: "alice"},
+		{name: "lowercase scheme", authorization: "basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret")), user: "alice"},
+		{name: "password with colon", authorization: basic("carol", "pa:ss"), user: "carol"},
+		{name: "bearer", authorization: "Bearer 0123456789abcdef", user: "bot"},
+		{name: "bearer of a user with both", authorization: "Bearer fedcba9876543210", user: "carol"},
+		{name: "wrong password", authorization: basic("alice", "guess")},
+		{name: "unknown user", authorization: basic("mallory", "secret")},
+		{name: "token as password", authorization: basic("bot", "0123456789abcdef")},
+		{name: "unknown token", authorization: "Bearer 0000000000000000"},
+		{name: "empty bearer", authorization: "Bearer "},
+		{name: "malformed basic", authorization: "Basic !!!"},
+		{name: "basic without colon", authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("alice"))},
+		{name: "other scheme", authorization: "Digest username=alice"},
+		{name: "missing", authorization: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the second call is answered from the cache
+			for i := 0; i < 2; i++ {
+				user, err := u.Authenticate(tt.authorization)
+				if tt.user == "" {
+					if err != ErrUnauthorized {
+						t.Fatalf("Authenticate() = %+v, %v, want %v", user, err, ErrUnauthorized)
+					}
+					continue
+				}
+
+				if err != nil || user.Name != tt.user {
+					t.Fatalf("Authenticate() = %+v, %v, want %s", user, err, tt.user)
+				}
+			}
+		})
+	}
+
+	if _, err := u.SaveUser(models.ProxyUserEdit{Name: "dave", Token: "0123456789abcdef"}); err != ErrTokenTaken {
+		t.Fatalf("SaveUser() with a taken token error = %v, want %v", err, ErrTokenTaken)
+	}
+
+	// changing or deleting a user takes effect despite the cache
+	if _, err := u.SaveUser(models.ProxyUserEdit{Name: "alice", Password: "changed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Authenticate(basic("alice", "secret")); err != ErrUnauthorized {
+		t.Fatalf("Authenticate() with the old password error = %v, want %v", err, ErrUnauthorized)
+	}
+	if err := u.DeleteUser("bot"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Authenticate("Bearer 0123456789abcdef"); err != ErrUnauthorized {
+		t.Fatalf("Authenticate() of a deleted user error = %v, want %v", err, ErrUnauthorized)
+	}
+}
+
+func TestSaveUserStoresHashes(t *testing.T) {
+	u := NewAuthUsecase(repository.NewMemoryRepository(), true)
+
+	user, err := u.SaveUser(models.ProxyUserEdit{Name: "alice", Password: "secret", Token: "0123456789abcdef"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(user.PasswordHash, "secret") || !strings.HasPrefix(user.PasswordHash, passwordScheme+"$") ||
+		user.TokenHash != hashToken("0123456789abcdef") {
+		t.Fatalf("SaveUser() = %+v", user)
+	}
+
+	again, err := u.SaveUser(models.ProxyUserEdit{Name: "bob", Password: "secret"})
+	if err != nil || again.PasswordHash == user.PasswordHash {
+		t.Fatalf("SaveUser() of the same password = %q, %v, want another salt", again.PasswordHash, err)
+	}
+
+	invalid := []models.ProxyUserEdit{
+		{Name: "", Password: "secret"},
+		{Name: "with space", Password: "secret"},
+		{Name: "alice"},
+		{Name: "alice", Token: "short"},
+	}
+	for _, edit := range invalid {
+		if _, err := u.SaveUser(edit); err == nil {
+			t.Errorf("SaveUser(%+v) error = nil", edit)
+		}
+	}
+}
+
+func TestLoadUsers(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		users []string
+		err   string
+	}{
+		{
+			name:  "users",
+			file:  "- name: alice\n  password: secret\n- name: bot\n  token: 0123456789abcdef\n",
+			users: []string{"alice", "bot"},
+		},
+		{name: "unknown field", file: "- name: alice\n  pasword: secret\n", err: "couldn't parse"},
+		{name: "invalid user", file: "- name: alice\n  password: secret\n- name: bob\n", err: "user 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "users.yaml")
+			if err := ioutil.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			u := NewAuthUsecase(repository.NewMemoryRepository(), true)
+			err := u.LoadUsers(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadUsers() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadUsers() error = %v", err)
+			}
+
+			for _, name := range tt.users {
+				if _, err := u.GetUser(name); err != nil {
+					t.Errorf("GetUser(%s) error = %v", name, err)
+				}
+			}
+		})
+	}
+
+	u := NewAuthUsecase(repository.NewMemoryRepository(), true)
+	if err := u.LoadUsers(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("LoadUsers() of a missing file error = nil")
+	}
+}
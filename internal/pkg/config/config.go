@@ -0,0 +1,453 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aanufriev/httpproxy/pkg/cert"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	StoragePostgres = "postgres"
+	StorageSqlite   = "sqlite"
+	StorageMemory   = "memory"
+
+	envPrefix     = "HTTPPROXY_"
+	envConfigFile = envPrefix + "CONFIG"
+)
+
+type Config struct {
+	Proxy       ProxyConfig       `yaml:"proxy"`
+	Repeater    RepeaterConfig    `yaml:"repeater"`
+	Storage     StorageConfig     `yaml:"storage"`
+	Cert        CertConfig        `yaml:"cert"`
+	Intercept   InterceptConfig   `yaml:"intercept"`
+	Scanner     ScannerConfig     `yaml:"scanner"`
+	Upstream    UpstreamConfig    `yaml:"upstream"`
+	Auth        AuthConfig        `yaml:"auth"`
+	Passthrough PassthroughConfig `yaml:"passthrough"`
+
+	PrintConfig bool     `yaml:"-"`
+	Args        []string `yaml:"-"`
+}
+
+type ProxyConfig struct {
+	Addr            string        `yaml:"addr"`
+	SocksAddr       string        `yaml:"socks_addr"`
+	TransparentAddr string        `yaml:"transparent_addr"`
+	CertHost        string        `yaml:"cert_host"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	ClientTimeout   time.Duration `yaml:"client_timeout"`
+	MaxBodySize     int64         `yaml:"max_body_size"`
+}
+
+type RepeaterConfig struct {
+	Addr          string        `yaml:"addr"`
+	Payloads      string        `yaml:"payloads"`
+	ClientTimeout time.Duration `yaml:"client_timeout"`
+}
+
+type StorageConfig struct {
+	Driver         string `yaml:"driver"`
+	PostgresDSN    string `yaml:"postgres_dsn"`
+	SqlitePath     string `yaml:"sqlite_path"`
+	MemoryCapacity int    `yaml:"memory_capacity"`
+}
+
+type InterceptConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	Responses bool          `yaml:"responses"`
+	Host      string        `yaml:"host"`
+	Methods   []string      `yaml:"methods"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+type ScannerConfig struct {
+	Workers   int     `yaml:"workers"`
+	QueueSize int     `yaml:"queue_size"`
+	RateLimit float64 `yaml:"rate_limit"`
+}
+
+type UpstreamConfig struct {
+	URL    string   `yaml:"url"`
+	Bypass []string `yaml:"bypass"`
+}
+
+type AuthConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	UsersFile string `yaml:"users_file"`
+	Realm     string `yaml:"realm"`
+}
+
+type PassthroughConfig struct {
+	Hosts   []string      `yaml:"hosts"`
+	Auto    bool          `yaml:"auto"`
+	AutoTTL time.Duration `yaml:"auto_ttl"`
+}
+
+type CertConfig struct {
+	CACert     string        `yaml:"ca_cert"`
+	CAKey      string        `yaml:"ca_key"`
+	CAName     string        `yaml:"ca_name"`
+	CAValidity time.Duration `yaml:"ca_validity"`
+	Dir        string        `yaml:"dir"`
+	KeyType    string        `yaml:"key_type"`
+	CacheSize  int           `yaml:"cache_size"`
+	Validity   time.Duration `yaml:"validity"`
+	Wildcard   bool          `yaml:"wildcard"`
+	Mirror     bool          `yaml:"mirror"`
+}
+
+func Default() Config {
+	return Config{
+		Proxy: ProxyConfig{
+			Addr:          ":8080",
+			CertHost:      "proxy.local",
+			ReadTimeout:   10 * time.Second,
+			WriteTimeout:  10 * time.Second,
+			ClientTimeout: 10 * time.Second,
+			MaxBodySize:   10 << 20,
+		},
+		Repeater: RepeaterConfig{
+			Addr:          ":8000",
+			Payloads:      "configs/payloads",
+			ClientTimeout: 10 * time.Second,
+		},
+		Storage: StorageConfig{
+			Driver:         StoragePostgres,
+			PostgresDSN:    "host=localhost user=test_user password=test_password dbname=requests sslmode=disable",
+			SqlitePath:     "requests.db",
+			MemoryCapacity: 10000,
+		},
+		Cert: CertConfig{
+			CACert:     "ca.crt",
+			CAKey:      "ca.key",
+			CAName:     cert.DefaultCAName,
+			CAValidity: 10 * 365 * 24 * time.Hour,
+			Dir:        "certs",
+			KeyType:    cert.KeyECDSA,
+			CacheSize:  1024,
+			Validity:   397 * 24 * time.Hour,
+		},
+		Intercept: InterceptConfig{
+			Timeout: 5 * time.Minute,
+		},
+		Scanner: ScannerConfig{
+			Workers:   4,
+			QueueSize: 100,
+			RateLimit: 10,
+		},
+		Upstream: UpstreamConfig{
+			Bypass: []string{"localhost", "127.0.0.0/8", "::1"},
+		},
+		Auth: AuthConfig{
+			Realm: "httpproxy",
+		},
+		Passthrough: PassthroughConfig{
+			AutoTTL: time.Hour,
+		},
+	}
+}
+
+func Load(name string, args []string) (Config, error) {
+	scratch := Default()
+	var path string
+	fs := newFlagSet(name, &scratch, &path)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	if path == "" {
+		path = os.Getenv(envConfigFile)
+	}
+
+	cfg := Default()
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	fs = newFlagSet(name, &cfg, &path)
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envName(f.Name))
+		if !ok || envErr != nil {
+			return
+		}
+
+		if err := f.Value.Set(value); err != nil {
+			envErr = fmt.Errorf("invalid value %q for %s: %w", value, envName(f.Name), err)
+		}
+	})
+	if envErr != nil {
+		return Config{}, envErr
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	cfg.Args = fs.Args()
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c Config) Validate() error {
+	if err := validateAddr("proxy.addr", c.Proxy.Addr); err != nil {
+		return err
+	}
+
+	if err := validateAddr("repeater.addr", c.Repeater.Addr); err != nil {
+		return err
+	}
+
+	if c.Proxy.Addr == c.Repeater.Addr {
+		return fmt.Errorf("proxy.addr and repeater.addr must differ, both are %s", c.Proxy.Addr)
+	}
+
+	listeners := []struct {
+		key  string
+		addr string
+	}{
+		{"proxy.socks_addr", c.Proxy.SocksAddr},
+		{"proxy.transparent_addr", c.Proxy.TransparentAddr},
+	}
+	used := map[string]bool{c.Proxy.Addr: true, c.Repeater.Addr: true}
+	for _, listener := range listeners {
+		if listener.addr == "" {
+			continue
+		}
+
+		if err := validateAddr(listener.key, listener.addr); err != nil {
+			return err
+		}
+
+		if used[listener.addr] {
+			return fmt.Errorf("%s %s is already used by another listener", listener.key, listener.addr)
+		}
+		used[listener.addr] = true
+	}
+
+	timeouts := map[string]time.Duration{
+		"proxy.read_timeout":      c.Proxy.ReadTimeout,
+		"proxy.write_timeout":     c.Proxy.WriteTimeout,
+		"proxy.client_timeout":    c.Proxy.ClientTimeout,
+		"repeater.client_timeout": c.Repeater.ClientTimeout,
+		"intercept.timeout":       c.Intercept.Timeout,
+		"passthrough.auto_ttl":    c.Passthrough.AutoTTL,
+		"cert.ca_validity":        c.Cert.CAValidity,
+		"cert.validity":           c.Cert.Validity,
+	}
+	for key, timeout := range timeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", key, timeout)
+		}
+	}
+
+	if c.Proxy.MaxBodySize <= 0 {
+		return fmt.Errorf("proxy.max_body_size must be positive, got %d", c.Proxy.MaxBodySize)
+	}
+
+	if c.Repeater.Payloads == "" {
+		return errors.New("repeater.payloads is required")
+	}
+
+	switch c.Storage.Driver {
+	case StoragePostgres:
+		if c.Storage.PostgresDSN == "" {
+			return errors.New("storage.postgres_dsn is required for postgres storage")
+		}
+	case StorageSqlite:
+		if c.Storage.SqlitePath == "" {
+			return errors.New("storage.sqlite_path is required for sqlite storage")
+		}
+	case StorageMemory:
+		if c.Storage.MemoryCapacity <= 0 {
+			return fmt.Errorf("storage.memory_capacity must be positive, got %d", c.Storage.MemoryCapacity)
+		}
+	default:
+		return fmt.Errorf("unknown storage.driver %q", c.Storage.Driver)
+	}
+
+	if c.Cert.CACert == "" || c.Cert.CAKey == "" {
+		return errors.New("cert.ca_cert and cert.ca_key are required")
+	}
+
+	if c.Cert.Dir == "" {
+		return errors.New("cert.dir is required")
+	}
+
+	switch c.Cert.KeyType {
+	case cert.KeyECDSA, cert.KeyRSA:
+	default:
+		return fmt.Errorf("unknown cert.key_type %q", c.Cert.KeyType)
+	}
+
+	if c.Cert.CacheSize <= 0 {
+		return fmt.Errorf("cert.cache_size must be positive, got %d", c.Cert.CacheSize)
+	}
+
+	if c.Cert.Validity < cert.MinValidity {
+		return fmt.Errorf("cert.validity must be at least %s, got %s", cert.MinValidity, c.Cert.Validity)
+	}
+
+	if c.Cert.Validity > cert.MaxValidity {
+		return fmt.Errorf("cert.validity can't exceed %s, got %s", cert.MaxValidity, c.Cert.Validity)
+	}
+
+	if _, err := regexp.Compile(c.Intercept.Host); err != nil {
+		return fmt.Errorf("invalid intercept.host: %w", err)
+	}
+
+	if c.Scanner.Workers <= 0 {
+		return fmt.Errorf("scanner.workers must be positive, got %d", c.Scanner.Workers)
+	}
+
+	if c.Scanner.QueueSize <= 0 {
+		return fmt.Errorf("scanner.queue_size must be positive, got %d", c.Scanner.QueueSize)
+	}
+
+	if c.Scanner.RateLimit < 0 {
+		return fmt.Errorf("scanner.rate_limit can't be negative, got %g", c.Scanner.RateLimit)
+	}
+
+	if _, err := upstream.New(c.Upstream.URL, c.Upstream.Bypass); err != nil {
+		return err
+	}
+
+	if c.Auth.Realm == "" {
+		return errors.New("auth.realm is required")
+	}
+
+	for _, host := range c.Passthrough.Hosts {
+		if _, err := path.Match(host, ""); err != nil {
+			return fmt.Errorf("invalid passthrough.hosts pattern %q: %w", host, err)
+		}
+	}
+
+	return nil
+}
+
+func (c Config) Marshal() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func newFlagSet(name string, cfg *Config, path *string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	fs.StringVar(path, "config", "", "path to YAML config file (env "+envConfigFile+")")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print effective config and exit")
+
+	fs.StringVar(&cfg.Proxy.Addr, "proxy-addr", cfg.Proxy.Addr, "proxy listen address")
+	fs.StringVar(&cfg.Proxy.SocksAddr, "socks-addr", cfg.Proxy.SocksAddr, "SOCKS5 proxy listen address, empty disables it")
+	fs.StringVar(&cfg.Proxy.TransparentAddr, "transparent-addr", cfg.Proxy.TransparentAddr, "listen address for connections redirected by iptables REDIRECT or TPROXY, empty disables it")
+	fs.StringVar(&cfg.Proxy.CertHost, "proxy-cert-host", cfg.Proxy.CertHost, "host serving the CA certificate through the proxy (http://<host>/cert), empty disables it")
+	fs.DurationVar(&cfg.Proxy.ReadTimeout, "proxy-read-timeout", cfg.Proxy.ReadTimeout, "proxy server read timeout")
+	fs.DurationVar(&cfg.Proxy.WriteTimeout, "proxy-write-timeout", cfg.Proxy.WriteTimeout, "proxy server write timeout")
+	fs.DurationVar(&cfg.Proxy.ClientTimeout, "proxy-client-timeout", cfg.Proxy.ClientTimeout, "timeout for upstream requests made by the proxy")
+	fs.Int64Var(&cfg.Proxy.MaxBodySize, "proxy-max-body-size", cfg.Proxy.MaxBodySize, "response body bytes saved with each request and buffered for rules and interception, longer bodies are passed through and saved truncated")
+
+	fs.StringVar(&cfg.Repeater.Addr, "repeater-addr", cfg.Repeater.Addr, "repeater listen address")
+	fs.StringVar(&cfg.Repeater.Payloads, "payloads", cfg.Repeater.Payloads, "path to scanner payloads file")
+	fs.DurationVar(&cfg.Repeater.ClientTimeout, "repeater-client-timeout", cfg.Repeater.ClientTimeout, "timeout for requests made by the repeater")
+
+	fs.StringVar(&cfg.Storage.Driver, "storage", cfg.Storage.Driver, "storage backend: postgres, sqlite or memory")
+	fs.StringVar(&cfg.Storage.PostgresDSN, "postgres-dsn", cfg.Storage.PostgresDSN, "postgres connection string")
+	fs.StringVar(&cfg.Storage.SqlitePath, "sqlite-path", cfg.Storage.SqlitePath, "sqlite database file")
+	fs.IntVar(&cfg.Storage.MemoryCapacity, "memory-capacity", cfg.Storage.MemoryCapacity, "number of requests, websocket messages and tunnels kept by memory storage")
+
+	fs.StringVar(&cfg.Cert.CACert, "ca-cert", cfg.Cert.CACert, "path to CA certificate, generated at startup if neither it nor the key exists")
+	fs.StringVar(&cfg.Cert.CAKey, "ca-key", cfg.Cert.CAKey, "path to CA private key")
+	fs.StringVar(&cfg.Cert.CAName, "ca-name", cfg.Cert.CAName, "common name of generated CA certificates")
+	fs.DurationVar(&cfg.Cert.CAValidity, "ca-validity", cfg.Cert.CAValidity, "validity of generated CA certificates")
+	fs.StringVar(&cfg.Cert.Dir, "cert-dir", cfg.Cert.Dir, "directory for generated host certificates")
+	fs.StringVar(&cfg.Cert.KeyType, "cert-key-type", cfg.Cert.KeyType, "key type of generated host and CA certificates: ecdsa (P-256) or rsa")
+	fs.IntVar(&cfg.Cert.CacheSize, "cert-cache-size", cfg.Cert.CacheSize, "number of host certificates kept in memory")
+	fs.DurationVar(&cfg.Cert.Validity, "cert-validity", cfg.Cert.Validity, "validity of host certificates, from 2h to 398 days (9552h)")
+	fs.BoolVar(&cfg.Cert.Wildcard, "cert-wildcard", cfg.Cert.Wildcard, "issue one *.example.com certificate for all hosts under example.com")
+	fs.BoolVar(&cfg.Cert.Mirror, "cert-mirror", cfg.Cert.Mirror, "copy the subject, names and validity of the upstream certificate")
+
+	fs.BoolVar(&cfg.Intercept.Enabled, "intercept", cfg.Intercept.Enabled, "hold matching requests until forwarded or dropped")
+	fs.BoolVar(&cfg.Intercept.Responses, "intercept-responses", cfg.Intercept.Responses, "hold responses of matching requests too")
+	fs.StringVar(&cfg.Intercept.Host, "intercept-host", cfg.Intercept.Host, "regexp for hosts to intercept, empty matches all")
+	fs.DurationVar(&cfg.Intercept.Timeout, "intercept-timeout", cfg.Intercept.Timeout, "forward held items unchanged after this timeout")
+
+	fs.IntVar(&cfg.Scanner.Workers, "scan-workers", cfg.Scanner.Workers, "number of concurrent scan jobs")
+	fs.IntVar(&cfg.Scanner.QueueSize, "scan-queue-size", cfg.Scanner.QueueSize, "number of scan jobs waiting for a worker")
+	fs.Float64Var(&cfg.Scanner.RateLimit, "scan-rate-limit", cfg.Scanner.RateLimit, "scanner requests per second per host, 0 disables the limit")
+
+	fs.StringVar(&cfg.Upstream.URL, "upstream-proxy", cfg.Upstream.URL, "upstream proxy for outgoing traffic: http://, https:// or socks5://, credentials as user:password@")
+	fs.Var((*listValue)(&cfg.Upstream.Bypass), "upstream-bypass", "comma separated hosts, domains (.example.com), IPs and CIDRs reached directly")
+
+	fs.BoolVar(&cfg.Auth.Enabled, "proxy-auth", cfg.Auth.Enabled, "require Proxy-Authorization (Basic or Bearer) on the proxy and username/password on SOCKS5")
+	fs.StringVar(&cfg.Auth.UsersFile, "proxy-users", cfg.Auth.UsersFile, "YAML file with proxy users (name, password, token) saved to storage at startup")
+	fs.StringVar(&cfg.Auth.Realm, "proxy-auth-realm", cfg.Auth.Realm, "realm sent in Proxy-Authenticate")
+
+	fs.Var((*listValue)(&cfg.Passthrough.Hosts), "passthrough-hosts", "comma separated host globs (*.example.com) whose tunnels are relayed without decryption")
+	fs.BoolVar(&cfg.Passthrough.Auto, "passthrough-auto", cfg.Passthrough.Auto, "relay tunnels without decryption after the client rejects the generated certificate")
+	fs.DurationVar(&cfg.Passthrough.AutoTTL, "passthrough-auto-ttl", cfg.Passthrough.AutoTTL, "how long a target stays passed through after a failed client handshake")
+
+	return fs
+}
+
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+
+	return nil
+}
+
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func readFile(path string, cfg *Config) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return fmt.Errorf("couldn't parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func validateAddr(key, addr string) error {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", key, addr, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,506 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want func(*Config)
+	}{
+		{
+			name: "defaults",
+			want: func(*Config) {},
+		},
+		{
+			name: "file over defaults",
+			file: "proxy:\n  addr: :9090\nstorage:\n  driver: memory\nintercept:\n  enabled: true\n",
+			want: func(c *Config) {
+				c.Proxy.Addr = ":9090"
+				c.Storage.Driver = StorageMemory
+				c.Intercept.Enabled = true
+			},
+		},
+		{
+			name: "file replaces lists",
+			file: "upstream:\n  bypass: [internal.test]\n",
+			want: func(c *Config) {
+				c.Upstream.Bypass = []string{"internal.test"}
+			},
+		},
+		{
+			name: "env over file",
+			file: "proxy:\n  addr: :9090\n  read_timeout: 1m\n",
+			env:  map[string]string{"HTTPPROXY_PROXY_ADDR": ":9191"},
+			want: func(c *Config) {
+				c.Proxy.Addr = ":9191"
+				c.Proxy.ReadTimeout = time.Minute
+			},
+		},
+		{
+			name: "flags over env",
+			file: "proxy:\n  addr: :9090\n",
+			env:  map[string]string{"HTTPPROXY_PROXY_ADDR": ":9191", "HTTPPROXY_SCAN_WORKERS": "8"},
+			args: []string{"-proxy-addr", ":9292"},
+			want: func(c *Config) {
+				c.Proxy.Addr = ":9292"
+				c.Scanner.Workers = 8
+			},
+		},
+		{
+			name: "env types",
+			env: map[string]string{
+				"HTTPPROXY_INTERCEPT":         "true",
+				"HTTPPROXY_INTERCEPT_TIMEOUT": "30s",
+				"HTTPPROXY_SCAN_RATE_LIMIT":   "0.5",
+				"HTTPPROXY_UPSTREAM_BYPASS":   " a.test, ,10.0.0.0/8 ",
+			},
+			want: func(c *Config) {
+				c.Intercept.Enabled = true
+				c.Intercept.Timeout = 30 * time.Second
+				c.Scanner.RateLimit = 0.5
+				c.Upstream.Bypass = []string{"a.test", "10.0.0.0/8"}
+			},
+		},
+		{
+			name: "flag clears list",
+			file: "passthrough:\n  hosts: ['*.bank.test']\n",
+			args: []string{"-upstream-bypass=", "-passthrough-hosts", ""},
+			want: func(c *Config) {
+				c.Upstream.Bypass = nil
+			},
+		},
+		{
+			name: "env clears list",
+			env:  map[string]string{"HTTPPROXY_UPSTREAM_BYPASS": ""},
+			want: func(c *Config) {
+				c.Upstream.Bypass = nil
+			},
+		},
+		{
+			name: "positional args",
+			args: []string{"-storage", "sqlite", "serve", "-proxy-addr", ":1"},
+			want: func(c *Config) {
+				c.Storage.Driver = StorageSqlite
+				c.Args = []string{"serve", "-proxy-addr", ":1"}
+			},
+		},
+		{
+			name: "print config",
+			env:  map[string]string{"HTTPPROXY_PRINT_CONFIG": "true"},
+			want: func(c *Config) {
+				c.PrintConfig = true
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if tt.file != "" {
+				env = withValue(env, envConfigFile, writeConfig(t, tt.file))
+			}
+			setEnv(t, env)
+
+			got, err := Load("httpproxy", tt.args)
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			want := Default()
+			tt.want(&want)
+			if len(want.Args) == 0 {
+				want.Args = got.Args
+			}
+			if len(got.Args) != len(want.Args) {
+				t.Fatalf("Load() args = %q, want %q", got.Args, want.Args)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("Load() =\n%+v\nwant\n%+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigPath(t *testing.T) {
+	fromEnv := writeConfig(t, "proxy:\n  addr: :1111\n")
+	fromFlag := writeConfig(t, "proxy:\n  addr: :2222\n")
+
+	setEnv(t, map[string]string{envConfigFile: fromEnv})
+
+	cfg, err := Load("httpproxy", nil)
+	if err != nil || cfg.Proxy.Addr != ":1111" {
+		t.Fatalf("Load() with %s = %s, %v, want :1111", envConfigFile, cfg.Proxy.Addr, err)
+	}
+
+	cfg, err = Load("httpproxy", []string{"-config", fromFlag})
+	if err != nil || cfg.Proxy.Addr != ":2222" {
+		t.Fatalf("Load(-config) = %s, %v, want :2222", cfg.Proxy.Addr, err)
+	}
+
+	// flags given before -config still win over the file
+	cfg, err = Load("httpproxy", []string{"-proxy-addr", ":3333", "-config", fromFlag})
+	if err != nil || cfg.Proxy.Addr != ":3333" {
+		t.Fatalf("Load(-proxy-addr, -config) = %s, %v, want :3333", cfg.Proxy.Addr, err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		err  string
+	}{
+		{
+			name: "unknown flag",
+			args: []string{"-nope"},
+			err:  "flag provided but not defined: -nope",
+		},
+		{
+			name: "invalid flag value",
+			args: []string{"-scan-workers", "many"},
+			err:  `invalid value "many" for flag -scan-workers`,
+		},
+		{
+			name: "invalid env value",
+			env:  map[string]string{"HTTPPROXY_PROXY_READ_TIMEOUT": "soon"},
+			err:  `invalid value "soon" for HTTPPROXY_PROXY_READ_TIMEOUT`,
+		},
+		{
+			name: "invalid env bool",
+			env:  map[string]string{"HTTPPROXY_INTERCEPT": "maybe"},
+			err:  `invalid value "maybe" for HTTPPROXY_INTERCEPT`,
+		},
+		{
+			name: "missing file",
+			env:  map[string]string{envConfigFile: filepath.Join(os.TempDir(), "httpproxy-missing.yaml")},
+			err:  "httpproxy-missing.yaml",
+		},
+		{
+			name: "unknown key",
+			file: "proxy:\n  adr: :9090\n",
+			err:  "field adr not found",
+		},
+		{
+			name: "wrong type",
+			file: "scanner:\n  workers: many\n",
+			err:  "couldn't parse",
+		},
+		{
+			name: "invalid duration",
+			file: "proxy:\n  read_timeout: soon\n",
+			err:  "couldn't parse",
+		},
+		{
+			name: "invalid yaml",
+			file: "proxy: [\n",
+			err:  "couldn't parse",
+		},
+		{
+			name: "flag fails validation",
+			args: []string{"-proxy-addr", ":8000"},
+			err:  "proxy.addr and repeater.addr must differ",
+		},
+		{
+			name: "env fails validation",
+			env:  map[string]string{"HTTPPROXY_STORAGE": "mongo"},
+			err:  `unknown storage.driver "mongo"`,
+		},
+		{
+			name: "file fails validation",
+			file: "cert:\n  validity: 9553h\n",
+			err:  "cert.validity can't exceed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := tt.env
+			if tt.file != "" {
+				env = withValue(env, envConfigFile, writeConfig(t, tt.file))
+			}
+			setEnv(t, env)
+
+			_, err := Load("httpproxy", tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{name: "defaults", modify: func(*Config) {}},
+		{
+			name:   "invalid proxy addr",
+			modify: func(c *Config) { c.Proxy.Addr = "8080" },
+			err:    `invalid proxy.addr "8080"`,
+		},
+		{
+			name:   "empty repeater addr",
+			modify: func(c *Config) { c.Repeater.Addr = "" },
+			err:    `invalid repeater.addr ""`,
+		},
+		{
+			name:   "socks addr taken",
+			modify: func(c *Config) { c.Proxy.SocksAddr = c.Repeater.Addr },
+			err:    "proxy.socks_addr :8000 is already used by another listener",
+		},
+		{
+			name: "socks and transparent addr taken",
+			modify: func(c *Config) {
+				c.Proxy.SocksAddr = ":1080"
+				c.Proxy.TransparentAddr = ":1080"
+			},
+			err: "proxy.transparent_addr :1080 is already used",
+		},
+		{
+			name:   "invalid transparent addr",
+			modify: func(c *Config) { c.Proxy.TransparentAddr = "localhost" },
+			err:    `invalid proxy.transparent_addr "localhost"`,
+		},
+		{
+			name:   "zero timeout",
+			modify: func(c *Config) { c.Proxy.WriteTimeout = 0 },
+			err:    "proxy.write_timeout must be positive, got 0s",
+		},
+		{
+			name:   "negative ttl",
+			modify: func(c *Config) { c.Passthrough.AutoTTL = -time.Second },
+			err:    "passthrough.auto_ttl must be positive",
+		},
+		{
+			name:   "zero ca validity",
+			modify: func(c *Config) { c.Cert.CAValidity = 0 },
+			err:    "cert.ca_validity must be positive",
+		},
+		{
+			name:   "zero max body size",
+			modify: func(c *Config) { c.Proxy.MaxBodySize = 0 },
+			err:    "proxy.max_body_size must be positive, got 0",
+		},
+		{
+			name:   "no payloads",
+			modify: func(c *Config) { c.Repeater.Payloads = "" },
+			err:    "repeater.payloads is required",
+		},
+		{
+			name:   "no postgres dsn",
+			modify: func(c *Config) { c.Storage.PostgresDSN = "" },
+			err:    "storage.postgres_dsn is required",
+		},
+		{
+			name: "no sqlite path",
+			modify: func(c *Config) {
+				c.Storage.Driver = StorageSqlite
+				c.Storage.SqlitePath = ""
+			},
+			err: "storage.sqlite_path is required",
+		},
+		{
+			name: "no memory capacity",
+			modify: func(c *Config) {
+				c.Storage.Driver = StorageMemory
+				c.Storage.MemoryCapacity = 0
+			},
+			err: "storage.memory_capacity must be positive, got 0",
+		},
+		{
+			name: "memory capacity ignored by sqlite",
+			modify: func(c *Config) {
+				c.Storage.Driver = StorageSqlite
+				c.Storage.MemoryCapacity = 0
+			},
+		},
+		{
+			name:   "unknown storage",
+			modify: func(c *Config) { c.Storage.Driver = "" },
+			err:    `unknown storage.driver ""`,
+		},
+		{
+			name:   "no ca key",
+			modify: func(c *Config) { c.Cert.CAKey = "" },
+			err:    "cert.ca_cert and cert.ca_key are required",
+		},
+		{
+			name:   "no cert dir",
+			modify: func(c *Config) { c.Cert.Dir = "" },
+			err:    "cert.dir is required",
+		},
+		{
+			name:   "unknown key type",
+			modify: func(c *Config) { c.Cert.KeyType = "dsa" },
+			err:    `unknown cert.key_type "dsa"`,
+		},
+		{
+			name:   "zero cert cache",
+			modify: func(c *Config) { c.Cert.CacheSize = 0 },
+			err:    "cert.cache_size must be positive",
+		},
+		{
+			name:   "shortest cert validity",
+			modify: func(c *Config) { c.Cert.Validity = 2 * time.Hour },
+		},
+		{
+			name:   "cert validity too short",
+			modify: func(c *Config) { c.Cert.Validity = time.Hour },
+			err:    "cert.validity must be at least 2h0m0s",
+		},
+		{
+			name:   "longest cert validity",
+			modify: func(c *Config) { c.Cert.Validity = 398 * 24 * time.Hour },
+		},
+		{
+			name:   "cert validity too long",
+			modify: func(c *Config) { c.Cert.Validity = 398*24*time.Hour + time.Second },
+			err:    "cert.validity can't exceed",
+		},
+		{
+			name:   "invalid intercept host",
+			modify: func(c *Config) { c.Intercept.Host = "(" },
+			err:    "invalid intercept.host",
+		},
+		{
+			name:   "zero workers",
+			modify: func(c *Config) { c.Scanner.Workers = 0 },
+			err:    "scanner.workers must be positive",
+		},
+		{
+			name:   "zero queue",
+			modify: func(c *Config) { c.Scanner.QueueSize = 0 },
+			err:    "scanner.queue_size must be positive",
+		},
+		{
+			name:   "negative rate limit",
+			modify: func(c *Config) { c.Scanner.RateLimit = -1 },
+			err:    "scanner.rate_limit can't be negative, got -1",
+		},
+		{
+			name:   "no rate limit",
+			modify: func(c *Config) { c.Scanner.RateLimit = 0 },
+		},
+		{
+			name:   "upstream scheme",
+			modify: func(c *Config) { c.Upstream.URL = "ftp://proxy.test" },
+			err:    "upstream proxy scheme must be http, https or socks5",
+		},
+		{
+			name:   "upstream bypass",
+			modify: func(c *Config) { c.Upstream.Bypass = []string{"10.0.0.0/33"} },
+			err:    `invalid upstream bypass "10.0.0.0/33"`,
+		},
+		{
+			name:   "no realm",
+			modify: func(c *Config) { c.Auth.Realm = "" },
+			err:    "auth.realm is required",
+		},
+		{
+			name:   "passthrough glob",
+			modify: func(c *Config) { c.Passthrough.Hosts = []string{"*.ok.test", "[bad"} },
+			err:    `invalid passthrough.hosts pattern "[bad"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Validate() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	cfg := Default()
+	cfg.Upstream.URL = "socks5://proxy.test"
+	cfg.Passthrough.Hosts = []string{"*.bank.test"}
+	cfg.Args = []string{"ignored"}
+
+	data, err := cfg.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	path := writeConfig(t, string(data))
+	setEnv(t, map[string]string{envConfigFile: path})
+
+	got, err := Load("httpproxy", nil)
+	if err != nil {
+		t.Fatalf("Load() of marshaled config error = %v", err)
+	}
+
+	again, err := got.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(again) != string(data) {
+		t.Fatalf("Load() of marshaled config =\n%s\nwant\n%s", again, data)
+	}
+}
+
+// setEnv replaces every HTTPPROXY_ variable with env for the test.
+func setEnv(t *testing.T, env map[string]string) {
+	saved := make(map[string]string)
+	for _, pair := range os.Environ() {
+		if !strings.HasPrefix(pair, envPrefix) {
+			continue
+		}
+
+		name := pair[:strings.IndexByte(pair, '=')]
+		saved[name] = os.Getenv(name)
+		os.Unsetenv(name)
+	}
+
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+
+	t.Cleanup(func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	})
+}
+
+func withValue(env map[string]string, name, value string) map[string]string {
+	result := map[string]string{name: value}
+	for k, v := range env {
+		result[k] = v
+	}
+
+	return result
+}
+
+func writeConfig(t *testing.T, data string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
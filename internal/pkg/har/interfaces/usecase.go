@@ -0,0 +1,8 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	Export(ids []int, filter models.RequestFilter) (models.HAR, error)
+	Import(har models.HAR) ([]int, error)
+}
@@ -0,0 +1,142 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/har/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+)
+
+var (
+	ErrNotHAR       = errors.New("not a HAR file: log.version is missing")
+	ErrInvalidEntry = errors.New("invalid har entry")
+)
+
+var creator = models.HarCreator{
+	Name:    "httpproxy",
+	Version: "1.0",
+}
+
+type HarUsecase struct {
+	proxyUsecase proxyInterfaces.Usecase
+}
+
+func NewHarUsecase(proxyUsecase proxyInterfaces.Usecase) interfaces.Usecase {
+	return HarUsecase{
+		proxyUsecase: proxyUsecase,
+	}
+}
+
+func (u HarUsecase) Export(ids []int, filter models.RequestFilter) (models.HAR, error) {
+	requests := make([]models.Request, 0, len(ids))
+	if len(ids) == 0 {
+		page, err := u.proxyUsecase.GetRequests(filter)
+		if err != nil {
+			return models.HAR{}, err
+		}
+		requests = page.Requests
+	}
+
+	for _, id := range ids {
+		req, err := u.proxyUsecase.GetRequest(id)
+		if err != nil {
+			return models.HAR{}, err
+		}
+		requests = append(requests, req)
+	}
+
+	started := time.Now()
+	entries := make([]models.HarEntry, 0, len(requests))
+	for _, req := range requests {
+		var respPtr *models.Response
+		resp, err := u.proxyUsecase.GetResponse(req.ID)
+		switch {
+		case err == nil:
+			respPtr = &resp
+		case err != sql.ErrNoRows:
+			return models.HAR{}, err
+		}
+
+		entry, err := models.HarEntryFromRequest(req, respPtr, started)
+		if err != nil {
+			return models.HAR{}, fmt.Errorf("request %d: %w", req.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return models.NewHAR(creator, entries), nil
+}
+
+// Import stores the entries of har. All of them are converted first, so an
+// invalid entry leaves storage untouched, ids of the entries stored before a
+// storage error are returned with it.
+func (u HarUsecase) Import(har models.HAR) ([]int, error) {
+	if har.Log.Version == "" {
+		return nil, ErrNotHAR
+	}
+
+	type exchange struct {
+		req     models.Request
+		resp    models.Response
+		hasResp bool
+	}
+
+	exchanges := make([]exchange, 0, len(har.Log.Entries))
+	for i, entry := range har.Log.Entries {
+		req, err := entry.ToRequest()
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %v", ErrInvalidEntry, i, err)
+		}
+
+		resp, ok, err := entry.ToResponse()
+		if err != nil {
+			return nil, fmt.Errorf("%w %d: %v", ErrInvalidEntry, i, err)
+		}
+
+		exchanges = append(exchanges, exchange{req: req, resp: resp, hasResp: ok})
+	}
+
+	ids := make([]int, 0, len(exchanges))
+	for _, e := range exchanges {
+		id, err := u.proxyUsecase.SaveRequest(e.req)
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, id)
+
+		if !e.hasResp {
+			continue
+		}
+
+		e.resp.RequestID = id
+		if err := u.proxyUsecase.SaveResponse(e.resp); err != nil {
+			return ids, err
+		}
+	}
+
+	return ids, nil
+}
+
+func ParseIDs(value string) ([]int, error) {
+	ids := make([]int, 0)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid request id %q", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
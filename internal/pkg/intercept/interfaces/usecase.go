@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+type Usecase interface {
+	GetSettings() models.InterceptSettings
+	SetSettings(settings models.InterceptSettings) error
+	InterceptRequest(ctx context.Context, req models.Request) (models.Request, error)
+	InterceptResponse(ctx context.Context, req models.Request, resp models.Response) (models.Response, error)
+	GetItems() []models.InterceptedItem
+	GetItem(id int) (models.InterceptedItem, error)
+	Resolve(id int, decision models.InterceptDecision) error
+}
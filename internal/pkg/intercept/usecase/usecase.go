@@ -0,0 +1,209 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/intercept/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+var (
+	ErrDropped  = errors.New("dropped by interceptor")
+	ErrNotFound = errors.New("intercepted item not found")
+)
+
+type pendingItem struct {
+	item     models.InterceptedItem
+	decision chan models.InterceptDecision
+}
+
+type InterceptUsecase struct {
+	mu       sync.Mutex
+	settings models.InterceptSettings
+	timeout  time.Duration
+	pending  map[int]pendingItem
+	nextID   int
+}
+
+func NewInterceptUsecase(settings models.InterceptSettings, timeout time.Duration) interfaces.Usecase {
+	return &InterceptUsecase{
+		settings: settings,
+		timeout:  timeout,
+		pending:  make(map[int]pendingItem),
+		nextID:   1,
+	}
+}
+
+func (u *InterceptUsecase) GetSettings() models.InterceptSettings {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	return u.settings
+}
+
+func (u *InterceptUsecase) SetSettings(settings models.InterceptSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.settings = settings
+
+	return nil
+}
+
+func (u *InterceptUsecase) InterceptRequest(ctx context.Context, req models.Request) (models.Request, error) {
+	if !u.GetSettings().Match(req) {
+		return req, nil
+	}
+
+	decision, err := u.hold(ctx, models.InterceptedItem{
+		Type:    models.InterceptRequest,
+		Request: req,
+	})
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	if decision == nil {
+		return req, nil
+	}
+
+	edited := decision.Request
+	edited.ID = req.ID
+
+	return edited, nil
+}
+
+func (u *InterceptUsecase) InterceptResponse(
+	ctx context.Context, req models.Request, resp models.Response,
+) (models.Response, error) {
+	settings := u.GetSettings()
+	if !settings.Responses || !settings.Match(req) {
+		return resp, nil
+	}
+
+	decision, err := u.hold(ctx, models.InterceptedItem{
+		Type:     models.InterceptResponse,
+		Request:  req,
+		Response: resp,
+	})
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	if decision == nil {
+		return resp, nil
+	}
+
+	edited := decision.Response
+	edited.ID = resp.ID
+	edited.RequestID = resp.RequestID
+	edited.Duration = resp.Duration
+	edited.ContentLength = int64(len(edited.Body))
+
+	return edited, nil
+}
+
+func (u *InterceptUsecase) GetItems() []models.InterceptedItem {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	items := make([]models.InterceptedItem, 0, len(u.pending))
+	for _, pending := range u.pending {
+		items = append(items, pending.item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items
+}
+
+func (u *InterceptUsecase) GetItem(id int) (models.InterceptedItem, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	pending, ok := u.pending[id]
+	if !ok {
+		return models.InterceptedItem{}, ErrNotFound
+	}
+
+	return pending.item, nil
+}
+
+func (u *InterceptUsecase) Resolve(id int, decision models.InterceptDecision) error {
+	if decision.Action != models.ActionForward && decision.Action != models.ActionDrop {
+		return errors.New("unknown action: " + decision.Action)
+	}
+
+	u.mu.Lock()
+	pending, ok := u.pending[id]
+	delete(u.pending, id)
+	u.mu.Unlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	pending.decision <- decision
+
+	return nil
+}
+
+// hold parks the item until it is resolved through the API. A nil decision
+// means the item timed out and has to be forwarded unchanged.
+func (u *InterceptUsecase) hold(ctx context.Context, item models.InterceptedItem) (*models.InterceptDecision, error) {
+	u.mu.Lock()
+	item.ID = u.nextID
+	item.CreatedAt = time.Now()
+	u.nextID++
+
+	pending := pendingItem{
+		item:     item,
+		decision: make(chan models.InterceptDecision, 1),
+	}
+	u.pending[item.ID] = pending
+	u.mu.Unlock()
+
+	timer := time.NewTimer(u.timeout)
+	defer timer.Stop()
+
+	select {
+	case decision := <-pending.decision:
+		if decision.Action == models.ActionDrop {
+			return nil, ErrDropped
+		}
+
+		return &decision, nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+
+	u.mu.Lock()
+	_, stillPending := u.pending[item.ID]
+	delete(u.pending, item.ID)
+	u.mu.Unlock()
+
+	if !stillPending {
+		decision := <-pending.decision
+		if decision.Action == models.ActionDrop {
+			return nil, ErrDropped
+		}
+
+		return &decision, nil
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+	SeverityInfo   = "info"
+
+	ConfidenceCertain   = "certain"
+	ConfidenceFirm      = "firm"
+	ConfidenceTentative = "tentative"
+)
+
+type Finding struct {
+	ID         int
+	RequestID  int
+	Endpoint   string
+	Check      string
+	Parameter  string
+	Payload    string
+	Evidence   string
+	Severity   string
+	Confidence string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+type FindingFilter struct {
+	RequestID  int
+	Endpoint   string
+	Check      string
+	Severity   string
+	Confidence string
+}
+
+func (f Finding) Key() string {
+	return f.Endpoint + "\x00" + f.Check + "\x00" + f.Parameter
+}
+
+func (f FindingFilter) Match(finding Finding) bool {
+	return (f.RequestID == 0 || f.RequestID == finding.RequestID) &&
+		containsFold(finding.Endpoint, f.Endpoint) &&
+		(f.Check == "" || f.Check == finding.Check) &&
+		(f.Severity == "" || f.Severity == finding.Severity) &&
+		(f.Confidence == "" || f.Confidence == finding.Confidence)
+}
+
+func FindingFilterFromQuery(query url.Values) (FindingFilter, error) {
+	filter := FindingFilter{
+		Endpoint:   query.Get("endpoint"),
+		Check:      query.Get("check"),
+		Severity:   query.Get("severity"),
+		Confidence: query.Get("confidence"),
+	}
+
+	if requestID := query.Get("request_id"); requestID != "" {
+		id, err := strconv.Atoi(requestID)
+		if err != nil {
+			return FindingFilter{}, fmt.Errorf("invalid request_id %q", requestID)
+		}
+		filter.RequestID = id
+	}
+
+	return filter, nil
+}
+
+func EndpointFromRequest(r Request) string {
+	return r.Method + " " + r.Scheme + "://" + r.Host + r.Path
+}
+
+func (f Finding) StringFromFinding() string {
+	return fmt.Sprintf(
+		"%d: [%s/%s] %s in %s of %s (request %d), payload %q, evidence %q, last seen %s",
+		f.ID, f.Severity, f.Confidence, f.Check, f.Parameter, f.Endpoint, f.RequestID,
+		f.Payload, f.Evidence, f.LastSeenAt.Format(time.RFC3339),
+	)
+}
+
+type ProbeResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+	Duration   time.Duration
+}
@@ -0,0 +1,338 @@
+package models
+
+import (
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	HarVersion     = "1.2"
+	harHTTPVersion = "HTTP/1.1"
+	harBase64      = "base64"
+)
+
+type HAR struct {
+	Log HarLog `json:"log"`
+}
+
+type HarLog struct {
+	Version string     `json:"version"`
+	Creator HarCreator `json:"creator"`
+	Entries []HarEntry `json:"entries"`
+}
+
+type HarCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type HarEntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HarRequest  `json:"request"`
+	Response        HarResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HarTimings  `json:"timings"`
+}
+
+type HarRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarCookie    `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	QueryString []HarNameValue `json:"queryString"`
+	PostData    *HarPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HarResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HarCookie    `json:"cookies"`
+	Headers     []HarNameValue `json:"headers"`
+	Content     HarContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type HarNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HarCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	HTTPOnly bool   `json:"httpOnly,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+}
+
+type HarPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type HarContent struct {
+	Size        int    `json:"size"`
+	Compression int    `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+	Comment     string `json:"comment,omitempty"`
+}
+
+type HarTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+func NewHAR(creator HarCreator, entries []HarEntry) HAR {
+	return HAR{
+		Log: HarLog{
+			Version: HarVersion,
+			Creator: creator,
+			Entries: entries,
+		},
+	}
+}
+
+func HarEntryFromRequest(req Request, resp *Response, started time.Time) (HarEntry, error) {
+	httpReq, err := ConvertToHttpRequest(req)
+	if err != nil {
+		return HarEntry{}, err
+	}
+
+	harReq := HarRequest{
+		Method:      req.Method,
+		URL:         httpReq.URL.String(),
+		HTTPVersion: req.httpVersion(),
+		Cookies:     make([]HarCookie, 0),
+		Headers:     harHeaders(httpReq.Header),
+		QueryString: make([]HarNameValue, 0),
+		HeadersSize: -1,
+		BodySize:    len(req.Body),
+	}
+
+	for _, cookie := range httpReq.Cookies() {
+		harReq.Cookies = append(harReq.Cookies, HarCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	for key, values := range httpReq.URL.Query() {
+		for _, value := range values {
+			harReq.QueryString = append(harReq.QueryString, HarNameValue{Name: key, Value: value})
+		}
+	}
+
+	if req.Body != "" {
+		harReq.PostData = &HarPostData{
+			MimeType: httpReq.Header.Get("Content-Type"),
+			Text:     req.Body,
+		}
+	}
+
+	if !req.CreatedAt.IsZero() {
+		started = req.CreatedAt
+	}
+
+	entry := HarEntry{
+		StartedDateTime: started,
+		Request:         harReq,
+		Response: HarResponse{
+			HTTPVersion: req.httpVersion(),
+			Cookies:     make([]HarCookie, 0),
+			Headers:     make([]HarNameValue, 0),
+			HeadersSize: -1,
+		},
+	}
+
+	if resp == nil {
+		return entry, nil
+	}
+
+	var header http.Header
+	if err := json.Unmarshal([]byte(resp.Headers), &header); err != nil {
+		return HarEntry{}, err
+	}
+
+	entry.Time = float64(resp.Duration)
+	entry.Timings.Wait = float64(resp.Duration)
+	entry.Response.Status = resp.StatusCode
+	entry.Response.StatusText = http.StatusText(resp.StatusCode)
+	entry.Response.Headers = harHeaders(header)
+	entry.Response.RedirectURL = header.Get("Location")
+	entry.Response.BodySize = len(resp.Body)
+	entry.Response.Content = harContent(resp.Body, header)
+	if resp.Truncated {
+		entry.Response.BodySize = int(resp.ContentLength)
+		entry.Response.Content.Comment = "truncated to " + strconv.Itoa(len(resp.Body)) + " bytes"
+	}
+
+	httpResp := http.Response{Header: header}
+	for _, cookie := range httpResp.Cookies() {
+		harCookie := HarCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Domain:   cookie.Domain,
+			HTTPOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+		}
+		if !cookie.Expires.IsZero() {
+			harCookie.Expires = cookie.Expires.Format(time.RFC3339)
+		}
+		entry.Response.Cookies = append(entry.Response.Cookies, harCookie)
+	}
+
+	return entry, nil
+}
+
+func (e HarEntry) ToRequest() (Request, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return Request{}, err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return Request{}, errors.New("har entry url must be absolute: " + e.Request.URL)
+	}
+
+	header := http.Header{}
+	for _, h := range e.Request.Headers {
+		if strings.HasPrefix(h.Name, ":") || strings.EqualFold(h.Name, "Host") {
+			continue
+		}
+		header.Add(h.Name, h.Value)
+	}
+
+	var body string
+	if e.Request.PostData != nil {
+		body = e.Request.PostData.Text
+	}
+
+	encodedHeaders, err := json.Marshal(header)
+	if err != nil {
+		return Request{}, err
+	}
+
+	encodedParams, err := json.Marshal(u.Query())
+	if err != nil {
+		return Request{}, err
+	}
+
+	return Request{
+		Method:    e.Request.Method,
+		Host:      u.Host,
+		Scheme:    u.Scheme,
+		Path:      u.Path,
+		Headers:   string(encodedHeaders),
+		Body:      body,
+		Params:    string(encodedParams),
+		Proto:     e.Request.HTTPVersion,
+		CreatedAt: e.StartedDateTime,
+	}, nil
+}
+
+func (e HarEntry) ToResponse() (Response, bool, error) {
+	if e.Response.Status == 0 {
+		return Response{}, false, nil
+	}
+
+	body := e.Response.Content.Text
+	if e.Response.Content.Encoding == harBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return Response{}, false, err
+		}
+		body = string(decoded)
+	}
+
+	header := http.Header{}
+	for _, h := range e.Response.Headers {
+		if strings.HasPrefix(h.Name, ":") {
+			continue
+		}
+		header.Add(h.Name, h.Value)
+	}
+
+	header.Del("Content-Encoding")
+	if header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	encodedHeaders, err := json.Marshal(header)
+	if err != nil {
+		return Response{}, false, err
+	}
+
+	return Response{
+		StatusCode:    e.Response.Status,
+		Headers:       string(encodedHeaders),
+		Body:          body,
+		ContentLength: int64(len(body)),
+		Duration:      int64(e.Time),
+	}, true, nil
+}
+
+func harHeaders(header http.Header) []HarNameValue {
+	headers := make([]HarNameValue, 0, len(header))
+	for key, values := range header {
+		for _, value := range values {
+			headers = append(headers, HarNameValue{Name: key, Value: value})
+		}
+	}
+
+	return headers
+}
+
+func harContent(body string, header http.Header) HarContent {
+	content := HarContent{
+		Size:     len(body),
+		MimeType: header.Get("Content-Type"),
+	}
+
+	if strings.EqualFold(header.Get("Content-Encoding"), "gzip") {
+		reader, err := gzip.NewReader(strings.NewReader(body))
+		if err == nil {
+			decoded, err := ioutil.ReadAll(reader)
+			if err == nil {
+				content.Size = len(decoded)
+				content.Compression = len(decoded) - len(body)
+				body = string(decoded)
+			}
+		}
+	}
+
+	if utf8.ValidString(body) {
+		content.Text = body
+	} else {
+		content.Text = base64.StdEncoding.EncodeToString([]byte(body))
+		content.Encoding = harBase64
+	}
+
+	return content
+}
+
+func (r Request) httpVersion() string {
+	if r.Proto == "" {
+		return harHTTPVersion
+	}
+
+	return r.Proto
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	InterceptRequest  = "request"
+	InterceptResponse = "response"
+
+	ActionForward = "forward"
+	ActionDrop    = "drop"
+)
+
+type InterceptSettings struct {
+	Enabled   bool
+	Responses bool
+	Host      string
+	Methods   []string
+}
+
+func (s InterceptSettings) Validate() error {
+	if s.Host == "" {
+		return nil
+	}
+
+	if _, err := regexp.Compile(s.Host); err != nil {
+		return fmt.Errorf("invalid host pattern: %w", err)
+	}
+
+	return nil
+}
+
+func (s InterceptSettings) Match(req Request) bool {
+	if !s.Enabled {
+		return false
+	}
+
+	if len(s.Methods) != 0 {
+		found := false
+		for _, method := range s.Methods {
+			if strings.EqualFold(method, req.Method) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if s.Host != "" {
+		matched, err := regexp.MatchString(s.Host, req.Host)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+type InterceptedItem struct {
+	ID        int
+	Type      string
+	Request   Request
+	Response  Response
+	CreatedAt time.Time
+}
+
+type InterceptDecision struct {
+	Action   string
+	Request  Request
+	Response Response
+}
+
+func (i InterceptedItem) StringFromItem() string {
+	result := fmt.Sprintf("%d: [%s] ", i.ID, i.Type)
+	result += i.Request.Method + " " + i.Request.Scheme + "://" + i.Request.Host + i.Request.Path
+	if i.Type == InterceptResponse {
+		result += " -> " + i.Response.StringFromResponse()
+	}
+
+	return result
+}
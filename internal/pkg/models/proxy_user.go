@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+const minTokenLength = 16
+
+var userNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+type ProxyUser struct {
+	ID           int
+	Name         string
+	PasswordHash string
+	TokenHash    string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type ProxyUserEdit struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+}
+
+func (e ProxyUserEdit) Validate() error {
+	if !userNamePattern.MatchString(e.Name) {
+		return fmt.Errorf("invalid user name %q: use up to 64 letters, digits, '_', '.', '@' or '-'", e.Name)
+	}
+
+	if e.Password == "" && e.Token == "" {
+		return errors.New("user needs a password, a token or both")
+	}
+
+	if e.Token != "" && len(e.Token) < minTokenLength {
+		return fmt.Errorf("token must be at least %d characters long", minTokenLength)
+	}
+
+	return nil
+}
+
+func (u ProxyUser) StringFromUser() string {
+	auth := "password"
+	switch {
+	case u.PasswordHash != "" && u.TokenHash != "":
+		auth = "password, token"
+	case u.PasswordHash == "":
+		auth = "token"
+	}
+
+	return u.Name + " (" + auth + ")"
+}
@@ -9,20 +9,25 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Request struct {
-	ID      int
-	Method  string
-	Host    string
-	Scheme  string
-	Path    string
-	Headers string
-	Body    string
-	Params  string
+	ID        int
+	ParentID  int
+	Method    string
+	Host      string
+	Scheme    string
+	Path      string
+	Headers   string
+	Body      string
+	Params    string
+	Proto     string
+	User      string
+	CreatedAt time.Time
 }
 
-func ConvertFromHttpRequest(r http.Request) (Request, error) {
+func ConvertFromHttpRequest(r *http.Request) (Request, error) {
 	encodedHeaders, err := json.Marshal(r.Header)
 	if err != nil {
 		return Request{}, err
@@ -52,6 +57,7 @@ func ConvertFromHttpRequest(r http.Request) (Request, error) {
 		Headers: string(encodedHeaders),
 		Body:    string(body),
 		Params:  string(encodedParams),
+		Proto:   r.Proto,
 	}, nil
 }
 
@@ -64,7 +70,8 @@ func ConvertToHttpRequest(r Request) (http.Request, error) {
 			Host:   r.Host,
 			Path:   r.Path,
 		},
-		Body: ioutil.NopCloser(strings.NewReader(r.Body)),
+		Body:          ioutil.NopCloser(strings.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
 	}
 
 	var headers http.Header
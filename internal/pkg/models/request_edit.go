@@ -0,0 +1,163 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RequestEdit struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers"`
+	Body    *string     `json:"body"`
+}
+
+func ParseEditedRequest(contentType string, raw []byte, base Request) (Request, error) {
+	if !strings.HasPrefix(contentType, "application/json") {
+		return RequestFromRaw(raw, base)
+	}
+
+	var edit RequestEdit
+	if err := json.Unmarshal(raw, &edit); err != nil {
+		return Request{}, err
+	}
+
+	return edit.Apply(base)
+}
+
+func (e RequestEdit) Apply(base Request) (Request, error) {
+	req := base
+	req.ID = 0
+	req.ParentID = base.ID
+	req.CreatedAt = time.Time{}
+
+	if e.Method != "" {
+		req.Method = e.Method
+	}
+
+	if e.URL != "" {
+		if err := req.setURL(e.URL); err != nil {
+			return Request{}, err
+		}
+	}
+
+	if e.Headers != nil {
+		header := http.Header{}
+		for key, values := range e.Headers {
+			for _, value := range values {
+				header.Add(key, value)
+			}
+		}
+		header.Del("Host")
+
+		encodedHeaders, err := json.Marshal(header)
+		if err != nil {
+			return Request{}, err
+		}
+		req.Headers = string(encodedHeaders)
+	}
+
+	if e.Body != nil {
+		req.Body = *e.Body
+	}
+
+	return req.withContentLength()
+}
+
+func RequestFromRaw(raw []byte, base Request) (Request, error) {
+	reader := bufio.NewReader(bytes.NewReader(raw))
+	httpReq, err := http.ReadRequest(reader)
+	if err != nil {
+		return Request{}, err
+	}
+
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return Request{}, err
+	}
+
+	req := base
+	req.ID = 0
+	req.ParentID = base.ID
+	req.CreatedAt = time.Time{}
+	req.Method = httpReq.Method
+	req.Body = string(body)
+
+	if err := req.setURL(httpReq.RequestURI); err != nil {
+		return Request{}, err
+	}
+	if httpReq.URL.Host == "" && httpReq.Host != "" {
+		req.Host = httpReq.Host
+	}
+
+	header := httpReq.Header
+	header.Del("Transfer-Encoding")
+
+	encodedHeaders, err := json.Marshal(header)
+	if err != nil {
+		return Request{}, err
+	}
+	req.Headers = string(encodedHeaders)
+
+	return req.withContentLength()
+}
+
+func (r *Request) setURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Host != "" {
+		if u.Scheme == "" {
+			return errors.New("url with host must have scheme: " + rawURL)
+		}
+		r.Scheme = u.Scheme
+		r.Host = u.Host
+	}
+
+	if !strings.HasPrefix(u.Path, "/") {
+		u.Path = "/" + u.Path
+	}
+	r.Path = u.Path
+
+	encodedParams, err := json.Marshal(u.Query())
+	if err != nil {
+		return err
+	}
+	r.Params = string(encodedParams)
+
+	return nil
+}
+
+func (r Request) withContentLength() (Request, error) {
+	var header http.Header
+	if err := json.Unmarshal([]byte(r.Headers), &header); err != nil {
+		return Request{}, err
+	}
+
+	if r.Body == "" && header.Get("Content-Length") == "" {
+		return r, nil
+	}
+
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Content-Length", strconv.Itoa(len(r.Body)))
+
+	encodedHeaders, err := json.Marshal(header)
+	if err != nil {
+		return Request{}, err
+	}
+	r.Headers = string(encodedHeaders)
+
+	return r, nil
+}
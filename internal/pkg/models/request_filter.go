@@ -0,0 +1,207 @@
+package models
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
+)
+
+const (
+	DefaultRequestLimit = 100
+	MaxRequestLimit     = 1000
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+type RequestFilter struct {
+	Host   string
+	Method string
+	Scheme string
+	Path   string
+	Header string
+	Body   string
+	From   time.Time
+	To     time.Time
+	Query  string
+	Saved  string
+	Expr   query.Expr
+	Cursor int
+	Limit  int
+	Order  string
+}
+
+type RequestPage struct {
+	Requests   []Request
+	NextCursor int
+}
+
+func RequestFilterFromQuery(values url.Values, defaultLimit int) (RequestFilter, error) {
+	filter := RequestFilter{
+		Host:   strings.ToLower(values.Get("host")),
+		Method: strings.ToUpper(values.Get("method")),
+		Scheme: strings.ToLower(values.Get("scheme")),
+		Path:   values.Get("path"),
+		Header: values.Get("header"),
+		Body:   values.Get("body"),
+		Query:  values.Get("q"),
+		Saved:  values.Get("saved"),
+		Order:  OrderAsc,
+	}
+
+	if filter.Query != "" {
+		expr, err := query.Parse(filter.Query)
+		if err != nil {
+			return RequestFilter{}, err
+		}
+		filter.Expr = expr
+	}
+
+	times := map[string]*time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	}
+	for key, field := range times {
+		value := values.Get(key)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return RequestFilter{}, fmt.Errorf("invalid %s %q, expected RFC 3339 time", key, value)
+		}
+		*field = parsed
+	}
+
+	numbers := map[string]*int{
+		"cursor": &filter.Cursor,
+		"limit":  &filter.Limit,
+	}
+	for key, field := range numbers {
+		value := values.Get(key)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return RequestFilter{}, fmt.Errorf("invalid %s %q", key, value)
+		}
+		*field = parsed
+	}
+
+	if values.Get("limit") == "" {
+		filter.Limit = defaultLimit
+	} else if filter.Limit == 0 || filter.Limit > MaxRequestLimit {
+		return RequestFilter{}, fmt.Errorf("limit must be between 1 and %d", MaxRequestLimit)
+	}
+
+	if order := values.Get("order"); order != "" {
+		filter.Order = strings.ToLower(order)
+	}
+	if filter.Order != OrderAsc && filter.Order != OrderDesc {
+		return RequestFilter{}, fmt.Errorf("order must be %s or %s", OrderAsc, OrderDesc)
+	}
+
+	return filter, nil
+}
+
+func (f RequestFilter) Match(req Request, resp *Response) bool {
+	return (f.Host == "" || f.Host == strings.ToLower(req.Host)) &&
+		(f.Method == "" || f.Method == req.Method) &&
+		(f.Scheme == "" || f.Scheme == req.Scheme) &&
+		f.afterCursor(req.ID) &&
+		containsFold(req.Path, f.Path) &&
+		containsFold(req.Headers, f.Header) &&
+		containsFold(req.Body, f.Body) &&
+		(f.From.IsZero() || !req.CreatedAt.Before(f.From)) &&
+		(f.To.IsZero() || req.CreatedAt.Before(f.To)) &&
+		(f.Expr == nil || f.Expr.Eval(queryRecord(req, resp)))
+}
+
+func (f RequestFilter) Descending() bool {
+	return f.Order == OrderDesc
+}
+
+func (f RequestFilter) afterCursor(id int) bool {
+	if f.Cursor == 0 {
+		return true
+	}
+
+	if f.Descending() {
+		return id < f.Cursor
+	}
+
+	return id > f.Cursor
+}
+
+func (f RequestFilter) Values() url.Values {
+	values := url.Values{}
+	fields := map[string]string{
+		"host":   f.Host,
+		"method": f.Method,
+		"scheme": f.Scheme,
+		"path":   f.Path,
+		"header": f.Header,
+		"body":   f.Body,
+		"q":      f.Query,
+		"saved":  f.Saved,
+		"order":  f.Order,
+	}
+	for key, value := range fields {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	if !f.From.IsZero() {
+		values.Set("from", f.From.Format(time.RFC3339))
+	}
+	if !f.To.IsZero() {
+		values.Set("to", f.To.Format(time.RFC3339))
+	}
+	if f.Cursor != 0 {
+		values.Set("cursor", strconv.Itoa(f.Cursor))
+	}
+	if f.Limit != 0 {
+		values.Set("limit", strconv.Itoa(f.Limit))
+	}
+
+	return values
+}
+
+func queryRecord(req Request, resp *Response) query.Record {
+	record := query.Record{
+		"req.id":        int64(req.ID),
+		"req.parent_id": int64(req.ParentID),
+		"req.method":    req.Method,
+		"req.scheme":    req.Scheme,
+		"req.proto":     req.Proto,
+		"req.user":      req.User,
+		"req.host":      req.Host,
+		"req.path":      req.Path,
+		"req.query":     req.Params,
+		"req.headers":   req.Headers,
+		"req.body":      req.Body,
+		"req.time":      req.CreatedAt,
+	}
+
+	if resp != nil {
+		record["resp.status"] = int64(resp.StatusCode)
+		record["resp.headers"] = resp.Headers
+		record["resp.body"] = resp.Body
+		record["resp.length"] = resp.ContentLength
+		record["resp.duration"] = resp.Duration
+	}
+
+	return record
+}
+
+func containsFold(s, substr string) bool {
+	return substr == "" || strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+type Response struct {
+	ID            int
+	RequestID     int
+	StatusCode    int
+	Headers       string
+	Body          string
+	ContentLength int64
+	Duration      int64
+	// Truncated is set when only the beginning of a longer body was kept
+	Truncated bool
+}
+
+func ConvertFromHttpResponse(resp *http.Response) (Response, error) {
+	response, err := ConvertFromHttpResponseHeader(resp)
+	if err != nil {
+		return Response{}, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Response{}, err
+	}
+	resp.Body.Close()
+
+	resp.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+	response.Body = string(body)
+	response.ContentLength = int64(len(body))
+
+	return response, nil
+}
+
+// ConvertFromHttpResponseHeader converts the response without reading its
+// body, for bodies recorded while they are streamed to the client.
+func ConvertFromHttpResponseHeader(resp *http.Response) (Response, error) {
+	encodedHeaders, err := json.Marshal(resp.Header)
+	if err != nil {
+		return Response{}, err
+	}
+
+	return Response{
+		StatusCode: resp.StatusCode,
+		Headers:    string(encodedHeaders),
+	}, nil
+}
+
+// Validate checks a response edited during interception before it is
+// written to the client.
+func (r Response) Validate() error {
+	if r.StatusCode < 100 || r.StatusCode > 999 {
+		return fmt.Errorf("status code must be between 100 and 999, got %d", r.StatusCode)
+	}
+
+	var headers map[string][]string
+	if err := json.Unmarshal([]byte(r.Headers), &headers); err != nil {
+		return fmt.Errorf("invalid headers: %w", err)
+	}
+	if headers == nil {
+		return errors.New("headers must be a JSON object")
+	}
+
+	return nil
+}
+
+func (r Response) StringFromResponse() string {
+	length := fmt.Sprintf("%d bytes", r.ContentLength)
+	if r.Truncated {
+		length += fmt.Sprintf(", %d kept", len(r.Body))
+	}
+
+	return fmt.Sprintf("%d %s (%s, %d ms)", r.StatusCode, http.StatusText(r.StatusCode), length, r.Duration)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+const (
+	TargetRequestLine    = "request_line"
+	TargetRequestHeader  = "request_header"
+	TargetRequestBody    = "request_body"
+	TargetResponseHeader = "response_header"
+	TargetResponseBody   = "response_body"
+)
+
+type Rule struct {
+	ID      int
+	Enabled bool
+	Target  string
+	Match   string
+	Replace string
+	IsRegex bool
+	Host    string
+	Path    string
+	Method  string
+}
+
+func (r Rule) Validate() error {
+	switch r.Target {
+	case TargetRequestLine, TargetRequestHeader, TargetRequestBody,
+		TargetResponseHeader, TargetResponseBody:
+	default:
+		return fmt.Errorf("unknown target: %q", r.Target)
+	}
+
+	if r.Match == "" && r.Target != TargetRequestHeader && r.Target != TargetResponseHeader {
+		return errors.New("match is required")
+	}
+
+	if r.IsRegex {
+		if _, err := regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("invalid match: %w", err)
+		}
+	}
+
+	if _, err := regexp.Compile(r.Host); err != nil {
+		return fmt.Errorf("invalid host scope: %w", err)
+	}
+
+	if _, err := regexp.Compile(r.Path); err != nil {
+		return fmt.Errorf("invalid path scope: %w", err)
+	}
+
+	return nil
+}
+
+func (r Rule) StringFromRule() string {
+	kind := "literal"
+	if r.IsRegex {
+		kind = "regex"
+	}
+
+	result := fmt.Sprintf("%d: [%s] %s %q -> %q (%s)", r.ID, r.Target, kind, r.Match, r.Replace, r.scope())
+	if !r.Enabled {
+		result += " disabled"
+	}
+
+	return result
+}
+
+func (r Rule) scope() string {
+	method, host, path := r.Method, r.Host, r.Path
+	if method == "" {
+		method = "*"
+	}
+	if host == "" {
+		host = "*"
+	}
+	if path == "" {
+		path = "*"
+	}
+
+	return method + " " + host + " " + path
+}
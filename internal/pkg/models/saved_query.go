@@ -0,0 +1,32 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
+)
+
+var queryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+type SavedQuery struct {
+	ID        int
+	Name      string
+	Query     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q SavedQuery) Validate() error {
+	if !queryNamePattern.MatchString(q.Name) {
+		return fmt.Errorf("invalid name %q: use up to 64 letters, digits, '_', '.' or '-'", q.Name)
+	}
+
+	_, err := query.Parse(q.Query)
+	return err
+}
+
+func (q SavedQuery) StringFromQuery() string {
+	return q.Name + ": " + q.Query
+}
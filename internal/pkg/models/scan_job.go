@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+)
+
+type ScanJob struct {
+	ID        int
+	RequestID int
+	Status    string
+	Progress  int
+	Total     int
+	Error     string
+	Found     int
+	Findings  []Finding
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (j ScanJob) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+func (j ScanJob) StringFromJob() string {
+	result := fmt.Sprintf("%d: request %d, %s", j.ID, j.RequestID, j.Status)
+	if j.Total > 0 {
+		result += fmt.Sprintf(" %d/%d", j.Progress, j.Total)
+	}
+	if j.Finished() || j.Found > 0 {
+		result += fmt.Sprintf(", %d findings", j.Found)
+	}
+	if j.Error != "" {
+		result += ", error: " + j.Error
+	}
+
+	return result
+}
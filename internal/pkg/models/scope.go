@@ -0,0 +1,125 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	ScopeInclude = "include"
+	ScopeExclude = "exclude"
+)
+
+type ScopeRule struct {
+	ID      int
+	Enabled bool
+	Action  string
+	Host    string
+	Port    int
+	Scheme  string
+	Path    string
+}
+
+// ScopeTarget is what scope rules are matched against, Path is empty when
+// only the host is known, e.g. before a tunnel is decrypted.
+type ScopeTarget struct {
+	Scheme string
+	Host   string
+	Port   int
+	Path   string
+}
+
+func NewScopeTarget(scheme, hostport, path string) ScopeTarget {
+	target := ScopeTarget{
+		Scheme: strings.ToLower(scheme),
+		Host:   hostport,
+		Path:   path,
+	}
+
+	if host, port, err := net.SplitHostPort(hostport); err == nil {
+		target.Host = host
+		target.Port, _ = strconv.Atoi(port)
+	}
+	target.Host = strings.ToLower(strings.TrimSuffix(target.Host, "."))
+
+	if target.Port == 0 {
+		switch target.Scheme {
+		case "http":
+			target.Port = 80
+		case "https":
+			target.Port = 443
+		}
+	}
+
+	return target
+}
+
+func (r ScopeRule) Validate() error {
+	switch r.Action {
+	case ScopeInclude, ScopeExclude:
+	default:
+		return fmt.Errorf("unknown action: %q", r.Action)
+	}
+
+	if r.Host == "" {
+		return errors.New("host is required, use * for any host")
+	}
+
+	if _, err := path.Match(r.Host, ""); err != nil {
+		return fmt.Errorf("invalid host glob: %w", err)
+	}
+
+	if r.Port < 0 || r.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", r.Port)
+	}
+
+	switch r.Scheme {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("unknown scheme: %q", r.Scheme)
+	}
+
+	if _, err := regexp.Compile(r.Path); err != nil {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	return nil
+}
+
+// MatchesHost reports whether the rule matches the target, ignoring the path.
+func (r ScopeRule) MatchesHost(target ScopeTarget) bool {
+	if r.Scheme != "" && r.Scheme != target.Scheme {
+		return false
+	}
+
+	if r.Port != 0 && r.Port != target.Port {
+		return false
+	}
+
+	matched, err := path.Match(strings.ToLower(r.Host), target.Host)
+	return err == nil && matched
+}
+
+func (r ScopeRule) StringFromRule() string {
+	result := r.Action + " "
+	if r.Scheme != "" {
+		result += r.Scheme + "://"
+	}
+	result += r.Host
+	if r.Port != 0 {
+		result += ":" + strconv.Itoa(r.Port)
+	}
+	if r.Path != "" {
+		result += " path ~ " + r.Path
+	}
+	if !r.Enabled {
+		result += " (disabled)"
+	}
+
+	return result
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	PassthroughHost      = "host"
+	PassthroughHandshake = "handshake"
+)
+
+// Tunnel is a CONNECT tunnel relayed without decryption, only its metadata
+// is recorded.
+type Tunnel struct {
+	ID            int
+	Host          string
+	Port          int
+	SNI           string
+	Reason        string
+	User          string
+	BytesSent     int64
+	BytesReceived int64
+	Duration      int64
+	CreatedAt     time.Time
+}
+
+func (t Tunnel) StringFromTunnel() string {
+	result := fmt.Sprintf(
+		"%d: %s %s, %s",
+		t.ID, t.CreatedAt.Format(time.RFC3339), net.JoinHostPort(t.Host, strconv.Itoa(t.Port)), t.Reason,
+	)
+	if t.SNI != "" {
+		result += ", sni " + t.SNI
+	}
+	if t.User != "" {
+		result += ", user " + t.User
+	}
+
+	return result + fmt.Sprintf(
+		", %d bytes sent, %d bytes received, %d ms",
+		t.BytesSent, t.BytesReceived, t.Duration,
+	)
+}
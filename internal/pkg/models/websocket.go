@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DirectionToServer = "to_server"
+	DirectionToClient = "to_client"
+)
+
+var opcodeNames = map[int]string{
+	0:  "continuation",
+	1:  "text",
+	2:  "binary",
+	8:  "close",
+	9:  "ping",
+	10: "pong",
+}
+
+type WebSocketMessage struct {
+	ID        int
+	RequestID int
+	ParentID  int
+	Direction string
+	Opcode    int
+	Payload   string
+	CreatedAt time.Time
+}
+
+type WebSocketConnection struct {
+	Request       Request
+	Messages      []WebSocketMessage
+	MessageCount  int
+	LastMessageAt time.Time
+}
+
+type WebSocketEdit struct {
+	Opcode  string  `json:"opcode"`
+	Payload *string `json:"payload"`
+}
+
+func OpcodeName(opcode int) string {
+	if name, ok := opcodeNames[opcode]; ok {
+		return name
+	}
+
+	return strconv.Itoa(opcode)
+}
+
+func ParseOpcode(name string) (int, error) {
+	for opcode, candidate := range opcodeNames {
+		if candidate == name {
+			return opcode, nil
+		}
+	}
+
+	return 0, fmt.Errorf("unknown opcode %q", name)
+}
+
+func (e WebSocketEdit) Apply(msg WebSocketMessage) (WebSocketMessage, error) {
+	edited := msg
+	edited.ID = 0
+	edited.ParentID = msg.ID
+
+	if e.Opcode != "" {
+		opcode, err := ParseOpcode(e.Opcode)
+		if err != nil {
+			return WebSocketMessage{}, err
+		}
+		edited.Opcode = opcode
+	}
+
+	if e.Payload != nil {
+		edited.Payload = *e.Payload
+	}
+
+	return edited, nil
+}
+
+func (m WebSocketMessage) IsData() bool {
+	return m.Opcode == 1 || m.Opcode == 2
+}
+
+func (m WebSocketMessage) StringFromMessage() string {
+	arrow := "->"
+	if m.Direction == DirectionToClient {
+		arrow = "<-"
+	}
+
+	payload := m.Payload
+	if !utf8.ValidString(payload) {
+		payload = fmt.Sprintf("%d bytes of binary data", len(payload))
+	}
+
+	return fmt.Sprintf(
+		"%d: %s %s %s %s",
+		m.ID, m.CreatedAt.Format(time.RFC3339), arrow, OpcodeName(m.Opcode), payload,
+	)
+}
+
+func (c WebSocketConnection) StringFromConnection() string {
+	return fmt.Sprintf(
+		"%s, %d messages, last at %s",
+		c.Request.StringFromRequest(), c.MessageCount, c.LastMessageAt.Format(time.RFC3339),
+	)
+}
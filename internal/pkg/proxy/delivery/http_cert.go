@@ -0,0 +1,67 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/pkg/cert"
+)
+
+const certPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>httpproxy CA</title>
+</head>
+<body>
+	%s<br>
+	SHA-256: %x<br>
+	<a href="/cert">certificate (DER)</a><br>
+	<a href="/cert.pem">certificate (PEM)</a>
+</body>
+</html>`
+
+// IsCertRequest reports whether r is addressed to the proxy itself, either
+// sent straight to the listener or to the configured cert host.
+func (h ProxyHandler) IsCertRequest(r *http.Request) bool {
+	if r.Method == http.MethodConnect {
+		return false
+	}
+
+	if r.URL.Host == "" {
+		return true
+	}
+
+	return h.config.CertHost != "" && strings.EqualFold(r.URL.Hostname(), h.config.CertHost)
+}
+
+// ServeCert lets clients download the CA certificate to install it.
+func (h ProxyHandler) ServeCert(w http.ResponseWriter, r *http.Request) {
+	ca := h.certs.CA()
+
+	var body []byte
+	switch r.URL.Path {
+	case "/":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		body = []byte(fmt.Sprintf(certPage, html.EscapeString(ca.Subject.String()), sha256.Sum256(ca.Raw)))
+	case "/cert":
+		w.Header().Set("Content-Type", "application/x-x509-ca-cert")
+		w.Header().Set("Content-Disposition", `attachment; filename="httpproxy-ca.crt"`)
+		body = ca.Raw
+	case "/cert.pem":
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Header().Set("Content-Disposition", `attachment; filename="httpproxy-ca.pem"`)
+		body = cert.EncodePEM(ca)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	if _, err := w.Write(body); err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
@@ -1,34 +1,93 @@
 package delivery
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
-	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	authInterfaces "github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	authUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	interceptInterfaces "github.com/aanufriev/httpproxy/internal/pkg/intercept/interfaces"
+	interceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
 	"github.com/aanufriev/httpproxy/internal/pkg/models"
 	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	rulesInterfaces "github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+	scopeInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+	tunnelInterfaces "github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+	websocketInterfaces "github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/protocol"
 	"github.com/aanufriev/httpproxy/pkg/cert"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
 )
 
 type ProxyHandler struct {
-	usecase interfaces.Usecase
+	usecase          interfaces.Usecase
+	interceptUsecase interceptInterfaces.Usecase
+	rulesUsecase     rulesInterfaces.Usecase
+	websocketUsecase websocketInterfaces.Usecase
+	authUsecase      authInterfaces.Usecase
+	scopeUsecase     scopeInterfaces.Usecase
+	tunnelUsecase    tunnelInterfaces.Usecase
+	config           config.ProxyConfig
+	certs            *cert.CertManager
+	upstream         *upstream.Dialer
+	transport        *http.Transport
 }
 
-func NewProxyHandler(usecase interfaces.Usecase) ProxyHandler {
+func NewProxyHandler(
+	usecase interfaces.Usecase, interceptUsecase interceptInterfaces.Usecase,
+	rulesUsecase rulesInterfaces.Usecase, websocketUsecase websocketInterfaces.Usecase,
+	authUsecase authInterfaces.Usecase, scopeUsecase scopeInterfaces.Usecase, tunnelUsecase tunnelInterfaces.Usecase,
+	cfg config.ProxyConfig, certs *cert.CertManager, dialer *upstream.Dialer,
+) ProxyHandler {
 	return ProxyHandler{
-		usecase: usecase,
+		usecase:          usecase,
+		interceptUsecase: interceptUsecase,
+		rulesUsecase:     rulesUsecase,
+		websocketUsecase: websocketUsecase,
+		authUsecase:      authUsecase,
+		scopeUsecase:     scopeUsecase,
+		tunnelUsecase:    tunnelUsecase,
+		config:           cfg,
+		certs:            certs,
+		upstream:         dialer,
+		transport:        dialer.Transport(),
 	}
 }
 
 func (h ProxyHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrade := protocol.IsUpgrade(r)
+	if upgrade {
+		r.Header.Del("Sec-WebSocket-Extensions")
+	}
+
+	r, err := h.saveRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if upgrade {
+		h.proxyWebSocket(w, r, h.dialTCP(r.Host))
+		return
+	}
+
 	client := http.Client{
-		Timeout: time.Second * 10,
+		Transport: h.transport,
+		Timeout:   h.config.ClientTimeout,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
 		},
@@ -40,7 +99,14 @@ func (h ProxyHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
-	defer resp.Body.Close()
+	// saveResponse replaces the body with one that is saved once closed
+	defer func() { resp.Body.Close() }()
+
+	err = h.saveResponse(resp)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
 
 	for key, values := range resp.Header {
 		for _, value := range values {
@@ -50,155 +116,451 @@ func (h ProxyHandler) HandleHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(resp.StatusCode)
 
-	_, err = io.Copy(w, resp.Body)
+	var dst io.Writer = w
+	if flusher, ok := w.(http.Flusher); ok && resp.ContentLength == -1 {
+		// a body of unknown length may be a stream, e.g. server-sent events
+		dst = flushWriter{Writer: w, flusher: flusher}
+	}
+
+	_, err = io.Copy(dst, resp.Body)
 	if err != nil {
 		log.Printf("transfer answer err: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
+}
 
-	req, err := models.ConvertFromHttpRequest(*r)
+func (h ProxyHandler) HandleHTTPS(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
 	if err != nil {
-		log.Printf("couldn't convert request: %v", err)
+		log.Printf("couldn't get host: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	err = h.usecase.SaveRequest(req)
+	_, passthrough := h.tunnelUsecase.Passthrough(r.Host)
+	if !passthrough && h.scopeUsecase.InScopeHost("https", r.Host) {
+		_, err = h.certs.GetCertificate(host, nil)
+		if err != nil {
+			log.Printf("couldn't get certifate: %v", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	raw, _, err := w.(http.Hijacker).Hijack()
 	if err != nil {
-		log.Printf("couldn't save request to db: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
 		return
 	}
-}
 
-func (h ProxyHandler) HandleHTTPS(w http.ResponseWriter, r *http.Request) {
-	host, _, err := net.SplitHostPort(r.Host)
-	if err != nil {
-		log.Printf("couldn't get host: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	// the server timeouts are for the CONNECT request, not the tunnel, older
+	// Go versions keep them on hijacked connections
+	if err = raw.SetDeadline(time.Time{}); err != nil {
+		raw.Close()
 		return
 	}
 
-	certificate, err := cert.GetCertificate(host)
-	if err != nil {
-		log.Printf("couldn't get certifate: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	if _, err = raw.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
+		raw.Close()
 		return
 	}
 
-	var serverConn *tls.Conn
+	h.serveTunnel(r.Context(), raw, r.Host)
+}
 
-	serverConfig := new(tls.Config)
+func (h ProxyHandler) wrap(upstream http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrade := protocol.IsUpgrade(r)
+		if upgrade {
+			r.Header.Del("Sec-WebSocket-Extensions")
+		}
 
-	serverConfig.Certificates = []tls.Certificate{certificate}
-	serverConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-		clientConfig := new(tls.Config)
-		clientConfig.ServerName = hello.ServerName
-		serverConn, err = tls.Dial("tcp", r.Host, clientConfig)
+		r, err := h.saveRequest(r)
 		if err != nil {
-			log.Printf("dial tcp error: %v", err)
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
-			return nil, err
+			http.Error(w, err.Error(), errorStatus(err))
+			return
 		}
 
-		helloCert, err := cert.GetCertificate(hello.ServerName)
+		if upgrade {
+			h.proxyWebSocket(w, r, h.dialTLS(r.Host))
+			return
+		}
+
+		upstream.ServeHTTP(w, r)
+	})
+}
+
+type captureKey struct{}
+
+type capture struct {
+	requestID int
+	request   models.Request
+	start     time.Time
+	clientCtx context.Context
+}
+
+func (h ProxyHandler) saveRequest(r *http.Request) (*http.Request, error) {
+	req, err := models.ConvertFromHttpRequest(r)
+	if err != nil {
+		log.Printf("couldn't convert request: %v", err)
+		return nil, err
+	}
+
+	if !h.scopeUsecase.InScope(req) {
+		return r, nil
+	}
+
+	rewritten, applied, err := h.rulesUsecase.ApplyToRequest(req)
+	if err != nil {
+		log.Printf("couldn't apply rules to request: %v", err)
+		rewritten, applied = req, nil
+	}
+
+	restore := h.liftDeadlines(r.Context(), h.interceptUsecase.GetSettings().Match(rewritten))
+	intercepted, err := h.interceptUsecase.InterceptRequest(r.Context(), rewritten)
+	restore()
+	if err != nil {
+		log.Printf("request not forwarded: %v", err)
+		return nil, err
+	}
+
+	if intercepted != req {
+		r, err = applyRequest(r, intercepted)
 		if err != nil {
-			log.Printf("couldn't get cert: %v", err)
-			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			log.Printf("couldn't apply modified request: %v", err)
 			return nil, err
 		}
-
-		return &helloCert, nil
+		req = intercepted
 	}
+	req.User = authUsecase.UserFromContext(r.Context())
 
-	clientConn, err := h.handshake(w, serverConfig)
+	id, err := h.usecase.SaveRequest(req)
 	if err != nil {
-		return
+		log.Printf("couldn't save request to db: %v", err)
+		return nil, err
 	}
-	defer clientConn.Close()
-	defer serverConn.Close()
 
-	rp := &httputil.ReverseProxy{
-		Director: func(r *http.Request) {
-			r.URL.Host = r.Host
-			r.URL.Scheme = "https"
-		},
-		Transport: &http.Transport{
-			DialTLSContext: func(
-				ctx context.Context, network string, address string,
-			) (net.Conn, error) {
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				default:
-					return serverConn, nil
-				}
-			}},
+	err = h.rulesUsecase.SaveApplied(id, applied)
+	if err != nil {
+		log.Printf("couldn't save applied rules: %v", err)
 	}
 
-	wc := newOnCloseConn(clientConn)
-	http.Serve(&oneShotListener{wc}, h.wrap(rp))
-	wc.Wait()
+	ctx := context.WithValue(r.Context(), captureKey{}, capture{
+		requestID: id,
+		request:   req,
+		start:     time.Now(),
+		clientCtx: r.Context(),
+	})
+
+	return r.WithContext(ctx), nil
 }
 
-func (h ProxyHandler) wrap(upstream http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req, err := models.ConvertFromHttpRequest(*r)
+// saveResponse records the response and applies rules and interception to
+// it. The body is only read in advance when it may be edited, otherwise it
+// is saved once it has been streamed to the client.
+func (h ProxyHandler) saveResponse(resp *http.Response) error {
+	info, ok := resp.Request.Context().Value(captureKey{}).(capture)
+	if !ok {
+		return nil
+	}
+
+	settings := h.interceptUsecase.GetSettings()
+	hold := settings.Responses && settings.Match(info.request)
+	if hold || h.rulesUsecase.RewritesResponseBody(info.request) {
+		buffered, err := bufferBody(resp, h.config.MaxBodySize)
 		if err != nil {
-			log.Printf("couldn't convert request: %v", err)
-			return
+			log.Printf("couldn't read response: %v", err)
+			return err
+		}
+
+		if buffered {
+			return h.saveBufferedResponse(resp, info, hold)
 		}
+		log.Printf("response to request %d is longer than %d bytes, passing it through unedited", info.requestID, h.config.MaxBodySize)
+	}
+
+	response, err := models.ConvertFromHttpResponseHeader(resp)
+	if err != nil {
+		log.Printf("couldn't convert response: %v", err)
+		return err
+	}
+	response.RequestID = info.requestID
+
+	rewritten, applied, err := h.rulesUsecase.ApplyToResponse(info.request, response)
+	if err != nil {
+		log.Printf("couldn't apply rules to response: %v", err)
+		rewritten, applied = response, nil
+	}
 
-		err = h.usecase.SaveRequest(req)
+	if rewritten.Headers != response.Headers {
+		var headers http.Header
+		err = json.Unmarshal([]byte(rewritten.Headers), &headers)
 		if err != nil {
-			log.Printf("couldn't save request to db: %v", err)
-			return
+			log.Printf("couldn't apply modified response: %v", err)
+			return err
 		}
+		resp.Header = headers
+	}
 
-		upstream.ServeHTTP(w, r)
-	})
+	save := func(body []byte, length int64, truncated bool) {
+		rewritten.Body = string(body)
+		rewritten.ContentLength = length
+		rewritten.Truncated = truncated
+		rewritten.Duration = time.Since(info.start).Milliseconds()
+
+		if err := h.usecase.SaveResponse(rewritten); err != nil {
+			log.Printf("couldn't save response to db: %v", err)
+		}
+	}
+
+	if resp.Body == http.NoBody {
+		save(nil, 0, false)
+	} else {
+		resp.Body = &recordedBody{
+			ReadCloser: resp.Body,
+			record:     &prefixBuffer{limit: int(h.config.MaxBodySize)},
+			save:       save,
+		}
+	}
+
+	err = h.rulesUsecase.SaveApplied(info.requestID, applied)
+	if err != nil {
+		log.Printf("couldn't save applied rules: %v", err)
+	}
+
+	return nil
 }
 
-func (h ProxyHandler) handshake(w http.ResponseWriter, config *tls.Config) (net.Conn, error) {
-	raw, _, err := w.(http.Hijacker).Hijack()
+func (h ProxyHandler) saveBufferedResponse(resp *http.Response, info capture, hold bool) error {
+	response, err := models.ConvertFromHttpResponse(resp)
 	if err != nil {
-		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
-		return nil, err
+		log.Printf("couldn't convert response: %v", err)
+		return err
 	}
 
-	if _, err = raw.Write([]byte("HTTP/1.1 200 OK\r\n\r\n")); err != nil {
-		raw.Close()
-		return nil, err
+	response.RequestID = info.requestID
+	response.Duration = time.Since(info.start).Milliseconds()
+
+	rewritten, applied, err := h.rulesUsecase.ApplyToResponse(info.request, response)
+	if err != nil {
+		log.Printf("couldn't apply rules to response: %v", err)
+		rewritten, applied = response, nil
 	}
 
-	conn := tls.Server(raw, config)
-	err = conn.Handshake()
+	restore := h.liftDeadlines(info.clientCtx, hold)
+	intercepted, err := h.interceptUsecase.InterceptResponse(info.clientCtx, info.request, rewritten)
+	restore()
+	if err != nil {
+		log.Printf("response not forwarded: %v", err)
+		return err
+	}
+
+	if intercepted != response {
+		err = applyResponse(resp, intercepted)
+		if err != nil {
+			log.Printf("couldn't apply modified response: %v", err)
+			return err
+		}
+		response = intercepted
+	}
+
+	err = h.usecase.SaveResponse(response)
+	if err != nil {
+		log.Printf("couldn't save response to db: %v", err)
+	}
+
+	err = h.rulesUsecase.SaveApplied(info.requestID, applied)
+	if err != nil {
+		log.Printf("couldn't save applied rules: %v", err)
+	}
+
+	return nil
+}
+
+// bufferBody reads a body of at most limit bytes into memory. A longer body
+// is left to be streamed and false is returned.
+func bufferBody(resp *http.Response, limit int64) (bool, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return false, err
+	}
+
+	if int64(len(body)) > limit {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return false, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return true, nil
+}
+
+// recordedBody keeps the beginning of the body while it is read and passes
+// it to save once the body is read to the end or closed.
+type recordedBody struct {
+	io.ReadCloser
+	record *prefixBuffer
+	length int64
+	once   sync.Once
+	save   func(body []byte, length int64, truncated bool)
+}
+
+func (b *recordedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.record.Write(p[:n])
+	b.length += int64(n)
+
+	if err == io.EOF {
+		b.finish(true)
+	}
+
+	return n, err
+}
+
+func (b *recordedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish(false)
+
+	return err
+}
+
+func (b *recordedBody) finish(complete bool) {
+	b.once.Do(func() {
+		b.save(b.record.data, b.length, !complete || b.length > int64(len(b.record.data)))
+	})
+}
+
+type connKey struct{}
+
+// WithConn keeps the client connection in the request context, it is the
+// ConnContext of the proxy server.
+func WithConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// liftDeadlines clears the server timeouts of the client connection while
+// the interceptor may hold an item, so that held items are bound only by
+// the intercept timeout. The returned func restarts the write timeout for
+// the rest of the exchange.
+func (h ProxyHandler) liftDeadlines(ctx context.Context, hold bool) func() {
+	conn, ok := ctx.Value(connKey{}).(net.Conn)
+	if !ok || !hold {
+		return func() {}
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	return func() {
+		if h.config.WriteTimeout > 0 {
+			_ = conn.SetWriteDeadline(time.Now().Add(h.config.WriteTimeout))
+		}
+	}
+}
+
+func applyRequest(r *http.Request, req models.Request) (*http.Request, error) {
+	edited, err := models.ConvertToHttpRequest(req)
 	if err != nil {
-		conn.Close()
-		raw.Close()
 		return nil, err
 	}
-	return conn, nil
+
+	edited.RemoteAddr = r.RemoteAddr
+	edited.Proto, edited.ProtoMajor, edited.ProtoMinor = r.Proto, r.ProtoMajor, r.ProtoMinor
+
+	return edited.WithContext(r.Context()), nil
+}
+
+func applyResponse(resp *http.Response, response models.Response) error {
+	var headers http.Header
+	err := json.Unmarshal([]byte(response.Headers), &headers)
+	if err != nil {
+		return err
+	}
+
+	resp.StatusCode = response.StatusCode
+	resp.Status = fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	if headers == nil {
+		headers = http.Header{}
+	}
+	resp.Header = headers
+	resp.Header.Set("Content-Length", strconv.Itoa(len(response.Body)))
+	resp.ContentLength = int64(len(response.Body))
+	resp.Body = ioutil.NopCloser(strings.NewReader(response.Body))
+
+	return nil
 }
 
-type onCloseConn struct {
-	net.Conn
-	stopChan chan struct{}
+type flushWriter struct {
+	io.Writer
+	flusher http.Flusher
+}
+
+func (w flushWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.flusher.Flush()
+
+	return n, err
 }
 
-func newOnCloseConn(conn net.Conn) *onCloseConn {
-	c := &onCloseConn{conn, make(chan struct{})}
-	return c
+func errorStatus(err error) int {
+	if err == interceptUsecase.ErrDropped {
+		return http.StatusBadGateway
+	}
+
+	return http.StatusServiceUnavailable
 }
 
-func (c *onCloseConn) Close() error {
-	c.stopChan <- struct{}{}
-	return c.Conn.Close()
+// serveConn serves HTTP/1.1 or h2, whichever was negotiated, on a single
+// connection and returns once it is closed or a hijacking handler is done.
+// Requests get contexts derived from ctx.
+func serveConn(ctx context.Context, conn net.Conn, handler http.Handler) {
+	done := make(chan struct{})
+	var once sync.Once
+	finish := func() {
+		once.Do(func() { close(done) })
+	}
+
+	var hijacked int32
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler.ServeHTTP(w, r)
+			if atomic.LoadInt32(&hijacked) == 1 {
+				finish()
+			}
+		}),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+		// conn has no server timeouts to lift, unlike the one ctx came from
+		ConnContext: func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, nil)
+		},
+		ConnState: func(_ net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateHijacked:
+				atomic.StoreInt32(&hijacked, 1)
+			case http.StateClosed:
+				finish()
+			}
+		},
+	}
+
+	server.Serve(&oneShotListener{conn})
+	<-done
 }
 
-func (c *onCloseConn) Wait() {
-	<-c.stopChan
+func supportedProtos(offered []string) []string {
+	protos := make([]string, 0, len(offered))
+	for _, proto := range offered {
+		if proto == "h2" || proto == "http/1.1" {
+			protos = append(protos, proto)
+		}
+	}
+
+	return protos
 }
 
 type oneShotListener struct {
@@ -0,0 +1,400 @@
+package delivery
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	interceptInterfaces "github.com/aanufriev/httpproxy/internal/pkg/intercept/interfaces"
+	interceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyRepository "github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+	rulesRepository "github.com/aanufriev/httpproxy/internal/pkg/rules/repository"
+	rulesUsecase "github.com/aanufriev/httpproxy/internal/pkg/rules/usecase"
+	scopeRepository "github.com/aanufriev/httpproxy/internal/pkg/scope/repository"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+func httpHandler(t *testing.T, intercept interceptInterfaces.Usecase) ProxyHandler {
+	t.Helper()
+
+	dialer, err := upstream.New("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ProxyHandler{
+		usecase:          proxyUsecase.NewProxyUsecase(proxyRepository.NewMemoryRepository(10)),
+		interceptUsecase: intercept,
+		rulesUsecase:     rulesUsecase.NewRulesUsecase(rulesRepository.NewMemoryRepository(10)),
+		scopeUsecase:     scopeUsecase.NewScopeUsecase(scopeRepository.NewMemoryRepository()),
+		config: config.ProxyConfig{
+			ReadTimeout:   100 * time.Millisecond,
+			WriteTimeout:  100 * time.Millisecond,
+			ClientTimeout: time.Second,
+			MaxBodySize:   1 << 20,
+		},
+		upstream:  dialer,
+		transport: dialer.Transport(),
+	}
+}
+
+// proxyServer serves h the way the proxy server does, with its timeouts.
+func proxyServer(t *testing.T, h ProxyHandler) *http.Client {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RequestURI = ""
+		h.HandleHTTP(w, r)
+	}))
+	server.Config.ReadTimeout = h.config.ReadTimeout
+	server.Config.WriteTimeout = h.config.WriteTimeout
+	server.Config.ConnContext = WithConn
+	server.Start()
+	t.Cleanup(server.Close)
+
+	proxyURL, _ := url.Parse(server.URL)
+	return &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)},
+		Timeout:   5 * time.Second,
+	}
+}
+
+// resolveAfter resolves every held item after delay with the decision made
+// by decide.
+func resolveAfter(
+	intercept interceptInterfaces.Usecase, delay time.Duration, decide func(models.InterceptedItem) models.InterceptDecision,
+) chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			for _, item := range intercept.GetItems() {
+				time.Sleep(delay)
+				_ = intercept.Resolve(item.ID, decide(item))
+			}
+		}
+	}()
+
+	return stop
+}
+
+func TestInterceptOutlivesProxyTimeout(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("path " + r.URL.Path))
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name     string
+		settings models.InterceptSettings
+		timeout  time.Duration
+		decide   func(models.InterceptedItem) models.InterceptDecision
+		status   int
+		body     string
+	}{
+		{
+			name:     "edited request",
+			settings: models.InterceptSettings{Enabled: true},
+			timeout:  5 * time.Second,
+			decide: func(item models.InterceptedItem) models.InterceptDecision {
+				item.Request.Path = "/edited"
+				return models.InterceptDecision{Action: models.ActionForward, Request: item.Request}
+			},
+			status: http.StatusOK,
+			body:   "path /edited",
+		},
+		{
+			name:     "edited response",
+			settings: models.InterceptSettings{Enabled: true, Responses: true},
+			timeout:  5 * time.Second,
+			decide: func(item models.InterceptedItem) models.InterceptDecision {
+				item.Response.StatusCode = http.StatusTeapot
+				item.Response.Body = "edited"
+				return models.InterceptDecision{Action: models.ActionForward, Request: item.Request, Response: item.Response}
+			},
+			status: http.StatusTeapot,
+			body:   "edited",
+		},
+		{
+			name:     "timed out",
+			settings: models.InterceptSettings{Enabled: true, Responses: true},
+			timeout:  250 * time.Millisecond,
+			status:   http.StatusOK,
+			body:     "path /original",
+		},
+		{
+			name:     "dropped",
+			settings: models.InterceptSettings{Enabled: true},
+			timeout:  5 * time.Second,
+			decide: func(models.InterceptedItem) models.InterceptDecision {
+				return models.InterceptDecision{Action: models.ActionDrop}
+			},
+			status: http.StatusBadGateway,
+			body:   interceptUsecase.ErrDropped.Error() + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			intercept := interceptUsecase.NewInterceptUsecase(tt.settings, tt.timeout)
+			if tt.decide != nil {
+				stop := resolveAfter(intercept, 250*time.Millisecond, tt.decide)
+				defer close(stop)
+			}
+
+			client := proxyServer(t, httpHandler(t, intercept))
+
+			resp, err := client.Get(target.URL + "/original")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil || resp.StatusCode != tt.status || string(body) != tt.body {
+				t.Fatalf("Get() = %d %q, %v, want %d %q", resp.StatusCode, body, err, tt.status, tt.body)
+			}
+		})
+	}
+}
+
+// savedResponse waits for the response of request id, a streamed response is
+// saved once the proxy is done reading it.
+func savedResponse(t *testing.T, h ProxyHandler, id int) models.Response {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		resp, err := h.usecase.GetResponse(id)
+		if err == nil {
+			return resp
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetResponse(%d) error = %v", id, err)
+		}
+	}
+}
+
+func TestStreamedResponse(t *testing.T) {
+	proceed := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: first\n\n"))
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-proceed:
+		case <-time.After(2 * time.Second):
+		}
+		w.Write([]byte("data: second\n\n"))
+	}))
+	defer target.Close()
+
+	h := httpHandler(t, interceptUsecase.NewInterceptUsecase(models.InterceptSettings{}, time.Second))
+	client := proxyServer(t, h)
+
+	resp, err := client.Get(target.URL + "/events")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	start := time.Now()
+	first := make([]byte, len("data: first\n\n"))
+	if _, err := io.ReadFull(resp.Body, first); err != nil || string(first) != "data: first\n\n" {
+		t.Fatalf("first event = %q, %v", first, err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("first event arrived with the end of the stream")
+	}
+	close(proceed)
+
+	rest, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(rest) != "data: second\n\n" {
+		t.Fatalf("second event = %q, %v", rest, err)
+	}
+
+	saved := savedResponse(t, h, 1)
+	if saved.Body != "data: first\n\ndata: second\n\n" || saved.ContentLength != 27 || saved.Truncated {
+		t.Fatalf("saved response = %+v", saved)
+	}
+}
+
+func TestRecordedResponse(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Upstream", "original")
+		w.Write([]byte("0123456789"))
+	}))
+	defer target.Close()
+
+	tests := []struct {
+		name      string
+		limit     int64
+		rules     []models.Rule
+		body      string
+		header    string
+		saved     string
+		truncated bool
+	}{
+		{name: "within limit", limit: 10, body: "0123456789", header: "original", saved: "0123456789"},
+		{name: "over limit", limit: 4, body: "0123456789", header: "original", saved: "0123", truncated: true},
+		{
+			name:      "header rule",
+			limit:     4,
+			rules:     []models.Rule{{Enabled: true, Target: models.TargetResponseHeader, Match: "original", Replace: "edited"}},
+			body:      "0123456789",
+			header:    "edited",
+			saved:     "0123",
+			truncated: true,
+		},
+		{
+			name:   "body rule",
+			limit:  10,
+			rules:  []models.Rule{{Enabled: true, Target: models.TargetResponseBody, Match: "456", Replace: "-"}},
+			body:   "0123-789",
+			header: "original",
+			saved:  "0123-789",
+		},
+		{
+			name:      "body rule over limit",
+			limit:     9,
+			rules:     []models.Rule{{Enabled: true, Target: models.TargetResponseBody, Match: "456", Replace: "-"}},
+			body:      "0123456789",
+			header:    "original",
+			saved:     "012345678",
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := httpHandler(t, interceptUsecase.NewInterceptUsecase(models.InterceptSettings{}, time.Second))
+			h.config.MaxBodySize = tt.limit
+			for _, rule := range tt.rules {
+				if _, err := h.rulesUsecase.CreateRule(rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client := proxyServer(t, h)
+
+			resp, err := client.Get(target.URL)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil || string(body) != tt.body || resp.Header.Get("X-Upstream") != tt.header {
+				t.Fatalf("Get() = %q %q, %v, want %q %q", body, resp.Header.Get("X-Upstream"), err, tt.body, tt.header)
+			}
+
+			saved := savedResponse(t, h, 1)
+			if saved.Body != tt.saved || saved.ContentLength != int64(len(tt.body)) || saved.Truncated != tt.truncated {
+				t.Fatalf("saved response = %+v, want body %q, truncated %v", saved, tt.saved, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestRulesNotRecordedOnError(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Rule")))
+	}))
+	defer target.Close()
+
+	h := httpHandler(t, interceptUsecase.NewInterceptUsecase(models.InterceptSettings{}, time.Second))
+	rules := []models.Rule{
+		{Enabled: true, Target: models.TargetRequestHeader, Replace: "X-Rule: applied"},
+		{Enabled: true, Target: models.TargetRequestLine, Match: "GET ", Replace: ""},
+	}
+	for _, rule := range rules {
+		if _, err := h.rulesUsecase.CreateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+	client := proxyServer(t, h)
+
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil || len(body) != 0 {
+		t.Fatalf("Get() = %q, %v, want the request without the rules", body, err)
+	}
+
+	savedResponse(t, h, 1)
+	applied, err := h.rulesUsecase.GetApplied(1)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("GetApplied() = %+v, %v, want none", applied, err)
+	}
+}
+
+func TestHTTPThroughUpstreamProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("direct"))
+	}))
+	defer target.Close()
+
+	forwarded := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.URL.String() + " " + r.Header.Get("Proxy-Authorization")
+		w.Write([]byte("upstream"))
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("alice", "secret")
+
+	tests := []struct {
+		name   string
+		bypass []string
+		body   string
+	}{
+		{name: "upstream", body: "upstream"},
+		{name: "bypass", bypass: []string{"127.0.0.1"}, body: "direct"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := httpHandler(t, interceptUsecase.NewInterceptUsecase(models.InterceptSettings{}, time.Second))
+			dialer, err := upstream.New(proxyURL.String(), tt.bypass)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h.upstream, h.transport = dialer, dialer.Transport()
+			client := proxyServer(t, h)
+
+			resp, err := client.Get(target.URL + "/path?a=1")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			body, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if string(body) != tt.body {
+				t.Fatalf("Get() = %q, want %q", body, tt.body)
+			}
+
+			if tt.body == "upstream" {
+				if got, want := <-forwarded, target.URL+"/path?a=1 Basic YWxpY2U6c2VjcmV0"; got != want {
+					t.Fatalf("upstream proxy got %q, want %q", got, want)
+				}
+			}
+		})
+	}
+}
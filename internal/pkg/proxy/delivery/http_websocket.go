@@ -0,0 +1,138 @@
+package delivery
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/protocol"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+const closeTimeout = 5 * time.Second
+
+func (h ProxyHandler) proxyWebSocket(w http.ResponseWriter, r *http.Request, dial func() (net.Conn, error)) {
+	info, captured := r.Context().Value(captureKey{}).(capture)
+
+	serverConn, err := dial()
+	if err != nil {
+		log.Printf("couldn't dial websocket server: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer serverConn.Close()
+
+	if err := r.Write(serverConn); err != nil {
+		log.Printf("couldn't send websocket handshake: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	serverReader := bufio.NewReader(serverConn)
+	resp, err := http.ReadResponse(serverReader, r)
+	if err != nil {
+		log.Printf("couldn't read websocket handshake: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	if err := h.saveResponse(resp); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		for key, values := range resp.Header {
+			for _, value := range values {
+				w.Header().Add(key, value)
+			}
+		}
+		w.WriteHeader(resp.StatusCode)
+
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			log.Printf("transfer answer err: %v", err)
+		}
+		return
+	}
+
+	clientConn, clientBuf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		log.Printf("couldn't hijack websocket client: %v", err)
+		return
+	}
+	defer clientConn.Close()
+
+	if err := clientConn.SetDeadline(time.Time{}); err != nil {
+		log.Printf("couldn't reset client deadline: %v", err)
+		return
+	}
+
+	var head strings.Builder
+	fmt.Fprintf(&head, "HTTP/1.1 %s\r\n", resp.Status)
+	if err := resp.Header.Write(&head); err != nil {
+		return
+	}
+	head.WriteString("\r\n")
+
+	if _, err := clientConn.Write([]byte(head.String())); err != nil {
+		log.Printf("couldn't write websocket handshake: %v", err)
+		return
+	}
+
+	record := func(direction string) func(opcode int, payload []byte) {
+		return func(opcode int, payload []byte) {
+			if !captured {
+				return
+			}
+
+			_, err := h.websocketUsecase.SaveMessage(models.WebSocketMessage{
+				RequestID: info.requestID,
+				Direction: direction,
+				Opcode:    opcode,
+				Payload:   string(payload),
+			})
+			if err != nil {
+				log.Printf("couldn't save websocket message: %v", err)
+			}
+		}
+	}
+
+	done := make(chan error, 2)
+	go func() {
+		done <- protocol.Pipe(clientBuf.Reader, serverConn, record(models.DirectionToServer))
+	}()
+	go func() {
+		done <- protocol.Pipe(serverReader, clientConn, record(models.DirectionToClient))
+	}()
+
+	deadline := time.Now().Add(closeTimeout)
+	if err := <-done; err != nil {
+		if err == protocol.ErrMessageTooLarge {
+			log.Printf("closing websocket to %s: %v", r.Host, err)
+		}
+		deadline = time.Now()
+	}
+	_ = clientConn.SetDeadline(deadline)
+	_ = serverConn.SetDeadline(deadline)
+	<-done
+}
+
+func (h ProxyHandler) dialTCP(host string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		return h.upstream.DialTimeout("tcp", upstream.HostPort(host, "80"), h.config.ClientTimeout)
+	}
+}
+
+func (h ProxyHandler) dialTLS(host string) func() (net.Conn, error) {
+	return func() (net.Conn, error) {
+		return h.upstream.DialTLS("tcp", upstream.HostPort(host, "443"), new(tls.Config), h.config.ClientTimeout)
+	}
+}
@@ -0,0 +1,298 @@
+package delivery
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	interceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	tunnelRepository "github.com/aanufriev/httpproxy/internal/pkg/tunnel/repository"
+	tunnelUsecase "github.com/aanufriev/httpproxy/internal/pkg/tunnel/usecase"
+	"github.com/aanufriev/httpproxy/pkg/cert"
+)
+
+// testCA signs both the certificates of the test upstreams and the ones the
+// proxy issues. TestMain makes it the only system root, so the proxy trusts
+// the upstreams the way it trusts real sites.
+var testCA struct {
+	certFile string
+	keyFile  string
+	pool     *x509.CertPool
+}
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "httpproxy-delivery")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	testCA.certFile, testCA.keyFile = filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	if _, err := cert.EnsureCA(testCA.certFile, testCA.keyFile, cert.CAOptions{Validity: time.Hour}); err != nil {
+		log.Fatal(err)
+	}
+
+	ca, err := cert.LoadCA(testCA.certFile, testCA.keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	testCA.pool = x509.NewCertPool()
+	testCA.pool.AddCert(ca.Leaf)
+
+	os.Setenv("SSL_CERT_FILE", testCA.certFile)
+	os.Setenv("SSL_CERT_DIR", dir)
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func certManager(t *testing.T) *cert.CertManager {
+	t.Helper()
+
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" || runtime.GOOS == "ios" {
+		t.Skipf("system roots can't be replaced on %s", runtime.GOOS)
+	}
+
+	manager, err := cert.NewCertManager(cert.Options{
+		CACertFile: testCA.certFile,
+		CAKeyFile:  testCA.keyFile,
+		Dir:        t.TempDir(),
+		KeyType:    cert.KeyECDSA,
+		CacheSize:  10,
+		Validity:   24 * time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return manager
+}
+
+// tlsServer starts an upstream on 127.0.0.1 that speaks the given protocols,
+// without ALPN when there are none.
+func tlsServer(t *testing.T, handler http.Handler, protos ...string) *httptest.Server {
+	t.Helper()
+
+	if protos == nil {
+		protos = []string{}
+	}
+
+	leaf, err := certManager(t).GetCertificate("127.0.0.1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{leaf}, NextProtos: protos}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// mitmHandler returns a handler that intercepts every CONNECT.
+func mitmHandler(t *testing.T) ProxyHandler {
+	t.Helper()
+
+	h := httpHandler(t, interceptUsecase.NewInterceptUsecase(models.InterceptSettings{}, time.Second))
+	h.certs = certManager(t)
+	h.tunnelUsecase = tunnelUsecase.NewTunnelUsecase(tunnelRepository.NewMemoryRepository(10), nil, false, 0)
+
+	return h
+}
+
+// mitmClient returns a client that sends everything through the proxy
+// serving h and trusts its certificates.
+func mitmClient(t *testing.T, h ProxyHandler, h2 bool) *http.Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			h.HandleHTTPS(w, r)
+			return
+		}
+		r.RequestURI = ""
+		h.HandleHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	proxyURL, _ := url.Parse(server.URL)
+	transport := &http.Transport{
+		Proxy:             http.ProxyURL(proxyURL),
+		TLSClientConfig:   &tls.Config{RootCAs: testCA.pool},
+		ForceAttemptHTTP2: h2,
+	}
+	t.Cleanup(transport.CloseIdleConnections)
+
+	return &http.Client{Transport: transport, Timeout: 5 * time.Second}
+}
+
+func TestMITMProtocols(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   bool
+		upstream []string
+		proto    string
+	}{
+		{name: "h2 on both sides", client: true, upstream: []string{"h2", "http/1.1"}, proto: "HTTP/2.0"},
+		{name: "http/1.1 upstream", client: true, upstream: []string{"http/1.1"}, proto: "HTTP/1.1"},
+		{name: "http/1.1 client", upstream: []string{"h2", "http/1.1"}, proto: "HTTP/1.1"},
+		{name: "no alpn upstream", client: true, proto: "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := tlsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.Proto))
+			}), tt.upstream...)
+
+			h := mitmHandler(t)
+			client := mitmClient(t, h, tt.client)
+
+			resp, err := client.Get(target.URL + "/proto")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			body, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			if err != nil || resp.Proto != tt.proto || string(body) != tt.proto {
+				t.Fatalf("Get() = %s with upstream %q, %v, want %s on both sides", resp.Proto, body, err, tt.proto)
+			}
+
+			req, err := h.usecase.GetRequest(1)
+			if err != nil || req.Proto != tt.proto || req.Scheme != "https" || req.Path != "/proto" {
+				t.Fatalf("saved request = %+v, %v, want %s", req, err, tt.proto)
+			}
+
+			if saved := savedResponse(t, h, 1); saved.Body != tt.proto {
+				t.Fatalf("saved response = %+v", saved)
+			}
+		})
+	}
+}
+
+func TestScopeDecidesInterception(t *testing.T) {
+	tests := []struct {
+		name   string
+		scope  []models.ScopeRule
+		forged bool
+		saved  int
+	}{
+		{name: "no rules", forged: true, saved: 2},
+		{name: "excluded host", scope: []models.ScopeRule{{Enabled: true, Action: models.ScopeExclude, Host: "127.0.0.1"}}},
+		{name: "other host included", scope: []models.ScopeRule{{Enabled: true, Action: models.ScopeInclude, Host: "*.example.com"}}},
+		{
+			name:   "excluded path",
+			scope:  []models.ScopeRule{{Enabled: true, Action: models.ScopeExclude, Host: "*", Path: "^/static/"}},
+			forged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			})
+			secure := tlsServer(t, handler)
+			plain := httptest.NewServer(handler)
+			defer plain.Close()
+
+			h := mitmHandler(t)
+			for _, rule := range tt.scope {
+				if _, err := h.scopeUsecase.CreateRule(rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+			client := mitmClient(t, h, false)
+
+			for _, target := range []string{secure.URL, plain.URL} {
+				resp, err := client.Get(target + "/static/app.js")
+				if err != nil {
+					t.Fatalf("Get(%s) error = %v", target, err)
+				}
+				resp.Body.Close()
+
+				// out of scope tunnels reach the client with the upstream
+				// certificate, a path rule needs the tunnel decrypted
+				if resp.TLS != nil {
+					upstream := secure.TLS.Certificates[0].Certificate[0]
+					forged := !bytes.Equal(resp.TLS.PeerCertificates[0].Raw, upstream)
+					if forged != tt.forged {
+						t.Fatalf("Get(%s) forged certificate = %v, want %v", target, forged, tt.forged)
+					}
+				}
+			}
+
+			requests, err := h.usecase.GetRequests(models.RequestFilter{Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(requests.Requests) != tt.saved {
+				t.Fatalf("saved %d requests, want %d", len(requests.Requests), tt.saved)
+			}
+		})
+	}
+}
+
+func TestPassthroughAfterRejectedCertificate(t *testing.T) {
+	target := tlsServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	upstreamCert := target.TLS.Certificates[0].Certificate[0]
+
+	h := mitmHandler(t)
+	h.tunnelUsecase = tunnelUsecase.NewTunnelUsecase(tunnelRepository.NewMemoryRepository(10), nil, true, time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(h.HandleHTTPS))
+	defer server.Close()
+
+	addr := target.Listener.Addr().String()
+
+	// a pinning client trusts nothing the proxy can forge
+	conn, _ := connect(t, server.Listener.Addr().String(), addr)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := tls.Client(conn, &tls.Config{ServerName: "127.0.0.1", RootCAs: x509.NewCertPool()}).Handshake(); err == nil {
+		t.Fatal("Handshake() with a forged certificate error = nil")
+	}
+	conn.Close()
+
+	// the proxy learns about the rejection once it reads the client alert
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := h.tunnelUsecase.Passthrough(addr); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rejected target isn't passed through")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	conn, _ = connect(t, server.Listener.Addr().String(), addr)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	client := tls.Client(conn, &tls.Config{ServerName: "127.0.0.1", RootCAs: testCA.pool})
+	if err := client.Handshake(); err != nil {
+		t.Fatalf("Handshake() after the rejection error = %v", err)
+	}
+	if peer := client.ConnectionState().PeerCertificates[0].Raw; !bytes.Equal(peer, upstreamCert) {
+		t.Fatal("second tunnel was decrypted, want the upstream certificate")
+	}
+	client.Close()
+
+	tunnel := waitTunnel(t, h, 1)
+	if tunnel.Reason != models.PassthroughHandshake || tunnel.BytesReceived == 0 {
+		t.Fatalf("saved tunnel = %+v", tunnel)
+	}
+}
@@ -0,0 +1,233 @@
+package delivery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	authUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+)
+
+const (
+	socksVersion = 5
+
+	socksNoAuth       = 0
+	socksPasswordAuth = 2
+	socksNoAcceptable = 0xff
+
+	socksPasswordVersion = 1
+	socksAuthSucceeded   = 0
+	socksAuthFailed      = 1
+
+	socksConnect = 1
+
+	socksIPv4   = 1
+	socksDomain = 3
+	socksIPv6   = 4
+
+	socksSucceeded           = 0
+	socksCommandNotSupported = 7
+	socksAddressNotSupported = 8
+)
+
+var errSocksCommand = errors.New("only CONNECT is supported")
+
+func (h ProxyHandler) ListenAndServeSOCKS(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go h.handleSOCKS(conn)
+	}
+}
+
+func (h ProxyHandler) handleSOCKS(conn net.Conn) {
+	if err := conn.SetDeadline(time.Now().Add(h.config.ReadTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+
+	ctx := context.Background()
+	user, err := h.socksNegotiate(conn)
+	if err != nil {
+		log.Printf("socks5 handshake failed: %v", err)
+		conn.Close()
+		return
+	}
+	if user != "" {
+		ctx = authUsecase.WithUser(ctx, user)
+	}
+
+	target, err := socksRequest(conn)
+	if err != nil {
+		log.Printf("socks5 handshake failed: %v", err)
+		conn.Close()
+		return
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return
+	}
+
+	h.serveTunnel(ctx, conn, target)
+}
+
+// socksNegotiate agrees on the authentication method and returns the
+// authenticated user, username/password is required when proxy
+// authentication is enabled.
+func (h ProxyHandler) socksNegotiate(conn net.Conn) (string, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return "", err
+	}
+	if head[0] != socksVersion {
+		return "", fmt.Errorf("unsupported socks version %d", head[0])
+	}
+
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+
+	method := byte(socksNoAuth)
+	if h.authUsecase.Enabled() {
+		method = socksPasswordAuth
+	}
+
+	if !containsByte(methods, method) {
+		_, _ = conn.Write([]byte{socksVersion, socksNoAcceptable})
+		return "", errors.New("client doesn't offer an acceptable authentication method")
+	}
+
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil {
+		return "", err
+	}
+
+	if method == socksNoAuth {
+		return "", nil
+	}
+
+	return h.socksAuthenticate(conn)
+}
+
+// socksAuthenticate runs the username/password subnegotiation of RFC 1929.
+func (h ProxyHandler) socksAuthenticate(conn net.Conn) (string, error) {
+	var version [1]byte
+	if _, err := io.ReadFull(conn, version[:]); err != nil {
+		return "", err
+	}
+	if version[0] != socksPasswordVersion {
+		return "", fmt.Errorf("unsupported username/password auth version %d", version[0])
+	}
+
+	name, err := readSocksString(conn)
+	if err != nil {
+		return "", err
+	}
+
+	password, err := readSocksString(conn)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := h.authUsecase.AuthenticatePassword(name, password)
+	if err != nil {
+		_, _ = conn.Write([]byte{socksPasswordVersion, socksAuthFailed})
+		return "", fmt.Errorf("authentication failed for %s: %w", conn.RemoteAddr(), err)
+	}
+
+	if _, err := conn.Write([]byte{socksPasswordVersion, socksAuthSucceeded}); err != nil {
+		return "", err
+	}
+
+	return user.Name, nil
+}
+
+func socksRequest(conn net.Conn) (string, error) {
+	var request [4]byte
+	if _, err := io.ReadFull(conn, request[:]); err != nil {
+		return "", err
+	}
+
+	if request[1] != socksConnect {
+		_ = writeSocksReply(conn, socksCommandNotSupported)
+		return "", errSocksCommand
+	}
+
+	var host string
+	switch request[3] {
+	case socksIPv4, socksIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if request[3] == socksIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksDomain:
+		domain, err := readSocksString(conn)
+		if err != nil {
+			return "", err
+		}
+		host = domain
+	default:
+		_ = writeSocksReply(conn, socksAddressNotSupported)
+		return "", fmt.Errorf("unsupported address type %d", request[3])
+	}
+
+	var port [2]byte
+	if _, err := io.ReadFull(conn, port[:]); err != nil {
+		return "", err
+	}
+
+	if err := writeSocksReply(conn, socksSucceeded); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
+
+func readSocksString(conn net.Conn) (string, error) {
+	var length [1]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return "", err
+	}
+
+	value := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, value); err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+func writeSocksReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+func containsByte(values []byte, value byte) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,274 @@
+package delivery
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	authRepository "github.com/aanufriev/httpproxy/internal/pkg/auth/repository"
+	authUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+// scriptConn reads what the client sent from in and keeps what the proxy
+// answered in out.
+type scriptConn struct {
+	net.Conn
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (c *scriptConn) Read(p []byte) (int, error) {
+	return c.in.Read(p)
+}
+
+func (c *scriptConn) Write(p []byte) (int, error) {
+	return c.out.Write(p)
+}
+
+func (c *scriptConn) RemoteAddr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1}
+}
+
+func newScriptConn(data ...[]byte) *scriptConn {
+	return &scriptConn{in: bytes.NewReader(bytes.Join(data, nil))}
+}
+
+func socksHandler(t *testing.T, auth bool) ProxyHandler {
+	usecase := authUsecase.NewAuthUsecase(authRepository.NewMemoryRepository(), auth)
+	if _, err := usecase.SaveUser(models.ProxyUserEdit{Name: "alice", Password: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	return ProxyHandler{authUsecase: usecase}
+}
+
+func TestSocksNegotiate(t *testing.T) {
+	tests := []struct {
+		name  string
+		auth  bool
+		data  [][]byte
+		user  string
+		reply []byte
+		err   string
+	}{
+		{
+			name:  "no auth",
+			data:  [][]byte{{5, 1, 0}},
+			reply: []byte{5, 0},
+		},
+		{
+			name:  "no auth among methods",
+			data:  [][]byte{{5, 3, 1, 2, 0}},
+			reply: []byte{5, 0},
+		},
+		{
+			name:  "password",
+			auth:  true,
+			data:  [][]byte{{5, 2, 0, 2}, {1, 5}, []byte("alice"), {6}, []byte("secret")},
+			user:  "alice",
+			reply: []byte{5, 2, 1, 0},
+		},
+		{
+			name:  "wrong password",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {1, 5}, []byte("alice"), {5}, []byte("wrong")},
+			reply: []byte{5, 2, 1, 1},
+			err:   "authentication failed",
+		},
+		{
+			name:  "unknown user",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {1, 3}, []byte("bob"), {6}, []byte("secret")},
+			reply: []byte{5, 2, 1, 1},
+			err:   "authentication failed",
+		},
+		{
+			name:  "empty credentials",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {1, 0, 0}},
+			reply: []byte{5, 2, 1, 1},
+			err:   "authentication failed",
+		},
+		{
+			name:  "password not offered",
+			auth:  true,
+			data:  [][]byte{{5, 1, 0}},
+			reply: []byte{5, 0xff},
+			err:   "acceptable authentication method",
+		},
+		{
+			name:  "no auth not offered",
+			data:  [][]byte{{5, 1, 2}},
+			reply: []byte{5, 0xff},
+			err:   "acceptable authentication method",
+		},
+		{
+			name:  "no methods",
+			data:  [][]byte{{5, 0}},
+			reply: []byte{5, 0xff},
+			err:   "acceptable authentication method",
+		},
+		{
+			name: "socks4",
+			data: [][]byte{{4, 1, 0, 80, 127, 0, 0, 1, 0}},
+			err:  "unsupported socks version 4",
+		},
+		{
+			name: "http request",
+			data: [][]byte{[]byte("GET / HTTP/1.1\r\n")},
+			err:  "unsupported socks version 71",
+		},
+		{
+			name: "empty",
+			err:  "EOF",
+		},
+		{
+			name: "truncated methods",
+			data: [][]byte{{5, 3, 0}},
+			err:  "unexpected EOF",
+		},
+		{
+			name:  "wrong subnegotiation version",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {5, 5}, []byte("alice")},
+			reply: []byte{5, 2},
+			err:   "unsupported username/password auth version 5",
+		},
+		{
+			name:  "truncated username",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {1, 5}, []byte("ali")},
+			reply: []byte{5, 2},
+			err:   "unexpected EOF",
+		},
+		{
+			name:  "missing password",
+			auth:  true,
+			data:  [][]byte{{5, 1, 2}, {1, 5}, []byte("alice")},
+			reply: []byte{5, 2},
+			err:   "EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newScriptConn(tt.data...)
+
+			user, err := socksHandler(t, tt.auth).socksNegotiate(conn)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("socksNegotiate() error = %v, want %q", err, tt.err)
+			}
+			if user != tt.user {
+				t.Fatalf("socksNegotiate() user = %q, want %q", user, tt.user)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.reply) {
+				t.Fatalf("socksNegotiate() replied % x, want % x", conn.out.Bytes(), tt.reply)
+			}
+		})
+	}
+}
+
+func TestSocksRequest(t *testing.T) {
+	succeeded := []byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+
+	tests := []struct {
+		name   string
+		data   [][]byte
+		target string
+		reply  []byte
+		err    string
+	}{
+		{
+			name:   "ipv4",
+			data:   [][]byte{{5, 1, 0, 1, 127, 0, 0, 1, 0x1f, 0x90}},
+			target: "127.0.0.1:8080",
+			reply:  succeeded,
+		},
+		{
+			name:   "ipv6",
+			data:   [][]byte{{5, 1, 0, 4}, net.ParseIP("2001:db8::1"), {0x01, 0xbb}},
+			target: "[2001:db8::1]:443",
+			reply:  succeeded,
+		},
+		{
+			name:   "domain",
+			data:   [][]byte{{5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			target: "example.com:80",
+			reply:  succeeded,
+		},
+		{
+			name:   "port 0",
+			data:   [][]byte{{5, 1, 0, 1, 10, 0, 0, 1, 0, 0}},
+			target: "10.0.0.1:0",
+			reply:  succeeded,
+		},
+		{
+			name:  "bind",
+			data:  [][]byte{{5, 2, 0, 1, 127, 0, 0, 1, 0, 80}},
+			reply: []byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0},
+			err:   errSocksCommand.Error(),
+		},
+		{
+			name:  "udp associate",
+			data:  [][]byte{{5, 3, 0, 1, 0, 0, 0, 0, 0, 0}},
+			reply: []byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0},
+			err:   errSocksCommand.Error(),
+		},
+		{
+			name:  "unknown address type",
+			data:  [][]byte{{5, 1, 0, 2, 127, 0, 0, 1, 0, 80}},
+			reply: []byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0},
+			err:   "unsupported address type 2",
+		},
+		{
+			name: "truncated request",
+			data: [][]byte{{5, 1}},
+			err:  "unexpected EOF",
+		},
+		{
+			name: "truncated ipv4",
+			data: [][]byte{{5, 1, 0, 1, 127, 0}},
+			err:  "unexpected EOF",
+		},
+		{
+			name: "truncated ipv6",
+			data: [][]byte{{5, 1, 0, 4}, make([]byte, 10)},
+			err:  "unexpected EOF",
+		},
+		{
+			name: "truncated domain",
+			data: [][]byte{{5, 1, 0, 3, 11}, []byte("example")},
+			err:  "unexpected EOF",
+		},
+		{
+			name: "missing port",
+			data: [][]byte{{5, 1, 0, 3, 1}, []byte("a")},
+			err:  "EOF",
+		},
+		{
+			name: "truncated port",
+			data: [][]byte{{5, 1, 0, 1, 127, 0, 0, 1, 0}},
+			err:  "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := newScriptConn(tt.data...)
+
+			target, err := socksRequest(conn)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("socksRequest() error = %v, want %q", err, tt.err)
+			}
+			if target != tt.target {
+				t.Fatalf("socksRequest() target = %q, want %q", target, tt.target)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.reply) {
+				t.Fatalf("socksRequest() replied % x, want % x", conn.out.Bytes(), tt.reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+)
+
+var errServerNameRead = errors.New("server name read")
+
+func (h ProxyHandler) ListenAndServeTransparent(addr string) error {
+	listener, err := listenTransparent(addr)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go h.handleTransparent(conn)
+	}
+}
+
+func (h ProxyHandler) handleTransparent(conn net.Conn) {
+	host, port := originalDestination(conn)
+
+	reader := bufio.NewReader(conn)
+	if err := conn.SetReadDeadline(time.Now().Add(h.config.ReadTimeout)); err != nil {
+		conn.Close()
+		return
+	}
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	if first[0] != recordTypeHandshake {
+		_ = conn.SetReadDeadline(time.Time{})
+		target := ""
+		if host != "" {
+			target = net.JoinHostPort(host, strconv.Itoa(port))
+		}
+
+		h.serveHTTP(context.Background(), &peekedConn{Conn: conn, reader: reader}, target)
+		return
+	}
+
+	serverName, replay := readServerName(reader)
+	_ = conn.SetReadDeadline(time.Time{})
+	if serverName != "" {
+		host = serverName
+	}
+	if port == 0 {
+		port = 443
+	}
+
+	if host == "" {
+		log.Printf("couldn't route transparent connection from %s: no SNI and no original destination", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	target := net.JoinHostPort(host, strconv.Itoa(port))
+	if reason, ok := h.tunnelUsecase.Passthrough(target); ok {
+		h.passthrough(context.Background(), &peekedConn{Conn: conn, reader: replay}, target, reason)
+		return
+	}
+
+	if !h.scopeUsecase.InScopeHost("https", target) {
+		h.tunnelTCP(&peekedConn{Conn: conn, reader: replay}, target)
+		return
+	}
+
+	h.interceptTLS(context.Background(), &peekedConn{Conn: conn, reader: replay}, target)
+}
+
+// readServerName parses the ClientHello without consuming it, the returned
+// reader yields the connection from its first byte.
+func readServerName(reader io.Reader) (string, io.Reader) {
+	var recorded bytes.Buffer
+	var serverName string
+
+	config := &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errServerNameRead
+		},
+	}
+	_ = tls.Server(sniffConn{reader: io.TeeReader(reader, &recorded)}, config).Handshake()
+
+	return serverName, io.MultiReader(&recorded, reader)
+}
+
+func originalDestination(conn net.Conn) (string, int) {
+	addr, ok := redirectedDestination(conn)
+	if !ok {
+		addr, ok = conn.LocalAddr().(*net.TCPAddr)
+	}
+
+	if !ok || isLocalAddr(addr.IP) {
+		return "", 0
+	}
+
+	return addr.IP.String(), addr.Port
+}
+
+func isLocalAddr(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+
+	for _, addr := range addrs {
+		if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type sniffConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c sniffConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c sniffConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (c sniffConn) Close() error {
+	return nil
+}
+
+func (c sniffConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+func (c sniffConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+func (c sniffConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"syscall"
+)
+
+const soOriginalDst = 80
+
+// listenTransparent sets IP_TRANSPARENT when allowed so TPROXY rules can
+// deliver connections, REDIRECT works without it.
+func listenTransparent(addr string) (net.Listener, error) {
+	config := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return c.Control(func(fd uintptr) {
+				_ = syscall.SetsockoptInt(int(fd), syscall.SOL_IP, syscall.IP_TRANSPARENT, 1)
+			})
+		},
+	}
+
+	return config.Listen(context.Background(), "tcp", addr)
+}
+
+func redirectedDestination(conn net.Conn) (*net.TCPAddr, bool) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, false
+	}
+
+	raw, err := tcpConn.SyscallConn()
+	if err != nil {
+		return nil, false
+	}
+
+	var addr *net.TCPAddr
+	err = raw.Control(func(fd uintptr) {
+		mreq, err := syscall.GetsockoptIPv6Mreq(int(fd), syscall.SOL_IP, soOriginalDst)
+		if err != nil {
+			return
+		}
+
+		sockaddr := mreq.Multiaddr
+		addr = &net.TCPAddr{
+			IP:   net.IPv4(sockaddr[4], sockaddr[5], sockaddr[6], sockaddr[7]),
+			Port: int(binary.BigEndian.Uint16(sockaddr[2:4])),
+		}
+	})
+	if err != nil || addr == nil {
+		return nil, false
+	}
+
+	return addr, true
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package delivery
+
+import (
+	"net"
+)
+
+func listenTransparent(addr string) (net.Listener, error) {
+	return net.Listen("tcp", addr)
+}
+
+func redirectedDestination(conn net.Conn) (*net.TCPAddr, bool) {
+	return nil, false
+}
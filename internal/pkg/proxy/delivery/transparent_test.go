@@ -0,0 +1,140 @@
+package delivery
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// transparentServer hands every accepted connection to handleTransparent,
+// the way ListenAndServeTransparent does.
+func transparentServer(t *testing.T, h ProxyHandler) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go h.handleTransparent(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestTransparentHTTP(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI()))
+	}))
+	defer target.Close()
+
+	h := mitmHandler(t)
+	addr := transparentServer(t, h)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// origin-form, as an app that doesn't know about the proxy sends it
+	host := target.Listener.Addr().String()
+	if _, err := io.WriteString(conn, "GET /a?b=1 HTTP/1.1\r\nHost: "+host+"\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatalf("ReadResponse() error = %v", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "GET /a?b=1" {
+		t.Fatalf("response = %q, want the target's", body)
+	}
+
+	req, err := h.usecase.GetRequest(1)
+	if err != nil || req.Scheme != "http" || req.Host != host || req.Path != "/a" {
+		t.Fatalf("saved request = %+v, %v", req, err)
+	}
+}
+
+func TestTransparentWithoutRoute(t *testing.T) {
+	h := mitmHandler(t)
+	h.config.ReadTimeout = time.Second
+	addr := transparentServer(t, h)
+
+	// neither SNI nor an original destination, the loopback address is the proxy itself
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err == nil {
+		conn.Close()
+		t.Fatal("TLS without SNI was accepted")
+	}
+
+	h.config.ReadTimeout = 50 * time.Millisecond
+	addr = transparentServer(t, h)
+
+	// a client that never speaks is dropped after the read timeout
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	idle.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("idle connection read error = %v, want EOF", err)
+	}
+}
+
+func TestReadServerName(t *testing.T) {
+	tests := []struct {
+		name       string
+		serverName string
+	}{
+		{name: "sni", serverName: "example.com"},
+		{name: "no sni"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+
+			go func() {
+				tls.Client(client, &tls.Config{ServerName: tt.serverName, InsecureSkipVerify: true}).Handshake()
+				client.Close()
+			}()
+
+			serverName, replay := readServerName(server)
+			if serverName != tt.serverName {
+				t.Fatalf("readServerName() = %q, want %q", serverName, tt.serverName)
+			}
+
+			// the ClientHello is replayed from its first byte
+			header := make([]byte, 1)
+			if _, err := io.ReadFull(replay, header); err != nil || header[0] != recordTypeHandshake {
+				t.Fatalf("replayed %x, %v, want a handshake record", header, err)
+			}
+		})
+	}
+
+	if serverName, _ := readServerName(strings.NewReader("GET / HTTP/1.1\r\n\r\n")); serverName != "" {
+		t.Fatalf("readServerName() of plain HTTP = %q", serverName)
+	}
+}
@@ -0,0 +1,284 @@
+package delivery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+
+	authUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+const (
+	recordTypeHandshake = 0x16
+	maxHelloSize        = 16 << 10
+)
+
+// serveTunnel takes a client connection that already names its target, after
+// CONNECT or a SOCKS5 request, and captures TLS or plain HTTP sent through it.
+func (h ProxyHandler) serveTunnel(ctx context.Context, conn net.Conn, target string) {
+	if reason, ok := h.tunnelUsecase.Passthrough(target); ok {
+		h.passthrough(ctx, conn, target, reason)
+		return
+	}
+
+	reader := bufio.NewReader(conn)
+	first, err := reader.Peek(1)
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	conn = &peekedConn{Conn: conn, reader: reader}
+	scheme := "http"
+	if first[0] == recordTypeHandshake {
+		scheme = "https"
+	}
+
+	if !h.scopeUsecase.InScopeHost(scheme, target) {
+		h.tunnelTCP(conn, target)
+		return
+	}
+
+	if first[0] == recordTypeHandshake {
+		h.interceptTLS(ctx, conn, target)
+		return
+	}
+
+	h.serveHTTP(ctx, conn, target)
+}
+
+func (h ProxyHandler) serveHTTP(ctx context.Context, conn net.Conn, target string) {
+	serveConn(ctx, conn, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delete(r.Header, "Proxy-Connection")
+		r.RequestURI = ""
+		r.URL.Scheme = "http"
+		r.URL.Host = r.Host
+		if r.URL.Host == "" {
+			r.URL.Host = target
+		}
+
+		if r.URL.Host == "" {
+			http.Error(w, "Host header is required", http.StatusBadRequest)
+			return
+		}
+
+		h.HandleHTTP(w, r)
+	}))
+	conn.Close()
+}
+
+func (h ProxyHandler) interceptTLS(ctx context.Context, clientConn net.Conn, target string) {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		log.Printf("couldn't get host: %v", err)
+		clientConn.Close()
+		return
+	}
+
+	var serverConn *tls.Conn
+	var certSent bool
+
+	serverConfig := new(tls.Config)
+	serverConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		serverName := hello.ServerName
+		if serverName == "" {
+			serverName = host
+		}
+
+		clientConfig := new(tls.Config)
+		clientConfig.ServerName = serverName
+		clientConfig.NextProtos = supportedProtos(hello.SupportedProtos)
+		serverConn, err = h.upstream.DialTLS("tcp", target, clientConfig, h.config.ClientTimeout)
+		if err != nil {
+			log.Printf("dial tcp error: %v", err)
+			return nil, err
+		}
+
+		var upstreamCert *x509.Certificate
+		if peers := serverConn.ConnectionState().PeerCertificates; len(peers) > 0 {
+			upstreamCert = peers[0]
+		}
+
+		helloCert, err := h.certs.GetCertificate(serverName, upstreamCert)
+		if err != nil {
+			log.Printf("couldn't get cert: %v", err)
+			return nil, err
+		}
+
+		config := new(tls.Config)
+		config.Certificates = []tls.Certificate{helloCert}
+		if proto := serverConn.ConnectionState().NegotiatedProtocol; proto != "" {
+			config.NextProtos = []string{proto}
+		}
+		certSent = true
+
+		return config, nil
+	}
+
+	conn := tls.Server(clientConn, serverConfig)
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		if serverConn != nil {
+			serverConn.Close()
+		}
+		if certSent {
+			h.tunnelUsecase.HandshakeFailed(target)
+		}
+		return
+	}
+	defer conn.Close()
+	defer serverConn.Close()
+
+	rp := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Host = r.Host
+			r.URL.Scheme = "https"
+		},
+		ModifyResponse: h.saveResponse,
+		Transport: &http.Transport{
+			DialTLSContext: func(
+				ctx context.Context, network string, address string,
+			) (net.Conn, error) {
+				select {
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				default:
+					return serverConn, nil
+				}
+			},
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	serveConn(ctx, conn, h.wrap(rp))
+}
+
+// tunnelTCP relays an out of scope connection to its target unchanged.
+func (h ProxyHandler) tunnelTCP(clientConn net.Conn, target string) {
+	defer clientConn.Close()
+
+	serverConn, err := h.upstream.DialTimeout("tcp", target, h.config.ClientTimeout)
+	if err != nil {
+		log.Printf("couldn't dial %s: %v", target, err)
+		return
+	}
+	defer serverConn.Close()
+
+	splice(clientConn, serverConn, ioutil.Discard)
+}
+
+// passthrough relays the connection like tunnelTCP without waiting for the
+// client to speak first and records the tunnel metadata once it is closed.
+func (h ProxyHandler) passthrough(ctx context.Context, clientConn net.Conn, target, reason string) {
+	defer clientConn.Close()
+
+	start := time.Now()
+	serverConn, err := h.upstream.DialTimeout("tcp", target, h.config.ClientTimeout)
+	if err != nil {
+		log.Printf("couldn't dial %s: %v", target, err)
+		return
+	}
+	defer serverConn.Close()
+
+	hello := &prefixBuffer{limit: maxHelloSize}
+	sent, received := splice(clientConn, serverConn, hello)
+
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+
+	tunnel := models.Tunnel{
+		Host:          host,
+		Reason:        reason,
+		User:          authUsecase.UserFromContext(ctx),
+		BytesSent:     sent,
+		BytesReceived: received,
+		Duration:      time.Since(start).Milliseconds(),
+		CreatedAt:     start,
+	}
+	tunnel.Port, _ = strconv.Atoi(port)
+	if len(hello.data) > 0 && hello.data[0] == recordTypeHandshake {
+		tunnel.SNI, _ = readServerName(bytes.NewReader(hello.data))
+	}
+
+	if _, err = h.tunnelUsecase.SaveTunnel(tunnel); err != nil {
+		log.Printf("couldn't save tunnel: %v", err)
+	}
+}
+
+// splice copies both directions until they are closed, the client stream is
+// also written to record. It returns the bytes sent by each side.
+func splice(clientConn, serverConn net.Conn, record io.Writer) (int64, int64) {
+	var sent, received int64
+	done := make(chan struct{}, 2)
+
+	go func() {
+		sent, _ = io.Copy(serverConn, io.TeeReader(clientConn, record))
+		closeWrite(serverConn)
+		done <- struct{}{}
+	}()
+	go func() {
+		received, _ = io.Copy(clientConn, serverConn)
+		closeWrite(clientConn)
+		done <- struct{}{}
+	}()
+
+	<-done
+	<-done
+
+	return sent, received
+}
+
+// closeWrite half-closes conn when it supports it, so the peer still gets
+// the rest of the other direction.
+func closeWrite(conn net.Conn) {
+	if peeked, ok := conn.(*peekedConn); ok {
+		conn = peeked.Conn
+	}
+
+	if half, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = half.CloseWrite()
+		return
+	}
+
+	_ = conn.Close()
+}
+
+// prefixBuffer keeps the first limit bytes written to it.
+type prefixBuffer struct {
+	data  []byte
+	limit int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if free := b.limit - len(b.data); free > 0 {
+		if len(p) > free {
+			b.data = append(b.data, p[:free]...)
+		} else {
+			b.data = append(b.data, p...)
+		}
+	}
+
+	return len(p), nil
+}
+
+type peekedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
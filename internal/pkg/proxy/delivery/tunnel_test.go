@@ -0,0 +1,189 @@
+package delivery
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	authUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	scopeRepository "github.com/aanufriev/httpproxy/internal/pkg/scope/repository"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+	tunnelRepository "github.com/aanufriev/httpproxy/internal/pkg/tunnel/repository"
+	tunnelUsecase "github.com/aanufriev/httpproxy/internal/pkg/tunnel/usecase"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+// echoServer accepts connections and writes back everything it reads.
+func echoServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func tunnelHandler(t *testing.T, passthrough []string, scope ...models.ScopeRule) ProxyHandler {
+	t.Helper()
+
+	dialer, err := upstream.New("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scopes := scopeUsecase.NewScopeUsecase(scopeRepository.NewMemoryRepository())
+	for _, rule := range scope {
+		if _, err := scopes.CreateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return ProxyHandler{
+		scopeUsecase:  scopes,
+		tunnelUsecase: tunnelUsecase.NewTunnelUsecase(tunnelRepository.NewMemoryRepository(10), passthrough, false, 0),
+		config:        config.ProxyConfig{ClientTimeout: time.Second},
+		upstream:      dialer,
+	}
+}
+
+// connect opens a tunnel to target through the proxy at addr.
+func connect(t *testing.T, addr, target string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if _, err := conn.Write([]byte("CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT = %v, %v", resp, err)
+	}
+
+	return conn, reader
+}
+
+func TestTunnelOutlivesProxyTimeout(t *testing.T) {
+	target := echoServer(t)
+	host, _, _ := net.SplitHostPort(target)
+
+	tests := []struct {
+		name        string
+		passthrough []string
+		scope       []models.ScopeRule
+	}{
+		{name: "passthrough", passthrough: []string{host}},
+		{name: "out of scope", scope: []models.ScopeRule{{Enabled: true, Action: models.ScopeExclude, Host: host}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tunnelHandler(t, tt.passthrough, tt.scope...)
+
+			server := httptest.NewUnstartedServer(http.HandlerFunc(h.HandleHTTPS))
+			server.Config.ReadTimeout = 100 * time.Millisecond
+			server.Config.WriteTimeout = 100 * time.Millisecond
+			server.Start()
+			defer server.Close()
+
+			conn, reader := connect(t, server.Listener.Addr().String(), target)
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+			for _, message := range []string{"first", "second"} {
+				time.Sleep(250 * time.Millisecond)
+
+				if _, err := conn.Write([]byte(message)); err != nil {
+					t.Fatalf("write after %s: %v", message, err)
+				}
+
+				echo := make([]byte, len(message))
+				if _, err := io.ReadFull(reader, echo); err != nil || string(echo) != message {
+					t.Fatalf("echo = %q, %v, want %q", echo, err, message)
+				}
+			}
+		})
+	}
+}
+
+// waitTunnel polls the saved tunnels until there is the nth one.
+func waitTunnel(t *testing.T, h ProxyHandler, n int) models.Tunnel {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		tunnels, err := h.tunnelUsecase.GetTunnels()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tunnels) >= n {
+			tunnel, err := h.tunnelUsecase.GetTunnel(n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return tunnel
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d tunnels saved, want %d", len(tunnels), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPassthroughRecordsTunnel(t *testing.T) {
+	target := echoServer(t)
+	host, port, _ := net.SplitHostPort(target)
+
+	h := tunnelHandler(t, []string{host})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.HandleHTTPS(w, r.WithContext(authUsecase.WithUser(r.Context(), "alice")))
+	}))
+	defer server.Close()
+
+	conn, _ := connect(t, server.Listener.Addr().String(), target)
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// the echoed ClientHello fails the handshake, it only has to pass through
+	if err := tls.Client(conn, &tls.Config{ServerName: "pinned.example.com"}).Handshake(); err == nil {
+		t.Fatal("Handshake() with an echo server error = nil")
+	}
+	conn.Close()
+
+	tunnel := waitTunnel(t, h, 1)
+	if tunnel.Host != host || strconv.Itoa(tunnel.Port) != port || tunnel.Reason != models.PassthroughHost ||
+		tunnel.SNI != "pinned.example.com" || tunnel.User != "alice" {
+		t.Fatalf("saved tunnel = %+v", tunnel)
+	}
+	if tunnel.BytesSent == 0 || tunnel.BytesReceived != tunnel.BytesSent || tunnel.CreatedAt.IsZero() {
+		t.Fatalf("saved tunnel = %+v, want the echoed hello counted both ways", tunnel)
+	}
+}
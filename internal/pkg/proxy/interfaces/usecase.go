@@ -3,7 +3,13 @@ package interfaces
 import "github.com/aanufriev/httpproxy/internal/pkg/models"
 
 type Usecase interface {
-	SaveRequest(req models.Request) error
-	GetRequests() ([]models.Request, error)
+	SaveRequest(req models.Request) (int, error)
+	GetRequests(filter models.RequestFilter) (models.RequestPage, error)
 	GetRequest(id int) (models.Request, error)
+	SaveResponse(resp models.Response) error
+	GetResponse(requestID int) (models.Response, error)
+	SaveQuery(q models.SavedQuery) (models.SavedQuery, error)
+	GetQueries() ([]models.SavedQuery, error)
+	GetQuery(name string) (models.SavedQuery, error)
+	DeleteQuery(name string) error
 }
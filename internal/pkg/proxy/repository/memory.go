@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+)
+
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	requests    []models.Request
+	responses   map[int]models.Response
+	queries     map[string]models.SavedQuery
+	first       int
+	count       int
+	nextID      int
+	nextRespID  int
+	nextQueryID int
+}
+
+const DefaultMemoryCapacity = 10000
+
+func NewMemoryRepository(capacity int) interfaces.Repository {
+	if capacity <= 0 {
+		capacity = DefaultMemoryCapacity
+	}
+
+	return &MemoryRepository{
+		requests:    make([]models.Request, capacity),
+		responses:   make(map[int]models.Response),
+		queries:     make(map[string]models.SavedQuery),
+		nextID:      1,
+		nextRespID:  1,
+		nextQueryID: 1,
+	}
+}
+
+func (r *MemoryRepository) SaveRequest(req models.Request) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	req.ID = r.nextID
+	r.nextID++
+
+	if r.count == len(r.requests) {
+		delete(r.responses, r.requests[r.first].ID)
+		r.requests[r.first] = req
+		r.first = (r.first + 1) % len(r.requests)
+	} else {
+		r.requests[(r.first+r.count)%len(r.requests)] = req
+		r.count++
+	}
+
+	return req.ID, nil
+}
+
+func (r *MemoryRepository) GetRequests(filter models.RequestFilter) ([]models.Request, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	requests := make([]models.Request, 0)
+	for i := 0; i < r.count; i++ {
+		if filter.Limit > 0 && len(requests) == filter.Limit {
+			break
+		}
+
+		offset := i
+		if filter.Descending() {
+			offset = r.count - 1 - i
+		}
+
+		req := r.requests[(r.first+offset)%len(r.requests)]
+		var respPtr *models.Response
+		if resp, ok := r.responses[req.ID]; ok {
+			respPtr = &resp
+		}
+
+		if filter.Match(req, respPtr) {
+			requests = append(requests, req)
+		}
+	}
+
+	return requests, nil
+}
+
+func (r *MemoryRepository) GetRequest(id int) (models.Request, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	idx, ok := r.index(id)
+	if !ok {
+		return models.Request{}, sql.ErrNoRows
+	}
+
+	return r.requests[idx], nil
+}
+
+func (r *MemoryRepository) SaveResponse(resp models.Response) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.index(resp.RequestID); !ok {
+		return sql.ErrNoRows
+	}
+
+	resp.ID = r.nextRespID
+	r.nextRespID++
+	r.responses[resp.RequestID] = resp
+
+	return nil
+}
+
+func (r *MemoryRepository) GetResponse(requestID int) (models.Response, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	resp, ok := r.responses[requestID]
+	if !ok {
+		return models.Response{}, sql.ErrNoRows
+	}
+
+	return resp, nil
+}
+
+func (r *MemoryRepository) SaveQuery(q models.SavedQuery) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.queries[q.Name]; ok {
+		q.ID = existing.ID
+		q.CreatedAt = existing.CreatedAt
+	} else {
+		q.ID = r.nextQueryID
+		r.nextQueryID++
+	}
+	r.queries[q.Name] = q
+
+	return q.ID, nil
+}
+
+func (r *MemoryRepository) GetQueries() ([]models.SavedQuery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	queries := make([]models.SavedQuery, 0, len(r.queries))
+	for _, q := range r.queries {
+		queries = append(queries, q)
+	}
+	sort.Slice(queries, func(i, j int) bool {
+		return queries[i].Name < queries[j].Name
+	})
+
+	return queries, nil
+}
+
+func (r *MemoryRepository) GetQuery(name string) (models.SavedQuery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	q, ok := r.queries[name]
+	if !ok {
+		return models.SavedQuery{}, sql.ErrNoRows
+	}
+
+	return q, nil
+}
+
+func (r *MemoryRepository) DeleteQuery(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.queries[name]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.queries, name)
+
+	return nil
+}
+
+func (r *MemoryRepository) index(id int) (int, bool) {
+	oldest := r.nextID - r.count
+	if id < oldest || id >= r.nextID {
+		return 0, false
+	}
+
+	return (r.first + id - oldest) % len(r.requests), true
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+type migration struct {
+	table    string
+	column   string
+	postgres string
+	sqlite   string
+}
+
+// migrations add, in order, the columns the tables got after they first
+// shipped. CREATE TABLE IF NOT EXISTS leaves the table of a database created
+// by an older build as it was, so missing columns are added with defaults
+// for the rows already stored.
+var migrations = []migration{
+	{
+		table:    "requests",
+		column:   "parent_id",
+		postgres: "INT REFERENCES requests (id) ON DELETE SET NULL",
+		sqlite:   "INTEGER REFERENCES requests (id) ON DELETE SET NULL",
+	},
+	{
+		table:    "requests",
+		column:   "created_at",
+		postgres: "TIMESTAMPTZ NOT NULL DEFAULT 'epoch'",
+		sqlite:   "TIMESTAMP NOT NULL DEFAULT '1970-01-01 00:00:00+00:00'",
+	},
+	{
+		table:    "requests",
+		column:   "proto",
+		postgres: "TEXT NOT NULL DEFAULT 'HTTP/1.1'",
+		sqlite:   "TEXT NOT NULL DEFAULT 'HTTP/1.1'",
+	},
+	{
+		table:    "requests",
+		column:   "username",
+		postgres: "TEXT NOT NULL DEFAULT ''",
+		sqlite:   "TEXT NOT NULL DEFAULT ''",
+	},
+	{
+		table:    "responses",
+		column:   "truncated",
+		postgres: "BOOLEAN NOT NULL DEFAULT FALSE",
+		sqlite:   "BOOLEAN NOT NULL DEFAULT FALSE",
+	},
+}
+
+// MigratePostgres upgrades a database initialized with an older
+// configs/init.sql and creates the tables and indexes it is missing.
+func MigratePostgres(db *sql.DB) error {
+	err := migrate(db,
+		`SELECT column_name FROM information_schema.columns
+		WHERE table_schema = current_schema() AND table_name = $1`,
+		func(m migration) string { return m.postgres },
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(postgresSchema)
+	return err
+}
+
+func migrateSqlite(db *sql.DB) error {
+	return migrate(db,
+		`SELECT name FROM pragma_table_info($1)`,
+		func(m migration) string { return m.sqlite },
+	)
+}
+
+func migrate(db *sql.DB, columnsQuery string, definition func(migration) string) error {
+	columns := make(map[string]map[string]bool)
+	for _, m := range migrations {
+		if _, ok := columns[m.table]; ok {
+			continue
+		}
+
+		var err error
+		columns[m.table], err = queryColumns(db, columnsQuery, m.table)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, m := range migrations {
+		// a new table gets the current schema as a whole
+		if len(columns[m.table]) == 0 || columns[m.table][m.column] {
+			continue
+		}
+
+		_, err := db.Exec("ALTER TABLE " + m.table + " ADD COLUMN " + m.column + " " + definition(m))
+		if err != nil {
+			return fmt.Errorf("couldn't add %s.%s: %w", m.table, m.column, err)
+		}
+	}
+
+	return nil
+}
+
+func queryColumns(db *sql.DB, query, table string) (map[string]bool, error) {
+	rows, err := db.Query(query, table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+
+	return columns, rows.Err()
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMigrateSqlite(t *testing.T) {
+	db, err := sql.Open(SqliteDriver, filepath.Join(t.TempDir(), "requests.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// requests as the first build created it, responses as they shipped
+	_, err = db.Exec(`
+		CREATE TABLE requests (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			method TEXT NOT NULL,
+			host TEXT NOT NULL,
+			scheme TEXT NOT NULL,
+			path TEXT NOT NULL,
+			headers TEXT NOT NULL,
+			body TEXT NOT NULL,
+			params TEXT NOT NULL
+		);
+		CREATE TABLE responses (
+			id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+			request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+			status_code INTEGER NOT NULL,
+			headers TEXT NOT NULL,
+			body BLOB NOT NULL,
+			content_length INTEGER NOT NULL,
+			duration INTEGER NOT NULL
+		);
+		INSERT INTO requests (method, host, scheme, path, headers, body, params)
+		VALUES ('GET', 'example.com', 'http', '/', '{}', '', '{}');
+		INSERT INTO responses (request_id, status_code, headers, body, content_length, duration)
+		VALUES (1, 200, '{}', 'ok', 2, 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := migrateSqlite(db); err != nil {
+			t.Fatalf("migrateSqlite() #%d error = %v", i+1, err)
+		}
+	}
+
+	for _, m := range migrations {
+		columns, err := queryColumns(db, `SELECT name FROM pragma_table_info($1)`, m.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !columns[m.column] {
+			t.Errorf("%s.%s wasn't added", m.table, m.column)
+		}
+	}
+
+	repo, err := NewSqliteRepository(db)
+	if err != nil {
+		t.Fatalf("NewSqliteRepository() error = %v", err)
+	}
+
+	req, err := repo.GetRequest(1)
+	if err != nil || req.Host != "example.com" || req.ParentID != 0 || req.Proto != "HTTP/1.1" ||
+		req.User != "" || !req.CreatedAt.Equal(time.Unix(0, 0)) {
+		t.Fatalf("GetRequest() of a stored request = %+v, %v", req, err)
+	}
+
+	resp, err := repo.GetResponse(1)
+	if err != nil || resp.Body != "ok" || resp.Truncated {
+		t.Fatalf("GetResponse() of a stored response = %+v, %v", resp, err)
+	}
+}
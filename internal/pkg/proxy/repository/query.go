@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
+)
+
+const responseJoin = ` LEFT JOIN responses resp ON resp.id = (SELECT MAX(id) FROM responses WHERE request_id = req.id)`
+
+type dialect struct {
+	regexp       string
+	responseBody string
+}
+
+var (
+	postgresDialect = dialect{
+		regexp:       "%s ~ %s",
+		responseBody: "encode(resp.body, 'escape')",
+	}
+	sqliteDialect = dialect{
+		regexp:       "%s REGEXP %s",
+		responseBody: "CAST(resp.body AS TEXT)",
+	}
+)
+
+func (d dialect) column(field string) string {
+	switch field {
+	case "req.parent_id":
+		return "COALESCE(req.parent_id, 0)"
+	case "req.query":
+		return "req.params"
+	case "req.user":
+		return "req.username"
+	case "req.time":
+		return "req.created_at"
+	case "resp.status":
+		return "COALESCE(resp.status_code, 0)"
+	case "resp.headers":
+		return "COALESCE(resp.headers, '')"
+	case "resp.body":
+		return "COALESCE(" + d.responseBody + ", '')"
+	case "resp.length":
+		return "COALESCE(resp.content_length, 0)"
+	case "resp.duration":
+		return "COALESCE(resp.duration, 0)"
+	default:
+		return field
+	}
+}
+
+type queryCompiler struct {
+	dialect dialect
+	args    []interface{}
+	offset  int
+}
+
+func (d dialect) compile(expr query.Expr, offset int) (string, []interface{}) {
+	c := queryCompiler{
+		dialect: d,
+		args:    make([]interface{}, 0),
+		offset:  offset,
+	}
+
+	return c.compile(expr), c.args
+}
+
+func usesResponse(expr query.Expr) bool {
+	uses := false
+	query.Walk(expr, func(c query.Comparison) {
+		if strings.HasPrefix(c.Field, "resp.") {
+			uses = true
+		}
+	})
+
+	return uses
+}
+
+func (c *queryCompiler) placeholder(arg interface{}) string {
+	c.args = append(c.args, arg)
+	return fmt.Sprintf("$%d", c.offset+len(c.args))
+}
+
+func (c *queryCompiler) compile(expr query.Expr) string {
+	switch e := expr.(type) {
+	case query.And:
+		return "(" + c.compile(e.Left) + " AND " + c.compile(e.Right) + ")"
+	case query.Or:
+		return "(" + c.compile(e.Left) + " OR " + c.compile(e.Right) + ")"
+	case query.Not:
+		return "NOT " + c.compile(e.Expr)
+	case query.Comparison:
+		return c.comparison(e)
+	default:
+		return "FALSE"
+	}
+}
+
+func (c *queryCompiler) comparison(e query.Comparison) string {
+	column := c.dialect.column(e.Field)
+
+	switch e.Op {
+	case query.OpMatch:
+		return "(" + fmt.Sprintf(c.dialect.regexp, column, c.placeholder(e.Value)) + ")"
+	case query.OpNotMatch:
+		return "NOT (" + fmt.Sprintf(c.dialect.regexp, column, c.placeholder(e.Value)) + ")"
+	case query.OpContains:
+		value := EscapeLike(strings.ToLower(e.Value.(string)))
+		return "(LOWER(" + column + ") LIKE '%' || " + c.placeholder(value) + ` || '%' ESCAPE '\')`
+	default:
+		return "(" + column + " " + string(e.Op) + " " + c.placeholder(e.Value) + ")"
+	}
+}
@@ -0,0 +1,278 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		src      string
+		dialect  dialect
+		offset   int
+		wantSQL  string
+		wantArgs []interface{}
+	}{
+		{
+			src:      `req.method = "POST"`,
+			dialect:  postgresDialect,
+			wantSQL:  `(req.method = $1)`,
+			wantArgs: []interface{}{"POST"},
+		},
+		{
+			src:      `req.method = "POST"`,
+			dialect:  postgresDialect,
+			offset:   3,
+			wantSQL:  `(req.method = $4)`,
+			wantArgs: []interface{}{"POST"},
+		},
+		{
+			src:      `req.host ~ "^api\\."`,
+			dialect:  postgresDialect,
+			wantSQL:  `(req.host ~ $1)`,
+			wantArgs: []interface{}{`^api\.`},
+		},
+		{
+			src:      `req.host ~ "^api"`,
+			dialect:  sqliteDialect,
+			wantSQL:  `(req.host REGEXP $1)`,
+			wantArgs: []interface{}{`^api`},
+		},
+		{
+			src:      `req.host !~ "^api"`,
+			dialect:  sqliteDialect,
+			wantSQL:  `NOT (req.host REGEXP $1)`,
+			wantArgs: []interface{}{`^api`},
+		},
+		{
+			src:      `req.body contains "50%_Ä\\"`,
+			dialect:  postgresDialect,
+			wantSQL:  `(LOWER(req.body) LIKE '%' || $1 || '%' ESCAPE '\')`,
+			wantArgs: []interface{}{`50\%\_ä\\`},
+		},
+		{
+			src:      `resp.body contains "x"`,
+			dialect:  postgresDialect,
+			wantSQL:  `(LOWER(COALESCE(encode(resp.body, 'escape'), '')) LIKE '%' || $1 || '%' ESCAPE '\')`,
+			wantArgs: []interface{}{"x"},
+		},
+		{
+			src:      `resp.body contains "x"`,
+			dialect:  sqliteDialect,
+			wantSQL:  `(LOWER(COALESCE(CAST(resp.body AS TEXT), '')) LIKE '%' || $1 || '%' ESCAPE '\')`,
+			wantArgs: []interface{}{"x"},
+		},
+		{
+			src:      `req.user = "alice" and req.query contains "id"`,
+			dialect:  postgresDialect,
+			wantSQL:  `((req.username = $1) AND (LOWER(req.params) LIKE '%' || $2 || '%' ESCAPE '\'))`,
+			wantArgs: []interface{}{"alice", "id"},
+		},
+		{
+			src:      `req.parent_id = 0 or not resp.status >= 500`,
+			dialect:  postgresDialect,
+			wantSQL:  `((COALESCE(req.parent_id, 0) = $1) OR NOT (COALESCE(resp.status_code, 0) >= $2))`,
+			wantArgs: []interface{}{int64(0), int64(500)},
+		},
+		{
+			src:      `req.time < "2024-01-02T03:04:05+01:00"`,
+			dialect:  sqliteDialect,
+			wantSQL:  `(req.created_at < $1)`,
+			wantArgs: []interface{}{time.Date(2024, 1, 2, 2, 4, 5, 0, time.UTC)},
+		},
+		{
+			src:      `resp.length > 1 and resp.duration <= 2 and resp.headers = ""`,
+			dialect:  sqliteDialect,
+			wantSQL:  `(((COALESCE(resp.content_length, 0) > $1) AND (COALESCE(resp.duration, 0) <= $2)) AND (COALESCE(resp.headers, '') = $3))`,
+			wantArgs: []interface{}{int64(1), int64(2), ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := query.Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.src, err)
+			}
+
+			gotSQL, gotArgs := tt.dialect.compile(expr, tt.offset)
+			if gotSQL != tt.wantSQL {
+				t.Errorf("compile(%q) sql =\n%s\nwant\n%s", tt.src, gotSQL, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("compile(%q) args = %#v, want %#v", tt.src, gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestUsesResponse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: `req.id = 1`, want: false},
+		{src: `req.id = 1 and not (req.host = "a" or resp.status = 200)`, want: true},
+		{src: `resp.body contains "a"`, want: true},
+	}
+
+	for _, tt := range tests {
+		expr, err := query.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.src, err)
+		}
+
+		if got := usesResponse(expr); got != tt.want {
+			t.Errorf("usesResponse(%q) = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+// TestQueryStorages runs the same queries against sqlite and memory storage,
+// both have to return the same requests.
+func TestQueryStorages(t *testing.T) {
+	db, err := sql.Open(SqliteDriver, filepath.Join(t.TempDir(), "requests.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlite, err := NewSqliteRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storages := map[string]interfaces.Repository{
+		"sqlite": sqlite,
+		"memory": NewMemoryRepository(100),
+	}
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	exchanges := []struct {
+		req  models.Request
+		resp *models.Response
+	}{
+		{
+			req:  models.Request{Method: "GET", Scheme: "https", Host: "api.example.com", Path: "/users", Body: "", User: "alice"},
+			resp: &models.Response{StatusCode: 200, Headers: `{"Content-Type":["application/json"]}`, Body: `{"name":"Jürgen"}`},
+		},
+		{
+			req:  models.Request{Method: "POST", Scheme: "https", Host: "api.example.com", Path: "/users", Body: "name=ÄBC&x=50%"},
+			resp: &models.Response{StatusCode: 503, Headers: `{}`, Body: "ÜBER LOAD", Duration: 1500},
+		},
+		{
+			req: models.Request{Method: "GET", Scheme: "http", Host: "Example.org", Path: "/a_b", Body: "plain"},
+		},
+	}
+
+	for name, storage := range storages {
+		for i, e := range exchanges {
+			e.req.Headers, e.req.Params, e.req.Proto = `{}`, `{}`, "HTTP/1.1"
+			e.req.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+
+			id, err := storage.SaveRequest(e.req)
+			if err != nil {
+				t.Fatalf("%s: SaveRequest error = %v", name, err)
+			}
+
+			if e.resp != nil {
+				e.resp.RequestID = id
+				e.resp.ContentLength = int64(len(e.resp.Body))
+				if err := storage.SaveResponse(*e.resp); err != nil {
+					t.Fatalf("%s: SaveResponse error = %v", name, err)
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		src  string
+		want []int
+	}{
+		{src: `req.method = "GET"`, want: []int{1, 3}},
+		{src: `req.method = "get"`, want: []int{}},
+		{src: `req.host contains "EXAMPLE"`, want: []int{1, 2, 3}},
+		{src: `req.host ~ "^api\\."`, want: []int{1, 2}},
+		{src: `req.host !~ "^api\\."`, want: []int{3}},
+		{src: `req.body contains "äbc"`, want: []int{2}},
+		{src: `req.body contains "50%"`, want: []int{2}},
+		{src: `req.body contains "%"`, want: []int{2}},
+		{src: `req.path contains "_"`, want: []int{3}},
+		{src: `req.path contains "a_"`, want: []int{3}},
+		{src: `resp.body contains "über"`, want: []int{2}},
+		{src: `resp.body contains "jürgen"`, want: []int{1}},
+		{src: `resp.status >= 500`, want: []int{2}},
+		{src: `resp.status = 0`, want: []int{3}},
+		{src: `resp.body = ""`, want: []int{3}},
+		{src: `resp.duration > 1000`, want: []int{2}},
+		{src: `resp.length = 10`, want: []int{2}},
+		{src: `req.user = "alice"`, want: []int{1}},
+		{src: `req.user = ""`, want: []int{2, 3}},
+		{src: `req.parent_id = 0 and req.proto = "HTTP/1.1"`, want: []int{1, 2, 3}},
+		{src: `req.time >= "2024-01-02T04:04:05Z"`, want: []int{2, 3}},
+		{src: `req.time < "2024-01-02T05:04:05+01:00"`, want: []int{1}},
+		{src: `not (req.method = "GET" and resp.status = 200)`, want: []int{2, 3}},
+		{src: `req.id = 1 or resp.headers contains "json" or req.scheme = "http"`, want: []int{1, 3}},
+	}
+
+	for _, tt := range tests {
+		expr, err := query.Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tt.src, err)
+		}
+
+		for name, storage := range storages {
+			requests, err := storage.GetRequests(models.RequestFilter{Expr: expr})
+			if err != nil {
+				t.Fatalf("%s: %s error = %v", name, tt.src, err)
+			}
+
+			got := make([]int, 0, len(requests))
+			for _, req := range requests {
+				got = append(got, req.ID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: %s = %v, want %v", name, tt.src, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestMatchRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		want    bool
+		err     bool
+	}{
+		{pattern: "^a", value: "abc", want: true},
+		{pattern: "^a", value: "bac", want: false},
+		{pattern: "(?i)ä", value: "Ä", want: true},
+		{pattern: "(", value: "", err: true},
+	}
+
+	for _, tt := range tests {
+		got, err := matchRegexp(tt.pattern, tt.value)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("matchRegexp(%q, %q) = %v, %v", tt.pattern, tt.value, got, err)
+		}
+	}
+
+	for i := 0; i < 2*maxCachedRegexps; i++ {
+		if _, err := matchRegexp(string(rune('a'+i%26))+strings.Repeat("x", i), ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(regexpCache.entries); n > maxCachedRegexps {
+		t.Errorf("regexp cache holds %d patterns, want at most %d", n, maxCachedRegexps)
+	}
+}
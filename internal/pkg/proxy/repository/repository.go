@@ -2,49 +2,161 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/aanufriev/httpproxy/internal/pkg/models"
 	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
 )
 
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS requests (
+    id SERIAL NOT NULL PRIMARY KEY,
+    method TEXT NOT NULL,
+    host TEXT NOT NULL,
+    scheme TEXT NOT NULL,
+    path TEXT NOT NULL,
+    headers TEXT NOT NULL,
+    body TEXT NOT NULL,
+    params TEXT NOT NULL,
+    proto TEXT NOT NULL,
+    username TEXT NOT NULL,
+    parent_id INT REFERENCES requests (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS requests_host_idx ON requests (LOWER(host));
+CREATE INDEX IF NOT EXISTS requests_method_idx ON requests (method);
+CREATE INDEX IF NOT EXISTS requests_created_at_idx ON requests (created_at);
+CREATE INDEX IF NOT EXISTS requests_path_trgm_idx ON requests USING GIN (LOWER(path) gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS responses (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status_code INT NOT NULL,
+    headers TEXT NOT NULL,
+    body BYTEA NOT NULL,
+    content_length BIGINT NOT NULL,
+    duration BIGINT NOT NULL,
+    truncated BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS responses_request_id_idx ON responses (request_id);
+
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id SERIAL NOT NULL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    query TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+`
+
+const (
+	requestColumns = `req.id, COALESCE(req.parent_id, 0), req.method, req.host, req.scheme, req.path,
+		req.headers, req.body, req.params, req.proto, req.username, req.created_at`
+	savedQueryColumns = `id, name, query, created_at, updated_at`
+)
+
 type ProxyRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect dialect
 }
 
 func NewProxyRepository(db *sql.DB) interfaces.Repository {
 	return ProxyRepository{
-		db: db,
+		db:      db,
+		dialect: postgresDialect,
 	}
 }
 
-func (r ProxyRepository) SaveRequest(req models.Request) error {
-	_, err := r.db.Exec(
-		`INSERT INTO requests (method, host, scheme, path, headers, body, params)
-		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
-		req.Method, req.Host, req.Scheme, req.Path, req.Headers, req.Body, req.Params,
-	)
+func (r ProxyRepository) SaveRequest(req models.Request) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO requests (method, host, scheme, path, headers, body, params, proto, username, parent_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, 0), $11) RETURNING id`,
+		req.Method, req.Host, req.Scheme, req.Path, req.Headers, req.Body, req.Params, req.Proto,
+		req.User, req.ParentID, req.CreatedAt,
+	).Scan(&id)
 
-	return err
+	return id, err
 }
 
-func (r ProxyRepository) GetRequests() ([]models.Request, error) {
-	rows, err := r.db.Query(
-		`SELECT id, method, host, scheme, path, headers, body, params FROM requests
-		ORDER BY id`,
-	)
+func (r ProxyRepository) GetRequests(filter models.RequestFilter) ([]models.Request, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	contains := func(column string, value string) {
+		add("LOWER("+column+") LIKE '%%' || $%d || '%%' ESCAPE '\\'", EscapeLike(strings.ToLower(value)))
+	}
+
+	if filter.Host != "" {
+		add("LOWER(req.host) = $%d", filter.Host)
+	}
+	if filter.Method != "" {
+		add("req.method = $%d", filter.Method)
+	}
+	if filter.Scheme != "" {
+		add("req.scheme = $%d", filter.Scheme)
+	}
+	if filter.Path != "" {
+		contains("req.path", filter.Path)
+	}
+	if filter.Header != "" {
+		contains("req.headers", filter.Header)
+	}
+	if filter.Body != "" {
+		contains("req.body", filter.Body)
+	}
+	if !filter.From.IsZero() {
+		add("req.created_at >= $%d", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("req.created_at < $%d", filter.To.UTC())
+	}
+	if filter.Expr != nil {
+		condition, exprArgs := r.dialect.compile(filter.Expr, len(args))
+		args = append(args, exprArgs...)
+		conditions = append(conditions, condition)
+	}
+
+	order := "ASC"
+	if filter.Descending() {
+		order = "DESC"
+		if filter.Cursor != 0 {
+			add("req.id < $%d", filter.Cursor)
+		}
+	} else if filter.Cursor != 0 {
+		add("req.id > $%d", filter.Cursor)
+	}
+
+	query := `SELECT ` + requestColumns + ` FROM requests req`
+	if filter.Expr != nil && usesResponse(filter.Expr) {
+		query += responseJoin
+	}
+	if len(conditions) != 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY req.id ` + order
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	requests := make([]models.Request, 0)
-	req := models.Request{}
 	for rows.Next() {
-		err = rows.Scan(
-			&req.ID, &req.Method, &req.Host, &req.Scheme,
-			&req.Path, &req.Headers, &req.Body, &req.Params,
-		)
-
+		req, err := scanRequest(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -52,23 +164,145 @@ func (r ProxyRepository) GetRequests() ([]models.Request, error) {
 		requests = append(requests, req)
 	}
 
-	return requests, nil
+	return requests, rows.Err()
 }
 
 func (r ProxyRepository) GetRequest(id int) (models.Request, error) {
-	var req models.Request
-	err := r.db.QueryRow(
-		`SELECT id, method, host, scheme, path, headers, body, params FROM requests
-		WHERE id = $1`,
+	row := r.db.QueryRow(
+		`SELECT `+requestColumns+` FROM requests req
+		WHERE req.id = $1`,
 		id,
+	)
+
+	return scanRequest(row)
+}
+
+func (r ProxyRepository) SaveResponse(resp models.Response) error {
+	_, err := r.db.Exec(
+		`INSERT INTO responses (request_id, status_code, headers, body, content_length, duration, truncated)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		resp.RequestID, resp.StatusCode, resp.Headers, []byte(resp.Body), resp.ContentLength, resp.Duration,
+		resp.Truncated,
+	)
+
+	return err
+}
+
+func (r ProxyRepository) GetResponse(requestID int) (models.Response, error) {
+	var resp models.Response
+	err := r.db.QueryRow(
+		`SELECT id, request_id, status_code, headers, body, content_length, duration, truncated FROM responses
+		WHERE request_id = $1
+		ORDER BY id DESC LIMIT 1`,
+		requestID,
 	).Scan(
-		&req.ID, &req.Method, &req.Host, &req.Scheme,
-		&req.Path, &req.Headers, &req.Body, &req.Params,
+		&resp.ID, &resp.RequestID, &resp.StatusCode, &resp.Headers,
+		&resp.Body, &resp.ContentLength, &resp.Duration, &resp.Truncated,
+	)
+
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	return resp, nil
+}
+
+func (r ProxyRepository) SaveQuery(q models.SavedQuery) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO saved_queries (name, query, created_at, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (name) DO UPDATE SET query = excluded.query, updated_at = excluded.updated_at
+		RETURNING id`,
+		q.Name, q.Query, q.CreatedAt, q.UpdatedAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r ProxyRepository) GetQueries() ([]models.SavedQuery, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + savedQueryColumns + ` FROM saved_queries
+		ORDER BY name`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	queries := make([]models.SavedQuery, 0)
+	for rows.Next() {
+		q, err := scanSavedQuery(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, q)
+	}
+
+	return queries, rows.Err()
+}
+
+func (r ProxyRepository) GetQuery(name string) (models.SavedQuery, error) {
+	row := r.db.QueryRow(
+		`SELECT `+savedQueryColumns+` FROM saved_queries
+		WHERE name = $1`,
+		name,
 	)
 
+	return scanSavedQuery(row)
+}
+
+func (r ProxyRepository) DeleteQuery(name string) error {
+	result, err := r.db.Exec(`DELETE FROM saved_queries WHERE name = $1`, name)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRequest(row scanner) (models.Request, error) {
+	var req models.Request
+	err := row.Scan(
+		&req.ID, &req.ParentID, &req.Method, &req.Host, &req.Scheme,
+		&req.Path, &req.Headers, &req.Body, &req.Params, &req.Proto, &req.User, &req.CreatedAt,
+	)
 	if err != nil {
 		return models.Request{}, err
 	}
 
 	return req, nil
 }
+
+func scanSavedQuery(row scanner) (models.SavedQuery, error) {
+	var q models.SavedQuery
+	err := row.Scan(&q.ID, &q.Name, &q.Query, &q.CreatedAt, &q.UpdatedAt)
+	if err != nil {
+		return models.SavedQuery{}, err
+	}
+
+	return q, nil
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// EscapeLike escapes the LIKE wildcards in value for use with ESCAPE '\'.
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"bytes"
+	"container/list"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS requests (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    method TEXT NOT NULL,
+    host TEXT NOT NULL,
+    scheme TEXT NOT NULL,
+    path TEXT NOT NULL,
+    headers TEXT NOT NULL,
+    body TEXT NOT NULL,
+    params TEXT NOT NULL,
+    proto TEXT NOT NULL,
+    username TEXT NOT NULL,
+    parent_id INTEGER REFERENCES requests (id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS requests_host_idx ON requests (LOWER(host));
+CREATE INDEX IF NOT EXISTS requests_method_idx ON requests (method);
+CREATE INDEX IF NOT EXISTS requests_created_at_idx ON requests (created_at);
+
+CREATE TABLE IF NOT EXISTS responses (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status_code INTEGER NOT NULL,
+    headers TEXT NOT NULL,
+    body BLOB NOT NULL,
+    content_length INTEGER NOT NULL,
+    duration INTEGER NOT NULL,
+    truncated BOOLEAN NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS responses_request_id_idx ON responses (request_id);
+
+CREATE TABLE IF NOT EXISTS saved_queries (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE,
+    query TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+`
+
+const SqliteDriver = "sqlite3_httpproxy"
+
+func init() {
+	sql.Register(SqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := conn.RegisterFunc("regexp", matchRegexp, true); err != nil {
+				return err
+			}
+
+			return conn.RegisterFunc("lower", lower, true)
+		},
+	})
+}
+
+type SqliteRepository struct {
+	ProxyRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	err := migrateSqlite(db)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		ProxyRepository: ProxyRepository{
+			db:      db,
+			dialect: sqliteDialect,
+		},
+	}, nil
+}
+
+// maxCachedRegexps bounds the compiled patterns kept between calls, REGEXP
+// runs once per row with the same pattern.
+const maxCachedRegexps = 64
+
+var regexpCache = struct {
+	sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}{
+	order:   list.New(),
+	entries: make(map[string]*list.Element),
+}
+
+func matchRegexp(pattern, value string) (bool, error) {
+	re, err := compileRegexp(pattern)
+	if err != nil {
+		return false, err
+	}
+
+	return re.MatchString(value), nil
+}
+
+func compileRegexp(pattern string) (*regexp.Regexp, error) {
+	regexpCache.Lock()
+	defer regexpCache.Unlock()
+
+	if elem, ok := regexpCache.entries[pattern]; ok {
+		regexpCache.order.MoveToFront(elem)
+		return elem.Value.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexpCache.entries[pattern] = regexpCache.order.PushFront(re)
+	if regexpCache.order.Len() > maxCachedRegexps {
+		oldest := regexpCache.order.Back()
+		regexpCache.order.Remove(oldest)
+		delete(regexpCache.entries, oldest.Value.(*regexp.Regexp).String())
+	}
+
+	return re, nil
+}
+
+// lower replaces the built-in LOWER, which folds only ASCII letters, so
+// contains matches the same rows as in the other storages.
+func lower(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ToLower(v)
+	case []byte:
+		if v == nil {
+			return nil
+		}
+		return bytes.ToLower(v)
+	default:
+		return v
+	}
+}
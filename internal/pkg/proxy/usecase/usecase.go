@@ -1,10 +1,18 @@
 package usecase
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/aanufriev/httpproxy/internal/pkg/models"
 	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/query"
 )
 
+var ErrQueryNotFound = errors.New("saved query not found")
+
 type ProxyUsecase struct {
 	proxyRepository interfaces.Repository
 }
@@ -15,14 +23,89 @@ func NewProxyUsecase(proxyRepository interfaces.Repository) ProxyUsecase {
 	}
 }
 
-func (u ProxyUsecase) SaveRequest(req models.Request) error {
+func (u ProxyUsecase) SaveRequest(req models.Request) (int, error) {
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = time.Now()
+	}
+	req.CreatedAt = req.CreatedAt.UTC()
+
 	return u.proxyRepository.SaveRequest(req)
 }
 
-func (u ProxyUsecase) GetRequests() ([]models.Request, error) {
-	return u.proxyRepository.GetRequests()
+func (u ProxyUsecase) GetRequests(filter models.RequestFilter) (models.RequestPage, error) {
+	if filter.Saved != "" {
+		saved, err := u.proxyRepository.GetQuery(filter.Saved)
+		if err == sql.ErrNoRows {
+			return models.RequestPage{}, fmt.Errorf("%w: %s", ErrQueryNotFound, filter.Saved)
+		}
+		if err != nil {
+			return models.RequestPage{}, err
+		}
+
+		expr, err := query.Parse(saved.Query)
+		if err != nil {
+			return models.RequestPage{}, err
+		}
+		filter.Expr = query.Conjoin(expr, filter.Expr)
+	}
+
+	limit := filter.Limit
+	if limit > 0 {
+		filter.Limit = limit + 1
+	}
+
+	requests, err := u.proxyRepository.GetRequests(filter)
+	if err != nil {
+		return models.RequestPage{}, err
+	}
+
+	page := models.RequestPage{
+		Requests: requests,
+	}
+	if limit > 0 && len(requests) > limit {
+		page.Requests = requests[:limit]
+		page.NextCursor = requests[limit-1].ID
+	}
+
+	return page, nil
 }
 
 func (u ProxyUsecase) GetRequest(id int) (models.Request, error) {
 	return u.proxyRepository.GetRequest(id)
 }
+
+func (u ProxyUsecase) SaveResponse(resp models.Response) error {
+	return u.proxyRepository.SaveResponse(resp)
+}
+
+func (u ProxyUsecase) GetResponse(requestID int) (models.Response, error) {
+	return u.proxyRepository.GetResponse(requestID)
+}
+
+func (u ProxyUsecase) SaveQuery(q models.SavedQuery) (models.SavedQuery, error) {
+	if err := q.Validate(); err != nil {
+		return models.SavedQuery{}, err
+	}
+
+	now := time.Now().UTC()
+	q.CreatedAt = now
+	q.UpdatedAt = now
+
+	if _, err := u.proxyRepository.SaveQuery(q); err != nil {
+		return models.SavedQuery{}, err
+	}
+
+	return u.proxyRepository.GetQuery(q.Name)
+}
+
+func (u ProxyUsecase) GetQueries() ([]models.SavedQuery, error) {
+	return u.proxyRepository.GetQueries()
+}
+
+func (u ProxyUsecase) GetQuery(name string) (models.SavedQuery, error) {
+	return u.proxyRepository.GetQuery(name)
+}
+
+func (u ProxyUsecase) DeleteQuery(name string) error {
+	return u.proxyRepository.DeleteQuery(name)
+}
@@ -0,0 +1,167 @@
+package usecase
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
+)
+
+func TestRequestPages(t *testing.T) {
+	db, err := sql.Open(repository.SqliteDriver, filepath.Join(t.TempDir(), "requests.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	sqlite, err := repository.NewSqliteRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usecases := map[string]ProxyUsecase{
+		"sqlite": NewProxyUsecase(sqlite),
+		"memory": NewProxyUsecase(repository.NewMemoryRepository(10)),
+	}
+
+	created := time.Date(2024, 1, 2, 3, 0, 0, 0, time.UTC)
+	requests := []models.Request{
+		{Method: "GET", Host: "api.example.com", Path: "/users", Headers: `{"Accept":["application/json"]}`},
+		{Method: "POST", Host: "api.example.com", Path: "/users", Body: "name=bob"},
+		{Method: "GET", Host: "Example.org", Path: "/"},
+		{Method: "GET", Host: "api.example.com", Path: "/users/1"},
+		{Method: "DELETE", Host: "api.example.com", Path: "/users/1"},
+		{Method: "GET", Host: "example.org", Path: "/about"},
+		{Method: "GET", Host: "api.example.com", Path: "/orders"},
+	}
+
+	for name, u := range usecases {
+		for i, req := range requests {
+			req.Scheme, req.Params, req.Proto = "https", `{}`, "HTTP/1.1"
+			if req.Headers == "" {
+				req.Headers = `{}`
+			}
+			req.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+
+			if _, err := u.SaveRequest(req); err != nil {
+				t.Fatalf("%s: SaveRequest error = %v", name, err)
+			}
+		}
+
+		if _, err := u.SaveQuery(models.SavedQuery{Name: "users", Query: `req.path contains "/users"`}); err != nil {
+			t.Fatalf("%s: SaveQuery error = %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{query: "limit=3", want: []int{1, 2, 3, 4, 5, 6, 7}},
+		{query: "limit=3&order=desc", want: []int{7, 6, 5, 4, 3, 2, 1}},
+		{query: "limit=2&method=get", want: []int{1, 3, 4, 6, 7}},
+		{query: "limit=2&method=get&order=desc", want: []int{7, 6, 4, 3, 1}},
+		{query: "limit=2&host=EXAMPLE.ORG", want: []int{3, 6}},
+		{query: "limit=1&host=example.org", want: []int{3, 6}},
+		{query: "path=USERS", want: []int{1, 2, 4, 5}},
+		{query: "header=JSON", want: []int{1}},
+		{query: "body=bob", want: []int{2}},
+		{query: "from=2024-01-02T05:00:00Z&to=2024-01-02T08:00:00Z&limit=2", want: []int{3, 4, 5}},
+		{query: "from=2024-01-02T06:00:00%2B01:00", want: []int{3, 4, 5, 6, 7}},
+		{query: "cursor=4", want: []int{5, 6, 7}},
+		{query: "cursor=4&order=desc", want: []int{3, 2, 1}},
+		{query: "saved=users&method=GET&limit=1", want: []int{1, 4}},
+		{query: `q=req.host+contains+"org"+or+req.method+%3D+"DELETE"&limit=2`, want: []int{3, 5, 6}},
+	}
+
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		filter, err := models.RequestFilterFromQuery(values, models.DefaultRequestLimit)
+		if err != nil {
+			t.Fatalf("RequestFilterFromQuery(%s) error = %v", tt.query, err)
+		}
+
+		for name, u := range usecases {
+			got := readPages(t, u, filter)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: %s = %v, want %v", name, tt.query, got, tt.want)
+			}
+		}
+	}
+
+	for name, u := range usecases {
+		if _, err := u.GetRequests(models.RequestFilter{Saved: "missing"}); !errors.Is(err, ErrQueryNotFound) {
+			t.Errorf("%s: GetRequests() with a missing saved query error = %v, want %v", name, err, ErrQueryNotFound)
+		}
+	}
+}
+
+// readPages follows the cursors from filter and returns the ids of every
+// request on the pages. Every page but the last must be full.
+func readPages(t *testing.T, u interfaces.Usecase, filter models.RequestFilter) []int {
+	t.Helper()
+
+	ids := make([]int, 0)
+	for pages := 0; pages < 10; pages++ {
+		page, err := u.GetRequests(filter)
+		if err != nil {
+			t.Fatalf("GetRequests(%+v) error = %v", filter, err)
+		}
+
+		for _, req := range page.Requests {
+			ids = append(ids, req.ID)
+		}
+
+		if page.NextCursor == 0 {
+			return ids
+		}
+		if len(page.Requests) != filter.Limit || page.NextCursor != page.Requests[len(page.Requests)-1].ID {
+			t.Fatalf("GetRequests(%+v) = %d requests, next cursor %d", filter, len(page.Requests), page.NextCursor)
+		}
+
+		filter.Cursor = page.NextCursor
+	}
+
+	t.Fatalf("GetRequests(%+v) doesn't stop paging", filter)
+	return nil
+}
+
+func TestRequestFilterFromQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		err   bool
+	}{
+		{query: ""},
+		{query: "limit=1000&order=DESC&cursor=5"},
+		{query: "limit=0", err: true},
+		{query: "limit=1001", err: true},
+		{query: "limit=x", err: true},
+		{query: "cursor=-1", err: true},
+		{query: "order=newest", err: true},
+		{query: "from=yesterday", err: true},
+		{query: "q=req.method+%3D", err: true},
+	}
+
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		filter, err := models.RequestFilterFromQuery(values, models.DefaultRequestLimit)
+		if (err != nil) != tt.err {
+			t.Errorf("RequestFilterFromQuery(%s) error = %v, want error %v", tt.query, err, tt.err)
+			continue
+		}
+
+		if err == nil && filter.Limit == 0 {
+			t.Errorf("RequestFilterFromQuery(%s) = %+v without a limit", tt.query, filter)
+		}
+	}
+}
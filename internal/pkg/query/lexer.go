@@ -0,0 +1,110 @@
+package query
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenOperator
+	tokenLParen
+	tokenRParen
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value string
+	pos   int
+}
+
+var operators = []string{"==", "!=", "!~", "<=", ">=", "&&", "||", "=", "~", "<", ">", "!"}
+
+func tokenize(src string) ([]token, error) {
+	tokens := make([]token, 0)
+	pos := 0
+
+	for pos < len(src) {
+		c := rune(src[pos])
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: pos})
+			pos++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: pos})
+			pos++
+		case c == '"':
+			tok, err := readString(src, pos)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, tok)
+			pos += len(tok.text)
+		case c == '-' || unicode.IsDigit(c):
+			end := pos + 1
+			for end < len(src) && unicode.IsDigit(rune(src[end])) {
+				end++
+			}
+			if src[pos:end] == "-" {
+				return nil, errorf(pos, "unexpected %q", "-")
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: src[pos:end], value: src[pos:end], pos: pos})
+			pos = end
+		case isIdentRune(c):
+			end := pos + 1
+			for end < len(src) && (isIdentRune(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[pos:end], value: src[pos:end], pos: pos})
+			pos = end
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, errorf(pos, "unexpected %q", string(src[pos]))
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, value: op, pos: pos})
+			pos += len(op)
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: pos}), nil
+}
+
+func readString(src string, start int) (token, error) {
+	var value strings.Builder
+	for end := start + 1; end < len(src); end++ {
+		switch c := src[end]; c {
+		case '\\':
+			if end+1 < len(src) && (src[end+1] == '"' || src[end+1] == '\\') {
+				end++
+				value.WriteByte(src[end])
+				continue
+			}
+			value.WriteByte(c)
+		case '"':
+			return token{kind: tokenString, text: src[start : end+1], value: value.String(), pos: start}, nil
+		default:
+			value.WriteByte(c)
+		}
+	}
+
+	return token{}, errorf(start, "unterminated string")
+}
+
+func isIdentRune(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
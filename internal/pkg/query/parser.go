@@ -0,0 +1,206 @@
+package query
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func Parse(src string) (Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, errorf(0, "empty query")
+	}
+
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, errorf(tok.pos, "unexpected %q", tok.text)
+	}
+
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *parser) accept(words ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenIdent && tok.kind != tokenOperator {
+		return false
+	}
+
+	for _, word := range words {
+		if strings.EqualFold(tok.text, word) {
+			p.pos++
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = Or{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("and", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = And{Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.accept("not", "!") {
+		expr, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return Not{Expr: expr}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, errorf(closing.pos, "expected \")\"")
+		}
+
+		return expr, nil
+	case tokenIdent:
+		return p.parseComparison(tok)
+	case tokenEOF:
+		return nil, errorf(tok.pos, "unexpected end of query")
+	default:
+		return nil, errorf(tok.pos, "expected field, got %q", tok.text)
+	}
+}
+
+func (p *parser) parseComparison(field token) (Expr, error) {
+	name := strings.ToLower(field.text)
+	kind, ok := fields[name]
+	if !ok {
+		return nil, errorf(field.pos, "unknown field %q, known fields: %s", field.text, strings.Join(Fields(), ", "))
+	}
+
+	opToken := p.next()
+	op := Op(strings.ToLower(opToken.text))
+	if op == "==" {
+		op = OpEq
+	}
+	if opToken.kind != tokenOperator && op != OpContains {
+		return nil, errorf(opToken.pos, "expected operator after %s", field.text)
+	}
+	if !allowed(kind, op) {
+		return nil, errorf(opToken.pos, "operator %q is not supported for %s", opToken.text, field.text)
+	}
+
+	valueToken := p.next()
+	comparison := Comparison{Field: name, Kind: kind, Op: op}
+
+	switch kind {
+	case KindString:
+		if valueToken.kind != tokenString {
+			return nil, errorf(valueToken.pos, "%s must be compared with a string", field.text)
+		}
+		comparison.Value = valueToken.value
+
+		if op == OpMatch || op == OpNotMatch {
+			re, err := regexp.Compile(valueToken.value)
+			if err != nil {
+				return nil, errorf(valueToken.pos, "invalid regexp: %v", err)
+			}
+			comparison.Regexp = re
+		}
+	case KindInt:
+		if valueToken.kind != tokenNumber {
+			return nil, errorf(valueToken.pos, "%s must be compared with a number", field.text)
+		}
+
+		value, err := strconv.ParseInt(valueToken.value, 10, 64)
+		if err != nil {
+			return nil, errorf(valueToken.pos, "invalid number %s", valueToken.text)
+		}
+		comparison.Value = value
+	case KindTime:
+		if valueToken.kind != tokenString {
+			return nil, errorf(valueToken.pos, "%s must be compared with an RFC 3339 time string", field.text)
+		}
+
+		value, err := time.Parse(time.RFC3339, valueToken.value)
+		if err != nil {
+			return nil, errorf(valueToken.pos, "invalid time %s, expected RFC 3339", valueToken.text)
+		}
+		comparison.Value = value.UTC()
+	}
+
+	return comparison, nil
+}
+
+func allowed(kind Kind, op Op) bool {
+	for _, candidate := range kindOps[kind] {
+		if candidate == op {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,203 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindInt
+	KindTime
+)
+
+type Op string
+
+const (
+	OpEq       Op = "="
+	OpNe       Op = "!="
+	OpLt       Op = "<"
+	OpLe       Op = "<="
+	OpGt       Op = ">"
+	OpGe       Op = ">="
+	OpMatch    Op = "~"
+	OpNotMatch Op = "!~"
+	OpContains Op = "contains"
+)
+
+var fields = map[string]Kind{
+	"req.id":        KindInt,
+	"req.parent_id": KindInt,
+	"req.method":    KindString,
+	"req.scheme":    KindString,
+	"req.proto":     KindString,
+	"req.user":      KindString,
+	"req.host":      KindString,
+	"req.path":      KindString,
+	"req.query":     KindString,
+	"req.headers":   KindString,
+	"req.body":      KindString,
+	"req.time":      KindTime,
+	"resp.status":   KindInt,
+	"resp.headers":  KindString,
+	"resp.body":     KindString,
+	"resp.length":   KindInt,
+	"resp.duration": KindInt,
+}
+
+var kindOps = map[Kind][]Op{
+	KindString: {OpEq, OpNe, OpMatch, OpNotMatch, OpContains},
+	KindInt:    {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+	KindTime:   {OpEq, OpNe, OpLt, OpLe, OpGt, OpGe},
+}
+
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("query: %s at position %d", e.Msg, e.Pos+1)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type Record map[string]interface{}
+
+type Expr interface {
+	Eval(record Record) bool
+}
+
+type And struct {
+	Left  Expr
+	Right Expr
+}
+
+type Or struct {
+	Left  Expr
+	Right Expr
+}
+
+type Not struct {
+	Expr Expr
+}
+
+type Comparison struct {
+	Field  string
+	Kind   Kind
+	Op     Op
+	Value  interface{}
+	Regexp *regexp.Regexp
+}
+
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func Conjoin(left, right Expr) Expr {
+	switch {
+	case left == nil:
+		return right
+	case right == nil:
+		return left
+	default:
+		return And{Left: left, Right: right}
+	}
+}
+
+func Walk(expr Expr, fn func(c Comparison)) {
+	switch e := expr.(type) {
+	case And:
+		Walk(e.Left, fn)
+		Walk(e.Right, fn)
+	case Or:
+		Walk(e.Left, fn)
+		Walk(e.Right, fn)
+	case Not:
+		Walk(e.Expr, fn)
+	case Comparison:
+		fn(e)
+	}
+}
+
+func (e And) Eval(record Record) bool {
+	return e.Left.Eval(record) && e.Right.Eval(record)
+}
+
+func (e Or) Eval(record Record) bool {
+	return e.Left.Eval(record) || e.Right.Eval(record)
+}
+
+func (e Not) Eval(record Record) bool {
+	return !e.Expr.Eval(record)
+}
+
+func (c Comparison) Eval(record Record) bool {
+	switch c.Kind {
+	case KindString:
+		value, _ := record[c.Field].(string)
+		want := c.Value.(string)
+
+		switch c.Op {
+		case OpEq:
+			return value == want
+		case OpNe:
+			return value != want
+		case OpMatch:
+			return c.Regexp.MatchString(value)
+		case OpNotMatch:
+			return !c.Regexp.MatchString(value)
+		case OpContains:
+			return strings.Contains(strings.ToLower(value), strings.ToLower(want))
+		}
+	case KindInt:
+		value, _ := record[c.Field].(int64)
+		return compare(c.Op, value, c.Value.(int64))
+	case KindTime:
+		value, _ := record[c.Field].(time.Time)
+		want := c.Value.(time.Time)
+
+		switch {
+		case value.Before(want):
+			return compare(c.Op, -1, 0)
+		case value.After(want):
+			return compare(c.Op, 1, 0)
+		default:
+			return compare(c.Op, 0, 0)
+		}
+	}
+
+	return false
+}
+
+func compare(op Op, value, want int64) bool {
+	switch op {
+	case OpEq:
+		return value == want
+	case OpNe:
+		return value != want
+	case OpLt:
+		return value < want
+	case OpLe:
+		return value <= want
+	case OpGt:
+		return value > want
+	case OpGe:
+		return value >= want
+	}
+
+	return false
+}
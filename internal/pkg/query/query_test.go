@@ -0,0 +1,257 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+		err  string
+	}{
+		{src: "", want: []string{}},
+		{src: "  \t\n", want: []string{}},
+		{src: `req.host = "a"`, want: []string{"ident req.host", "op =", `string a`}},
+		{src: `a==b`, want: []string{"ident a", "op ==", "ident b"}},
+		{src: `a!~b`, want: []string{"ident a", "op !~", "ident b"}},
+		{src: `a != b`, want: []string{"ident a", "op !=", "ident b"}},
+		{src: `a<=1 && b>=-2`, want: []string{"ident a", "op <=", "number 1", "op &&", "ident b", "op >=", "number -2"}},
+		{src: `!(a)`, want: []string{"op !", "lparen (", "ident a", "rparen )"}},
+		{src: `"say \"hi\" \\ \n"`, want: []string{`string say "hi" \ \n`}},
+		{src: `""`, want: []string{"string "}},
+		{src: `_x1.y_2`, want: []string{"ident _x1.y_2"}},
+		{src: `"unterminated`, err: "unterminated string at position 1"},
+		{src: `a = "b`, err: "unterminated string at position 5"},
+		{src: `"\"`, err: "unterminated string at position 1"},
+		{src: `a - 1`, err: `unexpected "-" at position 3`},
+		{src: `a # b`, err: `unexpected "#" at position 3`},
+		{src: `a & b`, err: `unexpected "&" at position 3`},
+		{src: `a | b`, err: `unexpected "|" at position 3`},
+	}
+
+	names := map[tokenKind]string{
+		tokenIdent:    "ident",
+		tokenString:   "string",
+		tokenNumber:   "number",
+		tokenOperator: "op",
+		tokenLParen:   "lparen",
+		tokenRParen:   "rparen",
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			tokens, err := tokenize(tt.src)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("tokenize(%q) error = %v, want %q", tt.src, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("tokenize(%q) error = %v", tt.src, err)
+			}
+
+			last := tokens[len(tokens)-1]
+			if last.kind != tokenEOF || last.pos != len(tt.src) {
+				t.Fatalf("tokenize(%q) ends with %+v, want EOF at %d", tt.src, last, len(tt.src))
+			}
+
+			got := make([]string, 0, len(tokens)-1)
+			for _, tok := range tokens[:len(tokens)-1] {
+				text := tok.text
+				if tok.kind == tokenString {
+					text = tok.value
+				}
+				got = append(got, names[tok.kind]+" "+text)
+			}
+			if tt.want == nil {
+				tt.want = []string{}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("tokenize(%q) = %q, want %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{src: `req.method = "POST"`, want: `(req.method = "POST")`},
+		{src: `REQ.Method == "POST"`, want: `(req.method = "POST")`},
+		{src: `req.host ~ "api\\."`, want: `(req.host ~ "api\\.")`},
+		{src: `req.body contains "x"`, want: `(req.body contains "x")`},
+		{src: `req.body CONTAINS "x"`, want: `(req.body contains "x")`},
+		{src: `resp.status >= 500`, want: `(resp.status >= 500)`},
+		{src: `resp.length < -1`, want: `(resp.length < -1)`},
+		{
+			src:  `req.time > "2024-01-02T03:04:05+02:00"`,
+			want: `(req.time > 2024-01-02T01:04:05Z)`,
+		},
+		{
+			src:  `req.method = "GET" or req.method = "HEAD" and resp.status = 200`,
+			want: `(or (req.method = "GET") (and (req.method = "HEAD") (resp.status = 200)))`,
+		},
+		{
+			src:  `(req.method = "GET" || req.method = "HEAD") && resp.status = 200`,
+			want: `(and (or (req.method = "GET") (req.method = "HEAD")) (resp.status = 200))`,
+		},
+		{
+			src:  `not not req.id = 1`,
+			want: `(not (not (req.id = 1)))`,
+		},
+		{
+			src:  `!req.id = 1 and req.id != 2`,
+			want: `(and (not (req.id = 1)) (req.id != 2))`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.src, err)
+			}
+
+			if got := format(expr); got != tt.want {
+				t.Fatalf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+		msg string
+	}{
+		{src: "", pos: 0, msg: "empty query"},
+		{src: "   ", pos: 0, msg: "empty query"},
+		{src: `req.nope = 1`, pos: 0, msg: `unknown field "req.nope"`},
+		{src: `req.method`, pos: 10, msg: "expected operator after req.method"},
+		{src: `req.method "GET"`, pos: 11, msg: "expected operator after req.method"},
+		{src: `req.method < "GET"`, pos: 11, msg: `operator "<" is not supported for req.method`},
+		{src: `resp.status ~ "5.."`, pos: 12, msg: `operator "~" is not supported for resp.status`},
+		{src: `resp.status contains 5`, pos: 12, msg: `operator "contains" is not supported`},
+		{src: `req.method = GET`, pos: 13, msg: "req.method must be compared with a string"},
+		{src: `req.method = 1`, pos: 13, msg: "must be compared with a string"},
+		{src: `resp.status = "500"`, pos: 14, msg: "resp.status must be compared with a number"},
+		{src: `resp.status = 99999999999999999999`, pos: 14, msg: "invalid number"},
+		{src: `req.time > 5`, pos: 11, msg: "RFC 3339"},
+		{src: `req.time > "yesterday"`, pos: 11, msg: "invalid time"},
+		{src: `req.host ~ "("`, pos: 11, msg: "invalid regexp"},
+		{src: `req.id = 1 and`, pos: 14, msg: "unexpected end of query"},
+		{src: `req.id = 1 req.id = 2`, pos: 11, msg: `unexpected "req.id"`},
+		{src: `(req.id = 1`, pos: 11, msg: `expected ")"`},
+		{src: `req.id = 1)`, pos: 10, msg: `unexpected ")"`},
+		{src: `()`, pos: 1, msg: `expected field, got ")"`},
+		{src: `= 1`, pos: 0, msg: `expected field, got "="`},
+		{src: `not`, pos: 3, msg: "unexpected end of query"},
+		{src: `req.id = `, pos: 9, msg: "must be compared with a number"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			qerr, ok := err.(*Error)
+			if !ok {
+				t.Fatalf("Parse(%q) error = %v, want *Error", tt.src, err)
+			}
+
+			if qerr.Pos != tt.pos || !strings.Contains(qerr.Msg, tt.msg) {
+				t.Fatalf("Parse(%q) error = %q at %d, want %q at %d", tt.src, qerr.Msg, qerr.Pos, tt.msg, tt.pos)
+			}
+		})
+	}
+}
+
+func TestEval(t *testing.T) {
+	record := Record{
+		"req.id":      int64(7),
+		"req.method":  "POST",
+		"req.host":    "api.example.com",
+		"req.body":    "Hello ÄÖÜ",
+		"req.time":    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"resp.status": int64(503),
+	}
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{src: `req.method = "POST"`, want: true},
+		{src: `req.method = "post"`, want: false},
+		{src: `req.method != "GET"`, want: true},
+		{src: `req.host ~ "^api\."`, want: true},
+		{src: `req.host !~ "^api\."`, want: false},
+		{src: `req.body contains "hello äöü"`, want: true},
+		{src: `req.body contains "xyz"`, want: false},
+		{src: `req.path = ""`, want: true},
+		{src: `req.path contains ""`, want: true},
+		{src: `resp.status >= 500 and resp.status < 600`, want: true},
+		{src: `resp.length = 0`, want: true},
+		{src: `req.id = 7 and not resp.status = 503`, want: false},
+		{src: `req.id = 1 or req.id = 7`, want: true},
+		{src: `req.time = "2024-01-02T05:04:05+02:00"`, want: true},
+		{src: `req.time < "2024-01-02T03:04:05Z"`, want: false},
+		{src: `req.time <= "2024-01-02T03:04:05Z"`, want: true},
+		{src: `req.time > "2023-12-31T00:00:00Z"`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			expr, err := Parse(tt.src)
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", tt.src, err)
+			}
+
+			if got := expr.Eval(record); got != tt.want {
+				t.Fatalf("%s = %v, want %v", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConjoin(t *testing.T) {
+	left, right := Comparison{Field: "a"}, Comparison{Field: "b"}
+
+	if got := Conjoin(nil, right); got != right {
+		t.Fatalf("Conjoin(nil, right) = %v", got)
+	}
+	if got := Conjoin(left, nil); got != left {
+		t.Fatalf("Conjoin(left, nil) = %v", got)
+	}
+	if got := Conjoin(left, right); got != (And{Left: left, Right: right}) {
+		t.Fatalf("Conjoin(left, right) = %v", got)
+	}
+}
+
+func format(expr Expr) string {
+	switch e := expr.(type) {
+	case And:
+		return "(and " + format(e.Left) + " " + format(e.Right) + ")"
+	case Or:
+		return "(or " + format(e.Left) + " " + format(e.Right) + ")"
+	case Not:
+		return "(not " + format(e.Expr) + ")"
+	case Comparison:
+		value := fmt.Sprint(e.Value)
+		switch v := e.Value.(type) {
+		case string:
+			value = fmt.Sprintf("%q", v)
+		case time.Time:
+			value = v.Format(time.RFC3339)
+		}
+		return fmt.Sprintf("(%s %s %s)", e.Field, e.Op, value)
+	default:
+		return fmt.Sprintf("%#v", expr)
+	}
+}
@@ -0,0 +1,99 @@
+package delivery
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	harInterfaces "github.com/aanufriev/httpproxy/internal/pkg/har/interfaces"
+	harUsecase "github.com/aanufriev/httpproxy/internal/pkg/har/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+)
+
+type HarHandler struct {
+	harUsecase harInterfaces.Usecase
+}
+
+func NewHarHandler(harUsecase harInterfaces.Usecase) HarHandler {
+	return HarHandler{
+		harUsecase: harUsecase,
+	}
+}
+
+func (h HarHandler) ExportHAR(w http.ResponseWriter, r *http.Request) {
+	ids, err := harUsecase.ParseIDs(r.URL.Query().Get("ids"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filter, err := models.RequestFilterFromQuery(r.URL.Query(), 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	har, err := h.harUsecase.Export(ids, filter)
+	if errors.Is(err, proxyUsecase.ErrQueryNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == sql.ErrNoRows {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't export har: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="requests.har"`)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(har); err != nil {
+		log.Printf("couldn't write har to client: %v", err)
+	}
+}
+
+func (h HarHandler) ImportHAR(w http.ResponseWriter, r *http.Request) {
+	var har models.HAR
+	if err := json.NewDecoder(r.Body).Decode(&har); err != nil {
+		http.Error(w, "couldn't parse har: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids, err := h.harUsecase.Import(har)
+	if err == harUsecase.ErrNotHAR || errors.Is(err, harUsecase.ErrInvalidEntry) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't import har: %v", err)
+		http.Error(w, fmt.Sprintf("imported requests %v: %v", ids, err), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, id := range ids {
+		response += fmt.Sprintf(`<a href="/request/%d">request %d</a><br>`, id, id)
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.write(w, response)
+}
+
+func (h HarHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
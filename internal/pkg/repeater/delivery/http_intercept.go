@@ -0,0 +1,225 @@
+package delivery
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	interceptInterfaces "github.com/aanufriev/httpproxy/internal/pkg/intercept/interfaces"
+	interceptUsecase "github.com/aanufriev/httpproxy/internal/pkg/intercept/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+type InterceptHandler struct {
+	interceptUsecase interceptInterfaces.Usecase
+}
+
+func NewInterceptHandler(interceptUsecase interceptInterfaces.Usecase) InterceptHandler {
+	return InterceptHandler{
+		interceptUsecase: interceptUsecase,
+	}
+}
+
+func (h InterceptHandler) ShowQueue(w http.ResponseWriter, r *http.Request) {
+	settings := h.interceptUsecase.GetSettings()
+
+	response := fmt.Sprintf(
+		"Intercept: %t, responses: %t, host: %q, methods: %s <br><br>",
+		settings.Enabled, settings.Responses, settings.Host, strings.Join(settings.Methods, ","),
+	)
+
+	for _, item := range h.interceptUsecase.GetItems() {
+		response += item.StringFromItem()
+		response += "<br>"
+	}
+
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write intercept queue to client: %v", err)
+		return
+	}
+}
+
+func (h InterceptHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	settings := h.interceptUsecase.GetSettings()
+
+	if _, ok := r.Form["enabled"]; ok {
+		settings.Enabled, err = strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			http.Error(w, "invalid enabled value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := r.Form["responses"]; ok {
+		settings.Responses, err = strconv.ParseBool(r.FormValue("responses"))
+		if err != nil {
+			http.Error(w, "invalid responses value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := r.Form["host"]; ok {
+		settings.Host = r.FormValue("host")
+	}
+
+	if _, ok := r.Form["methods"]; ok {
+		settings.Methods = nil
+		for _, method := range strings.Split(r.FormValue("methods"), ",") {
+			if method = strings.TrimSpace(method); method != "" {
+				settings.Methods = append(settings.Methods, strings.ToUpper(method))
+			}
+		}
+	}
+
+	err = h.interceptUsecase.SetSettings(settings)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.ShowQueue(w, r)
+}
+
+func (h InterceptHandler) ShowItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	request := item.Request
+	response := fmt.Sprintf("%d: [%s] <br>", item.ID, item.Type)
+	response += request.Method + " " + request.Scheme + "://" + request.Host + request.Path + "<br>"
+	response += fmt.Sprintf("Params: %s <br>", request.Params)
+	response += fmt.Sprintf("Headers: %s <br>", request.Headers)
+	response += fmt.Sprintf("Body: %s <br>", html.EscapeString(request.Body))
+
+	if item.Type == models.InterceptResponse {
+		response += fmt.Sprintf("<br>Response: %s <br>", item.Response.StringFromResponse())
+		response += fmt.Sprintf("Headers: %s <br>", item.Response.Headers)
+		response += fmt.Sprintf("Body: %s <br>", html.EscapeString(item.Response.Body))
+	}
+
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+		return
+	}
+}
+
+func (h InterceptHandler) ForwardItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	decision := models.InterceptDecision{
+		Action:   models.ActionForward,
+		Request:  item.Request,
+		Response: item.Response,
+	}
+
+	if item.Type == models.InterceptRequest {
+		fields := map[string]*string{
+			"method":  &decision.Request.Method,
+			"scheme":  &decision.Request.Scheme,
+			"host":    &decision.Request.Host,
+			"path":    &decision.Request.Path,
+			"params":  &decision.Request.Params,
+			"headers": &decision.Request.Headers,
+			"body":    &decision.Request.Body,
+		}
+		for key, field := range fields {
+			if _, ok := r.Form[key]; ok {
+				*field = r.FormValue(key)
+			}
+		}
+	} else {
+		if _, ok := r.Form["status_code"]; ok {
+			decision.Response.StatusCode, err = strconv.Atoi(r.FormValue("status_code"))
+			if err != nil {
+				http.Error(w, "invalid status_code", http.StatusBadRequest)
+				return
+			}
+		}
+		if _, ok := r.Form["headers"]; ok {
+			decision.Response.Headers = r.FormValue("headers")
+		}
+		if _, ok := r.Form["body"]; ok {
+			decision.Response.Body = r.FormValue("body")
+		}
+
+		if err = decision.Response.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	h.resolve(w, item.ID, decision)
+}
+
+func (h InterceptHandler) DropItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := h.getItem(w, r)
+	if !ok {
+		return
+	}
+
+	h.resolve(w, item.ID, models.InterceptDecision{Action: models.ActionDrop})
+}
+
+func (h InterceptHandler) resolve(w http.ResponseWriter, id int, decision models.InterceptDecision) {
+	err := h.interceptUsecase.Resolve(id, decision)
+	if err == interceptUsecase.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	_, err = w.Write([]byte(
+		fmt.Sprintf(responseTemplate, fmt.Sprintf("%d: %s", id, decision.Action)),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+		return
+	}
+}
+
+func (h InterceptHandler) getItem(w http.ResponseWriter, r *http.Request) (models.InterceptedItem, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return models.InterceptedItem{}, false
+	}
+
+	item, err := h.interceptUsecase.GetItem(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return models.InterceptedItem{}, false
+	}
+
+	return item, true
+}
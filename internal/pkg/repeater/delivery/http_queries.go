@@ -0,0 +1,123 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/gorilla/mux"
+)
+
+type QueriesHandler struct {
+	proxyUsecase interfaces.Usecase
+}
+
+func NewQueriesHandler(proxyUsecase interfaces.Usecase) QueriesHandler {
+	return QueriesHandler{
+		proxyUsecase: proxyUsecase,
+	}
+}
+
+func (h QueriesHandler) ShowAllQueries(w http.ResponseWriter, r *http.Request) {
+	queries, err := h.proxyUsecase.GetQueries()
+	if err != nil {
+		log.Printf("couldn't get saved queries: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, q := range queries {
+		response += queryLink(q)
+		response += "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h QueriesHandler) ShowQuery(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.getQuery(w, r)
+	if !ok {
+		return
+	}
+
+	h.write(w, queryLink(q))
+}
+
+func (h QueriesHandler) SaveQuery(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := models.SavedQuery{
+		Name:  r.FormValue("name"),
+		Query: r.FormValue("query"),
+	}
+	if err := q.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q, err = h.proxyUsecase.SaveQuery(q)
+	if err != nil {
+		log.Printf("couldn't save query: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, queryLink(q))
+}
+
+func (h QueriesHandler) DeleteQuery(w http.ResponseWriter, r *http.Request) {
+	q, ok := h.getQuery(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.proxyUsecase.DeleteQuery(q.Name)
+	if err != nil {
+		log.Printf("couldn't delete query: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, fmt.Sprintf("%s: deleted", html.EscapeString(q.Name)))
+}
+
+func (h QueriesHandler) getQuery(w http.ResponseWriter, r *http.Request) (models.SavedQuery, bool) {
+	q, err := h.proxyUsecase.GetQuery(mux.Vars(r)["name"])
+	if err == sql.ErrNoRows {
+		http.Error(w, "saved query not found", http.StatusNotFound)
+		return models.SavedQuery{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get saved query: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return models.SavedQuery{}, false
+	}
+
+	return q, true
+}
+
+func (h QueriesHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func queryLink(q models.SavedQuery) string {
+	return fmt.Sprintf(
+		`<a href="/requests?saved=%s">%s</a>`,
+		url.QueryEscape(q.Name), html.EscapeString(q.StringFromQuery()),
+	)
+}
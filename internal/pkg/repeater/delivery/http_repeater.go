@@ -1,17 +1,21 @@
 package delivery
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"html"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
-	"time"
 
 	"github.com/aanufriev/httpproxy/internal/pkg/models"
 	"github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+	repeaterInterfaces "github.com/aanufriev/httpproxy/internal/pkg/repeater/interfaces"
+	rulesInterfaces "github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
 	"github.com/gorilla/mux"
 )
 
@@ -28,20 +32,36 @@ const responseTemplate = `
 	</html>
 `
 
+const requestIDHeader = "X-Repeater-Request-Id"
+
 type RepeatHandler struct {
-	proxyUsecase interfaces.Usecase
-	payloads     []string
+	proxyUsecase    interfaces.Usecase
+	rulesUsecase    rulesInterfaces.Usecase
+	repeaterUsecase repeaterInterfaces.Usecase
 }
 
-func NewRepeaterHandler(proxyUsecase interfaces.Usecase, payloads []string) RepeatHandler {
+func NewRepeaterHandler(
+	proxyUsecase interfaces.Usecase, rulesUsecase rulesInterfaces.Usecase, repeaterUsecase repeaterInterfaces.Usecase,
+) RepeatHandler {
 	return RepeatHandler{
-		proxyUsecase: proxyUsecase,
-		payloads:     payloads,
+		proxyUsecase:    proxyUsecase,
+		rulesUsecase:    rulesUsecase,
+		repeaterUsecase: repeaterUsecase,
 	}
 }
 
 func (h RepeatHandler) ShowAllRequests(w http.ResponseWriter, r *http.Request) {
-	requests, err := h.proxyUsecase.GetRequests()
+	filter, err := models.RequestFilterFromQuery(r.URL.Query(), models.DefaultRequestLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.proxyUsecase.GetRequests(filter)
+	if errors.Is(err, proxyUsecase.ErrQueryNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		log.Printf("couldn't get requests: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
@@ -49,11 +69,16 @@ func (h RepeatHandler) ShowAllRequests(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var response string
-	for _, request := range requests {
+	for _, request := range page.Requests {
 		response += request.StringFromRequest()
 		response += "<br>"
 	}
 
+	if page.NextCursor != 0 {
+		filter.Cursor = page.NextCursor
+		response += fmt.Sprintf(`<br><a href="%s">next</a>`, html.EscapeString(r.URL.Path+"?"+filter.Values().Encode()))
+	}
+
 	_, err = w.Write([]byte(
 		fmt.Sprintf(responseTemplate, response),
 	))
@@ -65,180 +90,153 @@ func (h RepeatHandler) ShowAllRequests(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h RepeatHandler) ShowRequest(w http.ResponseWriter, r *http.Request) {
-	idStr, ok := mux.Vars(r)["id"]
-	if !ok {
-		log.Printf("couldn't get id")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
-	}
-
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
 		log.Printf("couldn't convert id")
-		w.WriteHeader(http.StatusServiceUnavailable)
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
 	request, err := h.proxyUsecase.GetRequest(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		log.Printf("couldn't get request: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	response := request.Method + " " + request.Scheme + "://" + request.Host + request.Path + "<br>"
+	response := request.Method + " " + request.Scheme + "://" + request.Host + request.Path + " " + request.Proto + "<br>"
 	response += fmt.Sprintf("Headers: %s <br>", request.Headers)
 	response += fmt.Sprintf("Body: %s <br>", request.Body)
+	if request.User != "" {
+		response += fmt.Sprintf("User: %s <br>", request.User)
+	}
+	if request.ParentID != 0 {
+		response += fmt.Sprintf(`Edited from <a href="/request/%d">request %d</a> <br>`, request.ParentID, request.ParentID)
+	}
 
-	_, err = w.Write([]byte(
-		fmt.Sprintf(responseTemplate, response),
-	))
-	if err != nil {
-		log.Printf("couldn't write to client: %v", err)
+	resp, err := h.proxyUsecase.GetResponse(id)
+	switch {
+	case err == sql.ErrNoRows:
+		response += "<br>Response: not recorded <br>"
+	case err != nil:
+		log.Printf("couldn't get response: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
+	default:
+		response += fmt.Sprintf("<br>Response: %s <br>", resp.StringFromResponse())
+		response += fmt.Sprintf("Headers: %s <br>", resp.Headers)
+		response += fmt.Sprintf("Body: %s <br>", html.EscapeString(resp.Body))
 	}
-}
 
-func (h RepeatHandler) RepeatRequest(w http.ResponseWriter, r *http.Request) {
-	idStr, ok := mux.Vars(r)["id"]
-	if !ok {
-		log.Printf("couldn't get id")
-		w.WriteHeader(http.StatusServiceUnavailable)
+	applied, err := h.rulesUsecase.GetApplied(id)
+	if err != nil {
+		log.Printf("couldn't get applied rules: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		log.Printf("couldn't convert id")
-		w.WriteHeader(http.StatusServiceUnavailable)
-		return
+	if len(applied) != 0 {
+		response += "<br>Applied rules: <br>"
+		for _, rule := range applied {
+			response += rule.StringFromRule() + "<br>"
+		}
 	}
 
-	request, err := h.proxyUsecase.GetRequest(id)
+	_, err = w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
 	if err != nil {
-		log.Printf("couldn't get request: %v", err)
+		log.Printf("couldn't write to client: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
+}
 
-	httpReq, err := models.ConvertToHttpRequest(request)
+func (h RepeatHandler) RepeatRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("couldn't convert to http request: %v", err)
-		w.WriteHeader(http.StatusServiceUnavailable)
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	client := http.Client{
-		Timeout: time.Second * 10,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+	resp, err := h.repeaterUsecase.Repeat(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
 	}
-
-	resp, err := client.Do(&httpReq)
 	if err != nil {
 		log.Printf("request err: %v", err)
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
-	defer resp.Body.Close()
 
-	for key, values := range resp.Header {
-		for _, value := range values {
-			w.Header().Add(key, value)
-		}
-	}
-
-	w.WriteHeader(resp.StatusCode)
+	h.writeResponse(w, resp)
+}
 
-	_, err = io.Copy(w, resp.Body)
+func (h RepeatHandler) ResendRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
 	if err != nil {
-		log.Printf("transfer answer err: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-}
 
-func (h RepeatHandler) ScanRequest(w http.ResponseWriter, r *http.Request) {
-	idStr, ok := mux.Vars(r)["id"]
-	if !ok {
-		log.Printf("couldn't get id")
-		w.WriteHeader(http.StatusServiceUnavailable)
+	original, err := h.proxyUsecase.GetRequest(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "request not found", http.StatusNotFound)
 		return
 	}
-
-	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		log.Printf("couldn't convert id")
-		w.WriteHeader(http.StatusServiceUnavailable)
+		log.Printf("couldn't get request: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
 		return
 	}
 
-	request, err := h.proxyUsecase.GetRequest(id)
+	raw, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("couldn't get request: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	httpRequest, err := models.ConvertToHttpRequest(request)
+	request, err := models.ParseEditedRequest(r.Header.Get("Content-Type"), raw, original)
 	if err != nil {
-		log.Printf("couldn't convert request: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		http.Error(w, "couldn't parse edited request: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	client := http.Client{
-		Timeout: time.Second * 10,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
+	request, resp, err := h.repeaterUsecase.Resend(request)
+	if err != nil {
+		log.Printf("request err: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
 	}
 
-	var resp *http.Response
-
-	infectedParams := make([]string, 0)
-
-	query := httpRequest.URL.Query()
-	for key, values := range query {
-		for i, value := range values {
-			for _, payload := range h.payloads {
-				query[key][i] = value + payload
-				httpRequest.URL.RawQuery = query.Encode()
-
-				resp, err = client.Do(&httpRequest)
-				if err != nil {
-					return
-				}
-				defer resp.Body.Close()
-
-				body, err := ioutil.ReadAll(resp.Body)
-				if err != nil {
-					return
-				}
+	w.Header().Set(requestIDHeader, strconv.Itoa(request.ID))
+	h.writeResponse(w, resp)
+}
 
-				if strings.Contains(string(body), "root:") {
-					infectedParams = append(infectedParams, key)
-					break
-				}
+func (h RepeatHandler) writeResponse(w http.ResponseWriter, resp models.Response) {
+	var headers http.Header
+	err := json.Unmarshal([]byte(resp.Headers), &headers)
+	if err != nil {
+		log.Printf("couldn't decode response headers: %v", err)
+	}
 
-				query[key][i] = value
-				httpRequest.URL.RawQuery = query.Encode()
-			}
+	for key, values := range headers {
+		for _, value := range values {
+			w.Header().Add(key, value)
 		}
 	}
 
-	_, err = w.Write([]byte(
-		fmt.Sprintf(
-			responseTemplate,
-			request.StringFromRequest()+"<br>"+
-				"params with command injection vulnerability: "+strings.Join(infectedParams, ","),
-		),
-	))
+	w.WriteHeader(resp.StatusCode)
+
+	_, err = w.Write([]byte(resp.Body))
 	if err != nil {
-		log.Printf("couldn't write to client: %v", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		return
+		log.Printf("transfer answer err: %v", err)
 	}
 }
@@ -0,0 +1,174 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	rulesInterfaces "github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+	"github.com/gorilla/mux"
+)
+
+type RulesHandler struct {
+	rulesUsecase rulesInterfaces.Usecase
+}
+
+func NewRulesHandler(rulesUsecase rulesInterfaces.Usecase) RulesHandler {
+	return RulesHandler{
+		rulesUsecase: rulesUsecase,
+	}
+}
+
+func (h RulesHandler) ShowAllRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.rulesUsecase.GetRules()
+	if err != nil {
+		log.Printf("couldn't get rules: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, rule := range rules {
+		response += rule.StringFromRule()
+		response += "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h RulesHandler) ShowRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	h.write(w, rule.StringFromRule())
+}
+
+func (h RulesHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	rule := models.Rule{Enabled: true}
+
+	err := ruleFromForm(r, &rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule.ID, err = h.rulesUsecase.CreateRule(rule)
+	if err != nil {
+		log.Printf("couldn't create rule: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.write(w, rule.StringFromRule())
+}
+
+func (h RulesHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	err := ruleFromForm(r, &rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.rulesUsecase.UpdateRule(rule)
+	if err != nil {
+		log.Printf("couldn't update rule: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.write(w, rule.StringFromRule())
+}
+
+func (h RulesHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.rulesUsecase.DeleteRule(rule.ID)
+	if err != nil {
+		log.Printf("couldn't delete rule: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, fmt.Sprintf("%d: deleted", rule.ID))
+}
+
+func (h RulesHandler) getRule(w http.ResponseWriter, r *http.Request) (models.Rule, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return models.Rule{}, false
+	}
+
+	rule, err := h.rulesUsecase.GetRule(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "rule not found", http.StatusNotFound)
+		return models.Rule{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get rule: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return models.Rule{}, false
+	}
+
+	return rule, true
+}
+
+func (h RulesHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func ruleFromForm(r *http.Request, rule *models.Rule) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]*string{
+		"target":  &rule.Target,
+		"match":   &rule.Match,
+		"replace": &rule.Replace,
+		"host":    &rule.Host,
+		"path":    &rule.Path,
+		"method":  &rule.Method,
+	}
+	for key, field := range fields {
+		if _, ok := r.Form[key]; ok {
+			*field = r.FormValue(key)
+		}
+	}
+
+	flags := map[string]*bool{
+		"enabled": &rule.Enabled,
+		"regex":   &rule.IsRegex,
+	}
+	for key, field := range flags {
+		if _, ok := r.Form[key]; ok {
+			*field, err = strconv.ParseBool(r.FormValue(key))
+			if err != nil {
+				return fmt.Errorf("invalid %s value", key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	scannerInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	scannerUsecase "github.com/aanufriev/httpproxy/internal/pkg/scanner/usecase"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+	"github.com/gorilla/mux"
+)
+
+type ScanHandler struct {
+	scannerUsecase scannerInterfaces.Usecase
+}
+
+func NewScanHandler(scannerUsecase scannerInterfaces.Usecase) ScanHandler {
+	return ScanHandler{
+		scannerUsecase: scannerUsecase,
+	}
+}
+
+func (h ScanHandler) ScanRequest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.scannerUsecase.SubmitJob(id)
+	switch {
+	case err == sql.ErrNoRows:
+		http.Error(w, "request not found", http.StatusNotFound)
+		return
+	case err == scopeUsecase.ErrOutOfScope:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err == scannerUsecase.ErrQueueFull:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		log.Printf("couldn't submit scan: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/scans/%d", job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	h.write(w, fmt.Sprintf(`<a href="/scans/%d">%s</a>`, job.ID, job.StringFromJob()))
+}
+
+func (h ScanHandler) ShowAllJobs(w http.ResponseWriter, r *http.Request) {
+	jobs, err := h.scannerUsecase.GetJobs()
+	if err != nil {
+		log.Printf("couldn't get scan jobs: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, job := range jobs {
+		response += job.StringFromJob()
+		response += "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h ScanHandler) ShowJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	response := job.StringFromJob() + "<br>"
+	if job.Status == models.JobDone && len(job.Findings) == 0 {
+		response += "no vulnerabilities found"
+	}
+	for _, finding := range job.Findings {
+		response += html.EscapeString(finding.StringFromFinding()) + "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h ScanHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := h.getJob(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.scannerUsecase.CancelJob(job.ID)
+	if err == scannerUsecase.ErrJobFinished {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't cancel scan job: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, fmt.Sprintf("%d: canceling", job.ID))
+}
+
+func (h ScanHandler) ShowAllFindings(w http.ResponseWriter, r *http.Request) {
+	filter, err := models.FindingFilterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	findings, err := h.scannerUsecase.GetFindings(filter)
+	if err != nil {
+		log.Printf("couldn't get findings: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, finding := range findings {
+		response += fmt.Sprintf(
+			`<a href="/findings/%d">%s</a><br>`,
+			finding.ID, html.EscapeString(finding.StringFromFinding()),
+		)
+	}
+
+	h.write(w, response)
+}
+
+func (h ScanHandler) ShowFinding(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	finding, err := h.scannerUsecase.GetFinding(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "finding not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't get finding: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	response := html.EscapeString(finding.StringFromFinding()) + "<br>"
+	response += fmt.Sprintf(`found at %s, <a href="/request/%d">request</a>`,
+		finding.CreatedAt.Format(time.RFC3339), finding.RequestID)
+
+	h.write(w, response)
+}
+
+func (h ScanHandler) getJob(w http.ResponseWriter, r *http.Request) (models.ScanJob, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return models.ScanJob{}, false
+	}
+
+	job, err := h.scannerUsecase.GetJob(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "scan job not found", http.StatusNotFound)
+		return models.ScanJob{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get scan job: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return models.ScanJob{}, false
+	}
+
+	return job, true
+}
+
+func (h ScanHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
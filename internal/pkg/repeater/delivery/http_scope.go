@@ -0,0 +1,181 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	scopeInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+	"github.com/gorilla/mux"
+)
+
+type ScopeHandler struct {
+	scopeUsecase scopeInterfaces.Usecase
+}
+
+func NewScopeHandler(scopeUsecase scopeInterfaces.Usecase) ScopeHandler {
+	return ScopeHandler{
+		scopeUsecase: scopeUsecase,
+	}
+}
+
+func (h ScopeHandler) ShowScope(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.scopeUsecase.GetRules()
+	if err != nil {
+		log.Printf("couldn't get scope rules: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	if len(rules) == 0 {
+		response += "no scope rules, everything is captured<br>"
+	}
+	for _, rule := range rules {
+		response += scopeRuleLink(rule)
+		response += "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h ScopeHandler) ShowRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	h.write(w, scopeRuleLink(rule))
+}
+
+func (h ScopeHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	rule := models.ScopeRule{Enabled: true, Action: models.ScopeInclude}
+
+	err := scopeRuleFromForm(r, &rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rule.ID, err = h.scopeUsecase.CreateRule(rule)
+	if err != nil {
+		log.Printf("couldn't create scope rule: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	h.write(w, scopeRuleLink(rule))
+}
+
+func (h ScopeHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	err := scopeRuleFromForm(r, &rule)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.scopeUsecase.UpdateRule(rule)
+	if err != nil {
+		log.Printf("couldn't update scope rule: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.write(w, scopeRuleLink(rule))
+}
+
+func (h ScopeHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	rule, ok := h.getRule(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.scopeUsecase.DeleteRule(rule.ID)
+	if err != nil {
+		log.Printf("couldn't delete scope rule: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, fmt.Sprintf("%d: deleted", rule.ID))
+}
+
+func (h ScopeHandler) getRule(w http.ResponseWriter, r *http.Request) (models.ScopeRule, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return models.ScopeRule{}, false
+	}
+
+	rule, err := h.scopeUsecase.GetRule(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "scope rule not found", http.StatusNotFound)
+		return models.ScopeRule{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get scope rule: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return models.ScopeRule{}, false
+	}
+
+	return rule, true
+}
+
+func (h ScopeHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func scopeRuleLink(rule models.ScopeRule) string {
+	return fmt.Sprintf(`<a href="/scope/%d">%d</a>: %s`, rule.ID, rule.ID, html.EscapeString(rule.StringFromRule()))
+}
+
+func scopeRuleFromForm(r *http.Request, rule *models.ScopeRule) error {
+	err := r.ParseForm()
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]*string{
+		"action": &rule.Action,
+		"host":   &rule.Host,
+		"scheme": &rule.Scheme,
+		"path":   &rule.Path,
+	}
+	for key, field := range fields {
+		if _, ok := r.Form[key]; ok {
+			*field = r.FormValue(key)
+		}
+	}
+
+	if _, ok := r.Form["port"]; ok {
+		rule.Port, err = strconv.Atoi(r.FormValue("port"))
+		if err != nil {
+			return fmt.Errorf("invalid port value")
+		}
+	}
+
+	if _, ok := r.Form["enabled"]; ok {
+		rule.Enabled, err = strconv.ParseBool(r.FormValue("enabled"))
+		if err != nil {
+			return fmt.Errorf("invalid enabled value")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+	"github.com/gorilla/mux"
+)
+
+type TunnelsHandler struct {
+	tunnelUsecase interfaces.Usecase
+}
+
+func NewTunnelsHandler(tunnelUsecase interfaces.Usecase) TunnelsHandler {
+	return TunnelsHandler{
+		tunnelUsecase: tunnelUsecase,
+	}
+}
+
+func (h TunnelsHandler) ShowAllTunnels(w http.ResponseWriter, r *http.Request) {
+	tunnels, err := h.tunnelUsecase.GetTunnels()
+	if err != nil {
+		log.Printf("couldn't get tunnels: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, tunnel := range tunnels {
+		response += fmt.Sprintf(
+			`<a href="/tunnels/%d">%s</a><br>`,
+			tunnel.ID, html.EscapeString(tunnel.StringFromTunnel()),
+		)
+	}
+
+	h.write(w, response)
+}
+
+func (h TunnelsHandler) ShowTunnel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	tunnel, err := h.tunnelUsecase.GetTunnel(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "tunnel not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't get tunnel: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, html.EscapeString(tunnel.StringFromTunnel()))
+}
+
+func (h TunnelsHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package delivery
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/auth/interfaces"
+	AuthUsecase "github.com/aanufriev/httpproxy/internal/pkg/auth/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/gorilla/mux"
+)
+
+type UsersHandler struct {
+	authUsecase interfaces.Usecase
+}
+
+func NewUsersHandler(authUsecase interfaces.Usecase) UsersHandler {
+	return UsersHandler{
+		authUsecase: authUsecase,
+	}
+}
+
+func (h UsersHandler) ShowAllUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.authUsecase.GetUsers()
+	if err != nil {
+		log.Printf("couldn't get proxy users: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	if !h.authUsecase.Enabled() {
+		response += "proxy authentication is disabled<br>"
+	}
+	for _, user := range users {
+		response += userLink(user)
+		response += "<br>"
+	}
+
+	h.write(w, response)
+}
+
+func (h UsersHandler) ShowUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.getUser(w, r)
+	if !ok {
+		return
+	}
+
+	h.write(w, userLink(user))
+}
+
+func (h UsersHandler) SaveUser(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	edit := models.ProxyUserEdit{
+		Name:     r.FormValue("name"),
+		Password: r.FormValue("password"),
+		Token:    r.FormValue("token"),
+	}
+	if err := edit.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.authUsecase.SaveUser(edit)
+	if err == AuthUsecase.ErrTokenTaken {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't save proxy user: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, userLink(user))
+}
+
+func (h UsersHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.getUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.authUsecase.DeleteUser(user.Name)
+	if err != nil {
+		log.Printf("couldn't delete proxy user: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, fmt.Sprintf("%s: deleted", html.EscapeString(user.Name)))
+}
+
+func (h UsersHandler) getUser(w http.ResponseWriter, r *http.Request) (models.ProxyUser, bool) {
+	user, err := h.authUsecase.GetUser(mux.Vars(r)["name"])
+	if err == sql.ErrNoRows {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return models.ProxyUser{}, false
+	}
+	if err != nil {
+		log.Printf("couldn't get proxy user: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return models.ProxyUser{}, false
+	}
+
+	return user, true
+}
+
+func (h UsersHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func userLink(user models.ProxyUser) string {
+	return fmt.Sprintf(
+		`<a href="/requests?q=%s">%s</a>`,
+		url.QueryEscape(fmt.Sprintf("req.user = %q", user.Name)), html.EscapeString(user.StringFromUser()),
+	)
+}
@@ -0,0 +1,145 @@
+package delivery
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+	websocketUsecase "github.com/aanufriev/httpproxy/internal/pkg/websocket/usecase"
+	"github.com/gorilla/mux"
+)
+
+type WebSocketHandler struct {
+	websocketUsecase interfaces.Usecase
+}
+
+func NewWebSocketHandler(websocketUsecase interfaces.Usecase) WebSocketHandler {
+	return WebSocketHandler{
+		websocketUsecase: websocketUsecase,
+	}
+}
+
+func (h WebSocketHandler) ShowAllConnections(w http.ResponseWriter, r *http.Request) {
+	connections, err := h.websocketUsecase.GetConnections()
+	if err != nil {
+		log.Printf("couldn't get websocket connections: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var response string
+	for _, conn := range connections {
+		response += fmt.Sprintf(
+			`<a href="/websockets/%d">%s</a><br>`,
+			conn.Request.ID, html.EscapeString(conn.StringFromConnection()),
+		)
+	}
+
+	h.write(w, response)
+}
+
+func (h WebSocketHandler) ShowConnection(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	conn, err := h.websocketUsecase.GetConnection(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "connection not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't get websocket connection: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	h.write(w, connectionPage(conn))
+}
+
+func (h WebSocketHandler) ResendMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		log.Printf("couldn't convert id")
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	msg, err := h.websocketUsecase.GetMessage(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, "message not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("couldn't get websocket message: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	edit := models.WebSocketEdit{Opcode: r.PostFormValue("opcode")}
+	if payload, ok := r.PostForm["payload"]; ok {
+		edit.Payload = &payload[0]
+	}
+
+	msg, err = edit.Apply(msg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	conn, err := h.websocketUsecase.Resend(msg)
+	switch {
+	case errors.Is(err, websocketUsecase.ErrNotResendable):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, websocketUsecase.ErrHandshakeFailed):
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	case err != nil:
+		log.Printf("couldn't resend websocket message: %v", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set(requestIDHeader, strconv.Itoa(conn.Request.ID))
+	h.write(w, connectionPage(conn))
+}
+
+func (h WebSocketHandler) write(w http.ResponseWriter, response string) {
+	_, err := w.Write([]byte(
+		fmt.Sprintf(responseTemplate, response),
+	))
+	if err != nil {
+		log.Printf("couldn't write to client: %v", err)
+	}
+}
+
+func connectionPage(conn models.WebSocketConnection) string {
+	response := html.EscapeString(conn.Request.StringFromRequest()) + "<br>"
+	response += fmt.Sprintf(`<a href="/request/%d">handshake</a><br><br>`, conn.Request.ID)
+
+	for _, msg := range conn.Messages {
+		response += html.EscapeString(msg.StringFromMessage())
+		if msg.ParentID != 0 {
+			response += fmt.Sprintf(" (edited from %d)", msg.ParentID)
+		}
+		response += "<br>"
+	}
+
+	return response
+}
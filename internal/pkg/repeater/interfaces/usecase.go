@@ -0,0 +1,8 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	Repeat(id int) (models.Response, error)
+	Resend(req models.Request) (models.Request, models.Response, error)
+}
@@ -0,0 +1,83 @@
+package usecase
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/repeater/interfaces"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+type RepeaterUsecase struct {
+	proxyUsecase proxyInterfaces.Usecase
+	client       *http.Client
+}
+
+func NewRepeaterUsecase(
+	proxyUsecase proxyInterfaces.Usecase, dialer *upstream.Dialer, timeout time.Duration,
+) interfaces.Usecase {
+	return RepeaterUsecase{
+		proxyUsecase: proxyUsecase,
+		client: &http.Client{
+			Transport: dialer.Transport(),
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (u RepeaterUsecase) Repeat(id int) (models.Response, error) {
+	req, err := u.proxyUsecase.GetRequest(id)
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	return u.send(req)
+}
+
+func (u RepeaterUsecase) Resend(req models.Request) (models.Request, models.Response, error) {
+	var err error
+	req.ID, err = u.proxyUsecase.SaveRequest(req)
+	if err != nil {
+		return models.Request{}, models.Response{}, err
+	}
+
+	resp, err := u.send(req)
+	if err != nil {
+		return req, models.Response{}, err
+	}
+
+	resp.RequestID = req.ID
+	err = u.proxyUsecase.SaveResponse(resp)
+	if err != nil {
+		log.Printf("couldn't save response: %v", err)
+	}
+
+	return req, resp, nil
+}
+
+func (u RepeaterUsecase) send(req models.Request) (models.Response, error) {
+	httpReq, err := models.ConvertToHttpRequest(req)
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	start := time.Now()
+	resp, err := u.client.Do(&httpReq)
+	if err != nil {
+		return models.Response{}, err
+	}
+
+	response, err := models.ConvertFromHttpResponse(resp)
+	if err != nil {
+		return models.Response{}, err
+	}
+	response.Duration = time.Since(start).Milliseconds()
+
+	return response, nil
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	CreateRule(rule models.Rule) (int, error)
+	UpdateRule(rule models.Rule) error
+	DeleteRule(id int) error
+	GetRules() ([]models.Rule, error)
+	GetRule(id int) (models.Rule, error)
+	SaveApplied(requestID int, ruleIDs []int) error
+	GetApplied(requestID int) ([]models.Rule, error)
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	CreateRule(rule models.Rule) (int, error)
+	UpdateRule(rule models.Rule) error
+	DeleteRule(id int) error
+	GetRules() ([]models.Rule, error)
+	GetRule(id int) (models.Rule, error)
+	ApplyToRequest(req models.Request) (models.Request, []int, error)
+	ApplyToResponse(req models.Request, resp models.Response) (models.Response, []int, error)
+	RewritesResponseBody(req models.Request) bool
+	SaveApplied(requestID int, ruleIDs []int) error
+	GetApplied(requestID int) ([]models.Rule, error)
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+)
+
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	rules    map[int]models.Rule
+	applied  map[int][]int
+	order    []int
+	capacity int
+	nextID   int
+}
+
+// NewMemoryRepository keeps applied rules of the last capacity requests,
+// as many as the memory proxy repository stores.
+func NewMemoryRepository(capacity int) interfaces.Repository {
+	return &MemoryRepository{
+		rules:    make(map[int]models.Rule),
+		applied:  make(map[int][]int),
+		capacity: capacity,
+		nextID:   1,
+	}
+}
+
+func (r *MemoryRepository) CreateRule(rule models.Rule) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule.ID = r.nextID
+	r.nextID++
+	r.rules[rule.ID] = rule
+
+	return rule.ID, nil
+}
+
+func (r *MemoryRepository) UpdateRule(rule models.Rule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[rule.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	r.rules[rule.ID] = rule
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteRule(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.rules, id)
+
+	return nil
+}
+
+func (r *MemoryRepository) GetRules() ([]models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]models.Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules, nil
+}
+
+func (r *MemoryRepository) GetRule(id int) (models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rules[id]
+	if !ok {
+		return models.Rule{}, sql.ErrNoRows
+	}
+
+	return rule, nil
+}
+
+func (r *MemoryRepository) SaveApplied(requestID int, ruleIDs []int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.applied[requestID]; !ok {
+		r.order = append(r.order, requestID)
+		for len(r.order) > r.capacity {
+			delete(r.applied, r.order[0])
+			r.order = r.order[1:]
+		}
+	}
+	r.applied[requestID] = append(r.applied[requestID], ruleIDs...)
+
+	return nil
+}
+
+func (r *MemoryRepository) GetApplied(requestID int) ([]models.Rule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]models.Rule, 0)
+	for _, id := range r.applied[requestID] {
+		if rule, ok := r.rules[id]; ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
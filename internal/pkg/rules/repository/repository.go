@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    target TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    replacement TEXT NOT NULL,
+    is_regex BOOLEAN NOT NULL,
+    host TEXT NOT NULL,
+    path TEXT NOT NULL,
+    method TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS applied_rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    rule_id INT NOT NULL REFERENCES rules (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS applied_rules_request_id_idx ON applied_rules (request_id);
+`
+
+type RulesRepository struct {
+	db *sql.DB
+}
+
+func NewRulesRepository(db *sql.DB) interfaces.Repository {
+	return RulesRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r RulesRepository) CreateRule(rule models.Rule) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO rules (enabled, target, pattern, replacement, is_regex, host, path, method)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		rule.Enabled, rule.Target, rule.Match, rule.Replace, rule.IsRegex, rule.Host, rule.Path, rule.Method,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r RulesRepository) UpdateRule(rule models.Rule) error {
+	result, err := r.db.Exec(
+		`UPDATE rules SET enabled = $1, target = $2, pattern = $3, replacement = $4,
+		is_regex = $5, host = $6, path = $7, method = $8
+		WHERE id = $9`,
+		rule.Enabled, rule.Target, rule.Match, rule.Replace, rule.IsRegex, rule.Host, rule.Path, rule.Method, rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+func (r RulesRepository) DeleteRule(id int) error {
+	result, err := r.db.Exec(`DELETE FROM rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+func (r RulesRepository) GetRules() ([]models.Rule, error) {
+	rows, err := r.db.Query(
+		`SELECT id, enabled, target, pattern, replacement, is_regex, host, path, method FROM rules
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRules(rows)
+}
+
+func (r RulesRepository) GetRule(id int) (models.Rule, error) {
+	var rule models.Rule
+	err := r.db.QueryRow(
+		`SELECT id, enabled, target, pattern, replacement, is_regex, host, path, method FROM rules
+		WHERE id = $1`,
+		id,
+	).Scan(
+		&rule.ID, &rule.Enabled, &rule.Target, &rule.Match, &rule.Replace,
+		&rule.IsRegex, &rule.Host, &rule.Path, &rule.Method,
+	)
+
+	if err != nil {
+		return models.Rule{}, err
+	}
+
+	return rule, nil
+}
+
+func (r RulesRepository) SaveApplied(requestID int, ruleIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, ruleID := range ruleIDs {
+		_, err = tx.Exec(
+			`INSERT INTO applied_rules (request_id, rule_id) VALUES ($1, $2)`,
+			requestID, ruleID,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r RulesRepository) GetApplied(requestID int) ([]models.Rule, error) {
+	rows, err := r.db.Query(
+		`SELECT r.id, r.enabled, r.target, r.pattern, r.replacement, r.is_regex, r.host, r.path, r.method
+		FROM applied_rules a JOIN rules r ON r.id = a.rule_id
+		WHERE a.request_id = $1
+		ORDER BY a.id`,
+		requestID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRules(rows)
+}
+
+func scanRules(rows *sql.Rows) ([]models.Rule, error) {
+	rules := make([]models.Rule, 0)
+	rule := models.Rule{}
+	for rows.Next() {
+		err := rows.Scan(
+			&rule.ID, &rule.Enabled, &rule.Target, &rule.Match, &rule.Replace,
+			&rule.IsRegex, &rule.Host, &rule.Path, &rule.Method,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
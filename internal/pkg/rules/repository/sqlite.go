@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS rules (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    enabled BOOLEAN NOT NULL,
+    target TEXT NOT NULL,
+    pattern TEXT NOT NULL,
+    replacement TEXT NOT NULL,
+    is_regex BOOLEAN NOT NULL,
+    host TEXT NOT NULL,
+    path TEXT NOT NULL,
+    method TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS applied_rules (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    rule_id INTEGER NOT NULL REFERENCES rules (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS applied_rules_request_id_idx ON applied_rules (request_id);
+`
+
+type SqliteRepository struct {
+	RulesRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		RulesRepository: RulesRepository{
+			db: db,
+		},
+	}, nil
+}
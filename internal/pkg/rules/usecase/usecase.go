@@ -0,0 +1,329 @@
+package usecase
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/rules/interfaces"
+)
+
+type compiledRule struct {
+	rule  models.Rule
+	match *regexp.Regexp
+	host  *regexp.Regexp
+	path  *regexp.Regexp
+}
+
+func (r compiledRule) inScope(req models.Request) bool {
+	return (r.rule.Method == "" || strings.EqualFold(r.rule.Method, req.Method)) &&
+		(r.host == nil || r.host.MatchString(req.Host)) &&
+		(r.path == nil || r.path.MatchString(req.Path))
+}
+
+type RulesUsecase struct {
+	rulesRepository interfaces.Repository
+
+	mu     sync.RWMutex
+	cache  []compiledRule
+	loaded bool
+}
+
+func NewRulesUsecase(rulesRepository interfaces.Repository) interfaces.Usecase {
+	return &RulesUsecase{
+		rulesRepository: rulesRepository,
+	}
+}
+
+func (u *RulesUsecase) CreateRule(rule models.Rule) (int, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	defer u.invalidate()
+	return u.rulesRepository.CreateRule(rule)
+}
+
+func (u *RulesUsecase) UpdateRule(rule models.Rule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	defer u.invalidate()
+	return u.rulesRepository.UpdateRule(rule)
+}
+
+func (u *RulesUsecase) DeleteRule(id int) error {
+	defer u.invalidate()
+	return u.rulesRepository.DeleteRule(id)
+}
+
+func (u *RulesUsecase) GetRules() ([]models.Rule, error) {
+	return u.rulesRepository.GetRules()
+}
+
+func (u *RulesUsecase) GetRule(id int) (models.Rule, error) {
+	return u.rulesRepository.GetRule(id)
+}
+
+func (u *RulesUsecase) SaveApplied(requestID int, ruleIDs []int) error {
+	if len(ruleIDs) == 0 {
+		return nil
+	}
+
+	return u.rulesRepository.SaveApplied(requestID, ruleIDs)
+}
+
+func (u *RulesUsecase) GetApplied(requestID int) ([]models.Rule, error) {
+	return u.rulesRepository.GetApplied(requestID)
+}
+
+func (u *RulesUsecase) ApplyToRequest(req models.Request) (models.Request, []int, error) {
+	rules, err := u.rules()
+	if err != nil {
+		return req, nil, err
+	}
+
+	applied := make([]int, 0)
+	for _, rule := range rules {
+		if !rule.inScope(req) {
+			continue
+		}
+
+		var changed bool
+		switch rule.rule.Target {
+		case models.TargetRequestLine:
+			req, changed, err = replaceRequestLine(rule, req)
+		case models.TargetRequestHeader:
+			req.Headers, changed, err = replaceHeaders(rule, req.Headers)
+		case models.TargetRequestBody:
+			req.Body, changed = replace(rule, req.Body)
+		}
+
+		if err != nil {
+			return req, applied, fmt.Errorf("rule %d: %w", rule.rule.ID, err)
+		}
+
+		if changed {
+			applied = append(applied, rule.rule.ID)
+		}
+	}
+
+	return req, applied, nil
+}
+
+func (u *RulesUsecase) ApplyToResponse(req models.Request, resp models.Response) (models.Response, []int, error) {
+	rules, err := u.rules()
+	if err != nil {
+		return resp, nil, err
+	}
+
+	applied := make([]int, 0)
+	for _, rule := range rules {
+		if !rule.inScope(req) {
+			continue
+		}
+
+		var changed bool
+		switch rule.rule.Target {
+		case models.TargetResponseHeader:
+			resp.Headers, changed, err = replaceHeaders(rule, resp.Headers)
+		case models.TargetResponseBody:
+			resp.Body, changed = replace(rule, resp.Body)
+		}
+
+		if err != nil {
+			return resp, applied, fmt.Errorf("rule %d: %w", rule.rule.ID, err)
+		}
+
+		if changed {
+			applied = append(applied, rule.rule.ID)
+		}
+	}
+
+	resp.ContentLength = int64(len(resp.Body))
+
+	return resp, applied, nil
+}
+
+// RewritesResponseBody reports whether a rule may change the body of the
+// response to req, so the body has to be read before it is sent.
+func (u *RulesUsecase) RewritesResponseBody(req models.Request) bool {
+	rules, err := u.rules()
+	if err != nil {
+		return false
+	}
+
+	for _, rule := range rules {
+		if rule.rule.Target == models.TargetResponseBody && rule.inScope(req) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (u *RulesUsecase) rules() ([]compiledRule, error) {
+	u.mu.RLock()
+	if u.loaded {
+		defer u.mu.RUnlock()
+		return u.cache, nil
+	}
+	u.mu.RUnlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rules, err := u.rulesRepository.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	u.cache = make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		compiled := compiledRule{rule: rule}
+		if rule.IsRegex {
+			compiled.match, err = regexp.Compile(rule.Match)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if rule.Host != "" {
+			compiled.host, err = regexp.Compile(rule.Host)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if rule.Path != "" {
+			compiled.path, err = regexp.Compile(rule.Path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		u.cache = append(u.cache, compiled)
+	}
+	u.loaded = true
+
+	return u.cache, nil
+}
+
+func (u *RulesUsecase) invalidate() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.loaded = false
+}
+
+func replace(rule compiledRule, text string) (string, bool) {
+	var result string
+	if rule.match != nil {
+		result = rule.match.ReplaceAllString(text, rule.rule.Replace)
+	} else {
+		result = strings.Replace(text, rule.rule.Match, rule.rule.Replace, -1)
+	}
+
+	return result, result != text
+}
+
+func replaceRequestLine(rule compiledRule, req models.Request) (models.Request, bool, error) {
+	var params url.Values
+	err := json.Unmarshal([]byte(req.Params), &params)
+	if err != nil {
+		return req, false, err
+	}
+
+	line := req.Method + " " + req.Path
+	if query := params.Encode(); query != "" {
+		line += "?" + query
+	}
+
+	line, changed := replace(rule, line)
+	if !changed {
+		return req, false, nil
+	}
+
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) != 2 || fields[0] == "" {
+		return req, false, fmt.Errorf("malformed request line %q", line)
+	}
+
+	uri, err := url.ParseRequestURI(fields[1])
+	if err != nil {
+		return req, false, err
+	}
+
+	encodedParams, err := json.Marshal(uri.Query())
+	if err != nil {
+		return req, false, err
+	}
+
+	req.Method = fields[0]
+	req.Path = uri.Path
+	req.Params = string(encodedParams)
+
+	return req, true, nil
+}
+
+func replaceHeaders(rule compiledRule, encoded string) (string, bool, error) {
+	var headers http.Header
+	err := json.Unmarshal([]byte(encoded), &headers)
+	if err != nil {
+		return encoded, false, err
+	}
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(headers))
+	changed := false
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			line := key + ": " + value
+			if rule.rule.Match != "" {
+				var lineChanged bool
+				line, lineChanged = replace(rule, line)
+				changed = changed || lineChanged
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	if rule.rule.Match == "" && rule.rule.Replace != "" {
+		lines = append(lines, rule.rule.Replace)
+		changed = true
+	}
+
+	if !changed {
+		return encoded, false, nil
+	}
+
+	result := http.Header{}
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			continue
+		}
+		result.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	encodedHeaders, err := json.Marshal(result)
+	if err != nil {
+		return encoded, false, err
+	}
+
+	return string(encodedHeaders), true, nil
+}
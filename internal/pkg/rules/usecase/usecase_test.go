@@ -0,0 +1,52 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/rules/repository"
+)
+
+func TestRuleScope(t *testing.T) {
+	rule := models.Rule{
+		Enabled: true,
+		Target:  models.TargetRequestBody,
+		Match:   "a",
+		Replace: "b",
+		Host:    `^api\.example\.com$`,
+		Path:    "^/users",
+		Method:  "post",
+	}
+
+	tests := []struct {
+		name    string
+		req     models.Request
+		applied bool
+	}{
+		{name: "in scope", req: models.Request{Method: "POST", Host: "api.example.com", Path: "/users/1"}, applied: true},
+		{name: "other method", req: models.Request{Method: "GET", Host: "api.example.com", Path: "/users/1"}},
+		{name: "other host", req: models.Request{Method: "POST", Host: "www.example.com", Path: "/users/1"}},
+		{name: "other path", req: models.Request{Method: "POST", Host: "api.example.com", Path: "/admin/users"}},
+	}
+
+	u := NewRulesUsecase(repository.NewMemoryRepository(10))
+	id, err := u.CreateRule(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Body = "a"
+
+			got, applied, err := u.ApplyToRequest(tt.req)
+			if err != nil {
+				t.Fatalf("ApplyToRequest() error = %v", err)
+			}
+
+			if tt.applied != (got.Body == "b") || tt.applied != (len(applied) == 1 && applied[0] == id) {
+				t.Fatalf("ApplyToRequest() = %q, %v, want applied %v", got.Body, applied, tt.applied)
+			}
+		})
+	}
+}
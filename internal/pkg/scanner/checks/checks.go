@@ -0,0 +1,97 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const evidenceRadius = 40
+
+func Default(commandPayloads []string) []interfaces.Check {
+	return []interfaces.Check{
+		NewCommandInjection(commandPayloads),
+		NewSQLInjection(),
+		NewXSS(),
+		NewPathTraversal(),
+		NewSSTI(),
+		NewOpenRedirect(),
+		NewCRLFInjection(),
+	}
+}
+
+// probe injects payload into the point and sends the resulting request.
+// Payloads the point can't carry yield an empty result that matches nothing.
+func probe(
+	ctx context.Context, point interfaces.InsertionPoint, sender interfaces.Sender, payload string,
+) (models.ProbeResult, error) {
+	req, err := point.Inject(payload)
+	if err == interfaces.ErrPayloadNotApplicable {
+		return models.ProbeResult{Header: http.Header{}}, nil
+	}
+	if err != nil {
+		return models.ProbeResult{}, err
+	}
+
+	return sender.Send(ctx, req)
+}
+
+func newFinding(check string, point interfaces.InsertionPoint, payload, evidence, severity, confidence string) models.Finding {
+	return models.Finding{
+		Check:      check,
+		Parameter:  point.Name(),
+		Payload:    payload,
+		Evidence:   evidence,
+		Severity:   severity,
+		Confidence: confidence,
+	}
+}
+
+func snippet(body string, start, end int) string {
+	from := start - evidenceRadius
+	if from < 0 {
+		from = 0
+	}
+
+	to := end + evidenceRadius
+	if to > len(body) {
+		to = len(body)
+	}
+
+	return body[from:to]
+}
+
+func findNew(pattern *regexp.Regexp, result, baseline models.ProbeResult) (string, bool) {
+	loc := pattern.FindStringIndex(result.Body)
+	if loc == nil || pattern.MatchString(baseline.Body) {
+		return "", false
+	}
+
+	return snippet(result.Body, loc[0], loc[1]), true
+}
+
+func findReflection(result models.ProbeResult, payload string) (string, bool) {
+	idx := strings.Index(result.Body, payload)
+	if idx < 0 {
+		return "", false
+	}
+
+	return snippet(result.Body, idx, idx+len(payload)), true
+}
+
+func similar(a, b models.ProbeResult) bool {
+	if a.StatusCode != b.StatusCode {
+		return false
+	}
+
+	diff := len(a.Body) - len(b.Body)
+	if diff < 0 {
+		diff = -diff
+	}
+
+	return diff <= len(b.Body)/50+10
+}
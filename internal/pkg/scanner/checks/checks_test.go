@@ -0,0 +1,207 @@
+package checks
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+// bodyPoint injects payloads into the request body.
+type bodyPoint struct{}
+
+func (p bodyPoint) Name() string {
+	return "form:q"
+}
+
+func (p bodyPoint) Value() string {
+	return "1"
+}
+
+func (p bodyPoint) Inject(payload string) (models.Request, error) {
+	if strings.ContainsAny(payload, "\r\n") {
+		return models.Request{}, interfaces.ErrPayloadNotApplicable
+	}
+
+	return models.Request{Body: payload}, nil
+}
+
+// app answers a probe with the value the payload ended up in.
+type app func(value string) models.ProbeResult
+
+func (a app) Send(ctx context.Context, req models.Request) (models.ProbeResult, error) {
+	result := a(req.Body)
+	if result.Header == nil {
+		result.Header = http.Header{}
+	}
+
+	return result, nil
+}
+
+func page(body string) models.ProbeResult {
+	return models.ProbeResult{StatusCode: 200, Header: http.Header{"Content-Type": {"text/html"}}, Body: body}
+}
+
+func TestChecks(t *testing.T) {
+	safe := app(func(value string) models.ProbeResult {
+		return page("<p>nothing to see</p>")
+	})
+	reflecting := app(func(value string) models.ProbeResult {
+		return page("<p>you searched for " + value + "</p>")
+	})
+
+	tests := []struct {
+		name       string
+		check      interfaces.Check
+		app        app
+		confidence string
+	}{
+		{
+			name:  "command injection",
+			check: NewCommandInjection([]string{";cat /etc/passwd"}),
+			app: func(value string) models.ProbeResult {
+				if strings.Contains(value, "cat /etc/passwd") {
+					return page("root:x:0:0:root:/root:/bin/sh")
+				}
+				return page("ok")
+			},
+			confidence: models.ConfidenceFirm,
+		},
+		{
+			name:  "error based sql injection",
+			check: NewSQLInjection(),
+			app: func(value string) models.ProbeResult {
+				if strings.Count(value, "'")%2 == 1 {
+					return page("You have an error in your SQL syntax near ''' at line 1")
+				}
+				return page("1 row")
+			},
+			confidence: models.ConfidenceFirm,
+		},
+		{
+			name:  "boolean based sql injection",
+			check: NewSQLInjection(),
+			app: func(value string) models.ProbeResult {
+				if strings.HasSuffix(value, "'1'='2") {
+					return page("")
+				}
+				return page("<table><tr><td>alice</td></tr></table>")
+			},
+			confidence: models.ConfidenceTentative,
+		},
+		{
+			name:  "time based sql injection",
+			check: NewSQLInjection(),
+			app: func(value string) models.ProbeResult {
+				result := page("ok")
+				if strings.Contains(value, "SLEEP(5)") {
+					result.Duration = 5 * time.Second
+				}
+				return result
+			},
+			confidence: models.ConfidenceFirm,
+		},
+		{name: "reflected xss", check: NewXSS(), app: reflecting, confidence: models.ConfidenceFirm},
+		{
+			name:  "escaped reflection",
+			check: NewXSS(),
+			app: func(value string) models.ProbeResult {
+				return page("<p>" + strings.NewReplacer("<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;").Replace(value) + "</p>")
+			},
+		},
+		{
+			name:  "reflection in json",
+			check: NewXSS(),
+			app: func(value string) models.ProbeResult {
+				return models.ProbeResult{StatusCode: 200, Header: http.Header{"Content-Type": {"application/json"}}, Body: value}
+			},
+		},
+		{
+			name:  "path traversal",
+			check: NewPathTraversal(),
+			app: func(value string) models.ProbeResult {
+				if value == "/etc/passwd" {
+					return page("root:x:0:0:root:/root:/bin/sh")
+				}
+				return page("not found")
+			},
+			confidence: models.ConfidenceFirm,
+		},
+		{
+			name:  "template injection",
+			check: NewSSTI(),
+			app: func(value string) models.ProbeResult {
+				return page(strings.Replace(value, "{{1337*1337}}", "1787569", -1))
+			},
+			confidence: models.ConfidenceFirm,
+		},
+		{name: "template reflected as is", check: NewSSTI(), app: reflecting},
+		{
+			name:  "open redirect",
+			check: NewOpenRedirect(),
+			app: func(value string) models.ProbeResult {
+				return models.ProbeResult{StatusCode: 302, Header: http.Header{"Location": {value}}}
+			},
+			confidence: models.ConfidenceCertain,
+		},
+		{
+			name:  "local redirect",
+			check: NewOpenRedirect(),
+			app: func(value string) models.ProbeResult {
+				return models.ProbeResult{StatusCode: 302, Header: http.Header{"Location": {"/login?next=" + value}}}
+			},
+		},
+		{
+			name:  "crlf injection",
+			check: NewCRLFInjection(),
+			app: func(value string) models.ProbeResult {
+				result := page("ok")
+				if strings.Contains(value, "%0d%0a") {
+					result.Header = http.Header{crlfHeader: {crlfValue}}
+				}
+				return result
+			},
+			confidence: models.ConfidenceCertain,
+		},
+	}
+
+	for _, check := range Default([]string{";cat /etc/passwd"}) {
+		tests = append(tests, struct {
+			name       string
+			check      interfaces.Check
+			app        app
+			confidence string
+		}{name: check.Name() + " on a safe app", check: check, app: safe})
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline, _ := tt.app.Send(context.Background(), models.Request{Body: bodyPoint{}.Value()})
+
+			findings, err := tt.check.Run(context.Background(), bodyPoint{}, baseline, tt.app)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if tt.confidence == "" {
+				if len(findings) != 0 {
+					t.Fatalf("Run() = %+v, want no findings", findings)
+				}
+				return
+			}
+
+			if len(findings) != 1 {
+				t.Fatalf("Run() = %+v, want one finding", findings)
+			}
+			finding := findings[0]
+			if finding.Check != tt.check.Name() || finding.Parameter != "form:q" || finding.Payload == "" ||
+				finding.Evidence == "" || finding.Confidence != tt.confidence {
+				t.Fatalf("Run() = %+v, want confidence %s", finding, tt.confidence)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package checks
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+var passwdPattern = regexp.MustCompile(`root:[^:\r\n]*:0:0:`)
+
+type CommandInjection struct {
+	payloads []string
+}
+
+func NewCommandInjection(payloads []string) CommandInjection {
+	return CommandInjection{
+		payloads: payloads,
+	}
+}
+
+func (c CommandInjection) Name() string {
+	return "command_injection"
+}
+
+func (c CommandInjection) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	for _, payload := range c.payloads {
+		payload = point.Value() + payload
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if evidence, ok := findNew(passwdPattern, result, baseline); ok {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, evidence, models.SeverityHigh, models.ConfidenceFirm),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
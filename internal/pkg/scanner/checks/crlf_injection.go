@@ -0,0 +1,55 @@
+package checks
+
+import (
+	"context"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const (
+	crlfHeader = "X-Hpx-Injected"
+	crlfValue  = "hpx7331"
+)
+
+var crlfPayloads = []string{
+	"\r\n" + crlfHeader + ": " + crlfValue,
+	"%0d%0a" + crlfHeader + ":%20" + crlfValue,
+	"\n" + crlfHeader + ": " + crlfValue,
+	"%E5%98%8A%E5%98%8D" + crlfHeader + ":%20" + crlfValue,
+}
+
+type CRLFInjection struct{}
+
+func NewCRLFInjection() CRLFInjection {
+	return CRLFInjection{}
+}
+
+func (c CRLFInjection) Name() string {
+	return "crlf_injection"
+}
+
+func (c CRLFInjection) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	if baseline.Header.Get(crlfHeader) != "" {
+		return nil, nil
+	}
+
+	for _, suffix := range crlfPayloads {
+		payload := point.Value() + suffix
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if value := result.Header.Get(crlfHeader); value != "" {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, crlfHeader+": "+value, models.SeverityMedium, models.ConfidenceCertain),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
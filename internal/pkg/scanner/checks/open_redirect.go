@@ -0,0 +1,78 @@
+package checks
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const redirectHost = "hpx-redirect.example.org"
+
+var redirectPayloads = []string{
+	"https://" + redirectHost + "/",
+	"//" + redirectHost + "/",
+	"/\\" + redirectHost + "/",
+	"https:" + redirectHost,
+}
+
+var metaRefreshPattern = regexp.MustCompile(`(?i)<meta[^>]+http-equiv=["']?refresh[^>]+url=['"]?[^'">]*` +
+	regexp.QuoteMeta(redirectHost))
+
+type OpenRedirect struct{}
+
+func NewOpenRedirect() OpenRedirect {
+	return OpenRedirect{}
+}
+
+func (c OpenRedirect) Name() string {
+	return "open_redirect"
+}
+
+func (c OpenRedirect) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	for _, payload := range redirectPayloads {
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if location := result.Header.Get("Location"); result.StatusCode/100 == 3 && redirectsAway(location) {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, "Location: "+location, models.SeverityMedium, models.ConfidenceCertain),
+			}, nil
+		}
+
+		if refresh := result.Header.Get("Refresh"); strings.Contains(refresh, redirectHost) {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, "Refresh: "+refresh, models.SeverityMedium, models.ConfidenceFirm),
+			}, nil
+		}
+
+		if evidence, ok := findNew(metaRefreshPattern, result, baseline); ok {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, evidence, models.SeverityMedium, models.ConfidenceFirm),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func redirectsAway(location string) bool {
+	location = strings.Replace(location, "\\", "/", -1)
+	if strings.HasPrefix(location, "//") {
+		location = "https:" + location
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+
+	return strings.EqualFold(u.Hostname(), redirectHost) || strings.EqualFold(u.Opaque, redirectHost)
+}
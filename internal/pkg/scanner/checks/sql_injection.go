@@ -0,0 +1,172 @@
+package checks
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const sqlSleepSeconds = 5
+
+var sqlErrorPattern = regexp.MustCompile(`(?i)(` +
+	`you have an error in your sql syntax|warning: mysql|mysqli?_fetch|` +
+	`unterminated quoted string|pg_query\(\)|postgresql.*error|syntax error at or near|` +
+	`ora-\d{5}|sqlstate\[|sqlite3?\.operationalerror|sqlite3::|sqlite_error|` +
+	`unclosed quotation mark|microsoft ole db provider|odbc sql server driver|` +
+	`quoted string not properly terminated)`)
+
+var sqlErrorPayloads = []string{"'", "\"", "')", "\\"}
+
+var sqlBooleanPayloads = []struct {
+	truthy string
+	falsy  string
+}{
+	{"' AND '1'='1", "' AND '1'='2"},
+	{"\" AND \"1\"=\"1", "\" AND \"1\"=\"2"},
+	{" AND 1=1", " AND 1=2"},
+}
+
+var sqlTimePayloads = []string{
+	"' AND SLEEP(%d)-- -",
+	"' AND 1=(SELECT 1 FROM PG_SLEEP(%d))-- -",
+	"'; WAITFOR DELAY '0:0:%d'-- -",
+	" AND SLEEP(%d)",
+}
+
+type SQLInjection struct{}
+
+func NewSQLInjection() SQLInjection {
+	return SQLInjection{}
+}
+
+func (c SQLInjection) Name() string {
+	return "sql_injection"
+}
+
+func (c SQLInjection) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	steps := []func(context.Context, interfaces.InsertionPoint, models.ProbeResult, interfaces.Sender) (*models.Finding, error){
+		c.errorBased, c.booleanBased, c.timeBased,
+	}
+
+	for _, step := range steps {
+		finding, err := step(ctx, point, baseline, sender)
+		if err != nil {
+			return nil, err
+		}
+
+		if finding != nil {
+			return []models.Finding{*finding}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c SQLInjection) errorBased(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) (*models.Finding, error) {
+	for _, suffix := range sqlErrorPayloads {
+		payload := point.Value() + suffix
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if evidence, ok := findNew(sqlErrorPattern, result, baseline); ok {
+			finding := newFinding(c.Name(), point, payload, evidence, models.SeverityHigh, models.ConfidenceFirm)
+			return &finding, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c SQLInjection) booleanBased(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) (*models.Finding, error) {
+	for _, pair := range sqlBooleanPayloads {
+		truthy, err := probe(ctx, point, sender, point.Value()+pair.truthy)
+		if err != nil {
+			return nil, err
+		}
+
+		if !similar(truthy, baseline) {
+			continue
+		}
+
+		falsy, err := probe(ctx, point, sender, point.Value()+pair.falsy)
+		if err != nil {
+			return nil, err
+		}
+
+		if similar(falsy, baseline) {
+			continue
+		}
+
+		confirm, err := probe(ctx, point, sender, point.Value()+pair.truthy)
+		if err != nil {
+			return nil, err
+		}
+
+		if !similar(confirm, baseline) {
+			continue
+		}
+
+		evidence := fmt.Sprintf(
+			"true condition: %d (%d bytes), false condition: %d (%d bytes)",
+			truthy.StatusCode, len(truthy.Body), falsy.StatusCode, len(falsy.Body),
+		)
+		finding := newFinding(
+			c.Name(), point, point.Value()+pair.falsy, evidence, models.SeverityHigh, models.ConfidenceTentative,
+		)
+		return &finding, nil
+	}
+
+	return nil, nil
+}
+
+func (c SQLInjection) timeBased(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) (*models.Finding, error) {
+	delay := time.Duration(sqlSleepSeconds) * time.Second
+	threshold := baseline.Duration + delay*9/10
+
+	for _, template := range sqlTimePayloads {
+		payload := point.Value() + fmt.Sprintf(template, sqlSleepSeconds)
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Duration < threshold {
+			continue
+		}
+
+		control, err := probe(ctx, point, sender, point.Value()+fmt.Sprintf(template, 0))
+		if err != nil {
+			return nil, err
+		}
+
+		if control.Duration >= threshold {
+			continue
+		}
+
+		evidence := fmt.Sprintf(
+			"sleep(%d) took %s, sleep(0) took %s, baseline %s",
+			sqlSleepSeconds, result.Duration.Round(time.Millisecond),
+			control.Duration.Round(time.Millisecond), baseline.Duration.Round(time.Millisecond),
+		)
+		finding := newFinding(c.Name(), point, payload, evidence, models.SeverityHigh, models.ConfidenceFirm)
+		return &finding, nil
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package checks
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const sstiProduct = "1787569"
+
+var sstiPattern = regexp.MustCompile(sstiProduct)
+
+var sstiPayloads = []string{
+	"{{1337*1337}}",
+	"${1337*1337}",
+	"<%= 1337*1337 %>",
+	"#{1337*1337}",
+	"${{1337*1337}}",
+	"{1337*1337}",
+}
+
+type SSTI struct{}
+
+func NewSSTI() SSTI {
+	return SSTI{}
+}
+
+func (c SSTI) Name() string {
+	return "template_injection"
+}
+
+func (c SSTI) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	for _, suffix := range sstiPayloads {
+		payload := point.Value() + suffix
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if evidence, ok := findNew(sstiPattern, result, baseline); ok {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, evidence, models.SeverityHigh, models.ConfidenceFirm),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
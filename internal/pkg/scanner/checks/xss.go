@@ -0,0 +1,73 @@
+package checks
+
+import (
+	"context"
+	"mime"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const xssMarker = "hpx7331"
+
+var xssPayloads = []string{
+	`"><script>alert('` + xssMarker + `')</script>`,
+	`'"><img src=x onerror=alert('` + xssMarker + `')>`,
+	`"` + xssMarker + `" onmouseover="alert(1)`,
+	`</textarea><svg onload=alert('` + xssMarker + `')>`,
+}
+
+type XSS struct{}
+
+func NewXSS() XSS {
+	return XSS{}
+}
+
+func (c XSS) Name() string {
+	return "reflected_xss"
+}
+
+func (c XSS) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	result, err := probe(ctx, point, sender, point.Value()+xssMarker)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.Contains(result.Body, xssMarker) || !isHTML(result) {
+		return nil, nil
+	}
+
+	for _, suffix := range xssPayloads {
+		payload := point.Value() + suffix
+
+		result, err := probe(ctx, point, sender, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if evidence, ok := findReflection(result, suffix); ok && isHTML(result) {
+			return []models.Finding{
+				newFinding(c.Name(), point, payload, evidence, models.SeverityMedium, models.ConfidenceFirm),
+			}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func isHTML(result models.ProbeResult) bool {
+	contentType := result.Header.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
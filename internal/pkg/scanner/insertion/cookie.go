@@ -0,0 +1,84 @@
+package insertion
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type cookiePoint struct {
+	req   models.Request
+	name  string
+	value string
+	// line is the index of the Cookie header, start and end bound the value in it
+	line  int
+	start int
+	end   int
+}
+
+func cookiePoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	headers, err := decodeHeaders(req)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]interfaces.InsertionPoint, 0)
+	for line, header := range headers["Cookie"] {
+		offset := 0
+		for _, pair := range strings.Split(header, ";") {
+			start, end := offset, offset+len(pair)
+			offset = end + 1
+
+			// net/http decides which pairs are cookies, the rest stay in the header as they are
+			cookies := (&http.Request{Header: http.Header{"Cookie": {pair}}}).Cookies()
+			equals := strings.Index(pair, "=")
+			if len(cookies) != 1 || equals < 0 {
+				continue
+			}
+
+			start += equals + 1
+			end -= len(pair) - len(strings.TrimRight(pair, " \t"))
+			if end-start >= 2 && header[start] == '"' && header[end-1] == '"' {
+				start, end = start+1, end-1
+			}
+
+			points = append(points, cookiePoint{
+				req:   req,
+				name:  cookies[0].Name,
+				value: cookies[0].Value,
+				line:  line,
+				start: start,
+				end:   end,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+func (p cookiePoint) Name() string {
+	return "cookie:" + p.name
+}
+
+func (p cookiePoint) Value() string {
+	return p.value
+}
+
+func (p cookiePoint) Inject(payload string) (models.Request, error) {
+	if !validHeaderValue(payload) || strings.Contains(payload, ";") {
+		return models.Request{}, interfaces.ErrPayloadNotApplicable
+	}
+
+	headers, err := decodeHeaders(p.req)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	lines := append([]string(nil), headers["Cookie"]...)
+	lines[p.line] = lines[p.line][:p.start] + payload + lines[p.line][p.end:]
+	headers["Cookie"] = lines
+
+	return withHeaders(p.req, headers)
+}
@@ -0,0 +1,61 @@
+package insertion
+
+import (
+	"net/url"
+	"sort"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type formPoint struct {
+	req    models.Request
+	values url.Values
+	key    string
+	index  int
+}
+
+func formPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	values, err := url.ParseQuery(req.Body)
+	if err != nil {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	points := make([]interfaces.InsertionPoint, 0)
+	for _, key := range keys {
+		for i := range values[key] {
+			points = append(points, formPoint{
+				req:    req,
+				values: values,
+				key:    key,
+				index:  i,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+func (p formPoint) Name() string {
+	return "form:" + p.key
+}
+
+func (p formPoint) Value() string {
+	return p.values[p.key][p.index]
+}
+
+func (p formPoint) Inject(payload string) (models.Request, error) {
+	values := url.Values{}
+	for key, list := range p.values {
+		values[key] = append([]string(nil), list...)
+	}
+	values[p.key][p.index] = payload
+
+	return withBody(p.req, values.Encode())
+}
@@ -0,0 +1,55 @@
+package insertion
+
+import (
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+var scannedHeaders = []string{"User-Agent", "Referer", "X-Forwarded-For"}
+
+type headerPoint struct {
+	req   models.Request
+	name  string
+	value string
+}
+
+func headerPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	headers, err := decodeHeaders(req)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]interfaces.InsertionPoint, 0, len(scannedHeaders))
+	for _, name := range scannedHeaders {
+		points = append(points, headerPoint{
+			req:   req,
+			name:  name,
+			value: headers.Get(name),
+		})
+	}
+
+	return points, nil
+}
+
+func (p headerPoint) Name() string {
+	return "header:" + p.name
+}
+
+func (p headerPoint) Value() string {
+	return p.value
+}
+
+func (p headerPoint) Inject(payload string) (models.Request, error) {
+	if !validHeaderValue(payload) {
+		return models.Request{}, interfaces.ErrPayloadNotApplicable
+	}
+
+	headers, err := decodeHeaders(p.req)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	headers.Set(p.name, payload)
+
+	return withHeaders(p.req, headers)
+}
@@ -0,0 +1,103 @@
+package insertion
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+func Points(req models.Request) ([]interfaces.InsertionPoint, error) {
+	builders := []func(models.Request) ([]interfaces.InsertionPoint, error){
+		queryPoints,
+		bodyPoints,
+		cookiePoints,
+		headerPoints,
+		pathPoints,
+	}
+
+	points := make([]interfaces.InsertionPoint, 0)
+	for _, build := range builders {
+		found, err := build(req)
+		if err != nil {
+			return nil, err
+		}
+
+		points = append(points, found...)
+	}
+
+	return points, nil
+}
+
+func bodyPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	if req.Body == "" {
+		return nil, nil
+	}
+
+	headers, err := decodeHeaders(req)
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, err := mime.ParseMediaType(headers.Get("Content-Type"))
+	if err != nil {
+		return nil, nil
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return formPoints(req)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return jsonPoints(req)
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return xmlPoints(req)
+	default:
+		return nil, nil
+	}
+}
+
+func decodeHeaders(req models.Request) (http.Header, error) {
+	headers := http.Header{}
+	if req.Headers == "" {
+		return headers, nil
+	}
+
+	err := json.Unmarshal([]byte(req.Headers), &headers)
+	if err != nil {
+		return nil, err
+	}
+
+	return headers, nil
+}
+
+func withHeaders(req models.Request, headers http.Header) (models.Request, error) {
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	req.Headers = string(encodedHeaders)
+
+	return req, nil
+}
+
+// withBody replaces the body and keeps Content-Length in sync with it.
+func withBody(req models.Request, body string) (models.Request, error) {
+	headers, err := decodeHeaders(req)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	headers.Set("Content-Length", strconv.Itoa(len(body)))
+	req.Body = body
+
+	return withHeaders(req, headers)
+}
+
+func validHeaderValue(value string) bool {
+	return !strings.ContainsAny(value, "\r\n\x00")
+}
@@ -0,0 +1,410 @@
+package insertion
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const (
+	formType = `{"Content-Type":["application/x-www-form-urlencoded"]}`
+	jsonType = `{"Content-Type":["application/json"]}`
+	xmlType  = `{"Content-Type":["text/xml; charset=utf-8"]}`
+)
+
+func TestPoints(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(models.Request) ([]interfaces.InsertionPoint, error)
+		req   models.Request
+		want  []string
+		err   bool
+	}{
+		{
+			name:  "query",
+			build: queryPoints,
+			req:   models.Request{Params: `{"b":["2"],"a":["1","x"]}`},
+			want:  []string{"query:a=1", "query:a=x", "query:b=2"},
+		},
+		{
+			name:  "query without values",
+			build: queryPoints,
+			req:   models.Request{Params: `{"a":[]}`},
+			want:  []string{},
+		},
+		{
+			name:  "malformed query",
+			build: queryPoints,
+			req:   models.Request{Params: `{"a":"1"}`},
+			err:   true,
+		},
+		{
+			name:  "path",
+			build: pathPoints,
+			req:   models.Request{Path: "/api/v1//users/42/"},
+			want:  []string{"path:1:api=api", "path:2:v1=v1", "path:4:users=users", "path:5:42=42"},
+		},
+		{
+			name:  "root path",
+			build: pathPoints,
+			req:   models.Request{Path: "/"},
+			want:  []string{},
+		},
+		{
+			name:  "cookies",
+			build: cookiePoints,
+			req:   models.Request{Headers: `{"Cookie":["a=1; =x; b=\"q\"", "c=3"]}`},
+			want:  []string{"cookie:a=1", "cookie:b=q", "cookie:c=3"},
+		},
+		{
+			name:  "no headers",
+			build: cookiePoints,
+			req:   models.Request{},
+			want:  []string{},
+		},
+		{
+			name:  "malformed cookie headers",
+			build: cookiePoints,
+			req:   models.Request{Headers: `["Cookie"]`},
+			err:   true,
+		},
+		{
+			name:  "headers",
+			build: headerPoints,
+			req:   models.Request{Headers: `{"User-Agent":["curl"],"Accept":["*/*"]}`},
+			want:  []string{"header:User-Agent=curl", "header:Referer=", "header:X-Forwarded-For="},
+		},
+		{
+			name:  "malformed headers",
+			build: headerPoints,
+			req:   models.Request{Headers: `{"User-Agent":"curl"}`},
+			err:   true,
+		},
+		{
+			name:  "form",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{"Content-Type":["application/x-www-form-urlencoded; charset=utf-8"]}`, Body: "b=2&a=1&a="},
+			want:  []string{"form:a=1", "form:a=", "form:b=2"},
+		},
+		{
+			name:  "malformed form",
+			build: bodyPoints,
+			req:   models.Request{Headers: formType, Body: "a=%zz"},
+		},
+		{
+			name:  "json",
+			build: bodyPoints,
+			req:   models.Request{Headers: jsonType, Body: `{"user":{"name":"bob","tags":["x",1.5]},"admin":false,"n":null,"e":{}}`},
+			want:  []string{"json:admin=false", "json:user.name=bob", "json:user.tags[0]=x", "json:user.tags[1]=1.5"},
+		},
+		{
+			name:  "json array",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{"Content-Type":["application/vnd.api+json"]}`, Body: `[{"a":"b"},[2]]`},
+			want:  []string{"json:[0].a=b", "json:[1][0]=2"},
+		},
+		{
+			name:  "json scalar",
+			build: bodyPoints,
+			req:   models.Request{Headers: jsonType, Body: `"x"`},
+			want:  []string{"json:=x"},
+		},
+		{
+			name:  "malformed json",
+			build: bodyPoints,
+			req:   models.Request{Headers: jsonType, Body: `{"a":`},
+		},
+		{
+			name:  "xml",
+			build: bodyPoints,
+			req:   models.Request{Headers: xmlType, Body: `<?xml version="1.0"?><user id="1"><name>bob</name><tags><tag>x</tag><tag/></tags></user>`},
+			want:  []string{"xml:user/name=bob", "xml:user/tags/tag=x", "xml:user/tags/tag="},
+		},
+		{
+			name:  "xml with cdata and prefixes",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{"Content-Type":["application/soap+xml"]}`, Body: `<s:Body><s:q><![CDATA[a<b]]></s:q></s:Body>`},
+			want:  []string{"xml:Body/q=a<b"},
+		},
+		{
+			name:  "unexpected xml end element",
+			build: bodyPoints,
+			req:   models.Request{Headers: xmlType, Body: `</a>`},
+		},
+		{
+			name:  "malformed xml",
+			build: bodyPoints,
+			req:   models.Request{Headers: xmlType, Body: `<a x='1>`},
+		},
+		{
+			name:  "text body",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{"Content-Type":["text/plain"]}`, Body: "a=1"},
+		},
+		{
+			name:  "no content type",
+			build: bodyPoints,
+			req:   models.Request{Body: "a=1"},
+		},
+		{
+			name:  "malformed content type",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{"Content-Type":["/;;"]}`, Body: "a=1"},
+		},
+		{
+			name:  "empty body",
+			build: bodyPoints,
+			req:   models.Request{Headers: formType},
+		},
+		{
+			name:  "malformed body headers",
+			build: bodyPoints,
+			req:   models.Request{Headers: `{`, Body: "a=1"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			points, err := tt.build(tt.req)
+			if (err != nil) != tt.err {
+				t.Fatalf("error = %v, want error %v", err, tt.err)
+			}
+
+			got := describe(points)
+			if tt.want == nil && len(got) != 0 || tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("points = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointsOfRequest(t *testing.T) {
+	points, err := Points(models.Request{
+		Path:    "/search",
+		Params:  `{"q":["x"]}`,
+		Headers: `{"Content-Type":["application/json"],"Cookie":["s=1"]}`,
+		Body:    `{"page":2}`,
+	})
+	if err != nil {
+		t.Fatalf("Points() error = %v", err)
+	}
+
+	want := []string{
+		"query:q=x",
+		"json:page=2",
+		"cookie:s=1",
+		"header:User-Agent=", "header:Referer=", "header:X-Forwarded-For=",
+		"path:1:search=search",
+	}
+	if got := describe(points); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Points() = %q, want %q", got, want)
+	}
+
+	if _, err := Points(models.Request{Params: "not json"}); err == nil {
+		t.Fatal("Points() with malformed params error = nil")
+	}
+}
+
+func TestInject(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     models.Request
+		point   string
+		payload string
+		want    models.Request
+		err     error
+	}{
+		{
+			name:    "query value",
+			req:     models.Request{Params: `{"a":["1","2"],"b":["3"]}`},
+			point:   "query:a#1",
+			payload: "<x>",
+			want:    models.Request{Params: `{"a":["1","\u003cx\u003e"],"b":["3"]}`},
+		},
+		{
+			name:    "form value",
+			req:     models.Request{Headers: formType, Body: "b=2&a=1"},
+			point:   "form:b",
+			payload: "x y&z",
+			want: models.Request{
+				Headers: `{"Content-Length":["13"],"Content-Type":["application/x-www-form-urlencoded"]}`,
+				Body:    "a=1&b=x+y%26z",
+			},
+		},
+		{
+			name:    "cookie",
+			req:     models.Request{Headers: `{"Cookie":["a=1; b=2"]}`},
+			point:   "cookie:a",
+			payload: "'",
+			want:    models.Request{Headers: `{"Cookie":["a='; b=2"]}`},
+		},
+		{
+			name:    "cookie next to unparseable pairs",
+			req:     models.Request{Headers: `{"Cookie":["=x;a=1 ;flag; b=\"q\"", "c=3"]}`},
+			point:   "cookie:b",
+			payload: "'",
+			want:    models.Request{Headers: `{"Cookie":["=x;a=1 ;flag; b=\"'\"","c=3"]}`},
+		},
+		{
+			name:    "cookie in a second header",
+			req:     models.Request{Headers: `{"Cookie":["a=1","a=2; c=3"]}`},
+			point:   "cookie:a#1",
+			payload: "x",
+			want:    models.Request{Headers: `{"Cookie":["a=1","a=x; c=3"]}`},
+		},
+		{
+			name:    "cookie with semicolon",
+			req:     models.Request{Headers: `{"Cookie":["a=1; b=2"]}`},
+			point:   "cookie:a",
+			payload: "x; b=3",
+			err:     interfaces.ErrPayloadNotApplicable,
+		},
+		{
+			name:    "cookie with line break",
+			req:     models.Request{Headers: `{"Cookie":["a=1"]}`},
+			point:   "cookie:a",
+			payload: "x\r\nX-Injected: 1",
+			err:     interfaces.ErrPayloadNotApplicable,
+		},
+		{
+			name:    "missing header",
+			req:     models.Request{},
+			point:   "header:Referer",
+			payload: "http://evil.test/",
+			want:    models.Request{Headers: `{"Referer":["http://evil.test/"]}`},
+		},
+		{
+			name:    "header with nul",
+			req:     models.Request{},
+			point:   "header:User-Agent",
+			payload: "a\x00b",
+			err:     interfaces.ErrPayloadNotApplicable,
+		},
+		{
+			name:    "path segment",
+			req:     models.Request{Path: "/a/b/"},
+			point:   "path:2:b",
+			payload: "../etc",
+			want:    models.Request{Path: "/a/../etc/"},
+		},
+		{
+			name:    "json string",
+			req:     models.Request{Headers: jsonType, Body: `{"a":{"b":["x","y"]},"c":10000000000000000001}`},
+			point:   "json:a.b[1]",
+			payload: `"</script>`,
+			want: models.Request{
+				Headers: `{"Content-Length":["56"],"Content-Type":["application/json"]}`,
+				Body:    `{"a":{"b":["x","\"</script>"]},"c":10000000000000000001}`,
+			},
+		},
+		{
+			name:    "json number",
+			req:     models.Request{Headers: jsonType, Body: `[1,true]`},
+			point:   "json:[0]",
+			payload: "1 OR 1=1",
+			want: models.Request{
+				Headers: `{"Content-Length":["17"],"Content-Type":["application/json"]}`,
+				Body:    `["1 OR 1=1",true]`,
+			},
+		},
+		{
+			name:    "xml element",
+			req:     models.Request{Headers: xmlType, Body: `<r><a>1</a><b>2</b></r>`},
+			point:   "xml:r/b",
+			payload: "<x>&",
+			want: models.Request{
+				Headers: `{"Content-Length":["36"],"Content-Type":["text/xml; charset=utf-8"]}`,
+				Body:    `<r><a>1</a><b>&lt;x&gt;&amp;</b></r>`,
+			},
+		},
+		{
+			name:    "empty xml element",
+			req:     models.Request{Headers: xmlType, Body: `<r><a></a></r>`},
+			point:   "xml:r/a",
+			payload: "p",
+			want: models.Request{
+				Headers: `{"Content-Length":["15"],"Content-Type":["text/xml; charset=utf-8"]}`,
+				Body:    `<r><a>p</a></r>`,
+			},
+		},
+		{
+			name:    "self-closing xml element",
+			req:     models.Request{Headers: xmlType, Body: `<r><s:a x="1" /><b/></r>`},
+			point:   "xml:r/a",
+			payload: "p",
+			want: models.Request{
+				Headers: `{"Content-Length":["30"],"Content-Type":["text/xml; charset=utf-8"]}`,
+				Body:    `<r><s:a x="1" >p</s:a><b/></r>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.req.Params == "" {
+				tt.req.Params, tt.want.Params = "{}", "{}"
+			}
+
+			points, err := Points(tt.req)
+			if err != nil {
+				t.Fatalf("Points() error = %v", err)
+			}
+
+			point := find(points, tt.point)
+			if point == nil {
+				t.Fatalf("no point %s in %q", tt.point, describe(points))
+			}
+			value := point.Value()
+
+			got, err := point.Inject(tt.payload)
+			if err != tt.err {
+				t.Fatalf("Inject() error = %v, want %v", err, tt.err)
+			}
+			if err == nil && (got.Params != tt.want.Params || got.Headers != tt.want.Headers ||
+				got.Body != tt.want.Body || got.Path != tt.want.Path) {
+				t.Fatalf("Inject() = %+v, want %+v", got, tt.want)
+			}
+
+			if point.Value() != value {
+				t.Fatalf("Inject() changed the point value from %q to %q", value, point.Value())
+			}
+			if again, _ := point.Inject(tt.payload); err == nil && !reflect.DeepEqual(again, got) {
+				t.Fatalf("second Inject() = %+v, want %+v", again, got)
+			}
+		})
+	}
+}
+
+func describe(points []interfaces.InsertionPoint) []string {
+	described := make([]string, 0, len(points))
+	for _, point := range points {
+		described = append(described, point.Name()+"="+point.Value())
+	}
+
+	return described
+}
+
+// find returns the point with the given name, "#n" picks the n-th of points
+// sharing it.
+func find(points []interfaces.InsertionPoint, name string) interfaces.InsertionPoint {
+	index := 0
+	if i := len(name) - 2; i > 0 && name[i] == '#' {
+		index = int(name[i+1] - '0')
+		name = name[:i]
+	}
+
+	for _, point := range points {
+		if point.Name() != name {
+			continue
+		}
+		if index == 0 {
+			return point
+		}
+		index--
+	}
+
+	return nil
+}
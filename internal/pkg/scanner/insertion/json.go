@@ -0,0 +1,155 @@
+package insertion
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type jsonPoint struct {
+	req   models.Request
+	path  []interface{}
+	value string
+}
+
+func jsonPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	document, err := decodeJSON(req.Body)
+	if err != nil {
+		return nil, nil
+	}
+
+	points := make([]interfaces.InsertionPoint, 0)
+	walkJSON(document, nil, func(path []interface{}, value string) {
+		points = append(points, jsonPoint{
+			req:   req,
+			path:  path,
+			value: value,
+		})
+	})
+
+	return points, nil
+}
+
+func (p jsonPoint) Name() string {
+	name := "json:"
+	for i, step := range p.path {
+		switch step := step.(type) {
+		case string:
+			if i > 0 {
+				name += "."
+			}
+			name += step
+		case int:
+			name += "[" + strconv.Itoa(step) + "]"
+		}
+	}
+
+	return name
+}
+
+func (p jsonPoint) Value() string {
+	return p.value
+}
+
+func (p jsonPoint) Inject(payload string) (models.Request, error) {
+	document, err := decodeJSON(p.req.Body)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	document, err = setJSON(document, p.path, payload)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	encoder.SetEscapeHTML(false)
+	err = encoder.Encode(document)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	return withBody(p.req, strings.TrimSuffix(body.String(), "\n"))
+}
+
+func decodeJSON(body string) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewBufferString(body))
+	decoder.UseNumber()
+
+	var document interface{}
+	err := decoder.Decode(&document)
+
+	return document, err
+}
+
+func walkJSON(node interface{}, path []interface{}, visit func([]interface{}, string)) {
+	switch node := node.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(node))
+		for key := range node {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			walkJSON(node[key], appendStep(path, key), visit)
+		}
+	case []interface{}:
+		for i, item := range node {
+			walkJSON(item, appendStep(path, i), visit)
+		}
+	case string:
+		visit(path, node)
+	case json.Number:
+		visit(path, node.String())
+	case bool:
+		visit(path, strconv.FormatBool(node))
+	}
+}
+
+func setJSON(node interface{}, path []interface{}, payload string) (interface{}, error) {
+	if len(path) == 0 {
+		return payload, nil
+	}
+
+	switch step := path[0].(type) {
+	case string:
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("json: %q is not an object", step)
+		}
+
+		child, err := setJSON(object[step], path[1:], payload)
+		if err != nil {
+			return nil, err
+		}
+		object[step] = child
+	case int:
+		array, ok := node.([]interface{})
+		if !ok || step >= len(array) {
+			return nil, fmt.Errorf("json: index %d out of range", step)
+		}
+
+		child, err := setJSON(array[step], path[1:], payload)
+		if err != nil {
+			return nil, err
+		}
+		array[step] = child
+	}
+
+	return node, nil
+}
+
+func appendStep(path []interface{}, step interface{}) []interface{} {
+	result := make([]interface{}, len(path), len(path)+1)
+	copy(result, path)
+
+	return append(result, step)
+}
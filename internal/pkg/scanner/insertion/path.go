@@ -0,0 +1,52 @@
+package insertion
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type pathPoint struct {
+	req      models.Request
+	segments []string
+	index    int
+}
+
+func pathPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	segments := strings.Split(req.Path, "/")
+
+	points := make([]interfaces.InsertionPoint, 0, len(segments))
+	for i, segment := range segments {
+		if segment == "" {
+			continue
+		}
+
+		points = append(points, pathPoint{
+			req:      req,
+			segments: segments,
+			index:    i,
+		})
+	}
+
+	return points, nil
+}
+
+func (p pathPoint) Name() string {
+	return "path:" + strconv.Itoa(p.index) + ":" + p.segments[p.index]
+}
+
+func (p pathPoint) Value() string {
+	return p.segments[p.index]
+}
+
+func (p pathPoint) Inject(payload string) (models.Request, error) {
+	segments := append([]string(nil), p.segments...)
+	segments[p.index] = payload
+
+	req := p.req
+	req.Path = strings.Join(segments, "/")
+
+	return req, nil
+}
@@ -0,0 +1,71 @@
+package insertion
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type queryPoint struct {
+	req    models.Request
+	params url.Values
+	key    string
+	index  int
+}
+
+func queryPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	var params url.Values
+	err := json.Unmarshal([]byte(req.Params), &params)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	points := make([]interfaces.InsertionPoint, 0)
+	for _, key := range keys {
+		for i := range params[key] {
+			points = append(points, queryPoint{
+				req:    req,
+				params: params,
+				key:    key,
+				index:  i,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+func (p queryPoint) Name() string {
+	return "query:" + p.key
+}
+
+func (p queryPoint) Value() string {
+	return p.params[p.key][p.index]
+}
+
+func (p queryPoint) Inject(payload string) (models.Request, error) {
+	params := url.Values{}
+	for key, values := range p.params {
+		params[key] = append([]string(nil), values...)
+	}
+	params[p.key][p.index] = payload
+
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	req := p.req
+	req.Params = string(encodedParams)
+
+	return req, nil
+}
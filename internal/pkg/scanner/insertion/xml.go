@@ -0,0 +1,121 @@
+package insertion
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type xmlPoint struct {
+	req   models.Request
+	name  string
+	value string
+	start int64
+	end   int64
+	tag   string
+}
+
+type xmlElement struct {
+	name     string
+	start    int64
+	text     string
+	hasChild bool
+	tag      string
+}
+
+func xmlPoints(req models.Request) ([]interfaces.InsertionPoint, error) {
+	decoder := xml.NewDecoder(strings.NewReader(req.Body))
+	decoder.Strict = false
+
+	points := make([]interfaces.InsertionPoint, 0)
+	stack := make([]*xmlElement, 0)
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasChild = true
+			}
+			element := &xmlElement{
+				name:  token.Name.Local,
+				start: decoder.InputOffset(),
+			}
+
+			// <a/> has no content to replace, Inject expands it to <a>payload</a>
+			if strings.HasSuffix(req.Body[:element.start], "/>") {
+				element.tag = token.Name.Local
+				if token.Name.Space != "" {
+					element.tag = token.Name.Space + ":" + element.tag
+				}
+			}
+
+			stack = append(stack, element)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(token)
+			}
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, nil
+			}
+
+			element := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if element.hasChild {
+				continue
+			}
+
+			names := make([]string, 0, len(stack)+1)
+			for _, parent := range stack {
+				names = append(names, parent.name)
+			}
+
+			points = append(points, xmlPoint{
+				req:   req,
+				name:  strings.Join(append(names, element.name), "/"),
+				value: element.text,
+				start: element.start,
+				end:   offset,
+				tag:   element.tag,
+			})
+		}
+	}
+
+	return points, nil
+}
+
+func (p xmlPoint) Name() string {
+	return "xml:" + p.name
+}
+
+func (p xmlPoint) Value() string {
+	return p.value
+}
+
+func (p xmlPoint) Inject(payload string) (models.Request, error) {
+	var escaped bytes.Buffer
+	err := xml.EscapeText(&escaped, []byte(payload))
+	if err != nil {
+		return models.Request{}, err
+	}
+
+	prefix, suffix := p.req.Body[:p.start], p.req.Body[p.end:]
+	if p.tag != "" {
+		prefix = strings.TrimSuffix(prefix, "/>") + ">"
+		suffix = "</" + p.tag + ">" + suffix
+	}
+
+	return withBody(p.req, prefix+escaped.String()+suffix)
+}
@@ -0,0 +1,25 @@
+package interfaces
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+)
+
+var ErrPayloadNotApplicable = errors.New("payload can't be placed at this insertion point")
+
+type InsertionPoint interface {
+	Name() string
+	Value() string
+	Inject(payload string) (models.Request, error)
+}
+
+type Sender interface {
+	Send(ctx context.Context, req models.Request) (models.ProbeResult, error)
+}
+
+type Check interface {
+	Name() string
+	Run(ctx context.Context, point InsertionPoint, baseline models.ProbeResult, sender Sender) ([]models.Finding, error)
+}
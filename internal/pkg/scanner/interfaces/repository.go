@@ -0,0 +1,15 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	CreateJob(job models.ScanJob) (int, error)
+	UpdateJob(job models.ScanJob) error
+	GetJob(id int) (models.ScanJob, error)
+	GetJobs() ([]models.ScanJob, error)
+	GetUnfinishedJobs() ([]models.ScanJob, error)
+	SaveFinding(jobID int, finding models.Finding) (int, error)
+	GetFinding(id int) (models.Finding, error)
+	GetFindings(filter models.FindingFilter) ([]models.Finding, error)
+	GetJobFindings(jobID int) ([]models.Finding, error)
+}
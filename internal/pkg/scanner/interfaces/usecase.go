@@ -0,0 +1,13 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	Start() error
+	SubmitJob(requestID int) (models.ScanJob, error)
+	GetJob(id int) (models.ScanJob, error)
+	GetJobs() ([]models.ScanJob, error)
+	CancelJob(id int) error
+	GetFinding(id int) (models.Finding, error)
+	GetFindings(filter models.FindingFilter) ([]models.Finding, error)
+}
@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type MemoryRepository struct {
+	mu          sync.RWMutex
+	jobs        []models.ScanJob
+	findings    []models.Finding
+	findingKeys map[string]int
+	jobFindings map[int][]int
+}
+
+func NewMemoryRepository() interfaces.Repository {
+	return &MemoryRepository{
+		findingKeys: make(map[string]int),
+		jobFindings: make(map[int][]int),
+	}
+}
+
+func (r *MemoryRepository) CreateJob(job models.ScanJob) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	job.ID = len(r.jobs) + 1
+	job.Findings = nil
+	r.jobs = append(r.jobs, job)
+
+	return job.ID, nil
+}
+
+func (r *MemoryRepository) UpdateJob(job models.ScanJob) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if job.ID < 1 || job.ID > len(r.jobs) {
+		return sql.ErrNoRows
+	}
+
+	stored := job
+	stored.Findings = nil
+	stored.CreatedAt = r.jobs[job.ID-1].CreatedAt
+	r.jobs[job.ID-1] = stored
+
+	return nil
+}
+
+func (r *MemoryRepository) GetJob(id int) (models.ScanJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id < 1 || id > len(r.jobs) {
+		return models.ScanJob{}, sql.ErrNoRows
+	}
+
+	return r.copyJob(r.jobs[id-1]), nil
+}
+
+func (r *MemoryRepository) GetJobs() ([]models.ScanJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make([]models.ScanJob, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		jobs = append(jobs, r.copyJob(job))
+	}
+
+	return jobs, nil
+}
+
+func (r *MemoryRepository) GetUnfinishedJobs() ([]models.ScanJob, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	jobs := make([]models.ScanJob, 0)
+	for _, job := range r.jobs {
+		if !job.Finished() {
+			jobs = append(jobs, r.copyJob(job))
+		}
+	}
+
+	return jobs, nil
+}
+
+func (r *MemoryRepository) SaveFinding(jobID int, finding models.Finding) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if jobID < 1 || jobID > len(r.jobs) {
+		return 0, sql.ErrNoRows
+	}
+
+	id, ok := r.findingKeys[finding.Key()]
+	if ok {
+		stored := &r.findings[id-1]
+		stored.RequestID = finding.RequestID
+		stored.Payload = finding.Payload
+		stored.Evidence = finding.Evidence
+		stored.Severity = finding.Severity
+		stored.Confidence = finding.Confidence
+		stored.LastSeenAt = finding.LastSeenAt
+	} else {
+		id = len(r.findings) + 1
+		finding.ID = id
+		r.findings = append(r.findings, finding)
+		r.findingKeys[finding.Key()] = id
+	}
+
+	for _, linked := range r.jobFindings[jobID] {
+		if linked == id {
+			return id, nil
+		}
+	}
+	r.jobFindings[jobID] = append(r.jobFindings[jobID], id)
+
+	return id, nil
+}
+
+func (r *MemoryRepository) GetFinding(id int) (models.Finding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id < 1 || id > len(r.findings) {
+		return models.Finding{}, sql.ErrNoRows
+	}
+
+	return r.findings[id-1], nil
+}
+
+func (r *MemoryRepository) GetFindings(filter models.FindingFilter) ([]models.Finding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	findings := make([]models.Finding, 0)
+	for _, finding := range r.findings {
+		if filter.Match(finding) {
+			findings = append(findings, finding)
+		}
+	}
+
+	return findings, nil
+}
+
+func (r *MemoryRepository) GetJobFindings(jobID int) ([]models.Finding, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := append([]int{}, r.jobFindings[jobID]...)
+	sort.Ints(ids)
+
+	findings := make([]models.Finding, 0, len(ids))
+	for _, id := range ids {
+		findings = append(findings, r.findings[id-1])
+	}
+
+	return findings, nil
+}
+
+func (r *MemoryRepository) copyJob(job models.ScanJob) models.ScanJob {
+	job.Findings = nil
+	job.Found = len(r.jobFindings[job.ID])
+
+	return job
+}
@@ -0,0 +1,277 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyRepository "github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS scan_jobs (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    progress INT NOT NULL,
+    total INT NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scan_jobs_status_idx ON scan_jobs (status);
+
+CREATE TABLE IF NOT EXISTS findings (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    check_name TEXT NOT NULL,
+    parameter TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    evidence TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    confidence TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_seen_at TIMESTAMPTZ NOT NULL,
+    UNIQUE (endpoint, check_name, parameter)
+);
+
+CREATE TABLE IF NOT EXISTS job_findings (
+    job_id INT NOT NULL REFERENCES scan_jobs (id) ON DELETE CASCADE,
+    finding_id INT NOT NULL REFERENCES findings (id) ON DELETE CASCADE,
+    PRIMARY KEY (job_id, finding_id)
+);
+`
+
+const (
+	jobColumns = `id, request_id, status, progress, total, error,
+		(SELECT COUNT(*) FROM job_findings WHERE job_id = scan_jobs.id), created_at, updated_at`
+	findingColumns = `id, request_id, endpoint, check_name, parameter, payload, evidence,
+		severity, confidence, created_at, last_seen_at`
+)
+
+type ScannerRepository struct {
+	db *sql.DB
+}
+
+func NewScannerRepository(db *sql.DB) interfaces.Repository {
+	return ScannerRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r ScannerRepository) CreateJob(job models.ScanJob) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO scan_jobs (request_id, status, progress, total, error, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		job.RequestID, job.Status, job.Progress, job.Total, job.Error, job.CreatedAt, job.UpdatedAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r ScannerRepository) UpdateJob(job models.ScanJob) error {
+	_, err := r.db.Exec(
+		`UPDATE scan_jobs SET status = $1, progress = $2, total = $3, error = $4, updated_at = $5
+		WHERE id = $6`,
+		job.Status, job.Progress, job.Total, job.Error, job.UpdatedAt, job.ID,
+	)
+
+	return err
+}
+
+func (r ScannerRepository) GetJob(id int) (models.ScanJob, error) {
+	row := r.db.QueryRow(
+		`SELECT `+jobColumns+` FROM scan_jobs
+		WHERE id = $1`,
+		id,
+	)
+
+	return scanJob(row)
+}
+
+func (r ScannerRepository) GetJobs() ([]models.ScanJob, error) {
+	return r.queryJobs(
+		`SELECT ` + jobColumns + ` FROM scan_jobs
+		ORDER BY id`,
+	)
+}
+
+func (r ScannerRepository) GetUnfinishedJobs() ([]models.ScanJob, error) {
+	return r.queryJobs(
+		`SELECT `+jobColumns+` FROM scan_jobs
+		WHERE status IN ($1, $2)
+		ORDER BY id`,
+		models.JobQueued, models.JobRunning,
+	)
+}
+
+func (r ScannerRepository) SaveFinding(jobID int, finding models.Finding) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = tx.QueryRow(
+		`INSERT INTO findings (request_id, endpoint, check_name, parameter, payload, evidence,
+		severity, confidence, created_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (endpoint, check_name, parameter) DO UPDATE SET
+		request_id = excluded.request_id, payload = excluded.payload, evidence = excluded.evidence,
+		severity = excluded.severity, confidence = excluded.confidence, last_seen_at = excluded.last_seen_at
+		RETURNING id`,
+		finding.RequestID, finding.Endpoint, finding.Check, finding.Parameter, finding.Payload, finding.Evidence,
+		finding.Severity, finding.Confidence, finding.CreatedAt, finding.LastSeenAt,
+	).Scan(&id)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO job_findings (job_id, finding_id) VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`,
+		jobID, id,
+	)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r ScannerRepository) GetFinding(id int) (models.Finding, error) {
+	row := r.db.QueryRow(
+		`SELECT `+findingColumns+` FROM findings
+		WHERE id = $1`,
+		id,
+	)
+
+	return scanFinding(row)
+}
+
+func (r ScannerRepository) GetFindings(filter models.FindingFilter) ([]models.Finding, error) {
+	conditions := make([]string, 0)
+	args := make([]interface{}, 0)
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.RequestID != 0 {
+		add("request_id = $%d", filter.RequestID)
+	}
+	if filter.Endpoint != "" {
+		add("LOWER(endpoint) LIKE '%%' || $%d || '%%' ESCAPE '\\'", proxyRepository.EscapeLike(strings.ToLower(filter.Endpoint)))
+	}
+	if filter.Check != "" {
+		add("check_name = $%d", filter.Check)
+	}
+	if filter.Severity != "" {
+		add("severity = $%d", filter.Severity)
+	}
+	if filter.Confidence != "" {
+		add("confidence = $%d", filter.Confidence)
+	}
+
+	query := `SELECT ` + findingColumns + ` FROM findings`
+	if len(conditions) != 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY id`
+
+	return r.queryFindings(query, args...)
+}
+
+func (r ScannerRepository) GetJobFindings(jobID int) ([]models.Finding, error) {
+	return r.queryFindings(
+		`SELECT `+findingColumns+` FROM findings
+		WHERE id IN (SELECT finding_id FROM job_findings WHERE job_id = $1)
+		ORDER BY id`,
+		jobID,
+	)
+}
+
+func (r ScannerRepository) queryJobs(query string, args ...interface{}) ([]models.ScanJob, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]models.ScanJob, 0)
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func (r ScannerRepository) queryFindings(query string, args ...interface{}) ([]models.Finding, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	findings := make([]models.Finding, 0)
+	for rows.Next() {
+		finding, err := scanFinding(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		findings = append(findings, finding)
+	}
+
+	return findings, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanJob(row scanner) (models.ScanJob, error) {
+	var job models.ScanJob
+	err := row.Scan(
+		&job.ID, &job.RequestID, &job.Status, &job.Progress, &job.Total,
+		&job.Error, &job.Found, &job.CreatedAt, &job.UpdatedAt,
+	)
+	if err != nil {
+		return models.ScanJob{}, err
+	}
+
+	return job, nil
+}
+
+func scanFinding(row scanner) (models.Finding, error) {
+	var finding models.Finding
+	err := row.Scan(
+		&finding.ID, &finding.RequestID, &finding.Endpoint, &finding.Check, &finding.Parameter,
+		&finding.Payload, &finding.Evidence, &finding.Severity, &finding.Confidence,
+		&finding.CreatedAt, &finding.LastSeenAt,
+	)
+	if err != nil {
+		return models.Finding{}, err
+	}
+
+	return finding, nil
+}
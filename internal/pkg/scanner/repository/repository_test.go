@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyRepository "github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+func TestFindingsEndpointFilter(t *testing.T) {
+	db, err := sql.Open(proxyRepository.SqliteDriver, filepath.Join(t.TempDir(), "requests.db")+"?_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	requests, err := proxyRepository.NewSqliteRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestID, err := requests.SaveRequest(models.Request{
+		Method: "GET", Scheme: "http", Host: "example.com", Path: "/", Headers: `{}`, Params: `{}`, Proto: "HTTP/1.1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlite, err := NewSqliteRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storages := map[string]interfaces.Repository{
+		"sqlite": sqlite,
+		"memory": NewMemoryRepository(),
+	}
+
+	now := time.Now()
+	endpoints := []string{
+		"GET http://example.com/Users",
+		"GET http://example.com/users_list",
+		"GET http://example.com/usersXlist",
+		"GET http://example.com/discount?rate=50%",
+	}
+
+	for name, storage := range storages {
+		jobID, err := storage.CreateJob(models.ScanJob{RequestID: requestID, Status: models.JobQueued, CreatedAt: now, UpdatedAt: now})
+		if err != nil {
+			t.Fatalf("%s: CreateJob error = %v", name, err)
+		}
+
+		for _, endpoint := range endpoints {
+			_, err := storage.SaveFinding(jobID, models.Finding{
+				RequestID: requestID, Endpoint: endpoint, Check: "xss", Parameter: "q",
+				Severity: models.SeverityHigh, Confidence: models.ConfidenceFirm, CreatedAt: now, LastSeenAt: now,
+			})
+			if err != nil {
+				t.Fatalf("%s: SaveFinding error = %v", name, err)
+			}
+		}
+	}
+
+	tests := []struct {
+		endpoint string
+		want     []int
+	}{
+		{endpoint: "", want: []int{1, 2, 3, 4}},
+		{endpoint: "/users", want: []int{1, 2, 3}},
+		{endpoint: "USERS", want: []int{1, 2, 3}},
+		{endpoint: "users_", want: []int{2}},
+		{endpoint: "50%", want: []int{4}},
+		{endpoint: "%", want: []int{4}},
+		{endpoint: `\`, want: []int{}},
+	}
+
+	for _, tt := range tests {
+		for name, storage := range storages {
+			findings, err := storage.GetFindings(models.FindingFilter{Endpoint: tt.endpoint})
+			if err != nil {
+				t.Fatalf("%s: GetFindings(%q) error = %v", name, tt.endpoint, err)
+			}
+
+			got := make([]int, 0, len(findings))
+			for _, finding := range findings {
+				got = append(got, finding.ID)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: GetFindings(%q) = %v, want %v", name, tt.endpoint, got, tt.want)
+			}
+		}
+	}
+}
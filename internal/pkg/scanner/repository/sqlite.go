@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS scan_jobs (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    status TEXT NOT NULL,
+    progress INTEGER NOT NULL,
+    total INTEGER NOT NULL,
+    error TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS scan_jobs_status_idx ON scan_jobs (status);
+
+CREATE TABLE IF NOT EXISTS findings (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL,
+    check_name TEXT NOT NULL,
+    parameter TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    evidence TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    confidence TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP NOT NULL,
+    UNIQUE (endpoint, check_name, parameter)
+);
+
+CREATE TABLE IF NOT EXISTS job_findings (
+    job_id INTEGER NOT NULL REFERENCES scan_jobs (id) ON DELETE CASCADE,
+    finding_id INTEGER NOT NULL REFERENCES findings (id) ON DELETE CASCADE,
+    PRIMARY KEY (job_id, finding_id)
+);
+`
+
+type SqliteRepository struct {
+	ScannerRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		ScannerRepository: ScannerRepository{
+			db: db,
+		},
+	}, nil
+}
@@ -0,0 +1,64 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+)
+
+type hostLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     map[string]time.Time
+}
+
+func (l *hostLimiter) wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+	l.next[host] = at.Add(l.interval)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type RateLimitedSender struct {
+	sender  interfaces.Sender
+	limiter *hostLimiter
+}
+
+func NewRateLimitedSender(sender interfaces.Sender, requestsPerSecond float64) interfaces.Sender {
+	if requestsPerSecond <= 0 {
+		return sender
+	}
+
+	return RateLimitedSender{
+		sender: sender,
+		limiter: &hostLimiter{
+			interval: time.Duration(float64(time.Second) / requestsPerSecond),
+			next:     make(map[string]time.Time),
+		},
+	}
+}
+
+func (s RateLimitedSender) Send(ctx context.Context, req models.Request) (models.ProbeResult, error) {
+	if err := s.limiter.wait(ctx, req.Host); err != nil {
+		return models.ProbeResult{}, err
+	}
+
+	return s.sender.Send(ctx, req)
+}
@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+const maxProbeBody = 1 << 20
+
+type HTTPSender struct {
+	client *http.Client
+}
+
+func NewHTTPSender(dialer *upstream.Dialer, timeout time.Duration) interfaces.Sender {
+	return HTTPSender{
+		client: &http.Client{
+			Transport: dialer.Transport(),
+			Timeout:   timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (s HTTPSender) Send(ctx context.Context, req models.Request) (models.ProbeResult, error) {
+	httpRequest, err := models.ConvertToHttpRequest(req)
+	if err != nil {
+		return models.ProbeResult{}, err
+	}
+
+	start := time.Now()
+	resp, err := s.client.Do(httpRequest.WithContext(ctx))
+	if err != nil {
+		return models.ProbeResult{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxProbeBody))
+	if err != nil {
+		return models.ProbeResult{}, err
+	}
+
+	return models.ProbeResult{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       string(body),
+		Duration:   time.Since(start),
+	}, nil
+}
@@ -0,0 +1,280 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/insertion"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	scopeInterfaces "github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+)
+
+var (
+	ErrQueueFull   = errors.New("scan queue is full")
+	ErrJobFinished = errors.New("scan job already finished")
+)
+
+type ScannerUsecase struct {
+	checks            []interfaces.Check
+	sender            interfaces.Sender
+	scannerRepository interfaces.Repository
+	proxyUsecase      proxyInterfaces.Usecase
+	scopeUsecase      scopeInterfaces.Usecase
+	config            config.ScannerConfig
+
+	queue   chan int
+	mu      sync.Mutex
+	running map[int]context.CancelFunc
+}
+
+func NewScannerUsecase(
+	checks []interfaces.Check, sender interfaces.Sender, scannerRepository interfaces.Repository,
+	proxyUsecase proxyInterfaces.Usecase, scopeUsecase scopeInterfaces.Usecase, cfg config.ScannerConfig,
+) interfaces.Usecase {
+	return &ScannerUsecase{
+		checks:            checks,
+		sender:            NewRateLimitedSender(sender, cfg.RateLimit),
+		scannerRepository: scannerRepository,
+		proxyUsecase:      proxyUsecase,
+		scopeUsecase:      scopeUsecase,
+		config:            cfg,
+		queue:             make(chan int, cfg.QueueSize),
+		running:           make(map[int]context.CancelFunc),
+	}
+}
+
+func (u *ScannerUsecase) Start() error {
+	unfinished, err := u.scannerRepository.GetUnfinishedJobs()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < u.config.Workers; i++ {
+		go u.worker()
+	}
+
+	go func() {
+		for _, job := range unfinished {
+			log.Printf("resuming scan job %d", job.ID)
+			job.Status = models.JobQueued
+			job.Progress = 0
+			job.UpdatedAt = time.Now()
+			if err := u.scannerRepository.UpdateJob(job); err != nil {
+				log.Printf("couldn't requeue scan job %d: %v", job.ID, err)
+				continue
+			}
+
+			u.queue <- job.ID
+		}
+	}()
+
+	return nil
+}
+
+func (u *ScannerUsecase) SubmitJob(requestID int) (models.ScanJob, error) {
+	req, err := u.proxyUsecase.GetRequest(requestID)
+	if err != nil {
+		return models.ScanJob{}, err
+	}
+
+	if !u.scopeUsecase.InScope(req) {
+		return models.ScanJob{}, scopeUsecase.ErrOutOfScope
+	}
+
+	now := time.Now()
+	job := models.ScanJob{
+		RequestID: requestID,
+		Status:    models.JobQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	job.ID, err = u.scannerRepository.CreateJob(job)
+	if err != nil {
+		return models.ScanJob{}, err
+	}
+
+	select {
+	case u.queue <- job.ID:
+		return job, nil
+	default:
+		job.Status = models.JobFailed
+		job.Error = ErrQueueFull.Error()
+		job.UpdatedAt = time.Now()
+		if err := u.scannerRepository.UpdateJob(job); err != nil {
+			log.Printf("couldn't update scan job %d: %v", job.ID, err)
+		}
+
+		return job, ErrQueueFull
+	}
+}
+
+func (u *ScannerUsecase) GetJob(id int) (models.ScanJob, error) {
+	job, err := u.scannerRepository.GetJob(id)
+	if err != nil {
+		return models.ScanJob{}, err
+	}
+
+	job.Findings, err = u.scannerRepository.GetJobFindings(id)
+	if err != nil {
+		return models.ScanJob{}, err
+	}
+
+	return job, nil
+}
+
+func (u *ScannerUsecase) GetJobs() ([]models.ScanJob, error) {
+	return u.scannerRepository.GetJobs()
+}
+
+func (u *ScannerUsecase) GetFinding(id int) (models.Finding, error) {
+	return u.scannerRepository.GetFinding(id)
+}
+
+func (u *ScannerUsecase) GetFindings(filter models.FindingFilter) ([]models.Finding, error) {
+	return u.scannerRepository.GetFindings(filter)
+}
+
+func (u *ScannerUsecase) CancelJob(id int) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if cancel, ok := u.running[id]; ok {
+		cancel()
+		return nil
+	}
+
+	job, err := u.scannerRepository.GetJob(id)
+	if err != nil {
+		return err
+	}
+
+	if job.Finished() {
+		return ErrJobFinished
+	}
+
+	job.Status = models.JobCanceled
+	job.UpdatedAt = time.Now()
+
+	return u.scannerRepository.UpdateJob(job)
+}
+
+func (u *ScannerUsecase) worker() {
+	for id := range u.queue {
+		u.runJob(id)
+	}
+}
+
+func (u *ScannerUsecase) runJob(id int) {
+	u.mu.Lock()
+	job, err := u.scannerRepository.GetJob(id)
+	if err != nil || job.Status != models.JobQueued {
+		u.mu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	u.running[id] = cancel
+	job.Status = models.JobRunning
+	job.UpdatedAt = time.Now()
+	err = u.scannerRepository.UpdateJob(job)
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		delete(u.running, id)
+		u.mu.Unlock()
+	}()
+
+	if err != nil {
+		log.Printf("couldn't update scan job %d: %v", id, err)
+		return
+	}
+
+	err = u.scanRequest(ctx, &job)
+	job.UpdatedAt = time.Now()
+
+	switch {
+	case ctx.Err() != nil:
+		job.Status = models.JobCanceled
+	case err != nil:
+		job.Status = models.JobFailed
+		job.Error = err.Error()
+	default:
+		job.Status = models.JobDone
+	}
+
+	if err := u.scannerRepository.UpdateJob(job); err != nil {
+		log.Printf("couldn't update scan job %d: %v", id, err)
+	}
+}
+
+func (u *ScannerUsecase) scanRequest(ctx context.Context, job *models.ScanJob) error {
+	req, err := u.proxyUsecase.GetRequest(job.RequestID)
+	if err != nil {
+		return err
+	}
+
+	// scope may have changed while the job was queued
+	if !u.scopeUsecase.InScope(req) {
+		return scopeUsecase.ErrOutOfScope
+	}
+
+	baseline, err := u.sender.Send(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	points, err := insertion.Points(req)
+	if err != nil {
+		return err
+	}
+
+	job.Total = len(points) * len(u.checks)
+	endpoint := models.EndpointFromRequest(req)
+
+	for _, point := range points {
+		for _, check := range u.checks {
+			found, err := check.Run(ctx, point, baseline, u.sender)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				log.Printf("check %s failed on %s: %v", check.Name(), point.Name(), err)
+			}
+
+			for _, finding := range found {
+				u.saveFinding(job.ID, req, endpoint, finding)
+			}
+
+			job.Progress++
+			job.UpdatedAt = time.Now()
+			if err := u.scannerRepository.UpdateJob(*job); err != nil {
+				log.Printf("couldn't update scan job %d: %v", job.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (u *ScannerUsecase) saveFinding(jobID int, req models.Request, endpoint string, finding models.Finding) {
+	now := time.Now()
+	finding.RequestID = req.ID
+	finding.Endpoint = endpoint
+	finding.CreatedAt = now
+	finding.LastSeenAt = now
+
+	if _, err := u.scannerRepository.SaveFinding(jobID, finding); err != nil {
+		log.Printf("couldn't save finding for scan job %d: %v", jobID, err)
+	}
+}
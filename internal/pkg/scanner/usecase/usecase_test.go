@@ -0,0 +1,246 @@
+package usecase
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyRepository "github.com/aanufriev/httpproxy/internal/pkg/proxy/repository"
+	proxyUsecase "github.com/aanufriev/httpproxy/internal/pkg/proxy/usecase"
+	"github.com/aanufriev/httpproxy/internal/pkg/scanner/interfaces"
+	scannerRepository "github.com/aanufriev/httpproxy/internal/pkg/scanner/repository"
+	scopeRepository "github.com/aanufriev/httpproxy/internal/pkg/scope/repository"
+	scopeUsecase "github.com/aanufriev/httpproxy/internal/pkg/scope/usecase"
+)
+
+type okSender struct{}
+
+func (s okSender) Send(ctx context.Context, req models.Request) (models.ProbeResult, error) {
+	return models.ProbeResult{StatusCode: 200, Header: http.Header{}}, nil
+}
+
+// fakeCheck reports a finding on the query parameter and blocks every run
+// until release is closed or the job is canceled.
+type fakeCheck struct {
+	release chan struct{}
+}
+
+func (c fakeCheck) Name() string {
+	return "fake"
+}
+
+func (c fakeCheck) Run(
+	ctx context.Context, point interfaces.InsertionPoint, baseline models.ProbeResult, sender interfaces.Sender,
+) ([]models.Finding, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.release:
+	}
+
+	if point.Name() != "query:q" {
+		return nil, nil
+	}
+
+	return []models.Finding{{
+		Check: c.Name(), Parameter: point.Name(), Payload: "x", Evidence: "x",
+		Severity: models.SeverityLow, Confidence: models.ConfidenceTentative,
+	}}, nil
+}
+
+type scanner struct {
+	usecase    interfaces.Usecase
+	repository interfaces.Repository
+	requestID  int
+	release    chan struct{}
+}
+
+func newScanner(t *testing.T, cfg config.ScannerConfig, scope ...models.ScopeRule) scanner {
+	t.Helper()
+
+	proxy := proxyUsecase.NewProxyUsecase(proxyRepository.NewMemoryRepository(10))
+	requestID, err := proxy.SaveRequest(models.Request{
+		Method: "GET", Scheme: "http", Host: "example.com", Path: "/", Headers: `{}`, Params: `{"q":["1"]}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scopes := scopeUsecase.NewScopeUsecase(scopeRepository.NewMemoryRepository())
+	for _, rule := range scope {
+		if _, err := scopes.CreateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := scanner{
+		repository: scannerRepository.NewMemoryRepository(),
+		requestID:  requestID,
+		release:    make(chan struct{}),
+	}
+	s.usecase = NewScannerUsecase([]interfaces.Check{fakeCheck{release: s.release}}, okSender{}, s.repository, proxy, scopes, cfg)
+
+	return s
+}
+
+// waitStatus polls the job until it has the status.
+func waitStatus(t *testing.T, u interfaces.Usecase, id int, status string) models.ScanJob {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, err := u.GetJob(id)
+		if err != nil {
+			t.Fatalf("GetJob(%d) error = %v", id, err)
+		}
+		if job.Status == status {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %d is %s, want %s", id, job.Status, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestScanJob(t *testing.T) {
+	s := newScanner(t, config.ScannerConfig{Workers: 1, QueueSize: 1})
+	if err := s.usecase.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := s.usecase.SubmitJob(s.requestID)
+	if err != nil || job.Status != models.JobQueued {
+		t.Fatalf("SubmitJob() = %+v, %v", job, err)
+	}
+
+	waitStatus(t, s.usecase, job.ID, models.JobRunning)
+	close(s.release)
+	job = waitStatus(t, s.usecase, job.ID, models.JobDone)
+
+	// query:q and the three scanned headers
+	if job.Total != 4 || job.Progress != job.Total || len(job.Findings) != 1 ||
+		job.Findings[0].Endpoint != "GET http://example.com/" || job.Findings[0].RequestID != s.requestID {
+		t.Fatalf("finished job = %+v", job)
+	}
+
+	if err := s.usecase.CancelJob(job.ID); err != ErrJobFinished {
+		t.Fatalf("CancelJob() of a finished job error = %v, want %v", err, ErrJobFinished)
+	}
+}
+
+func TestCancelJob(t *testing.T) {
+	s := newScanner(t, config.ScannerConfig{Workers: 1, QueueSize: 1})
+	if err := s.usecase.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	running, err := s.usecase.SubmitJob(s.requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitStatus(t, s.usecase, running.ID, models.JobRunning)
+
+	queued, err := s.usecase.SubmitJob(s.requestID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.usecase.SubmitJob(s.requestID); err != ErrQueueFull {
+		t.Fatalf("SubmitJob() to a full queue error = %v, want %v", err, ErrQueueFull)
+	}
+
+	if err := s.usecase.CancelJob(queued.ID); err != nil {
+		t.Fatalf("CancelJob() of a queued job error = %v", err)
+	}
+	if err := s.usecase.CancelJob(running.ID); err != nil {
+		t.Fatalf("CancelJob() of a running job error = %v", err)
+	}
+
+	waitStatus(t, s.usecase, running.ID, models.JobCanceled)
+	close(s.release)
+
+	// the canceled job must not start once the worker is free
+	time.Sleep(50 * time.Millisecond)
+	if job := waitStatus(t, s.usecase, queued.ID, models.JobCanceled); job.Progress != 0 {
+		t.Fatalf("canceled queued job ran: %+v", job)
+	}
+
+	jobs, err := s.usecase.GetJobs()
+	if err != nil || len(jobs) != 3 || jobs[2].Status != models.JobFailed || jobs[2].Error != ErrQueueFull.Error() {
+		t.Fatalf("GetJobs() = %+v, %v", jobs, err)
+	}
+}
+
+func TestResumeJobs(t *testing.T) {
+	s := newScanner(t, config.ScannerConfig{Workers: 2, QueueSize: 2})
+	close(s.release)
+
+	now := time.Now()
+	ids := make([]int, 0)
+	for _, status := range []string{models.JobQueued, models.JobRunning, models.JobDone} {
+		id, err := s.repository.CreateJob(models.ScanJob{
+			RequestID: s.requestID, Status: status, Progress: 3, Total: 4, CreatedAt: now, UpdatedAt: now,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	if err := s.usecase.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range ids[:2] {
+		if job := waitStatus(t, s.usecase, id, models.JobDone); job.Progress != 4 || len(job.Findings) != 1 {
+			t.Fatalf("resumed job = %+v", job)
+		}
+	}
+
+	if job, err := s.usecase.GetJob(ids[2]); err != nil || job.Progress != 3 || len(job.Findings) != 0 {
+		t.Fatalf("finished job was run again: %+v, %v", job, err)
+	}
+}
+
+func TestSubmitOutOfScope(t *testing.T) {
+	s := newScanner(t, config.ScannerConfig{Workers: 1, QueueSize: 1},
+		models.ScopeRule{Enabled: true, Action: models.ScopeExclude, Host: "example.com"})
+
+	if _, err := s.usecase.SubmitJob(s.requestID); err != scopeUsecase.ErrOutOfScope {
+		t.Fatalf("SubmitJob() error = %v, want %v", err, scopeUsecase.ErrOutOfScope)
+	}
+
+	if jobs, err := s.usecase.GetJobs(); err != nil || len(jobs) != 0 {
+		t.Fatalf("GetJobs() = %+v, %v, want none", jobs, err)
+	}
+}
+
+func TestRateLimitedSender(t *testing.T) {
+	sender := NewRateLimitedSender(okSender{}, 20)
+
+	start := time.Now()
+	for _, host := range []string{"a.test", "b.test", "a.test", "b.test", "a.test"} {
+		if _, err := sender.Send(context.Background(), models.Request{Host: host}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// three requests to a.test are 50ms apart, b.test doesn't add to that
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed >= 190*time.Millisecond {
+		t.Fatalf("five requests took %s, want about 100ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sender.Send(ctx, models.Request{Host: "a.test"}); err != context.Canceled {
+		t.Fatalf("Send() with a canceled context error = %v, want %v", err, context.Canceled)
+	}
+
+	if NewRateLimitedSender(okSender{}, 0) != (okSender{}) {
+		t.Fatal("NewRateLimitedSender() without a limit wrapped the sender")
+	}
+}
@@ -0,0 +1,11 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	CreateRule(rule models.ScopeRule) (int, error)
+	UpdateRule(rule models.ScopeRule) error
+	DeleteRule(id int) error
+	GetRules() ([]models.ScopeRule, error)
+	GetRule(id int) (models.ScopeRule, error)
+}
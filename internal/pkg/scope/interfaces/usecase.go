@@ -0,0 +1,13 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	CreateRule(rule models.ScopeRule) (int, error)
+	UpdateRule(rule models.ScopeRule) error
+	DeleteRule(id int) error
+	GetRules() ([]models.ScopeRule, error)
+	GetRule(id int) (models.ScopeRule, error)
+	InScope(req models.Request) bool
+	InScopeHost(scheme, hostport string) bool
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+)
+
+type MemoryRepository struct {
+	mu     sync.RWMutex
+	rules  map[int]models.ScopeRule
+	nextID int
+}
+
+func NewMemoryRepository() interfaces.Repository {
+	return &MemoryRepository{
+		rules:  make(map[int]models.ScopeRule),
+		nextID: 1,
+	}
+}
+
+func (r *MemoryRepository) CreateRule(rule models.ScopeRule) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rule.ID = r.nextID
+	r.nextID++
+	r.rules[rule.ID] = rule
+
+	return rule.ID, nil
+}
+
+func (r *MemoryRepository) UpdateRule(rule models.ScopeRule) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[rule.ID]; !ok {
+		return sql.ErrNoRows
+	}
+	r.rules[rule.ID] = rule
+
+	return nil
+}
+
+func (r *MemoryRepository) DeleteRule(id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rules[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(r.rules, id)
+
+	return nil
+}
+
+func (r *MemoryRepository) GetRules() ([]models.ScopeRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]models.ScopeRule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].ID < rules[j].ID
+	})
+
+	return rules, nil
+}
+
+func (r *MemoryRepository) GetRule(id int) (models.ScopeRule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rule, ok := r.rules[id]
+	if !ok {
+		return models.ScopeRule{}, sql.ErrNoRows
+	}
+
+	return rule, nil
+}
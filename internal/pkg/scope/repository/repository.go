@@ -0,0 +1,132 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id SERIAL NOT NULL PRIMARY KEY,
+    enabled BOOLEAN NOT NULL,
+    action TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port INT NOT NULL,
+    scheme TEXT NOT NULL,
+    path TEXT NOT NULL
+);
+`
+
+const ruleColumns = `id, enabled, action, host, port, scheme, path`
+
+type ScopeRepository struct {
+	db *sql.DB
+}
+
+func NewScopeRepository(db *sql.DB) interfaces.Repository {
+	return ScopeRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r ScopeRepository) CreateRule(rule models.ScopeRule) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO scope_rules (enabled, action, host, port, scheme, path)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		rule.Enabled, rule.Action, rule.Host, rule.Port, rule.Scheme, rule.Path,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r ScopeRepository) UpdateRule(rule models.ScopeRule) error {
+	result, err := r.db.Exec(
+		`UPDATE scope_rules SET enabled = $1, action = $2, host = $3, port = $4, scheme = $5, path = $6
+		WHERE id = $7`,
+		rule.Enabled, rule.Action, rule.Host, rule.Port, rule.Scheme, rule.Path, rule.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+func (r ScopeRepository) DeleteRule(id int) error {
+	result, err := r.db.Exec(`DELETE FROM scope_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(result)
+}
+
+func (r ScopeRepository) GetRules() ([]models.ScopeRule, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + ruleColumns + ` FROM scope_rules
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]models.ScopeRule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (r ScopeRepository) GetRule(id int) (models.ScopeRule, error) {
+	row := r.db.QueryRow(
+		`SELECT `+ruleColumns+` FROM scope_rules
+		WHERE id = $1`,
+		id,
+	)
+
+	return scanRule(row)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRule(row scanner) (models.ScopeRule, error) {
+	var rule models.ScopeRule
+	err := row.Scan(&rule.ID, &rule.Enabled, &rule.Action, &rule.Host, &rule.Port, &rule.Scheme, &rule.Path)
+	if err != nil {
+		return models.ScopeRule{}, err
+	}
+
+	return rule, nil
+}
+
+func checkAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
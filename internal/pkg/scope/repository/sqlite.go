@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    enabled BOOLEAN NOT NULL,
+    action TEXT NOT NULL,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    scheme TEXT NOT NULL,
+    path TEXT NOT NULL
+);
+`
+
+type SqliteRepository struct {
+	ScopeRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		ScopeRepository: ScopeRepository{
+			db: db,
+		},
+	}, nil
+}
@@ -0,0 +1,155 @@
+package usecase
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scope/interfaces"
+)
+
+var ErrOutOfScope = errors.New("request is out of scope")
+
+type compiledRule struct {
+	rule models.ScopeRule
+	path *regexp.Regexp
+}
+
+type ScopeUsecase struct {
+	scopeRepository interfaces.Repository
+
+	mu     sync.RWMutex
+	cache  []compiledRule
+	loaded bool
+}
+
+func NewScopeUsecase(scopeRepository interfaces.Repository) interfaces.Usecase {
+	return &ScopeUsecase{
+		scopeRepository: scopeRepository,
+	}
+}
+
+func (u *ScopeUsecase) CreateRule(rule models.ScopeRule) (int, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	defer u.invalidate()
+	return u.scopeRepository.CreateRule(rule)
+}
+
+func (u *ScopeUsecase) UpdateRule(rule models.ScopeRule) error {
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+
+	defer u.invalidate()
+	return u.scopeRepository.UpdateRule(rule)
+}
+
+func (u *ScopeUsecase) DeleteRule(id int) error {
+	defer u.invalidate()
+	return u.scopeRepository.DeleteRule(id)
+}
+
+func (u *ScopeUsecase) GetRules() ([]models.ScopeRule, error) {
+	return u.scopeRepository.GetRules()
+}
+
+func (u *ScopeUsecase) GetRule(id int) (models.ScopeRule, error) {
+	return u.scopeRepository.GetRule(id)
+}
+
+func (u *ScopeUsecase) InScope(req models.Request) bool {
+	return u.inScope(models.NewScopeTarget(req.Scheme, req.Host, req.Path), false)
+}
+
+// InScopeHost reports whether any request to the host can be in scope, so
+// its tunnel has to be decrypted.
+func (u *ScopeUsecase) InScopeHost(scheme, hostport string) bool {
+	return u.inScope(models.NewScopeTarget(scheme, hostport, ""), true)
+}
+
+// inScope excludes targets matching an exclude rule and, once there are
+// include rules, everything that matches none of them. Without rules
+// everything is in scope.
+func (u *ScopeUsecase) inScope(target models.ScopeTarget, hostOnly bool) bool {
+	rules, err := u.rules()
+	if err != nil {
+		log.Printf("couldn't load scope rules: %v", err)
+		return true
+	}
+
+	var hasInclude, included bool
+	for _, rule := range rules {
+		if !rule.rule.MatchesHost(target) {
+			if rule.rule.Action == models.ScopeInclude {
+				hasInclude = true
+			}
+			continue
+		}
+
+		switch rule.rule.Action {
+		case models.ScopeInclude:
+			hasInclude = true
+			if hostOnly || rule.path == nil || rule.path.MatchString(target.Path) {
+				included = true
+			}
+		case models.ScopeExclude:
+			if rule.path == nil {
+				return false
+			}
+			if !hostOnly && rule.path.MatchString(target.Path) {
+				return false
+			}
+		}
+	}
+
+	return !hasInclude || included
+}
+
+func (u *ScopeUsecase) rules() ([]compiledRule, error) {
+	u.mu.RLock()
+	if u.loaded {
+		defer u.mu.RUnlock()
+		return u.cache, nil
+	}
+	u.mu.RUnlock()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	rules, err := u.scopeRepository.GetRules()
+	if err != nil {
+		return nil, err
+	}
+
+	u.cache = make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		compiled := compiledRule{rule: rule}
+		if rule.Path != "" {
+			compiled.path, err = regexp.Compile(rule.Path)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		u.cache = append(u.cache, compiled)
+	}
+	u.loaded = true
+
+	return u.cache, nil
+}
+
+func (u *ScopeUsecase) invalidate() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.loaded = false
+}
@@ -0,0 +1,179 @@
+package usecase
+
+import (
+	"testing"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/scope/repository"
+)
+
+func TestInScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []models.ScopeRule
+		disabled bool
+		request  models.Request
+		in       bool
+	}{
+		{name: "no rules", request: models.Request{Scheme: "http", Host: "example.com", Path: "/"}, in: true},
+		{
+			name:    "included host glob",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "*.example.com"}},
+			request: models.Request{Scheme: "https", Host: "API.example.com.", Path: "/"},
+			in:      true,
+		},
+		{
+			name:    "outside the includes",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "*.example.com"}},
+			request: models.Request{Scheme: "https", Host: "example.com", Path: "/"},
+		},
+		{
+			name: "excluded telemetry",
+			rules: []models.ScopeRule{
+				{Action: models.ScopeInclude, Host: "*"},
+				{Action: models.ScopeExclude, Host: "telemetry.*"},
+			},
+			request: models.Request{Scheme: "https", Host: "telemetry.example.com", Path: "/collect"},
+		},
+		{
+			name:    "default port",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "example.com", Port: 443}},
+			request: models.Request{Scheme: "https", Host: "example.com", Path: "/"},
+			in:      true,
+		},
+		{
+			name:    "other port",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "example.com", Port: 443}},
+			request: models.Request{Scheme: "https", Host: "example.com:8443", Path: "/"},
+		},
+		{
+			name:    "other scheme",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "example.com", Scheme: "https"}},
+			request: models.Request{Scheme: "http", Host: "example.com", Path: "/"},
+		},
+		{
+			name:    "included path",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "example.com", Path: "^/api/"}},
+			request: models.Request{Scheme: "https", Host: "example.com", Path: "/api/users"},
+			in:      true,
+		},
+		{
+			name:    "path outside the include",
+			rules:   []models.ScopeRule{{Action: models.ScopeInclude, Host: "example.com", Path: "^/api/"}},
+			request: models.Request{Scheme: "https", Host: "example.com", Path: "/static/app.js"},
+		},
+		{
+			name:    "excluded path",
+			rules:   []models.ScopeRule{{Action: models.ScopeExclude, Host: "*", Path: `\.(js|css|png)$`}},
+			request: models.Request{Scheme: "https", Host: "example.com", Path: "/static/app.js"},
+		},
+		{
+			name:     "disabled rule",
+			rules:    []models.ScopeRule{{Action: models.ScopeExclude, Host: "*"}},
+			disabled: true,
+			request:  models.Request{Scheme: "https", Host: "example.com", Path: "/"},
+			in:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := NewScopeUsecase(repository.NewMemoryRepository())
+			for _, rule := range tt.rules {
+				rule.Enabled = !tt.disabled
+				if _, err := u.CreateRule(rule); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if in := u.InScope(tt.request); in != tt.in {
+				t.Fatalf("InScope(%+v) = %v, want %v", tt.request, in, tt.in)
+			}
+		})
+	}
+}
+
+func TestInScopeHost(t *testing.T) {
+	u := NewScopeUsecase(repository.NewMemoryRepository())
+	rules := []models.ScopeRule{
+		{Enabled: true, Action: models.ScopeInclude, Host: "*.example.com", Path: "^/api/"},
+		{Enabled: true, Action: models.ScopeExclude, Host: "cdn.example.com"},
+		{Enabled: true, Action: models.ScopeExclude, Host: "www.example.com", Path: `\.js$`},
+	}
+	for _, rule := range rules {
+		if _, err := u.CreateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		hostport string
+		in       bool
+	}{
+		// a path rule can match some requests, so the tunnel is decrypted
+		{hostport: "api.example.com:443", in: true},
+		{hostport: "www.example.com:443", in: true},
+		{hostport: "cdn.example.com:443"},
+		{hostport: "example.org:443"},
+	}
+
+	for _, tt := range tests {
+		if in := u.InScopeHost("https", tt.hostport); in != tt.in {
+			t.Errorf("InScopeHost(%s) = %v, want %v", tt.hostport, in, tt.in)
+		}
+	}
+}
+
+func TestScopeChangesAtRuntime(t *testing.T) {
+	u := NewScopeUsecase(repository.NewMemoryRepository())
+	req := models.Request{Scheme: "https", Host: "example.com", Path: "/"}
+
+	if !u.InScope(req) {
+		t.Fatal("InScope() without rules = false")
+	}
+
+	id, err := u.CreateRule(models.ScopeRule{Enabled: true, Action: models.ScopeExclude, Host: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.InScope(req) {
+		t.Fatal("InScope() after adding an exclude rule = true")
+	}
+
+	rule, err := u.GetRule(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule.Enabled = false
+	if err := u.UpdateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if !u.InScope(req) {
+		t.Fatal("InScope() after disabling the rule = false")
+	}
+
+	rule.Enabled = true
+	if err := u.UpdateRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.DeleteRule(id); err != nil {
+		t.Fatal(err)
+	}
+	if !u.InScope(req) {
+		t.Fatal("InScope() after deleting the rule = false")
+	}
+
+	invalid := []models.ScopeRule{
+		{Action: "allow", Host: "*"},
+		{Action: models.ScopeInclude},
+		{Action: models.ScopeInclude, Host: "[a"},
+		{Action: models.ScopeInclude, Host: "*", Port: 70000},
+		{Action: models.ScopeInclude, Host: "*", Scheme: "ftp"},
+		{Action: models.ScopeInclude, Host: "*", Path: "("},
+	}
+	for _, rule := range invalid {
+		if _, err := u.CreateRule(rule); err == nil {
+			t.Errorf("CreateRule(%+v) error = nil", rule)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	SaveTunnel(tunnel models.Tunnel) (int, error)
+	GetTunnels() ([]models.Tunnel, error)
+	GetTunnel(id int) (models.Tunnel, error)
+}
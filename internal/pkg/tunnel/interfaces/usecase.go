@@ -0,0 +1,11 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	SaveTunnel(tunnel models.Tunnel) (int, error)
+	GetTunnels() ([]models.Tunnel, error)
+	GetTunnel(id int) (models.Tunnel, error)
+	Passthrough(hostport string) (string, bool)
+	HandshakeFailed(hostport string)
+}
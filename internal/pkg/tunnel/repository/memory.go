@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+)
+
+// MemoryRepository is a ring buffer of the last capacity tunnels.
+type MemoryRepository struct {
+	mu      sync.RWMutex
+	tunnels []models.Tunnel
+	first   int
+	count   int
+	nextID  int
+}
+
+func NewMemoryRepository(capacity int) interfaces.Repository {
+	return &MemoryRepository{
+		tunnels: make([]models.Tunnel, capacity),
+		nextID:  1,
+	}
+}
+
+func (r *MemoryRepository) SaveTunnel(tunnel models.Tunnel) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tunnel.ID = r.nextID
+	r.nextID++
+
+	if r.count == len(r.tunnels) {
+		r.tunnels[r.first] = tunnel
+		r.first = (r.first + 1) % len(r.tunnels)
+	} else {
+		r.tunnels[(r.first+r.count)%len(r.tunnels)] = tunnel
+		r.count++
+	}
+
+	return tunnel.ID, nil
+}
+
+func (r *MemoryRepository) GetTunnels() ([]models.Tunnel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tunnels := make([]models.Tunnel, 0, r.count)
+	for i := 0; i < r.count; i++ {
+		tunnels = append(tunnels, r.tunnels[(r.first+i)%len(r.tunnels)])
+	}
+
+	return tunnels, nil
+}
+
+func (r *MemoryRepository) GetTunnel(id int) (models.Tunnel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.count == 0 {
+		return models.Tunnel{}, sql.ErrNoRows
+	}
+
+	offset := id - r.tunnels[r.first].ID
+	if offset < 0 || offset >= r.count {
+		return models.Tunnel{}, sql.ErrNoRows
+	}
+
+	return r.tunnels[(r.first+offset)%len(r.tunnels)], nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS tunnels (
+    id SERIAL NOT NULL PRIMARY KEY,
+    host TEXT NOT NULL,
+    port INT NOT NULL,
+    sni TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    username TEXT NOT NULL,
+    bytes_sent BIGINT NOT NULL,
+    bytes_received BIGINT NOT NULL,
+    duration BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+`
+
+const tunnelColumns = `id, host, port, sni, reason, username, bytes_sent, bytes_received, duration, created_at`
+
+type TunnelRepository struct {
+	db *sql.DB
+}
+
+func NewTunnelRepository(db *sql.DB) interfaces.Repository {
+	return TunnelRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r TunnelRepository) SaveTunnel(tunnel models.Tunnel) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO tunnels (host, port, sni, reason, username, bytes_sent, bytes_received, duration, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		tunnel.Host, tunnel.Port, tunnel.SNI, tunnel.Reason, tunnel.User,
+		tunnel.BytesSent, tunnel.BytesReceived, tunnel.Duration, tunnel.CreatedAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r TunnelRepository) GetTunnels() ([]models.Tunnel, error) {
+	rows, err := r.db.Query(
+		`SELECT ` + tunnelColumns + ` FROM tunnels
+		ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tunnels := make([]models.Tunnel, 0)
+	for rows.Next() {
+		tunnel, err := scanTunnel(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		tunnels = append(tunnels, tunnel)
+	}
+
+	return tunnels, rows.Err()
+}
+
+func (r TunnelRepository) GetTunnel(id int) (models.Tunnel, error) {
+	row := r.db.QueryRow(
+		`SELECT `+tunnelColumns+` FROM tunnels
+		WHERE id = $1`,
+		id,
+	)
+
+	return scanTunnel(row)
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTunnel(row scanner) (models.Tunnel, error) {
+	var tunnel models.Tunnel
+	err := row.Scan(
+		&tunnel.ID, &tunnel.Host, &tunnel.Port, &tunnel.SNI, &tunnel.Reason, &tunnel.User,
+		&tunnel.BytesSent, &tunnel.BytesReceived, &tunnel.Duration, &tunnel.CreatedAt,
+	)
+	if err != nil {
+		return models.Tunnel{}, err
+	}
+
+	return tunnel, nil
+}
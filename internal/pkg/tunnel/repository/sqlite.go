@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tunnels (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    host TEXT NOT NULL,
+    port INTEGER NOT NULL,
+    sni TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    username TEXT NOT NULL,
+    bytes_sent INTEGER NOT NULL,
+    bytes_received INTEGER NOT NULL,
+    duration INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+`
+
+type SqliteRepository struct {
+	TunnelRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		TunnelRepository: TunnelRepository{
+			db: db,
+		},
+	}, nil
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"log"
+	"net"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/interfaces"
+)
+
+type TunnelUsecase struct {
+	tunnelRepository interfaces.Repository
+	hosts            []string
+	auto             bool
+	autoTTL          time.Duration
+
+	mu     sync.Mutex
+	failed map[string]time.Time
+}
+
+// NewTunnelUsecase passes through tunnels to hosts matching the glob
+// patterns and, when auto is set, tunnels to targets whose client rejected
+// the generated certificate during the last autoTTL.
+func NewTunnelUsecase(
+	tunnelRepository interfaces.Repository, hosts []string, auto bool, autoTTL time.Duration,
+) interfaces.Usecase {
+	patterns := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		patterns = append(patterns, strings.ToLower(host))
+	}
+
+	return &TunnelUsecase{
+		tunnelRepository: tunnelRepository,
+		hosts:            patterns,
+		auto:             auto,
+		autoTTL:          autoTTL,
+		failed:           make(map[string]time.Time),
+	}
+}
+
+func (u *TunnelUsecase) SaveTunnel(tunnel models.Tunnel) (int, error) {
+	return u.tunnelRepository.SaveTunnel(tunnel)
+}
+
+func (u *TunnelUsecase) GetTunnels() ([]models.Tunnel, error) {
+	return u.tunnelRepository.GetTunnels()
+}
+
+func (u *TunnelUsecase) GetTunnel(id int) (models.Tunnel, error) {
+	return u.tunnelRepository.GetTunnel(id)
+}
+
+// Passthrough reports whether the tunnel to hostport has to be relayed
+// without decryption and why.
+func (u *TunnelUsecase) Passthrough(hostport string) (string, bool) {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, pattern := range u.hosts {
+		if matched, _ := path.Match(pattern, host); matched {
+			return models.PassthroughHost, true
+		}
+	}
+
+	if !u.auto {
+		return "", false
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := strings.ToLower(hostport)
+	until, ok := u.failed[key]
+	if !ok {
+		return "", false
+	}
+	if time.Now().After(until) {
+		delete(u.failed, key)
+		return "", false
+	}
+
+	return models.PassthroughHandshake, true
+}
+
+// HandshakeFailed remembers that the client refused the generated
+// certificate for hostport, so its next tunnels are passed through.
+func (u *TunnelUsecase) HandshakeFailed(hostport string) {
+	if !u.auto {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	key := strings.ToLower(hostport)
+	if _, ok := u.failed[key]; !ok {
+		log.Printf("client rejected certificate for %s, passing it through for %s", hostport, u.autoTTL)
+	}
+	u.failed[key] = time.Now().Add(u.autoTTL)
+}
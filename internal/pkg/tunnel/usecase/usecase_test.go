@@ -0,0 +1,50 @@
+package usecase
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/tunnel/repository"
+)
+
+func TestPassthrough(t *testing.T) {
+	u := NewTunnelUsecase(repository.NewMemoryRepository(10), []string{"*.Bank.example", "pinned.example"}, true, 50*time.Millisecond)
+
+	tests := []struct {
+		hostport string
+		reason   string
+	}{
+		{hostport: "online.bank.example:443", reason: models.PassthroughHost},
+		{hostport: "PINNED.example.:443", reason: models.PassthroughHost},
+		{hostport: "pinned.example", reason: models.PassthroughHost},
+		{hostport: "bank.example:443"},
+		{hostport: "example.com:443"},
+	}
+
+	for _, tt := range tests {
+		reason, ok := u.Passthrough(tt.hostport)
+		if reason != tt.reason || ok != (tt.reason != "") {
+			t.Errorf("Passthrough(%s) = %q, %v, want %q", tt.hostport, reason, ok, tt.reason)
+		}
+	}
+
+	u.HandshakeFailed("Example.com:443")
+	if reason, ok := u.Passthrough("example.com:443"); !ok || reason != models.PassthroughHandshake {
+		t.Fatalf("Passthrough() after a failed handshake = %q, %v", reason, ok)
+	}
+	if _, ok := u.Passthrough("example.com:8443"); ok {
+		t.Fatal("Passthrough() of another port after a failed handshake = true")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := u.Passthrough("example.com:443"); ok {
+		t.Fatal("Passthrough() after the ttl = true")
+	}
+
+	manual := NewTunnelUsecase(repository.NewMemoryRepository(10), nil, false, time.Minute)
+	manual.HandshakeFailed("example.com:443")
+	if _, ok := manual.Passthrough("example.com:443"); ok {
+		t.Fatal("Passthrough() without auto after a failed handshake = true")
+	}
+}
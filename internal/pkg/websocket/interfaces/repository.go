@@ -0,0 +1,10 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Repository interface {
+	SaveMessage(msg models.WebSocketMessage) (int, error)
+	GetMessage(id int) (models.WebSocketMessage, error)
+	GetMessages(requestID int) ([]models.WebSocketMessage, error)
+	GetConnections() ([]models.WebSocketConnection, error)
+}
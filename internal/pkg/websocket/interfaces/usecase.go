@@ -0,0 +1,11 @@
+package interfaces
+
+import "github.com/aanufriev/httpproxy/internal/pkg/models"
+
+type Usecase interface {
+	SaveMessage(msg models.WebSocketMessage) (int, error)
+	GetMessage(id int) (models.WebSocketMessage, error)
+	GetConnection(requestID int) (models.WebSocketConnection, error)
+	GetConnections() ([]models.WebSocketConnection, error)
+	Resend(msg models.WebSocketMessage) (models.WebSocketConnection, error)
+}
@@ -0,0 +1,157 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	OpContinuation = 0
+	OpText         = 1
+	OpBinary       = 2
+	OpClose        = 8
+	OpPing         = 9
+	OpPong         = 10
+
+	MaxPayloadSize = 64 << 20
+)
+
+var ErrFrameTooLarge = errors.New("websocket frame is too large")
+
+type Frame struct {
+	Fin     bool
+	Rsv     byte
+	Opcode  int
+	Masked  bool
+	Mask    [4]byte
+	Payload []byte
+}
+
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") &&
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContains(header http.Header, key, token string) bool {
+	for _, value := range header.Values(key) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func ReadFrame(r io.Reader) (Frame, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return Frame{}, err
+	}
+
+	frame := Frame{
+		Fin:    head[0]&0x80 != 0,
+		Rsv:    head[0] & 0x70,
+		Opcode: int(head[0] & 0x0f),
+		Masked: head[1]&0x80 != 0,
+	}
+
+	length := uint64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return Frame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return Frame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > MaxPayloadSize {
+		return Frame{}, ErrFrameTooLarge
+	}
+
+	if frame.Masked {
+		if _, err := io.ReadFull(r, frame.Mask[:]); err != nil {
+			return Frame{}, err
+		}
+	}
+
+	frame.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.Payload); err != nil {
+		return Frame{}, err
+	}
+
+	if frame.Masked {
+		applyMask(frame.Payload, frame.Mask)
+	}
+
+	return frame, nil
+}
+
+func WriteFrame(w io.Writer, frame Frame) error {
+	head := make([]byte, 2, 14)
+	head[0] = frame.Rsv | byte(frame.Opcode&0x0f)
+	if frame.Fin {
+		head[0] |= 0x80
+	}
+
+	length := len(frame.Payload)
+	switch {
+	case length < 126:
+		head[1] = byte(length)
+	case length <= 0xffff:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(length))
+	default:
+		head[1] = 127
+		head = append(head, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(head[2:], uint64(length))
+	}
+
+	payload := frame.Payload
+	if frame.Masked {
+		head[1] |= 0x80
+		head = append(head, frame.Mask[:]...)
+
+		payload = append([]byte(nil), frame.Payload...)
+		applyMask(payload, frame.Mask)
+	}
+
+	_, err := w.Write(append(head, payload...))
+	return err
+}
+
+func NewMessage(opcode int, payload []byte, masked bool) (Frame, error) {
+	frame := Frame{
+		Fin:     true,
+		Opcode:  opcode,
+		Masked:  masked,
+		Payload: payload,
+	}
+
+	if masked {
+		if _, err := rand.Read(frame.Mask[:]); err != nil {
+			return Frame{}, err
+		}
+	}
+
+	return frame, nil
+}
+
+func applyMask(payload []byte, mask [4]byte) {
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+}
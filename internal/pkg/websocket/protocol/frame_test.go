@@ -0,0 +1,242 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Frame
+		err  error
+	}{
+		{
+			name: "text",
+			data: []byte{0x81, 0x02, 'h', 'i'},
+			want: Frame{Fin: true, Opcode: OpText, Payload: []byte("hi")},
+		},
+		{
+			name: "empty fragment",
+			data: []byte{0x02, 0x00},
+			want: Frame{Opcode: OpBinary, Payload: []byte{}},
+		},
+		{
+			name: "masked",
+			data: []byte{0x81, 0x82, 1, 2, 3, 4, 'h' ^ 1, 'i' ^ 2},
+			want: Frame{Fin: true, Opcode: OpText, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Payload: []byte("hi")},
+		},
+		{
+			name: "rsv bits",
+			data: []byte{0xc1, 0x00},
+			want: Frame{Fin: true, Rsv: 0x40, Opcode: OpText, Payload: []byte{}},
+		},
+		{
+			name: "16-bit length",
+			data: append([]byte{0x82, 126, 0x00, 0x7e}, make([]byte, 126)...),
+			want: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 126)},
+		},
+		{
+			name: "64-bit length",
+			data: append([]byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00}, make([]byte, 0x10000)...),
+			want: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 0x10000)},
+		},
+		{
+			name: "no data",
+			data: []byte{},
+			err:  io.EOF,
+		},
+		{
+			name: "truncated head",
+			data: []byte{0x81},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "truncated 16-bit length",
+			data: []byte{0x81, 126, 0x00},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "truncated 64-bit length",
+			data: []byte{0x81, 127, 0, 0, 0, 0},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "truncated mask",
+			data: []byte{0x81, 0x81, 1, 2},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "truncated payload",
+			data: []byte{0x81, 0x05, 'h', 'i'},
+			err:  io.ErrUnexpectedEOF,
+		},
+		{
+			name: "missing payload",
+			data: []byte{0x81, 0x05},
+			err:  io.EOF,
+		},
+		{
+			name: "payload over limit",
+			data: []byte{0x82, 127, 0, 0, 0, 0, 0x04, 0x00, 0x00, 0x01},
+			err:  ErrFrameTooLarge,
+		},
+		{
+			name: "length overflowing int",
+			data: []byte{0x82, 127, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			err:  ErrFrameTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadFrame(bytes.NewReader(tt.data))
+			if err != tt.err {
+				t.Fatalf("ReadFrame() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if got.Fin != tt.want.Fin || got.Rsv != tt.want.Rsv || got.Opcode != tt.want.Opcode ||
+				got.Masked != tt.want.Masked || got.Mask != tt.want.Mask || !bytes.Equal(got.Payload, tt.want.Payload) {
+				t.Fatalf("ReadFrame() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		head  []byte
+	}{
+		{
+			name:  "empty close",
+			frame: Frame{Fin: true, Opcode: OpClose},
+			head:  []byte{0x88, 0x00},
+		},
+		{
+			name:  "fragment",
+			frame: Frame{Opcode: OpText, Payload: []byte("a")},
+			head:  []byte{0x01, 0x01},
+		},
+		{
+			name:  "125 bytes",
+			frame: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 125)},
+			head:  []byte{0x82, 125},
+		},
+		{
+			name:  "126 bytes",
+			frame: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 126)},
+			head:  []byte{0x82, 126, 0x00, 0x7e},
+		},
+		{
+			name:  "65535 bytes",
+			frame: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 0xffff)},
+			head:  []byte{0x82, 126, 0xff, 0xff},
+		},
+		{
+			name:  "65536 bytes",
+			frame: Frame{Fin: true, Opcode: OpBinary, Payload: make([]byte, 0x10000)},
+			head:  []byte{0x82, 127, 0, 0, 0, 0, 0, 0x01, 0x00, 0x00},
+		},
+		{
+			name:  "masked",
+			frame: Frame{Fin: true, Opcode: OpText, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Payload: []byte("hi")},
+			head:  []byte{0x81, 0x82, 1, 2, 3, 4},
+		},
+		{
+			name:  "rsv bits",
+			frame: Frame{Fin: true, Rsv: 0x40, Opcode: OpText},
+			head:  []byte{0xc1, 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := append([]byte(nil), tt.frame.Payload...)
+
+			var buf bytes.Buffer
+			if err := WriteFrame(&buf, tt.frame); err != nil {
+				t.Fatalf("WriteFrame() error = %v", err)
+			}
+
+			data := buf.Bytes()
+			if !bytes.HasPrefix(data, tt.head) || len(data) != len(tt.head)+len(payload) {
+				t.Fatalf("WriteFrame() head = % x, want % x", data[:len(tt.head)], tt.head)
+			}
+			if !bytes.Equal(tt.frame.Payload, payload) {
+				t.Fatalf("WriteFrame() changed the payload of the frame")
+			}
+
+			got, err := ReadFrame(&buf)
+			if err != nil {
+				t.Fatalf("ReadFrame() error = %v", err)
+			}
+			if got.Fin != tt.frame.Fin || got.Rsv != tt.frame.Rsv || got.Opcode != tt.frame.Opcode ||
+				got.Mask != tt.frame.Mask || !bytes.Equal(got.Payload, payload) {
+				t.Fatalf("ReadFrame() = %+v, want %+v", got, tt.frame)
+			}
+		})
+	}
+}
+
+func TestNewMessage(t *testing.T) {
+	frame, err := NewMessage(OpText, []byte("hi"), false)
+	if err != nil || !frame.Fin || frame.Masked || frame.Mask != [4]byte{} {
+		t.Fatalf("NewMessage(unmasked) = %+v, %v", frame, err)
+	}
+
+	frame, err = NewMessage(OpText, []byte("hi"), true)
+	if err != nil || !frame.Fin || !frame.Masked || string(frame.Payload) != "hi" {
+		t.Fatalf("NewMessage(masked) = %+v, %v", frame, err)
+	}
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		upgrade bool
+	}{
+		{
+			name:    "upgrade",
+			header:  http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}},
+			upgrade: true,
+		},
+		{
+			name:    "token list",
+			header:  http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"WebSocket"}},
+			upgrade: true,
+		},
+		{
+			name:    "repeated header",
+			header:  http.Header{"Connection": {"keep-alive", "upgrade"}, "Upgrade": {"websocket"}},
+			upgrade: true,
+		},
+		{
+			name:   "no connection",
+			header: http.Header{"Upgrade": {"websocket"}},
+		},
+		{
+			name:   "partial token",
+			header: http.Header{"Connection": {"upgraded"}, "Upgrade": {"websocket"}},
+		},
+		{
+			name:   "other protocol",
+			header: http.Header{"Connection": {"upgrade"}, "Upgrade": {"h2c"}},
+		},
+	}
+
+	for _, tt := range tests {
+		r := &http.Request{Header: tt.header}
+		if got := IsUpgrade(r); got != tt.upgrade {
+			t.Errorf("%s: IsUpgrade() = %v, want %v", tt.name, got, tt.upgrade)
+		}
+	}
+}
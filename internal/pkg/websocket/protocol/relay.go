@@ -0,0 +1,68 @@
+package protocol
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrMessageTooLarge = errors.New("websocket message is too large")
+
+type Assembler struct {
+	opcode  int
+	payload []byte
+}
+
+// Add collects fragments of a message, the whole message can't exceed
+// MaxPayloadSize.
+func (a *Assembler) Add(frame Frame) (int, []byte, bool, error) {
+	if frame.Opcode >= OpClose {
+		return frame.Opcode, frame.Payload, true, nil
+	}
+
+	if frame.Opcode != OpContinuation {
+		a.opcode = frame.Opcode
+		a.payload = nil
+	}
+
+	if len(a.payload)+len(frame.Payload) > MaxPayloadSize {
+		a.payload = nil
+		return 0, nil, false, ErrMessageTooLarge
+	}
+	a.payload = append(a.payload, frame.Payload...)
+
+	if !frame.Fin {
+		return 0, nil, false, nil
+	}
+
+	payload := a.payload
+	a.payload = nil
+
+	return a.opcode, payload, true, nil
+}
+
+func Pipe(src io.Reader, dst io.Writer, onMessage func(opcode int, payload []byte)) error {
+	var assembler Assembler
+	for {
+		frame, err := ReadFrame(src)
+		if err != nil {
+			return err
+		}
+
+		opcode, payload, ok, err := assembler.Add(frame)
+		if err != nil {
+			return err
+		}
+
+		if err := WriteFrame(dst, frame); err != nil {
+			return err
+		}
+
+		if ok {
+			onMessage(opcode, payload)
+		}
+
+		if frame.Opcode == OpClose {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package protocol
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestAssembler(t *testing.T) {
+	type result struct {
+		opcode  int
+		payload string
+		ok      bool
+	}
+
+	tests := []struct {
+		name   string
+		frames []Frame
+		want   []result
+	}{
+		{
+			name:   "single frame",
+			frames: []Frame{{Fin: true, Opcode: OpText, Payload: []byte("hi")}},
+			want:   []result{{OpText, "hi", true}},
+		},
+		{
+			name: "fragments",
+			frames: []Frame{
+				{Opcode: OpBinary, Payload: []byte("a")},
+				{Opcode: OpContinuation, Payload: []byte("b")},
+				{Fin: true, Opcode: OpContinuation, Payload: []byte("c")},
+			},
+			want: []result{{}, {}, {OpBinary, "abc", true}},
+		},
+		{
+			name: "control frame between fragments",
+			frames: []Frame{
+				{Opcode: OpText, Payload: []byte("a")},
+				{Fin: true, Opcode: OpPing, Payload: []byte("p")},
+				{Fin: true, Opcode: OpContinuation, Payload: []byte("b")},
+			},
+			want: []result{{}, {OpPing, "p", true}, {OpText, "ab", true}},
+		},
+		{
+			name: "new message drops unfinished one",
+			frames: []Frame{
+				{Opcode: OpText, Payload: []byte("a")},
+				{Fin: true, Opcode: OpBinary, Payload: []byte("b")},
+			},
+			want: []result{{}, {OpBinary, "b", true}},
+		},
+		{
+			name: "empty message",
+			frames: []Frame{
+				{Opcode: OpText},
+				{Fin: true, Opcode: OpContinuation},
+			},
+			want: []result{{}, {OpText, "", true}},
+		},
+		{
+			name: "close",
+			frames: []Frame{
+				{Fin: true, Opcode: OpClose, Payload: []byte{0x03, 0xe8}},
+			},
+			want: []result{{OpClose, "\x03\xe8", true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var assembler Assembler
+			for i, frame := range tt.frames {
+				opcode, payload, ok, err := assembler.Add(frame)
+				if err != nil {
+					t.Fatalf("Add(frame %d) error = %v", i, err)
+				}
+
+				got := result{opcode, string(payload), ok}
+				if got != tt.want[i] {
+					t.Fatalf("Add(frame %d) = %+v, want %+v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestAssemblerMessageTooLarge(t *testing.T) {
+	half := make([]byte, MaxPayloadSize/2)
+
+	tests := []struct {
+		name   string
+		frames []Frame
+		err    error
+	}{
+		{
+			name: "exactly the limit",
+			frames: []Frame{
+				{Opcode: OpBinary, Payload: half},
+				{Fin: true, Opcode: OpContinuation, Payload: half},
+			},
+		},
+		{
+			name: "one byte over the limit",
+			frames: []Frame{
+				{Opcode: OpBinary, Payload: half},
+				{Opcode: OpContinuation, Payload: half},
+				{Fin: true, Opcode: OpContinuation, Payload: []byte{0}},
+			},
+			err: ErrMessageTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				assembler Assembler
+				err       error
+			)
+			for _, frame := range tt.frames {
+				if _, _, _, err = assembler.Add(frame); err != nil {
+					break
+				}
+			}
+
+			if err != tt.err {
+				t.Fatalf("Add() error = %v, want %v", err, tt.err)
+			}
+			if assembler.payload != nil {
+				t.Fatalf("Add() keeps %d bytes after the message", len(assembler.payload))
+			}
+		})
+	}
+}
+
+func TestPipe(t *testing.T) {
+	frames := []Frame{
+		{Opcode: OpText, Payload: []byte("he")},
+		{Fin: true, Opcode: OpPing},
+		{Fin: true, Opcode: OpContinuation, Masked: true, Mask: [4]byte{9, 8, 7, 6}, Payload: []byte("llo")},
+		{Fin: true, Opcode: OpClose},
+		{Fin: true, Opcode: OpText, Payload: []byte("after close")},
+	}
+
+	var src bytes.Buffer
+	for _, frame := range frames {
+		if err := WriteFrame(&src, frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent := append([]byte(nil), src.Bytes()...)
+
+	var (
+		dst      bytes.Buffer
+		messages []string
+	)
+	err := Pipe(&src, &dst, func(opcode int, payload []byte) {
+		messages = append(messages, string(rune('0'+opcode))+":"+string(payload))
+	})
+	if err != nil {
+		t.Fatalf("Pipe() error = %v", err)
+	}
+
+	want := []string{"9:", "1:hello", "8:"}
+	if len(messages) != len(want) {
+		t.Fatalf("Pipe() messages = %q, want %q", messages, want)
+	}
+	for i := range want {
+		if messages[i] != want[i] {
+			t.Fatalf("Pipe() messages = %q, want %q", messages, want)
+		}
+	}
+
+	if !bytes.HasPrefix(sent, dst.Bytes()) || src.Len() == 0 || dst.Len()+src.Len() != len(sent) {
+		t.Fatalf("Pipe() relayed % x, want frames up to close of % x", dst.Bytes(), sent)
+	}
+}
+
+func TestPipeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{name: "eof", data: nil, err: io.EOF},
+		{name: "truncated frame", data: []byte{0x81, 0x05, 'h'}, err: io.ErrUnexpectedEOF},
+		{name: "frame too large", data: []byte{0x82, 127, 0, 0, 0, 0, 0x04, 0x00, 0x00, 0x01}, err: ErrFrameTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dst bytes.Buffer
+			err := Pipe(bytes.NewReader(tt.data), &dst, func(int, []byte) {
+				t.Fatal("Pipe() reported a message")
+			})
+			if err != tt.err {
+				t.Fatalf("Pipe() error = %v, want %v", err, tt.err)
+			}
+			if dst.Len() != 0 {
+				t.Fatalf("Pipe() relayed % x", dst.Bytes())
+			}
+		})
+	}
+}
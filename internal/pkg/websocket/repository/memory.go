@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"sync"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+)
+
+// MemoryRepository is a ring buffer of the last capacity messages.
+type MemoryRepository struct {
+	mu       sync.RWMutex
+	messages []models.WebSocketMessage
+	byConn   map[int][]int
+	first    int
+	count    int
+	nextID   int
+}
+
+func NewMemoryRepository(capacity int) interfaces.Repository {
+	return &MemoryRepository{
+		messages: make([]models.WebSocketMessage, capacity),
+		byConn:   make(map[int][]int),
+		nextID:   1,
+	}
+}
+
+func (r *MemoryRepository) SaveMessage(msg models.WebSocketMessage) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg.ID = r.nextID
+	r.nextID++
+
+	if r.count == len(r.messages) {
+		oldest := r.messages[r.first]
+		if ids := r.byConn[oldest.RequestID][1:]; len(ids) != 0 {
+			r.byConn[oldest.RequestID] = ids
+		} else {
+			delete(r.byConn, oldest.RequestID)
+		}
+
+		r.messages[r.first] = msg
+		r.first = (r.first + 1) % len(r.messages)
+	} else {
+		r.messages[(r.first+r.count)%len(r.messages)] = msg
+		r.count++
+	}
+	r.byConn[msg.RequestID] = append(r.byConn[msg.RequestID], msg.ID)
+
+	return msg.ID, nil
+}
+
+func (r *MemoryRepository) GetMessage(id int) (models.WebSocketMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	msg, ok := r.message(id)
+	if !ok {
+		return models.WebSocketMessage{}, sql.ErrNoRows
+	}
+
+	return msg, nil
+}
+
+func (r *MemoryRepository) GetMessages(requestID int) ([]models.WebSocketMessage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := r.byConn[requestID]
+	messages := make([]models.WebSocketMessage, 0, len(ids))
+	for _, id := range ids {
+		msg, _ := r.message(id)
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+func (r *MemoryRepository) GetConnections() ([]models.WebSocketConnection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	connections := make([]models.WebSocketConnection, 0, len(r.byConn))
+	for requestID, ids := range r.byConn {
+		last, _ := r.message(ids[len(ids)-1])
+		conn := models.WebSocketConnection{
+			MessageCount:  len(ids),
+			LastMessageAt: last.CreatedAt,
+		}
+		conn.Request.ID = requestID
+
+		connections = append(connections, conn)
+	}
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].Request.ID < connections[j].Request.ID
+	})
+
+	return connections, nil
+}
+
+// message returns the stored message with id, ids are sequential so its
+// position follows from the oldest one.
+func (r *MemoryRepository) message(id int) (models.WebSocketMessage, bool) {
+	if r.count == 0 {
+		return models.WebSocketMessage{}, false
+	}
+
+	offset := id - r.messages[r.first].ID
+	if offset < 0 || offset >= r.count {
+		return models.WebSocketMessage{}, false
+	}
+
+	return r.messages[(r.first+offset)%len(r.messages)], true
+}
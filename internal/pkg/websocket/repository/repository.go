@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+)
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS websocket_messages (
+    id SERIAL NOT NULL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    parent_id INT REFERENCES websocket_messages (id) ON DELETE SET NULL,
+    direction TEXT NOT NULL,
+    opcode INT NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS websocket_messages_request_id_idx ON websocket_messages (request_id);
+`
+
+const messageColumns = `id, request_id, COALESCE(parent_id, 0), direction, opcode, payload, created_at`
+
+type WebSocketRepository struct {
+	db *sql.DB
+}
+
+func NewWebSocketRepository(db *sql.DB) interfaces.Repository {
+	return WebSocketRepository{
+		db: db,
+	}
+}
+
+// MigratePostgres creates the tables and indexes missing from a database
+// initialized with an older configs/init.sql.
+func MigratePostgres(db *sql.DB) error {
+	_, err := db.Exec(postgresSchema)
+	return err
+}
+
+func (r WebSocketRepository) SaveMessage(msg models.WebSocketMessage) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		`INSERT INTO websocket_messages (request_id, parent_id, direction, opcode, payload, created_at)
+		VALUES ($1, NULLIF($2, 0), $3, $4, $5, $6) RETURNING id`,
+		msg.RequestID, msg.ParentID, msg.Direction, msg.Opcode, []byte(msg.Payload), msg.CreatedAt,
+	).Scan(&id)
+
+	return id, err
+}
+
+func (r WebSocketRepository) GetMessage(id int) (models.WebSocketMessage, error) {
+	row := r.db.QueryRow(
+		`SELECT `+messageColumns+` FROM websocket_messages
+		WHERE id = $1`,
+		id,
+	)
+
+	return scanMessage(row)
+}
+
+func (r WebSocketRepository) GetMessages(requestID int) ([]models.WebSocketMessage, error) {
+	rows, err := r.db.Query(
+		`SELECT `+messageColumns+` FROM websocket_messages
+		WHERE request_id = $1
+		ORDER BY id`,
+		requestID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]models.WebSocketMessage, 0)
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return messages, rows.Err()
+}
+
+func (r WebSocketRepository) GetConnections() ([]models.WebSocketConnection, error) {
+	rows, err := r.db.Query(
+		`SELECT m.request_id, c.count, m.created_at FROM websocket_messages m
+		JOIN (
+			SELECT request_id, COUNT(*) AS count, MAX(id) AS last_id FROM websocket_messages
+			GROUP BY request_id
+		) c ON m.id = c.last_id
+		ORDER BY m.request_id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	connections := make([]models.WebSocketConnection, 0)
+	for rows.Next() {
+		var conn models.WebSocketConnection
+		err = rows.Scan(&conn.Request.ID, &conn.MessageCount, &conn.LastMessageAt)
+		if err != nil {
+			return nil, err
+		}
+
+		connections = append(connections, conn)
+	}
+
+	return connections, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanMessage(row scanner) (models.WebSocketMessage, error) {
+	var msg models.WebSocketMessage
+	var payload []byte
+	err := row.Scan(
+		&msg.ID, &msg.RequestID, &msg.ParentID, &msg.Direction,
+		&msg.Opcode, &payload, &msg.CreatedAt,
+	)
+	if err != nil {
+		return models.WebSocketMessage{}, err
+	}
+	msg.Payload = string(payload)
+
+	return msg, nil
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS websocket_messages (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    request_id INTEGER NOT NULL REFERENCES requests (id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES websocket_messages (id) ON DELETE SET NULL,
+    direction TEXT NOT NULL,
+    opcode INTEGER NOT NULL,
+    payload BLOB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS websocket_messages_request_id_idx ON websocket_messages (request_id);
+`
+
+type SqliteRepository struct {
+	WebSocketRepository
+}
+
+func NewSqliteRepository(db *sql.DB) (interfaces.Repository, error) {
+	_, err := db.Exec(sqliteSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	return SqliteRepository{
+		WebSocketRepository: WebSocketRepository{
+			db: db,
+		},
+	}, nil
+}
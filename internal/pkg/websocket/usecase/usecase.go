@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aanufriev/httpproxy/internal/pkg/models"
+	proxyInterfaces "github.com/aanufriev/httpproxy/internal/pkg/proxy/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/interfaces"
+	"github.com/aanufriev/httpproxy/internal/pkg/websocket/protocol"
+	"github.com/aanufriev/httpproxy/pkg/upstream"
+)
+
+const ReplyWait = 2 * time.Second
+
+var (
+	ErrNotResendable   = errors.New("only text and binary messages sent by the client can be resent")
+	ErrHandshakeFailed = errors.New("websocket handshake failed")
+)
+
+type WebSocketUsecase struct {
+	repository   interfaces.Repository
+	proxyUsecase proxyInterfaces.Usecase
+	dialer       *upstream.Dialer
+	timeout      time.Duration
+}
+
+func NewWebSocketUsecase(
+	repository interfaces.Repository, proxyUsecase proxyInterfaces.Usecase,
+	dialer *upstream.Dialer, timeout time.Duration,
+) interfaces.Usecase {
+	return WebSocketUsecase{
+		repository:   repository,
+		proxyUsecase: proxyUsecase,
+		dialer:       dialer,
+		timeout:      timeout,
+	}
+}
+
+func (u WebSocketUsecase) SaveMessage(msg models.WebSocketMessage) (int, error) {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	msg.CreatedAt = msg.CreatedAt.UTC()
+
+	return u.repository.SaveMessage(msg)
+}
+
+func (u WebSocketUsecase) GetMessage(id int) (models.WebSocketMessage, error) {
+	return u.repository.GetMessage(id)
+}
+
+func (u WebSocketUsecase) GetConnection(requestID int) (models.WebSocketConnection, error) {
+	req, err := u.proxyUsecase.GetRequest(requestID)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	messages, err := u.repository.GetMessages(requestID)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	conn := models.WebSocketConnection{
+		Request:      req,
+		Messages:     messages,
+		MessageCount: len(messages),
+	}
+	if len(messages) != 0 {
+		conn.LastMessageAt = messages[len(messages)-1].CreatedAt
+	}
+
+	return conn, nil
+}
+
+func (u WebSocketUsecase) GetConnections() ([]models.WebSocketConnection, error) {
+	connections, err := u.repository.GetConnections()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.WebSocketConnection, 0, len(connections))
+	for _, conn := range connections {
+		conn.Request, err = u.proxyUsecase.GetRequest(conn.Request.ID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, conn)
+	}
+
+	return result, nil
+}
+
+func (u WebSocketUsecase) Resend(msg models.WebSocketMessage) (models.WebSocketConnection, error) {
+	if msg.Direction != models.DirectionToServer || !msg.IsData() {
+		return models.WebSocketConnection{}, ErrNotResendable
+	}
+
+	req, err := u.proxyUsecase.GetRequest(msg.RequestID)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	req.ParentID = req.ID
+	req.CreatedAt = time.Time{}
+	req.ID, err = u.proxyUsecase.SaveRequest(req)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	conn, reader, err := u.handshake(req)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+	defer conn.Close()
+
+	frame, err := protocol.NewMessage(msg.Opcode, []byte(msg.Payload), true)
+	if err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	if err := protocol.WriteFrame(conn, frame); err != nil {
+		return models.WebSocketConnection{}, err
+	}
+
+	msg.ID = 0
+	msg.RequestID = req.ID
+	msg.CreatedAt = time.Time{}
+	if _, err := u.SaveMessage(msg); err != nil {
+		log.Printf("couldn't save websocket message: %v", err)
+	}
+
+	u.collectReplies(conn, reader, req.ID)
+
+	return u.GetConnection(req.ID)
+}
+
+func (u WebSocketUsecase) handshake(req models.Request) (net.Conn, *bufio.Reader, error) {
+	httpReq, err := models.ConvertToHttpRequest(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, err
+	}
+	httpReq.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	httpReq.Header.Del("Sec-WebSocket-Extensions")
+
+	start := time.Now()
+	conn, err := u.dial(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(u.timeout)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	if err := httpReq.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &httpReq)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	response, err := models.ConvertFromHttpResponse(resp)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	response.RequestID = req.ID
+	response.Duration = time.Since(start).Milliseconds()
+
+	if err := u.proxyUsecase.SaveResponse(response); err != nil {
+		log.Printf("couldn't save response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrHandshakeFailed, resp.Status)
+	}
+
+	return conn, reader, nil
+}
+
+func (u WebSocketUsecase) dial(req models.Request) (net.Conn, error) {
+	if req.Scheme != "https" {
+		return u.dialer.DialTimeout("tcp", upstream.HostPort(req.Host, "80"), u.timeout)
+	}
+
+	return u.dialer.DialTLS("tcp", upstream.HostPort(req.Host, "443"), new(tls.Config), u.timeout)
+}
+
+func (u WebSocketUsecase) collectReplies(conn net.Conn, reader *bufio.Reader, requestID int) {
+	if err := conn.SetDeadline(time.Now().Add(ReplyWait)); err != nil {
+		return
+	}
+
+	var assembler protocol.Assembler
+	for {
+		frame, err := protocol.ReadFrame(reader)
+		if err != nil {
+			break
+		}
+
+		opcode, payload, ok, err := assembler.Add(frame)
+		if err != nil {
+			break
+		}
+		if !ok {
+			continue
+		}
+
+		_, err = u.SaveMessage(models.WebSocketMessage{
+			RequestID: requestID,
+			Direction: models.DirectionToClient,
+			Opcode:    opcode,
+			Payload:   string(payload),
+		})
+		if err != nil {
+			log.Printf("couldn't save websocket message: %v", err)
+		}
+
+		if opcode == protocol.OpClose {
+			return
+		}
+	}
+
+	closing, err := protocol.NewMessage(protocol.OpClose, []byte{0x03, 0xe8}, true)
+	if err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(u.timeout))
+	_ = protocol.WriteFrame(conn, closing)
+}
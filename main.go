@@ -1,9 +1,40 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
 	"github.com/aanufriev/httpproxy/internal/app"
+	"github.com/aanufriev/httpproxy/internal/pkg/config"
 )
 
 func main() {
-	app.RunProxyServer()
+	cfg, err := config.Load(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if cfg.PrintConfig {
+		out, err := cfg.Marshal()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fmt.Print(string(out))
+		return
+	}
+
+	if len(cfg.Args) != 0 {
+		if err := app.RunCommand(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	app.RunProxyServer(cfg)
 }
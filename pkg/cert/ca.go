@@ -0,0 +1,157 @@
+package cert
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const DefaultCAName = "httpproxy CA"
+
+type CAOptions struct {
+	CommonName string
+	KeyType    string
+	Validity   time.Duration
+}
+
+// GenerateCA creates a self-signed CA that may sign only end-entity
+// certificates.
+func GenerateCA(opts CAOptions) (tls.Certificate, error) {
+	if opts.CommonName == "" {
+		opts.CommonName = DefaultCAName
+	}
+
+	if opts.Validity <= 0 {
+		return tls.Certificate{}, fmt.Errorf("CA validity must be positive, got %s", opts.Validity)
+	}
+
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := generateKey(opts.KeyType)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyID, err := publicKeyID(key.Public())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   opts.CommonName,
+			Organization: []string{"http proxy"},
+		},
+		NotBefore:             now,
+		NotAfter:              now.Add(opts.Validity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLen:            0,
+		MaxPathLenZero:        true,
+		SubjectKeyId:          keyID,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func LoadCA(certFile, keyFile string) (tls.Certificate, error) {
+	ca, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("couldn't load CA: %w", err)
+	}
+
+	if ca.Leaf, err = x509.ParseCertificate(ca.Certificate[0]); err != nil {
+		return tls.Certificate{}, fmt.Errorf("couldn't parse CA certificate: %w", err)
+	}
+
+	if !ca.Leaf.IsCA {
+		return tls.Certificate{}, fmt.Errorf("%s is not a CA certificate", certFile)
+	}
+
+	return ca, nil
+}
+
+func WriteCA(ca tls.Certificate, certFile, keyFile string) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(ca.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return err
+		}
+	}
+
+	err = writeFileAtomic(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(certFile, EncodePEM(ca.Leaf), 0644)
+}
+
+// EnsureCA generates a CA when neither of its files exists and reports
+// whether it did.
+func EnsureCA(certFile, keyFile string, opts CAOptions) (bool, error) {
+	for _, file := range []string{certFile, keyFile} {
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			return false, nil
+		}
+	}
+
+	ca, err := GenerateCA(opts)
+	if err != nil {
+		return false, err
+	}
+
+	return true, WriteCA(ca, certFile, keyFile)
+}
+
+// RotateCA replaces the CA with a new one, the previous files are kept
+// with the .old suffix. Host certificates signed by the previous CA are
+// reissued on their next use.
+func RotateCA(certFile, keyFile string, opts CAOptions) (tls.Certificate, error) {
+	ca, err := GenerateCA(opts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Rename(file, file+".old"); err != nil && !os.IsNotExist(err) {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return ca, WriteCA(ca, certFile, keyFile)
+}
+
+func EncodePEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
@@ -0,0 +1,212 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateCA(t *testing.T) {
+	tests := []struct {
+		name string
+		opts CAOptions
+		cn   string
+		err  string
+	}{
+		{name: "defaults", opts: CAOptions{Validity: time.Hour}, cn: DefaultCAName},
+		{name: "rsa", opts: CAOptions{CommonName: "team CA", KeyType: KeyRSA, Validity: time.Hour}, cn: "team CA"},
+		{name: "zero validity", opts: CAOptions{}, err: "CA validity must be positive, got 0s"},
+		{name: "negative validity", opts: CAOptions{Validity: -time.Hour}, err: "CA validity must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca, err := GenerateCA(tt.opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("GenerateCA() error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GenerateCA() error = %v", err)
+			}
+
+			leaf := ca.Leaf
+			if leaf.Subject.CommonName != tt.cn || !leaf.IsCA || !leaf.BasicConstraintsValid ||
+				leaf.MaxPathLen != 0 || !leaf.MaxPathLenZero || leaf.KeyUsage&x509.KeyUsageCertSign == 0 ||
+				len(leaf.SubjectKeyId) == 0 {
+				t.Fatalf("GenerateCA() = %+v", leaf)
+			}
+
+			if got := leaf.NotAfter.Sub(leaf.NotBefore); got != tt.opts.Validity {
+				t.Fatalf("GenerateCA() validity = %s, want %s", got, tt.opts.Validity)
+			}
+
+			if err := leaf.CheckSignatureFrom(leaf); err != nil {
+				t.Fatalf("GenerateCA() isn't self-signed: %v", err)
+			}
+
+			switch ca.PrivateKey.(type) {
+			case *rsa.PrivateKey:
+				if tt.opts.KeyType != KeyRSA {
+					t.Fatal("GenerateCA() made an RSA key")
+				}
+			case *ecdsa.PrivateKey:
+				if tt.opts.KeyType == KeyRSA {
+					t.Fatal("GenerateCA() made an ECDSA key")
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureCA(t *testing.T) {
+	opts := CAOptions{Validity: time.Hour}
+
+	tests := []struct {
+		name      string
+		existing  []string
+		generated bool
+	}{
+		{name: "no files", generated: true},
+		{name: "certificate only", existing: []string{"ca.crt"}},
+		{name: "key only", existing: []string{"ca.key"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := filepath.Join(dir, "nested", "ca.crt"), filepath.Join(dir, "nested", "ca.key")
+			for _, name := range tt.existing {
+				writeFile(t, filepath.Join(dir, "nested", name), "existing")
+			}
+
+			generated, err := EnsureCA(certFile, keyFile, opts)
+			if err != nil || generated != tt.generated {
+				t.Fatalf("EnsureCA() = %v, %v, want %v", generated, err, tt.generated)
+			}
+
+			if !tt.generated {
+				return
+			}
+
+			if _, err := LoadCA(certFile, keyFile); err != nil {
+				t.Fatalf("LoadCA() of generated CA error = %v", err)
+			}
+			if info, err := os.Stat(keyFile); err != nil || info.Mode().Perm() != 0600 {
+				t.Fatalf("key file mode = %v, %v, want 0600", info.Mode(), err)
+			}
+
+			generated, err = EnsureCA(certFile, keyFile, opts)
+			if err != nil || generated {
+				t.Fatalf("second EnsureCA() = %v, %v, want false", generated, err)
+			}
+		})
+	}
+}
+
+func TestRotateCA(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+
+	first, err := RotateCA(certFile, keyFile, CAOptions{Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("RotateCA() without files error = %v", err)
+	}
+	if _, err := os.Stat(certFile + ".old"); !os.IsNotExist(err) {
+		t.Fatalf("RotateCA() without files made a backup: %v", err)
+	}
+
+	second, err := RotateCA(certFile, keyFile, CAOptions{CommonName: "second", Validity: time.Hour})
+	if err != nil {
+		t.Fatalf("RotateCA() error = %v", err)
+	}
+
+	old, err := LoadCA(certFile+".old", keyFile+".old")
+	if err != nil || !bytes.Equal(old.Certificate[0], first.Certificate[0]) {
+		t.Fatalf("RotateCA() didn't keep the previous CA: %v", err)
+	}
+
+	current, err := LoadCA(certFile, keyFile)
+	if err != nil || !bytes.Equal(current.Certificate[0], second.Certificate[0]) || current.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("RotateCA() didn't write the new CA: %v", err)
+	}
+
+	if _, err := RotateCA(certFile, keyFile, CAOptions{}); err == nil {
+		t.Fatal("RotateCA() with invalid options error = nil")
+	}
+	if current, err := LoadCA(certFile, keyFile); err != nil || !bytes.Equal(current.Certificate[0], second.Certificate[0]) {
+		t.Fatalf("failed RotateCA() replaced the CA: %v", err)
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	if _, err := EnsureCA(caCert, caKey, CAOptions{Validity: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewCertManager(Options{
+		CACertFile: caCert,
+		CAKeyFile:  caKey,
+		Dir:        filepath.Join(dir, "certs"),
+		KeyType:    KeyECDSA,
+		CacheSize:  1,
+		Validity:   MinValidity,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := manager.GetCertificate("example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.Leaf, err = x509.ParseCertificate(leaf.Certificate[0]); err != nil {
+		t.Fatal(err)
+	}
+	leafCert, leafKey := filepath.Join(dir, "leaf.crt"), filepath.Join(dir, "leaf.key")
+	if err := WriteCA(leaf, leafCert, leafKey); err != nil {
+		t.Fatal(err)
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	writeFile(t, garbage, "not a certificate")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		err      string
+	}{
+		{name: "missing files", certFile: filepath.Join(dir, "none.crt"), keyFile: caKey, err: "couldn't load CA"},
+		{name: "garbage", certFile: garbage, keyFile: garbage, err: "couldn't load CA"},
+		{name: "mismatched key", certFile: caCert, keyFile: leafKey, err: "couldn't load CA"},
+		{name: "not a CA", certFile: leafCert, keyFile: leafKey, err: "is not a CA certificate"},
+	}
+
+	for _, tt := range tests {
+		if _, err := LoadCA(tt.certFile, tt.keyFile); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: LoadCA() error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func writeFile(t *testing.T, name, data string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
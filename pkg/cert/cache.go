@@ -0,0 +1,59 @@
+package cert
+
+import (
+	"container/list"
+	"crypto/tls"
+)
+
+// lruCache keeps the most recently used certificates, it isn't safe for
+// concurrent use.
+type lruCache struct {
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	host string
+	cert tls.Certificate
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *lruCache) get(host string) (tls.Certificate, bool) {
+	elem, ok := c.entries[host]
+	if !ok {
+		return tls.Certificate{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).cert, true
+}
+
+func (c *lruCache) add(host string, cert tls.Certificate) {
+	if elem, ok := c.entries[host]; ok {
+		elem.Value.(*cacheEntry).cert = cert
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[host] = c.order.PushFront(&cacheEntry{host: host, cert: cert})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).host)
+	}
+}
+
+func (c *lruCache) remove(host string) {
+	if elem, ok := c.entries[host]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, host)
+	}
+}
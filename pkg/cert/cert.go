@@ -1,130 +1,324 @@
 package cert
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha1"
 	"crypto/tls"
 	"crypto/x509"
-	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
+	"io/ioutil"
 	"math/big"
-	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	certDir  = "certs" + string(os.PathSeparator)
+	KeyRSA   = "rsa"
+	KeyECDSA = "ecdsa"
+
 	certFile = "cert.pem"
 	keyFile  = "key.pem"
 )
 
-func GetCertificate(host string) (tls.Certificate, error) {
-	cert, err := getCertificateFromDisk(host)
+type Options struct {
+	CACertFile string
+	CAKeyFile  string
+	Dir        string
+	KeyType    string
+	CacheSize  int
+	Validity   time.Duration
+	Wildcard   bool
+	Mirror     bool
+}
+
+// CertManager issues host certificates signed by the CA. They are kept in
+// an in-memory LRU cache and in Dir, so they survive restarts.
+type CertManager struct {
+	opts Options
+	ca   tls.Certificate
+
+	mu    sync.Mutex
+	cache *lruCache
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	cert tls.Certificate
+	err  error
+}
+
+func NewCertManager(opts Options) (*CertManager, error) {
+	switch opts.KeyType {
+	case KeyRSA, KeyECDSA:
+	default:
+		return nil, fmt.Errorf("unknown key type %q", opts.KeyType)
+	}
+
+	if opts.CacheSize <= 0 {
+		return nil, fmt.Errorf("cache size must be positive, got %d", opts.CacheSize)
+	}
+
+	if opts.Validity < MinValidity || opts.Validity > MaxValidity {
+		return nil, fmt.Errorf("validity must be between %s and %s, got %s", MinValidity, MaxValidity, opts.Validity)
+	}
+
+	ca, err := LoadCA(opts.CACertFile, opts.CAKeyFile)
 	if err != nil {
-		return createCertificate(host)
+		return nil, err
 	}
 
-	return cert, nil
+	return &CertManager{
+		opts:  opts,
+		ca:    ca,
+		cache: newLRUCache(opts.CacheSize),
+		calls: make(map[string]*call),
+	}, nil
+}
+
+// CA returns the certificate host certificates are signed with.
+func (m *CertManager) CA() *x509.Certificate {
+	return m.ca.Leaf
 }
 
-func getCertPathByHost(host string) string {
-	return certDir + host + string(os.PathSeparator)
+// GetCertificate returns the certificate for host. When mirroring is enabled
+// and the upstream certificate is known, its subject and names are copied.
+// Concurrent calls for the same certificate wait for a single load or
+// generation.
+func (m *CertManager) GetCertificate(host string, upstream *x509.Certificate) (tls.Certificate, error) {
+	// the trailing dot of a fully qualified name isn't part of SAN, empty
+	// labels can't be issued
+	name := strings.TrimSuffix(host, ".")
+	if name == "" || strings.ContainsAny(name, `/\`) || strings.Contains("."+name+".", "..") {
+		return tls.Certificate{}, fmt.Errorf("invalid host %q", host)
+	}
+	host = name
+
+	now := time.Now()
+	spec := m.newSpec(host, upstream, now)
+
+	m.mu.Lock()
+	if cert, ok := m.cache.get(spec.key); ok {
+		if spec.fresh(cert.Leaf, now) {
+			m.mu.Unlock()
+			return cert, nil
+		}
+		m.cache.remove(spec.key)
+	}
+
+	if c, ok := m.calls[spec.key]; ok {
+		m.mu.Unlock()
+		<-c.done
+		return c.cert, c.err
+	}
+
+	c := &call{done: make(chan struct{})}
+	m.calls[spec.key] = c
+	m.mu.Unlock()
+
+	c.cert, c.err = m.loadOrCreate(host, spec, now)
+
+	m.mu.Lock()
+	delete(m.calls, spec.key)
+	if c.err == nil {
+		m.cache.add(spec.key, c.cert)
+	}
+	m.mu.Unlock()
+	close(c.done)
+
+	return c.cert, c.err
 }
 
-func getCertificateFromDisk(host string) (tls.Certificate, error) {
-	dir := getCertPathByHost(host)
+func (m *CertManager) loadOrCreate(host string, spec leafSpec, now time.Time) (tls.Certificate, error) {
+	cert, err := m.load(spec.key)
+	if err == nil && m.usable(cert.Leaf, host, spec, now) {
+		return cert, nil
+	}
 
-	return tls.LoadX509KeyPair(dir+certFile, dir+keyFile)
+	return m.create(spec)
 }
 
-func createCertificate(host string) (tls.Certificate, error) {
-	notBefore := time.Now()
-	notAfter := notBefore.AddDate(1, 0, 0)
+func (m *CertManager) load(key string) (tls.Certificate, error) {
+	dir := m.path(key)
 
-	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
-	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, certFile), filepath.Join(dir, keyFile))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	return cert, err
+}
+
+// usable reports whether a certificate left in Dir can still be served: it is
+// signed by the current CA, has the configured key type, is valid for host
+// and is fresh.
+func (m *CertManager) usable(leaf *x509.Certificate, host string, spec leafSpec, now time.Time) bool {
+	if leaf.CheckSignatureFrom(m.ca.Leaf) != nil {
+		return false
+	}
+
+	keyType := KeyRSA
+	if leaf.PublicKeyAlgorithm == x509.ECDSA {
+		keyType = KeyECDSA
+	}
+	if keyType != m.opts.KeyType {
+		return false
+	}
+
+	if leaf.VerifyHostname(host) != nil {
+		return false
+	}
+
+	return spec.fresh(leaf, now)
+}
+
+func (m *CertManager) create(spec leafSpec) (tls.Certificate, error) {
+	serialNumber, err := newSerialNumber()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, err := generateKey(m.opts.KeyType)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	keyID, err := publicKeyID(key.Public())
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
 	template := x509.Certificate{
 		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization: []string{"http proxy"},
-		},
-		NotBefore: notBefore,
-		NotAfter:  notAfter,
-		KeyUsage:  x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		Subject:      spec.subject,
+		NotBefore:    spec.notBefore,
+		NotAfter:     spec.notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
 		ExtKeyUsage: []x509.ExtKeyUsage{
 			x509.ExtKeyUsageServerAuth,
 			x509.ExtKeyUsageClientAuth,
 		},
 		BasicConstraintsValid: true,
+		SubjectKeyId:          keyID,
+		AuthorityKeyId:        m.ca.Leaf.SubjectKeyId,
+		DNSNames:              spec.dnsNames,
+		IPAddresses:           spec.ipAddresses,
 	}
 
-	if ip := net.ParseIP(host); ip != nil {
-		template.IPAddresses = append(template.IPAddresses, ip)
-	} else {
-		template.DNSNames = append(template.DNSNames, host)
+	// RSA key exchange encrypts the premaster secret with the leaf key
+	if m.opts.KeyType == KeyRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment
 	}
 
-	rootCA, err := tls.LoadX509KeyPair("ca.crt", "ca.key")
+	der, err := x509.CreateCertificate(rand.Reader, &template, m.ca.Leaf, key.Public(), m.ca.PrivateKey)
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
-	if rootCA.Leaf, err = x509.ParseCertificate(rootCA.Certificate[0]); err != nil {
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
 		return tls.Certificate{}, err
 	}
 
-	template.AuthorityKeyId = rootCA.Leaf.SubjectKeyId
-
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
 	if err != nil {
 		return tls.Certificate{}, err
 	}
 
-	template.SubjectKeyId = func(n *big.Int) []byte {
-		h := sha1.New()
-		h.Write(n.Bytes())
-		return h.Sum(nil)
-	}(key.N)
+	if err = m.save(spec.key, der, keyDER); err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+func (m *CertManager) save(key string, certDER, keyDER []byte) error {
+	dir := m.path(key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
 
-	bytes, err := x509.CreateCertificate(
-		rand.Reader, &template, rootCA.Leaf, &key.PublicKey, rootCA.PrivateKey,
+	err := writeFileAtomic(
+		filepath.Join(dir, keyFile), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600,
 	)
 	if err != nil {
-		return tls.Certificate{}, err
+		return err
 	}
 
-	dir := getCertPathByHost(host)
-	if err = os.MkdirAll(dir, 0755); err != nil {
-		return tls.Certificate{}, err
+	return writeFileAtomic(
+		filepath.Join(dir, certFile), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0644,
+	)
+}
+
+// path returns the directory of the certificate, wildcard ones are kept in
+// _.example.com.
+func (m *CertManager) path(key string) string {
+	return filepath.Join(m.opts.Dir, strings.Replace(key, "*", "_", 1))
+}
+
+// expiresSoon reports whether less than a tenth of the certificate lifetime
+// is left.
+func expiresSoon(leaf *x509.Certificate, now time.Time) bool {
+	renew := leaf.NotAfter.Sub(leaf.NotBefore) / 10
+	return now.After(leaf.NotAfter.Add(-renew))
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func generateKey(keyType string) (crypto.Signer, error) {
+	if keyType == KeyRSA {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+func publicKeyID(pub crypto.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
 	}
 
-	certOut, err := os.Create(dir + certFile)
+	id := sha1.Sum(der)
+	return id[:], nil
+}
+
+// writeFileAtomic replaces name, so readers never see a partially written file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+"-")
 	if err != nil {
-		return tls.Certificate{}, err
+		return err
 	}
-	defer certOut.Close()
+	defer os.Remove(tmp.Name())
 
-	if err := pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: bytes}); err != nil {
-		return tls.Certificate{}, err
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
 	}
 
-	keyOut, err := os.OpenFile(dir+keyFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return tls.Certificate{}, err
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
 	}
-	defer keyOut.Close()
 
-	if err := pem.Encode(
-		keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)},
-	); err != nil {
-		return tls.Certificate{}, err
+	if err = tmp.Close(); err != nil {
+		return err
 	}
 
-	return tls.LoadX509KeyPair(dir+certFile, dir+keyFile)
+	return os.Rename(tmp.Name(), name)
 }
@@ -0,0 +1,249 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/tls"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newManager returns a manager with a new CA, opts fills in the defaults.
+func newManager(t *testing.T, opts Options) *CertManager {
+	t.Helper()
+
+	if opts.CACertFile == "" {
+		dir := t.TempDir()
+		opts.CACertFile, opts.CAKeyFile = filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+		if _, err := EnsureCA(opts.CACertFile, opts.CAKeyFile, CAOptions{Validity: 24 * time.Hour}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opts.Dir == "" {
+		opts.Dir = t.TempDir()
+	}
+	if opts.KeyType == "" {
+		opts.KeyType = KeyECDSA
+	}
+	if opts.CacheSize == 0 {
+		opts.CacheSize = 10
+	}
+	if opts.Validity == 0 {
+		opts.Validity = 24 * time.Hour
+	}
+
+	m, err := NewCertManager(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestNewCertManager(t *testing.T) {
+	valid := newManager(t, Options{}).opts
+
+	tests := []struct {
+		name   string
+		change func(opts *Options)
+		err    string
+	}{
+		{name: "key type", change: func(opts *Options) { opts.KeyType = "dsa" }, err: `unknown key type "dsa"`},
+		{name: "cache size", change: func(opts *Options) { opts.CacheSize = 0 }, err: "cache size must be positive"},
+		{name: "no validity", change: func(opts *Options) { opts.Validity = 0 }, err: "validity must be between"},
+		{name: "backdated validity", change: func(opts *Options) { opts.Validity = backdate }, err: "validity must be between"},
+		{name: "long validity", change: func(opts *Options) { opts.Validity = MaxValidity + time.Hour }, err: "validity must be between"},
+		{name: "missing CA", change: func(opts *Options) { opts.CACertFile += ".missing" }, err: "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := valid
+			tt.change(&opts)
+
+			if _, err := NewCertManager(opts); err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("NewCertManager() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGetCertificate(t *testing.T) {
+	for _, keyType := range []string{KeyECDSA, KeyRSA} {
+		t.Run(keyType, func(t *testing.T) {
+			m := newManager(t, Options{KeyType: keyType})
+
+			for _, host := range []string{"example.com", "127.0.0.1", "::1"} {
+				cert, err := m.GetCertificate(host, nil)
+				if err != nil {
+					t.Fatalf("GetCertificate(%s) error = %v", host, err)
+				}
+
+				leaf := cert.Leaf
+				if err := leaf.CheckSignatureFrom(m.CA()); err != nil {
+					t.Fatalf("GetCertificate(%s) isn't signed by the CA: %v", host, err)
+				}
+				if err := leaf.VerifyHostname(host); err != nil {
+					t.Fatalf("GetCertificate(%s) = %v", host, err)
+				}
+
+				switch cert.PrivateKey.(type) {
+				case *ecdsa.PrivateKey:
+					if keyType != KeyECDSA {
+						t.Fatalf("GetCertificate(%s) made an ECDSA key", host)
+					}
+				case *rsa.PrivateKey:
+					if keyType != KeyRSA {
+						t.Fatalf("GetCertificate(%s) made an RSA key", host)
+					}
+				}
+
+				cached, err := m.GetCertificate(host, nil)
+				if err != nil || !bytes.Equal(cached.Certificate[0], cert.Certificate[0]) {
+					t.Fatalf("second GetCertificate(%s) issued a new certificate: %v", host, err)
+				}
+			}
+
+			// the saved pair has to be usable by a TLS server after a restart
+			if _, err := tls.X509KeyPair(readPEM(t, m, "example.com", certFile), readPEM(t, m, "example.com", keyFile)); err != nil {
+				t.Fatalf("saved pair error = %v", err)
+			}
+		})
+	}
+
+	m := newManager(t, Options{})
+	for _, host := range []string{"", ".", "a..b", "../etc", `a\b`} {
+		if _, err := m.GetCertificate(host, nil); err == nil {
+			t.Errorf("GetCertificate(%q) error = nil", host)
+		}
+	}
+}
+
+func readPEM(t *testing.T, m *CertManager, key, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(filepath.Join(m.path(key), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestGetCertificateConcurrently(t *testing.T) {
+	m := newManager(t, Options{KeyType: KeyRSA})
+
+	const calls = 20
+	certs := make([]tls.Certificate, calls)
+	errs := make([]error, calls)
+
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			certs[i], errs[i] = m.GetCertificate("example.com", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range certs {
+		if errs[i] != nil || !bytes.Equal(certs[i].Certificate[0], certs[0].Certificate[0]) {
+			t.Fatalf("GetCertificate() call %d = %v, want the certificate of the first one", i, errs[i])
+		}
+	}
+
+	if files, err := ioutil.ReadDir(m.path("example.com")); err != nil || len(files) != 2 {
+		t.Fatalf("certificate dir has %d files, %v, want the pair only", len(files), err)
+	}
+}
+
+func TestGetCertificateFromDir(t *testing.T) {
+	first := newManager(t, Options{})
+	issued, err := first.GetCertificate("example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		opts   Options
+		reused bool
+	}{
+		{name: "restart", opts: first.opts, reused: true},
+		{name: "other key type", opts: Options{CACertFile: first.opts.CACertFile, CAKeyFile: first.opts.CAKeyFile, KeyType: KeyRSA}},
+		{name: "other CA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = first.opts.Dir
+			m := newManager(t, tt.opts)
+
+			cert, err := m.GetCertificate("example.com", nil)
+			if err != nil {
+				t.Fatalf("GetCertificate() error = %v", err)
+			}
+
+			if reused := bytes.Equal(cert.Certificate[0], issued.Certificate[0]); reused != tt.reused {
+				t.Fatalf("GetCertificate() reused the saved certificate = %v, want %v", reused, tt.reused)
+			}
+			if err := cert.Leaf.CheckSignatureFrom(m.CA()); err != nil {
+				t.Fatalf("GetCertificate() isn't signed by the CA: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpiringCertificateRenewed(t *testing.T) {
+	m := newManager(t, Options{})
+
+	now := time.Now()
+	spec := m.newSpec("example.com", nil, now)
+	// a tenth of the lifetime is left
+	spec.notBefore, spec.notAfter = now.Add(-23*time.Hour), now.Add(time.Hour)
+	expiring, err := m.create(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.mu.Lock()
+	m.cache.add(spec.key, expiring)
+	m.mu.Unlock()
+
+	cert, err := m.GetCertificate("example.com", nil)
+	if err != nil || bytes.Equal(cert.Certificate[0], expiring.Certificate[0]) {
+		t.Fatalf("GetCertificate() = the expiring certificate, %v", err)
+	}
+
+	// the saved one was replaced as well
+	if saved, err := m.load(spec.key); err != nil || !bytes.Equal(saved.Certificate[0], cert.Certificate[0]) {
+		t.Fatalf("saved certificate wasn't renewed: %v", err)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := newLRUCache(2)
+	for _, host := range []string{"a", "b"} {
+		c.add(host, tls.Certificate{Certificate: [][]byte{[]byte(host)}})
+	}
+
+	c.get("a")
+	c.add("c", tls.Certificate{Certificate: [][]byte{[]byte("c")}})
+
+	for host, cached := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.get(host); ok != cached {
+			t.Errorf("get(%s) = %v, want %v", host, ok, cached)
+		}
+	}
+
+	c.remove("a")
+	if _, ok := c.get("a"); ok {
+		t.Error("get() after remove() = true")
+	}
+}
@@ -0,0 +1,125 @@
+package cert
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxValidity is the longest leaf lifetime browsers accept.
+	MaxValidity = 398 * 24 * time.Hour
+
+	// backdate keeps certificates valid for clients whose clock is behind.
+	backdate = time.Hour
+
+	// MinValidity leaves a new certificate as long to live as it is
+	// backdated, shorter ones would be expired or renewed right away.
+	MinValidity = 2 * backdate
+
+	// maxCommonName is the upper bound for the common name in RFC 5280.
+	maxCommonName = 64
+)
+
+// secondLevelDomains under two letter country codes are registries, so
+// a.co.uk doesn't share *.co.uk with its neighbours.
+var secondLevelDomains = map[string]bool{
+	"ac": true, "co": true, "com": true, "edu": true, "go": true,
+	"gov": true, "ne": true, "net": true, "or": true, "org": true,
+}
+
+// leafSpec describes the certificate to issue, key identifies it in the
+// cache and in Dir.
+type leafSpec struct {
+	key         string
+	subject     pkix.Name
+	dnsNames    []string
+	ipAddresses []net.IP
+	notBefore   time.Time
+	notAfter    time.Time
+}
+
+func (m *CertManager) newSpec(host string, upstream *x509.Certificate, now time.Time) leafSpec {
+	notBefore := now.Add(-backdate)
+	spec := leafSpec{
+		notBefore: notBefore,
+		notAfter:  notBefore.Add(m.opts.Validity),
+	}
+
+	if m.opts.Mirror && upstream != nil && upstream.VerifyHostname(host) == nil {
+		sum := sha256.Sum256(upstream.Raw)
+		spec.key = "mirror-" + hex.EncodeToString(sum[:12])
+		spec.subject = upstream.Subject
+		spec.subject.ExtraNames = nil
+		spec.dnsNames = upstream.DNSNames
+		spec.ipAddresses = upstream.IPAddresses
+
+		if upstream.NotAfter.Before(spec.notAfter) {
+			spec.notAfter = upstream.NotAfter
+		}
+		if start := spec.notAfter.Add(-m.opts.Validity); upstream.NotBefore.After(start) {
+			spec.notBefore = upstream.NotBefore
+		} else {
+			spec.notBefore = start
+		}
+
+		return spec
+	}
+
+	name := host
+	if ip := net.ParseIP(host); ip != nil {
+		spec.ipAddresses = []net.IP{ip}
+	} else {
+		if parent, ok := wildcardParent(host); ok && m.opts.Wildcard {
+			name = "*." + parent
+		}
+		spec.dnsNames = []string{name}
+	}
+
+	spec.key = name
+	spec.subject = pkix.Name{
+		Organization: []string{"http proxy"},
+	}
+	if len(name) <= maxCommonName {
+		spec.subject.CommonName = name
+	}
+
+	return spec
+}
+
+// fresh reports whether leaf can still be served instead of a new
+// certificate: it doesn't outlive one issued now and either isn't about to
+// expire or a new one wouldn't last longer, as with mirrored certificates of
+// expiring upstream ones.
+func (s leafSpec) fresh(leaf *x509.Certificate, now time.Time) bool {
+	if leaf.NotAfter.After(s.notAfter) {
+		return false
+	}
+
+	return !expiresSoon(leaf, now) || !leaf.NotAfter.Before(s.notAfter)
+}
+
+// wildcardParent returns the domain whose wildcard certificate covers host.
+func wildcardParent(host string) (string, bool) {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	if len(labels) < 3 {
+		return "", false
+	}
+
+	for _, label := range labels {
+		if label == "" {
+			return "", false
+		}
+	}
+
+	parent := labels[1:]
+	if len(parent) == 2 && len(parent[1]) == 2 && secondLevelDomains[parent[0]] {
+		return "", false
+	}
+
+	return strings.Join(parent, "."), true
+}
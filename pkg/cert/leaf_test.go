@@ -0,0 +1,251 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verify checks that leaf is trusted for host by a client trusting m's CA.
+func verify(t *testing.T, m *CertManager, leaf *x509.Certificate, host string) {
+	t.Helper()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(m.CA())
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+		t.Fatalf("certificate for %s isn't trusted: %v", host, err)
+	}
+}
+
+func TestLeafNames(t *testing.T) {
+	long := strings.Repeat("a", 60) + ".example.com"
+
+	tests := []struct {
+		name     string
+		wildcard bool
+		host     string
+		cn       string
+		dnsNames []string
+		ip       string
+	}{
+		{name: "host", host: "api.example.com", cn: "api.example.com", dnsNames: []string{"api.example.com"}},
+		{name: "fully qualified", host: "example.com.", cn: "example.com", dnsNames: []string{"example.com"}},
+		{name: "ip", host: "10.0.0.1", cn: "10.0.0.1", ip: "10.0.0.1"},
+		{name: "long host", host: long, dnsNames: []string{long}},
+		{name: "wildcard", wildcard: true, host: "api.example.com", cn: "*.example.com", dnsNames: []string{"*.example.com"}},
+		{name: "wildcard of a deep host", wildcard: true, host: "a.b.example.com", cn: "*.b.example.com", dnsNames: []string{"*.b.example.com"}},
+		{name: "wildcard of a domain", wildcard: true, host: "example.com", cn: "example.com", dnsNames: []string{"example.com"}},
+		{name: "wildcard of a registry", wildcard: true, host: "shop.co.uk", cn: "shop.co.uk", dnsNames: []string{"shop.co.uk"}},
+		{name: "wildcard under a registry", wildcard: true, host: "www.shop.co.uk", cn: "*.shop.co.uk", dnsNames: []string{"*.shop.co.uk"}},
+		{name: "wildcard of an ip", wildcard: true, host: "10.0.0.1", cn: "10.0.0.1", ip: "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newManager(t, Options{Wildcard: tt.wildcard})
+
+			cert, err := m.GetCertificate(tt.host, nil)
+			if err != nil {
+				t.Fatalf("GetCertificate() error = %v", err)
+			}
+
+			leaf := cert.Leaf
+			if leaf.Subject.CommonName != tt.cn || !reflect.DeepEqual(leaf.Subject.Organization, []string{"http proxy"}) {
+				t.Fatalf("GetCertificate() subject = %v, want CN %q", leaf.Subject, tt.cn)
+			}
+			if !reflect.DeepEqual(leaf.DNSNames, tt.dnsNames) {
+				t.Fatalf("GetCertificate() DNS names = %q, want %q", leaf.DNSNames, tt.dnsNames)
+			}
+			if tt.ip != "" && (len(leaf.IPAddresses) != 1 || !leaf.IPAddresses[0].Equal(net.ParseIP(tt.ip))) {
+				t.Fatalf("GetCertificate() IP addresses = %v, want %s", leaf.IPAddresses, tt.ip)
+			}
+
+			verify(t, m, leaf, strings.TrimSuffix(tt.host, "."))
+		})
+	}
+}
+
+func TestWildcardShared(t *testing.T) {
+	m := newManager(t, Options{Wildcard: true})
+
+	first, err := m.GetCertificate("a.example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.GetCertificate("b.example.com", nil)
+	if err != nil || !bytes.Equal(first.Certificate[0], second.Certificate[0]) {
+		t.Fatalf("GetCertificate() of a neighbour issued another certificate: %v", err)
+	}
+
+	// a wildcard saved earlier isn't reused once wildcards are turned off
+	plain := newManager(t, Options{CACertFile: m.opts.CACertFile, CAKeyFile: m.opts.CAKeyFile, Dir: m.opts.Dir})
+	cert, err := plain.GetCertificate("a.example.com", nil)
+	if err != nil || !reflect.DeepEqual(cert.Leaf.DNSNames, []string{"a.example.com"}) {
+		t.Fatalf("GetCertificate() without wildcards = %q, %v", cert.Leaf.DNSNames, err)
+	}
+}
+
+func TestLeafValidity(t *testing.T) {
+	for _, validity := range []time.Duration{MinValidity, 90 * 24 * time.Hour, MaxValidity} {
+		m := newManager(t, Options{Validity: validity})
+
+		before := time.Now()
+		cert, err := m.GetCertificate("example.com", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		leaf := cert.Leaf
+		if got := leaf.NotAfter.Sub(leaf.NotBefore); got != validity {
+			t.Errorf("GetCertificate() validity = %s, want %s", got, validity)
+		}
+		if backdated := before.Sub(leaf.NotBefore); backdated < backdate-time.Second || backdated > backdate+time.Second {
+			t.Errorf("GetCertificate() is backdated by %s, want %s", backdated, backdate)
+		}
+
+		verify(t, m, leaf, "example.com")
+
+		// the shortest certificate doesn't need renewing right away
+		again, err := m.GetCertificate("example.com", nil)
+		if err != nil || !bytes.Equal(again.Certificate[0], cert.Certificate[0]) {
+			t.Errorf("GetCertificate() with %s validity issued a new certificate: %v", validity, err)
+		}
+	}
+}
+
+// upstreamCert returns a certificate like the one of a real site.
+func upstreamCert(t *testing.T, notBefore, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:   "www.example.com",
+			Organization: []string{"Example Inc"},
+			Country:      []string{"US"},
+			ExtraNames:   []pkix.AttributeTypeAndValue{{Type: []int{1, 2, 840, 113549, 1, 9, 1}, Value: "admin@example.com"}},
+		},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		DNSNames:    []string{"www.example.com", "example.com", "*.cdn.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func TestMirror(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	validity := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name      string
+		mirror    bool
+		host      string
+		upstream  *x509.Certificate
+		mirrored  bool
+		notBefore time.Time
+		notAfter  time.Time
+	}{
+		{
+			name: "long lived upstream", mirror: true, host: "www.example.com",
+			upstream: upstreamCert(t, now.Add(-100*24*time.Hour), now.Add(200*24*time.Hour)), mirrored: true,
+			notBefore: now.Add(-backdate), notAfter: now.Add(-backdate).Add(validity),
+		},
+		{
+			name: "expiring upstream", mirror: true, host: "img.cdn.example.com",
+			upstream: upstreamCert(t, now.Add(-100*24*time.Hour), now.Add(24*time.Hour)), mirrored: true,
+			notBefore: now.Add(24 * time.Hour).Add(-validity), notAfter: now.Add(24 * time.Hour),
+		},
+		{
+			name: "recent upstream", mirror: true, host: "example.com",
+			upstream: upstreamCert(t, now.Add(-24*time.Hour), now.Add(24*time.Hour)), mirrored: true,
+			notBefore: now.Add(-24 * time.Hour), notAfter: now.Add(24 * time.Hour),
+		},
+		{
+			name: "upstream for another host", mirror: true, host: "other.example.org",
+			upstream: upstreamCert(t, now.Add(-24*time.Hour), now.Add(24*time.Hour)),
+		},
+		{name: "unknown upstream", mirror: true, host: "www.example.com"},
+		{
+			name: "mirroring disabled", host: "www.example.com",
+			upstream: upstreamCert(t, now.Add(-24*time.Hour), now.Add(24*time.Hour)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newManager(t, Options{Mirror: tt.mirror, Validity: validity})
+
+			cert, err := m.GetCertificate(tt.host, tt.upstream)
+			if err != nil {
+				t.Fatalf("GetCertificate() error = %v", err)
+			}
+			leaf := cert.Leaf
+			verify(t, m, leaf, tt.host)
+
+			if !tt.mirrored {
+				if !reflect.DeepEqual(leaf.DNSNames, []string{tt.host}) || leaf.Subject.CommonName != tt.host {
+					t.Fatalf("GetCertificate() = %v %q, want the host only", leaf.Subject, leaf.DNSNames)
+				}
+				return
+			}
+
+			want := tt.upstream.Subject
+			if leaf.Subject.String() != (pkix.Name{
+				CommonName: want.CommonName, Organization: want.Organization, Country: want.Country,
+			}).String() {
+				t.Fatalf("GetCertificate() subject = %v, want %v without extra names", leaf.Subject, want)
+			}
+			if !reflect.DeepEqual(leaf.DNSNames, tt.upstream.DNSNames) || len(leaf.IPAddresses) != 1 {
+				t.Fatalf("GetCertificate() names = %q %v, want %q", leaf.DNSNames, leaf.IPAddresses, tt.upstream.DNSNames)
+			}
+
+			// the lifetime is cut to the upstream one, never over the validity
+			if !near(leaf.NotAfter, tt.notAfter) || !near(leaf.NotBefore, tt.notBefore) {
+				t.Fatalf("GetCertificate() valid %s - %s, want %s - %s", leaf.NotBefore, leaf.NotAfter, tt.notBefore, tt.notAfter)
+			}
+			if leaf.NotAfter.Sub(leaf.NotBefore) > validity {
+				t.Fatalf("GetCertificate() validity = %s, want at most %s", leaf.NotAfter.Sub(leaf.NotBefore), validity)
+			}
+
+			// an expiring upstream certificate doesn't make every call issue
+			// a new one
+			again, err := m.GetCertificate(tt.host, tt.upstream)
+			if err != nil || !bytes.Equal(again.Certificate[0], cert.Certificate[0]) {
+				t.Fatalf("second GetCertificate() issued a new certificate: %v", err)
+			}
+		})
+	}
+}
+
+// near reports whether the times are at most a couple of seconds apart, the
+// certificate was issued a bit later than the test took its time.
+func near(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -2*time.Second && d < 2*time.Second
+}
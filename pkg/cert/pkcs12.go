@@ -0,0 +1,297 @@
+package cert
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 (RFC 7292) with the key shrouded by pbeWithSHAAnd3-KeyTripleDES-CBC
+// and a SHA-1 MAC, the combination every platform can import.
+
+const pkcs12Iterations = 2048
+
+var (
+	oidData               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidCertBag            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidShroudedKeyBag     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidX509Certificate    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBEWithSHA3KeyTDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidSHA1               = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+)
+
+var (
+	errEmptyPKCS12Password = errors.New("PKCS#12 export needs a password")
+	errNoPKCS12Certificate = errors.New("PKCS#12 export needs a certificate")
+)
+
+type pfx struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []bagAttribute `asn1:"set"`
+}
+
+type bagAttribute struct {
+	ID     asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data asn1.RawValue
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+// EncodePKCS12 bundles the certificate and its private key, protected by
+// password.
+func EncodePKCS12(cert tls.Certificate, name, password string) ([]byte, error) {
+	if password == "" {
+		return nil, errEmptyPKCS12Password
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errNoPKCS12Certificate
+	}
+	secret := bmpString(password)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	localKeyID := sha1.Sum(cert.Certificate[0])
+	attributes, err := bagAttributes(name, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	certValue, err := asn1.Marshal(certBag{
+		ID:   oidX509Certificate,
+		Data: explicit(octetString(cert.Certificate[0])),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	keyValue, err := shroudKey(keyDER, secret)
+	if err != nil {
+		return nil, err
+	}
+
+	var safes []contentInfo
+	for _, bag := range []safeBag{
+		{ID: oidCertBag, Value: explicit(certValue), Attributes: attributes},
+		{ID: oidShroudedKeyBag, Value: explicit(keyValue), Attributes: attributes},
+	} {
+		contents, err := asn1.Marshal([]safeBag{bag})
+		if err != nil {
+			return nil, err
+		}
+
+		safes = append(safes, contentInfo{
+			ContentType: oidData,
+			Content:     explicit(octetString(contents)),
+		})
+	}
+
+	authSafe, err := asn1.Marshal(safes)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 8)
+	if _, err = rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha1.New, pkcs12KDF(salt, secret, pkcs12Iterations, 3, sha1.Size))
+	mac.Write(authSafe)
+
+	return asn1.Marshal(pfx{
+		Version: 3,
+		AuthSafe: contentInfo{
+			ContentType: oidData,
+			Content:     explicit(octetString(authSafe)),
+		},
+		MacData: macData{
+			Mac: digestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    salt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+func shroudKey(keyDER, secret []byte) ([]byte, error) {
+	salt := make([]byte, 8)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	block, err := des.NewTripleDESCipher(pkcs12KDF(salt, secret, pkcs12Iterations, 1, 24))
+	if err != nil {
+		return nil, err
+	}
+	iv := pkcs12KDF(salt, secret, pkcs12Iterations, 2, block.BlockSize())
+
+	padding := block.BlockSize() - len(keyDER)%block.BlockSize()
+	encrypted := make([]byte, len(keyDER), len(keyDER)+padding)
+	copy(encrypted, keyDER)
+	for i := 0; i < padding; i++ {
+		encrypted = append(encrypted, byte(padding))
+	}
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	params, err := asn1.Marshal(pbeParams{Salt: salt, Iterations: pkcs12Iterations})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPBEWithSHA3KeyTDES,
+			Parameters: asn1.RawValue{FullBytes: params},
+		},
+		EncryptedData: encrypted,
+	})
+}
+
+func bagAttributes(name string, localKeyID []byte) ([]bagAttribute, error) {
+	keyID, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	bmpName := bmpString(name)
+	friendlyName, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagBMPString, Bytes: bmpName[:len(bmpName)-2]})
+	if err != nil {
+		return nil, err
+	}
+
+	return []bagAttribute{
+		{ID: oidFriendlyName, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: friendlyName}},
+		{ID: oidLocalKeyID, Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: keyID}},
+	}, nil
+}
+
+// explicit wraps DER as the [0] EXPLICIT content of PKCS#7 and PKCS#12
+// structures.
+func explicit(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+// octetString encodes data as an OCTET STRING, which can't fail.
+func octetString(data []byte) []byte {
+	der, _ := asn1.Marshal(data)
+	return der
+}
+
+// bmpString encodes the password as big endian UTF-16 with a terminating
+// zero, as PKCS#12 key derivation expects.
+func bmpString(s string) []byte {
+	encoded := utf16.Encode([]rune(s))
+	result := make([]byte, 0, 2*len(encoded)+2)
+	for _, r := range encoded {
+		result = append(result, byte(r>>8), byte(r))
+	}
+
+	return append(result, 0, 0)
+}
+
+// pkcs12KDF derives size bytes of key material with SHA-1 as described in
+// RFC 7292 appendix B.2, id is 1 for keys, 2 for IVs and 3 for MAC keys.
+func pkcs12KDF(salt, password []byte, iterations int, id byte, size int) []byte {
+	const u, v = sha1.Size, 64
+
+	fill := func(data []byte) []byte {
+		if len(data) == 0 {
+			return nil
+		}
+
+		result := make([]byte, v*((len(data)+v-1)/v))
+		for i := range result {
+			result[i] = data[i%len(data)]
+		}
+		return result
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	input := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	modulus := new(big.Int).Lsh(one, v*8)
+
+	result := make([]byte, 0, size+u)
+	for len(result) < size {
+		hash := sha1.New()
+		hash.Write(d)
+		hash.Write(input)
+		a := hash.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			sum := sha1.Sum(a)
+			a = sum[:]
+		}
+		result = append(result, a...)
+
+		b := new(big.Int).SetBytes(fill(a))
+		for j := 0; j < len(input); j += v {
+			block := new(big.Int).SetBytes(input[j : j+v])
+			block.Add(block, b).Add(block, one).Mod(block, modulus)
+
+			out := block.Bytes()
+			chunk := input[j : j+v]
+			for k := range chunk {
+				chunk[k] = 0
+			}
+			copy(chunk[v-len(out):], out)
+		}
+	}
+
+	return result[:size]
+}
@@ -0,0 +1,361 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestPKCS12KDF(t *testing.T) {
+	tests := []struct {
+		name       string
+		salt       []byte
+		password   []byte
+		iterations int
+		id         byte
+		size       int
+		want       string
+	}{
+		{
+			name:       "3DES key",
+			salt:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			password:   bmpString("sesame"),
+			iterations: 2048,
+			id:         1,
+			size:       24,
+			want:       "7cd9fd3e2b3be7691a44e3bef0f9ea0fb9b897d4e325d9d1",
+		},
+		{
+			name:       "3DES IV",
+			salt:       []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			password:   bmpString("sesame"),
+			iterations: 2048,
+			id:         2,
+			size:       8,
+			want:       "3f5a277f9c21ff82",
+		},
+		{
+			name:       "MAC key",
+			salt:       []byte{1, 2, 3, 4, 5, 6, 7, 8},
+			password:   bmpString("smeg"),
+			iterations: 1,
+			id:         3,
+			size:       20,
+			want:       "48dcaffbfa031161cd57eda5a5df4c9af73f36c4",
+		},
+		{
+			name:       "long salt, non-ASCII password and several hash blocks",
+			salt:       sequence(70),
+			password:   bmpString("пароль"),
+			iterations: 3,
+			id:         1,
+			size:       45,
+			want:       "c0a1fffdcf581fc55e99201a04e8410db4c364bb15e3ccca21118735611300dbe019166812e8bc0a332bbce8f2",
+		},
+		{
+			name:       "no salt and password",
+			iterations: 1,
+			id:         1,
+			size:       20,
+			want:       "320b018758ece3752ffedbaeb1a6db67c80b9359",
+		},
+		{
+			name:       "empty password",
+			salt:       make([]byte, 8),
+			password:   bmpString(""),
+			iterations: 5,
+			id:         3,
+			size:       20,
+			want:       "dede98f2291d3568cfd61e6ce2ca41ad6536b85d",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			salt := append([]byte(nil), tt.salt...)
+			password := append([]byte(nil), tt.password...)
+
+			got := hex.EncodeToString(pkcs12KDF(salt, password, tt.iterations, tt.id, tt.size))
+			if got != tt.want {
+				t.Fatalf("pkcs12KDF() = %s, want %s", got, tt.want)
+			}
+
+			if !bytes.Equal(salt, tt.salt) || !bytes.Equal(password, tt.password) {
+				t.Fatal("pkcs12KDF() changed its input")
+			}
+		})
+	}
+}
+
+func TestBMPString(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{in: "", want: []byte{0, 0}},
+		{in: "Beavis", want: []byte{0, 'B', 0, 'e', 0, 'a', 0, 'v', 0, 'i', 0, 's', 0, 0}},
+		{in: "я", want: []byte{0x04, 0x4f, 0, 0}},
+		{in: "\U0001f512", want: []byte{0xd8, 0x3d, 0xdd, 0x12, 0, 0}},
+	}
+
+	for _, tt := range tests {
+		if got := bmpString(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("bmpString(%q) = % x, want % x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	tests := []struct {
+		name     string
+		keyType  string
+		friendly string
+		password string
+	}{
+		{name: "ecdsa", keyType: KeyECDSA, friendly: DefaultCAName, password: "secret"},
+		{name: "rsa", keyType: KeyRSA, friendly: "proxy", password: "secret"},
+		{name: "non-ASCII", keyType: KeyECDSA, friendly: "Прокси \U0001f512", password: "пароль"},
+		{name: "empty name", keyType: KeyECDSA, password: "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ca, err := GenerateCA(CAOptions{KeyType: tt.keyType, Validity: 24 * time.Hour})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			der, err := EncodePKCS12(ca, tt.friendly, tt.password)
+			if err != nil {
+				t.Fatalf("EncodePKCS12() error = %v", err)
+			}
+
+			bags := decodePKCS12(t, der, tt.password)
+			if len(bags) != 2 || !bags[0].ID.Equal(oidCertBag) || !bags[1].ID.Equal(oidShroudedKeyBag) {
+				t.Fatalf("EncodePKCS12() bags = %v, want a certificate and a shrouded key", bags)
+			}
+
+			var cb certBag
+			unmarshal(t, bags[0].Value.Bytes, &cb)
+			var certDER []byte
+			unmarshal(t, cb.Data.Bytes, &certDER)
+			if !cb.ID.Equal(oidX509Certificate) || !bytes.Equal(certDER, ca.Certificate[0]) {
+				t.Fatal("EncodePKCS12() certificate differs from the CA")
+			}
+
+			key, err := x509.ParsePKCS8PrivateKey(decryptKey(t, bags[1].Value.Bytes, tt.password))
+			if err != nil {
+				t.Fatalf("couldn't parse the decrypted key: %v", err)
+			}
+			if !reflect.DeepEqual(key, ca.PrivateKey) {
+				t.Fatal("EncodePKCS12() key differs from the CA key")
+			}
+
+			keyID := sha1.Sum(ca.Certificate[0])
+			for _, bag := range bags {
+				name, id := bagAttributeValues(t, bag)
+				if name != tt.friendly || !bytes.Equal(id, keyID[:]) {
+					t.Fatalf("bag attributes = %q, % x, want %q, % x", name, id, tt.friendly, keyID)
+				}
+			}
+		})
+	}
+}
+
+func TestEncodePKCS12Password(t *testing.T) {
+	ca, err := GenerateCA(CAOptions{Validity: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := EncodePKCS12(ca, "ca", ""); err != errEmptyPKCS12Password {
+		t.Fatalf("EncodePKCS12() with empty password error = %v, want %v", err, errEmptyPKCS12Password)
+	}
+
+	first, err := EncodePKCS12(ca, "ca", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := EncodePKCS12(ca, "ca", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(first, second) {
+		t.Fatal("EncodePKCS12() reuses salts")
+	}
+
+	var p pfx
+	unmarshal(t, first, &p)
+	if macMatches(p, "secre") || macMatches(p, "secret\x00") {
+		t.Fatal("MAC matches a wrong password")
+	}
+}
+
+func TestEncodePKCS12Errors(t *testing.T) {
+	ca, err := GenerateCA(CAOptions{Validity: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cert tls.Certificate
+		err  error
+	}{
+		{name: "no certificate", cert: tls.Certificate{PrivateKey: ca.PrivateKey}, err: errNoPKCS12Certificate},
+		{name: "no key", cert: tls.Certificate{Certificate: ca.Certificate}},
+		{name: "unsupported key", cert: tls.Certificate{Certificate: ca.Certificate, PrivateKey: "key"}},
+	}
+
+	for _, tt := range tests {
+		_, err := EncodePKCS12(tt.cert, "ca", "secret")
+		if err == nil || tt.err != nil && err != tt.err {
+			t.Errorf("%s: EncodePKCS12() error = %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+// decodePKCS12 checks the structure and MAC of a PFX and returns its bags.
+func decodePKCS12(t *testing.T, der []byte, password string) []safeBag {
+	t.Helper()
+
+	var p pfx
+	unmarshal(t, der, &p)
+	if p.Version != 3 || !p.AuthSafe.ContentType.Equal(oidData) {
+		t.Fatalf("pfx version %d, content type %v", p.Version, p.AuthSafe.ContentType)
+	}
+	if !p.MacData.Mac.Algorithm.Algorithm.Equal(oidSHA1) || len(p.MacData.MacSalt) == 0 || p.MacData.Iterations != pkcs12Iterations {
+		t.Fatalf("pfx mac data = %+v", p.MacData)
+	}
+	if !macMatches(p, password) {
+		t.Fatal("pfx MAC doesn't match the password")
+	}
+
+	var authSafe []byte
+	unmarshal(t, p.AuthSafe.Content.Bytes, &authSafe)
+	var safes []contentInfo
+	unmarshal(t, authSafe, &safes)
+
+	var bags []safeBag
+	for _, safe := range safes {
+		if !safe.ContentType.Equal(oidData) {
+			t.Fatalf("safe content type %v, want data", safe.ContentType)
+		}
+
+		var contents []byte
+		unmarshal(t, safe.Content.Bytes, &contents)
+		var safeBags []safeBag
+		unmarshal(t, contents, &safeBags)
+		bags = append(bags, safeBags...)
+	}
+
+	return bags
+}
+
+func macMatches(p pfx, password string) bool {
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(p.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return false
+	}
+
+	key := pkcs12KDF(p.MacData.MacSalt, bmpString(password), p.MacData.Iterations, 3, sha1.Size)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(authSafe)
+
+	return hmac.Equal(mac.Sum(nil), p.MacData.Mac.Digest)
+}
+
+func decryptKey(t *testing.T, der []byte, password string) []byte {
+	t.Helper()
+
+	var info encryptedPrivateKeyInfo
+	unmarshal(t, der, &info)
+	if !info.Algorithm.Algorithm.Equal(oidPBEWithSHA3KeyTDES) {
+		t.Fatalf("key algorithm %v, want pbeWithSHAAnd3-KeyTripleDES-CBC", info.Algorithm.Algorithm)
+	}
+
+	var params pbeParams
+	unmarshal(t, info.Algorithm.Parameters.FullBytes, &params)
+
+	secret := bmpString(password)
+	block, err := des.NewTripleDESCipher(pkcs12KDF(params.Salt, secret, params.Iterations, 1, 24))
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := pkcs12KDF(params.Salt, secret, params.Iterations, 2, block.BlockSize())
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 {
+		t.Fatalf("encrypted key is %d bytes", len(data))
+	}
+	decrypted := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() ||
+		!bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		t.Fatalf("invalid padding % x", decrypted[len(decrypted)-block.BlockSize():])
+	}
+
+	return decrypted[:len(decrypted)-padding]
+}
+
+func bagAttributeValues(t *testing.T, bag safeBag) (string, []byte) {
+	t.Helper()
+
+	var (
+		name string
+		id   []byte
+	)
+	for _, attribute := range bag.Attributes {
+		switch {
+		case attribute.ID.Equal(oidFriendlyName):
+			var value asn1.RawValue
+			unmarshal(t, attribute.Values.Bytes, &value)
+			if value.Tag != asn1.TagBMPString || len(value.Bytes)%2 != 0 {
+				t.Fatalf("friendly name tag %d, % x", value.Tag, value.Bytes)
+			}
+
+			units := make([]uint16, 0, len(value.Bytes)/2)
+			for i := 0; i < len(value.Bytes); i += 2 {
+				units = append(units, uint16(value.Bytes[i])<<8|uint16(value.Bytes[i+1]))
+			}
+			name = string(utf16.Decode(units))
+		case attribute.ID.Equal(oidLocalKeyID):
+			unmarshal(t, attribute.Values.Bytes, &id)
+		}
+	}
+
+	return name, id
+}
+
+func unmarshal(t *testing.T, der []byte, value interface{}) {
+	t.Helper()
+
+	rest, err := asn1.Unmarshal(der, value)
+	if err != nil {
+		t.Fatalf("asn1.Unmarshal(%T) error = %v", value, err)
+	}
+	if len(rest) != 0 {
+		t.Fatalf("asn1.Unmarshal(%T) left %d bytes", value, len(rest))
+	}
+}
+
+func sequence(n int) []byte {
+	result := make([]byte, n)
+	for i := range result {
+		result[i] = byte(i)
+	}
+
+	return result
+}
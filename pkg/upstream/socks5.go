@@ -0,0 +1,155 @@
+package upstream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+const (
+	socks5Version = 5
+
+	socks5NoAuth       = 0
+	socks5PasswordAuth = 2
+	socks5NoAcceptable = 0xff
+
+	socks5CmdConnect = 1
+
+	socks5IPv4   = 1
+	socks5Domain = 3
+	socks5IPv6   = 4
+)
+
+var socks5Replies = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func socks5Connect(conn net.Conn, address string, user *url.Userinfo) error {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 0xffff {
+		return fmt.Errorf("invalid port %q", portStr)
+	}
+
+	method := byte(socks5NoAuth)
+	if user != nil {
+		method = socks5PasswordAuth
+	}
+
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version {
+		return errors.New("upstream proxy is not a socks5 server")
+	}
+	if reply[1] == socks5NoAcceptable || reply[1] != method {
+		return errors.New("upstream socks5 proxy rejected the authentication method")
+	}
+
+	if method == socks5PasswordAuth {
+		if err := socks5Authenticate(conn, user); err != nil {
+			return err
+		}
+	}
+
+	request := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			request = append(request, socks5IPv4)
+			request = append(request, ip4...)
+		} else {
+			request = append(request, socks5IPv6)
+			request = append(request, ip...)
+		}
+	} else {
+		if len(host) > 255 {
+			return fmt.Errorf("host name %q is too long for socks5", host)
+		}
+		request = append(request, socks5Domain, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = append(request, 0, 0)
+	binary.BigEndian.PutUint16(request[len(request)-2:], uint16(port))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	var head [4]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		message, ok := socks5Replies[head[1]]
+		if !ok {
+			message = "unknown error " + strconv.Itoa(int(head[1]))
+		}
+		return fmt.Errorf("upstream socks5 proxy couldn't connect to %s: %s", address, message)
+	}
+
+	var skip int
+	switch head[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		var length [1]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return errors.New("upstream socks5 proxy sent an unknown address type")
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("socks5 credentials are too long")
+	}
+
+	request := []byte{1, byte(len(username))}
+	request = append(request, username...)
+	request = append(request, byte(len(password)))
+	request = append(request, password...)
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0 {
+		return errors.New("upstream socks5 proxy rejected the credentials")
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package upstream
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// scriptConn answers with what the upstream proxy sends in in and keeps what
+// the client wrote in out.
+type scriptConn struct {
+	net.Conn
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (c *scriptConn) Read(p []byte) (int, error) {
+	return c.in.Read(p)
+}
+
+func (c *scriptConn) Write(p []byte) (int, error) {
+	return c.out.Write(p)
+}
+
+func TestSocks5Connect(t *testing.T) {
+	succeeded := []byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+
+	tests := []struct {
+		name    string
+		address string
+		user    *url.Userinfo
+		replies [][]byte
+		sent    [][]byte
+		err     string
+	}{
+		{
+			name:    "domain",
+			address: "example.com:443",
+			replies: [][]byte{{5, 0}, succeeded},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0x01, 0xbb}},
+		},
+		{
+			name:    "ipv4",
+			address: "127.0.0.1:8080",
+			replies: [][]byte{{5, 0}, succeeded},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 1, 127, 0, 0, 1, 0x1f, 0x90}},
+		},
+		{
+			name:    "ipv6",
+			address: "[2001:db8::1]:80",
+			replies: [][]byte{{5, 0}, succeeded},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 4}, net.ParseIP("2001:db8::1"), {0, 80}},
+		},
+		{
+			name:    "ipv4-mapped ipv6",
+			address: "[::ffff:10.0.0.1]:80",
+			replies: [][]byte{{5, 0}, succeeded},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 1, 10, 0, 0, 1, 0, 80}},
+		},
+		{
+			name:    "password",
+			address: "example.com:80",
+			user:    url.UserPassword("alice", "secret"),
+			replies: [][]byte{{5, 2}, {1, 0}, succeeded},
+			sent: [][]byte{
+				{5, 1, 2},
+				{1, 5}, []byte("alice"), {6}, []byte("secret"),
+				{5, 1, 0, 3, 11}, []byte("example.com"), {0, 80},
+			},
+		},
+		{
+			name:    "user without password",
+			address: "example.com:80",
+			user:    url.User("alice"),
+			replies: [][]byte{{5, 2}, {1, 0}, succeeded},
+			sent: [][]byte{
+				{5, 1, 2},
+				{1, 5}, []byte("alice"), {0},
+				{5, 1, 0, 3, 11}, []byte("example.com"), {0, 80},
+			},
+		},
+		{
+			name:    "bound ipv6 address",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 0, 0, 4}, make([]byte, 18)},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+		},
+		{
+			name:    "bound domain",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 0, 0, 3, 5}, []byte("proxy"), {0, 80}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+		},
+		{
+			name:    "no port",
+			address: "example.com",
+			err:     "missing port",
+		},
+		{
+			name:    "port out of range",
+			address: "example.com:65536",
+			err:     `invalid port "65536"`,
+		},
+		{
+			name:    "port 0",
+			address: "example.com:0",
+			err:     `invalid port "0"`,
+		},
+		{
+			name:    "named port",
+			address: "example.com:http",
+			err:     `invalid port "http"`,
+		},
+		{
+			name:    "host too long",
+			address: strings.Repeat("a", 256) + ":80",
+			replies: [][]byte{{5, 0}},
+			sent:    [][]byte{{5, 1, 0}},
+			err:     "too long for socks5",
+		},
+		{
+			name:    "credentials too long",
+			address: "example.com:80",
+			user:    url.UserPassword("alice", strings.Repeat("x", 256)),
+			replies: [][]byte{{5, 2}},
+			sent:    [][]byte{{5, 1, 2}},
+			err:     "credentials are too long",
+		},
+		{
+			name:    "not a socks5 server",
+			address: "example.com:80",
+			replies: [][]byte{[]byte("HTTP/1.1 400 Bad Request\r\n")},
+			sent:    [][]byte{{5, 1, 0}},
+			err:     "not a socks5 server",
+		},
+		{
+			name:    "no acceptable method",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0xff}},
+			sent:    [][]byte{{5, 1, 0}},
+			err:     "rejected the authentication method",
+		},
+		{
+			name:    "other method",
+			address: "example.com:80",
+			user:    url.UserPassword("alice", "secret"),
+			replies: [][]byte{{5, 0}},
+			sent:    [][]byte{{5, 1, 2}},
+			err:     "rejected the authentication method",
+		},
+		{
+			name:    "wrong credentials",
+			address: "example.com:80",
+			user:    url.UserPassword("alice", "wrong"),
+			replies: [][]byte{{5, 2}, {1, 1}},
+			sent:    [][]byte{{5, 1, 2}, {1, 5}, []byte("alice"), {5}, []byte("wrong")},
+			err:     "rejected the credentials",
+		},
+		{
+			name:    "connection refused",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 5, 0, 1, 0, 0, 0, 0, 0, 0}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			err:     "couldn't connect to example.com:80: connection refused",
+		},
+		{
+			name:    "unknown reply",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 42, 0, 1, 0, 0, 0, 0, 0, 0}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			err:     "unknown error 42",
+		},
+		{
+			name:    "unknown bound address type",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 0, 0, 2, 0, 0, 0, 0, 0, 0}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			err:     "unknown address type",
+		},
+		{
+			name:    "no reply",
+			address: "example.com:80",
+			sent:    [][]byte{{5, 1, 0}},
+			err:     "EOF",
+		},
+		{
+			name:    "truncated method reply",
+			address: "example.com:80",
+			replies: [][]byte{{5}},
+			sent:    [][]byte{{5, 1, 0}},
+			err:     "unexpected EOF",
+		},
+		{
+			name:    "truncated connect reply",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 0, 0}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			err:     "unexpected EOF",
+		},
+		{
+			name:    "truncated bound address",
+			address: "example.com:80",
+			replies: [][]byte{{5, 0}, {5, 0, 0, 1, 127, 0, 0}},
+			sent:    [][]byte{{5, 1, 0}, {5, 1, 0, 3, 11}, []byte("example.com"), {0, 80}},
+			err:     "unexpected EOF",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &scriptConn{in: bytes.NewReader(bytes.Join(tt.replies, nil))}
+
+			err := socks5Connect(conn, tt.address, tt.user)
+			if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("socks5Connect() error = %v, want %q", err, tt.err)
+			}
+
+			if sent := bytes.Join(tt.sent, nil); !bytes.Equal(conn.out.Bytes(), sent) {
+				t.Fatalf("socks5Connect() sent % x, want % x", conn.out.Bytes(), sent)
+			}
+		})
+	}
+}
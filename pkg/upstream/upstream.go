@@ -0,0 +1,246 @@
+package upstream
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	SchemeHTTP   = "http"
+	SchemeHTTPS  = "https"
+	SchemeSocks5 = "socks5"
+)
+
+type Dialer struct {
+	proxy  *url.URL
+	bypass []bypassRule
+}
+
+type bypassRule struct {
+	all     bool
+	host    string
+	network *net.IPNet
+}
+
+func New(proxyURL string, bypass []string) (*Dialer, error) {
+	d := &Dialer{}
+
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream proxy: %w", err)
+		}
+
+		switch u.Scheme {
+		case SchemeHTTP, SchemeHTTPS, SchemeSocks5:
+		default:
+			return nil, fmt.Errorf("upstream proxy scheme must be http, https or socks5, got %q", u.Scheme)
+		}
+
+		if u.Hostname() == "" {
+			return nil, errors.New("upstream proxy needs a host")
+		}
+
+		d.proxy = u
+	}
+
+	for _, pattern := range bypass {
+		rule, err := parseBypass(pattern)
+		if err != nil {
+			return nil, err
+		}
+		d.bypass = append(d.bypass, rule)
+	}
+
+	return d, nil
+}
+
+func (d *Dialer) Proxy(r *http.Request) (*url.URL, error) {
+	if d.direct(r.URL.Hostname()) {
+		return nil, nil
+	}
+
+	return d.proxy, nil
+}
+
+func (d *Dialer) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if d.proxy != nil {
+		transport.Proxy = d.Proxy
+	}
+
+	return transport
+}
+
+func (d *Dialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return d.DialContext(ctx, network, address)
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	if d.direct(host) {
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", withPort(d.proxy))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't reach upstream proxy: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if d.proxy.Scheme == SchemeHTTPS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: d.proxy.Hostname()})
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("couldn't reach upstream proxy: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	if d.proxy.Scheme == SchemeSocks5 {
+		err = socks5Connect(conn, address, d.proxy.User)
+	} else {
+		conn, err = httpConnect(conn, address, d.proxy.User)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+func (d *Dialer) DialTLS(network, address string, config *tls.Config, timeout time.Duration) (*tls.Conn, error) {
+	conn, err := d.DialTimeout(network, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName, _, _ = net.SplitHostPort(address)
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if err := tlsConn.SetDeadline(time.Time{}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+func (d *Dialer) direct(host string) bool {
+	if d.proxy == nil {
+		return true
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+	for _, rule := range d.bypass {
+		switch {
+		case rule.all:
+			return true
+		case rule.network != nil:
+			if ip != nil && rule.network.Contains(ip) {
+				return true
+			}
+		case strings.HasPrefix(rule.host, "."):
+			if strings.HasSuffix(host, rule.host) {
+				return true
+			}
+		case host == rule.host || strings.HasSuffix(host, "."+rule.host):
+			return true
+		}
+	}
+
+	return false
+}
+
+func parseBypass(pattern string) (bypassRule, error) {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+
+	switch {
+	case pattern == "":
+		return bypassRule{}, errors.New("empty upstream bypass pattern")
+	case pattern == "*":
+		return bypassRule{all: true}, nil
+	case strings.Contains(pattern, "/"):
+		_, network, err := net.ParseCIDR(pattern)
+		if err != nil {
+			return bypassRule{}, fmt.Errorf("invalid upstream bypass %q: %w", pattern, err)
+		}
+		return bypassRule{network: network}, nil
+	}
+
+	if ip := net.ParseIP(strings.Trim(pattern, "[]")); ip != nil {
+		bits := 8 * len(ip.To4())
+		if bits == 0 {
+			bits = 8 * net.IPv6len
+		}
+		return bypassRule{network: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+
+	return bypassRule{host: strings.TrimPrefix(pattern, "*")}, nil
+}
+
+// HostPort returns host with port appended unless it already has one.
+func HostPort(host, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(strings.Trim(host, "[]"), port)
+}
+
+func withPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+
+	port := "80"
+	switch u.Scheme {
+	case SchemeHTTPS:
+		port = "443"
+	case SchemeSocks5:
+		port = "1080"
+	}
+
+	return net.JoinHostPort(u.Hostname(), port)
+}